@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/export"
+	"github.com/rodolfo-mora/huginn/pkg/journal"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// runExport writes policy and drift anomalies from the journal as SARIF or CSV for compliance tooling
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	format := fs.String("format", export.FormatSARIF, "Output format (sarif or csv)")
+	since := fs.Duration("since", 24*time.Hour, "Export anomalies detected within this window")
+	cluster := fs.String("cluster", "", "Only export anomalies of this cluster (ID or name)")
+	output := fs.String("output", "", "Output file (default stdout)")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	// The agent may be appending to the journal; opening it shared leaves it uncompacted
+	j, err := journal.OpenShared(cfg.Notification.Journal.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open anomaly journal: %v", err)
+	}
+
+	records, err := j.Since(time.Now().Add(-*since))
+	if err != nil {
+		return err
+	}
+
+	anomalies := make([]types.Anomaly, 0, len(records))
+	for _, record := range records {
+		anomalies = append(anomalies, record.Anomaly)
+	}
+	anomalies = export.Filter(anomalies, *cluster)
+
+	w := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := export.Write(w, *format, anomalies); err != nil {
+		return err
+	}
+	if *output != "" {
+		log.Printf("Exported %d anomalies to %s", len(anomalies), *output)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -10,16 +11,44 @@ import (
 
 	"github.com/rodolfo-mora/huginn/pkg/agent"
 	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/version"
 )
 
+// commands are subcommands run instead of the agent, e.g. "huginn replay -since 2h"
+var commands = map[string]func(args []string) error{
+	"replay":   runReplay,
+	"export":   runExport,
+	"simulate": runSimulate,
+	"rules":    runRules,
+	"report":   runReport,
+	"storage":  runStorage,
+}
+
 func main() {
+	// Run a subcommand if one was given
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatalf("%s: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
+
 	// Parse command line flags
 	configPath := flag.String("config", "config.yaml", "Path to configuration file")
 	printAnomalies := flag.Bool("print-anomalies", false, "Print detected anomalies")
 	printState := flag.Bool("print-state", false, "Print cluster state")
 	printConfig := flag.Bool("print-config", false, "Print configuration")
+	printVersion := flag.Bool("version", false, "Print version information")
 	flag.Parse()
 
+	info := version.Get()
+	if *printVersion {
+		fmt.Printf("huginn %s (commit: %s, built: %s, %s)\n", info.Version, info.Commit, info.BuildDate, info.GoVersion)
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.LoadConfig(*configPath)
 	if err != nil {
@@ -47,9 +76,9 @@ func main() {
 	ticker := time.NewTicker(time.Duration(cfg.ObservationInterval) * time.Second)
 	defer ticker.Stop()
 
-	go multiAgent.StartMetricsServer() // Starts on :8080
+	go multiAgent.StartMetricsServer() // Starts on metricsServer.address (default :8080)
 
-	log.Printf("Multi-cluster agent started with %d clusters", len(cfg.Clusters))
+	log.Printf("Multi-cluster agent %s (%s) started with %d clusters", info.Version, info.Commit, len(cfg.Clusters))
 
 	for {
 		select {
@@ -3,8 +3,12 @@ package agent
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -13,12 +17,20 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/rodolfo-mora/huginn/pkg/alertid"
 	"github.com/rodolfo-mora/huginn/pkg/anomaly"
+	"github.com/rodolfo-mora/huginn/pkg/awsauth"
+	"github.com/rodolfo-mora/huginn/pkg/catchup"
 	"github.com/rodolfo-mora/huginn/pkg/config"
 	"github.com/rodolfo-mora/huginn/pkg/embedding"
+	"github.com/rodolfo-mora/huginn/pkg/features"
+	"github.com/rodolfo-mora/huginn/pkg/hooks"
+	"github.com/rodolfo-mora/huginn/pkg/journal"
 	"github.com/rodolfo-mora/huginn/pkg/metrics"
 	"github.com/rodolfo-mora/huginn/pkg/notification"
 	"github.com/rodolfo-mora/huginn/pkg/storage"
+	"github.com/rodolfo-mora/huginn/pkg/stream"
+	"github.com/rodolfo-mora/huginn/pkg/timeseries"
 	"github.com/rodolfo-mora/huginn/pkg/types"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -52,9 +64,9 @@ func (s safeAnomalyData) SafeClusterName() string {
 
 func (s safeAnomalyData) SafeSeverity() string {
 	if s.Severity == "" {
-		return "Medium"
+		return string(types.SeverityMedium)
 	}
-	return s.Severity
+	return string(s.Severity)
 }
 
 func (s safeAnomalyData) SafeType() string {
@@ -148,6 +160,546 @@ func formatAnomaly(anomaly types.Anomaly, tplt string) (string, error) {
 	return result, nil
 }
 
+// newDetector creates an anomaly detector configured from the anomaly detection settings
+func newDetector(cfg *config.Config) *anomaly.Detector {
+	detector := anomaly.NewDetector(
+		cfg.AnomalyDetection.CPUThreshold,
+		cfg.AnomalyDetection.MemoryThreshold,
+		cfg.AnomalyDetection.PodRestartThreshold,
+		cfg.AnomalyDetection.MaxHistorySize,
+		cfg.AnomalyDetection.CPUAlpha,
+		cfg.AnomalyDetection.MemoryAlpha,
+		cfg.AnomalyDetection.RestartAlpha,
+		false, // debug mode - set to true to enable detailed logging
+		cfg.AnomalyDetection.MinStdDev,
+	)
+	imagePolicy := cfg.AnomalyDetection.ImagePolicy
+	// A cluster-specific registry allowlist replaces the global one
+	if len(cfg.Clusters) == 1 && len(cfg.Clusters[0].AllowedRegistries) > 0 {
+		imagePolicy.AllowedRegistries = cfg.Clusters[0].AllowedRegistries
+	}
+	detector.SetImagePolicy(imagePolicy)
+	detector.SetSecurityPolicy(cfg.AnomalyDetection.SecurityPolicy)
+	detector.SetStatistics(cfg.AnomalyDetection.Statistics)
+	detector.SetMinHistory(cfg.AnomalyDetection.MinHistory)
+	detector.SetSeriesHistorySize(cfg.AnomalyDetection.SeriesHistorySize)
+	detector.SetDisabledDetectors(cfg.AnomalyDetection.DisabledDetectors)
+	detector.SetDrift(cfg.AnomalyDetection.Drift)
+	detector.SetConfigChurn(cfg.AnomalyDetection.ConfigChurn)
+	detector.SetMultivariate(cfg.AnomalyDetection.Multivariate)
+	detector.SetSlope(cfg.AnomalyDetection.Slope)
+	detector.SetForecast(cfg.AnomalyDetection.Forecast)
+	detector.SetTrendHistory(cfg.AnomalyDetection.TrendHistory)
+	detector.SetSeverity(cfg.AnomalyDetection.Severity)
+	detector.SetDisk(cfg.AnomalyDetection.DiskThreshold, cfg.AnomalyDetection.DiskAlpha, time.Duration(cfg.AnomalyDetection.DiskPressureMinutes)*time.Minute)
+	detector.SetRestartWindow(time.Duration(cfg.AnomalyDetection.RestartWindowMinutes) * time.Minute)
+	detector.SetPVC(cfg.AnomalyDetection.PVC)
+	detector.SetPV(cfg.AnomalyDetection.PV)
+	detector.SetThresholdOverrides(cfg.AnomalyDetection.ThresholdOverrides)
+	detector.SetRules(cfg.AnomalyDetection.Rules)
+	detector.SetComposites(cfg.AnomalyDetection.Composites)
+	detector.SetEventReasons(cfg.AnomalyDetection.EventReasons)
+	detector.SetFeedback(cfg.AnomalyDetection.Feedback)
+	detector.SetHysteresis(cfg.AnomalyDetection.Hysteresis)
+	detector.SetWarmUp(cfg.AnomalyDetection.WarmUp)
+	detector.SetHealthScore(cfg.AnomalyDetection.HealthScore)
+	return detector
+}
+
+// newStorageConfig returns the settings of a storage backend from the configuration
+func newStorageConfig(cfg *config.Config, storageType string) storage.StorageConfig {
+	url := cfg.Storage.Qdrant.URL
+	if storage.StorageType(storageType) == storage.StorageTypeRedis && cfg.Storage.Redis.URL != "" {
+		url = cfg.Storage.Redis.URL
+	}
+	return storage.StorageConfig{
+		Type:       storage.StorageType(storageType),
+		URL:        url,
+		Password:   cfg.Storage.Redis.Password,
+		DB:         cfg.Storage.Redis.DB,
+		Collection: cfg.Storage.Qdrant.Collection,
+		VectorSize: cfg.Storage.Qdrant.VectorSize,
+		Distance:   cfg.Storage.Qdrant.DistanceMetric,
+		Protocol:   cfg.Storage.Qdrant.Protocol,
+		GRPCURL:    cfg.Storage.Qdrant.GRPCURL,
+		Qdrant: storage.QdrantOptions{
+			APIKey:             cfg.Storage.Qdrant.APIKey,
+			CAFile:             cfg.Storage.Qdrant.TLS.CAFile,
+			InsecureSkipVerify: cfg.Storage.Qdrant.TLS.InsecureSkipVerify,
+			Timeout:            time.Duration(cfg.Storage.Qdrant.TimeoutSeconds) * time.Second,
+		},
+		Path:      cfg.Storage.Local.Path,
+		Retention: time.Duration(cfg.Storage.Local.RetentionHours) * time.Hour,
+		Milvus: storage.MilvusOptions{
+			URL:        cfg.Storage.Milvus.URL,
+			Token:      cfg.Storage.Milvus.Token,
+			Database:   cfg.Storage.Milvus.Database,
+			Collection: cfg.Storage.Milvus.Collection,
+			VectorSize: cfg.Storage.Milvus.VectorSize,
+			Metric:     cfg.Storage.Milvus.MetricType,
+		},
+		Weaviate: storage.WeaviateOptions{
+			URL:         cfg.Storage.Weaviate.URL,
+			APIKey:      cfg.Storage.Weaviate.APIKey,
+			Class:       cfg.Storage.Weaviate.Class,
+			Distance:    cfg.Storage.Weaviate.DistanceMetric,
+			HybridAlpha: cfg.Storage.Weaviate.HybridAlpha,
+		},
+		ClickHouse: storage.ClickHouseOptions{
+			URL:               cfg.Storage.ClickHouse.URL,
+			User:              cfg.Storage.ClickHouse.User,
+			Password:          cfg.Storage.ClickHouse.Password,
+			Database:          cfg.Storage.ClickHouse.Database,
+			Table:             cfg.Storage.ClickHouse.Table,
+			ObservationsTable: cfg.Storage.ClickHouse.ObservationsTable,
+			Distance:          cfg.Storage.ClickHouse.DistanceMetric,
+		},
+	}
+}
+
+// OpenStorage connects to the configured storage backend, for commands reading stored alerts.
+// Alert payloads are decrypted if encryption is enabled.
+func OpenStorage(cfg *config.Config) (storage.Storage, error) {
+	client, err := storage.NewStorage(newStorageConfig(cfg, cfg.Storage.Type))
+	if err != nil {
+		return nil, err
+	}
+	return encryptStorage(cfg, client)
+}
+
+// encryptStorage wraps the storage with encryption of alert payloads if it is enabled
+func encryptStorage(cfg *config.Config, client storage.Storage) (storage.Storage, error) {
+	encryption := cfg.Storage.Encryption
+	if !encryption.Enabled {
+		return client, nil
+	}
+
+	var encoded string
+	if encryption.KeyFile != "" {
+		data, err := os.ReadFile(encryption.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key: %v", err)
+		}
+		encoded = string(data)
+	} else if encoded = os.Getenv("HUGINN_ENCRYPTION_KEY"); encoded == "" {
+		return nil, fmt.Errorf("storage encryption is enabled but neither keyFile nor HUGINN_ENCRYPTION_KEY is set")
+	}
+	decode := decodeEncryptionKey
+	if kms := encryption.KMS; kms.Enabled {
+		provider, err := newKMSKeyProvider(kms)
+		if err != nil {
+			return nil, err
+		}
+		decode = func(encoded string) ([]byte, error) {
+			ciphertext, err := decodeEncryptionKey(encoded)
+			if err != nil {
+				return nil, err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			return provider.DecryptKey(ctx, ciphertext)
+		}
+	}
+	key, err := decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous [][]byte
+	for _, path := range encryption.PreviousKeyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read previous encryption key: %v", err)
+		}
+		k, err := decode(string(data))
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, k)
+	}
+	for _, encoded := range strings.Split(os.Getenv("HUGINN_ENCRYPTION_PREVIOUS_KEYS"), ",") {
+		if strings.TrimSpace(encoded) == "" {
+			continue
+		}
+		k, err := decode(encoded)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, k)
+	}
+	return storage.NewEncryptedStorage(client, key, previous)
+}
+
+// newKMSKeyProvider creates the client of AWS KMS decrypting encryption keys, with credentials
+// defaulting to the AWS environment variables
+func newKMSKeyProvider(kms config.EncryptionKMSConfig) (*storage.KMSKeyProvider, error) {
+	credentials := awsauth.Credentials{
+		AccessKeyID:     kms.AccessKeyID,
+		SecretAccessKey: kms.SecretAccessKey,
+		SessionToken:    kms.SessionToken,
+	}.WithEnvironment()
+	return storage.NewKMSKeyProvider(kms.Endpoint, kms.Region, credentials)
+}
+
+// decodeEncryptionKey decodes a base64-encoded encryption key
+func decodeEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: expected base64: %v", err)
+	}
+	return key, nil
+}
+
+// newStorage creates the storage client, health checked, replicated, deduplicated and
+// batched if configured.
+// Unless the storage degradation mode is "fail", an unreachable backend does not prevent startup;
+// the client connects on first successful use. The circuit breaker is nil unless health checks
+// are enabled.
+func newStorage(cfg *config.Config, storageConfig storage.StorageConfig) (storage.Storage, *storage.CircuitBreaker, error) {
+	storageConfig = verifyVectorSize(cfg, storageConfig)
+	client, err := storage.NewStorage(storageConfig)
+	if err != nil {
+		// Retrying cannot fix a collection of the wrong vector size
+		if errors.Is(err, storage.ErrVectorSizeMismatch) || cfg.Degradation.Storage.Mode == config.DegradeFail {
+			return nil, nil, fmt.Errorf("failed to create storage client: %v", err)
+		}
+
+		log.Printf("Warning: storage is unavailable, connecting on first use: %v", err)
+		client, err = storage.NewDeferredStorage(storageConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create storage client: %v", err)
+		}
+	}
+
+	var breaker *storage.CircuitBreaker
+	if check := cfg.Storage.HealthCheck; check.Enabled {
+		breaker = storage.NewCircuitBreaker(client, storageConfig, time.Duration(check.IntervalSeconds)*time.Second, check.FailureThreshold)
+		client = breaker
+	}
+	if replicas := cfg.Storage.Replicas; len(replicas.Types) > 0 {
+		configs := make([]storage.StorageConfig, len(replicas.Types))
+		for i, replicaType := range replicas.Types {
+			configs[i] = verifyVectorSize(cfg, newStorageConfig(cfg, replicaType))
+		}
+		replicated, err := storage.NewReplicatedStorage(client, configs, replicas.QueueSize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create storage replicas: %v", err)
+		}
+		client = replicated
+	}
+	if client, err = encryptStorage(cfg, client); err != nil {
+		return nil, nil, err
+	}
+
+	if dedup := cfg.Storage.Dedup; dedup.Enabled {
+		client = storage.NewDedupedStorage(client, time.Duration(dedup.WindowMinutes)*time.Minute)
+	}
+	batch := cfg.Storage.Batch
+	if writeBehind := cfg.Storage.WriteBehind; writeBehind.Enabled {
+		queued, err := storage.NewWriteBehindStorage(client, writeBehind.QueueSize, batch.Size, writeBehind.Path,
+			time.Duration(writeBehind.MaxBackoffSeconds)*time.Second)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create storage write-behind queue: %v", err)
+		}
+		client = queued
+	} else if batch.Enabled {
+		client = storage.NewBatchedStorage(client, batch.Size, time.Duration(batch.IntervalSeconds)*time.Second)
+	}
+	return client, breaker, nil
+}
+
+// newSampler creates the policy of which anomalies are stored if sampling is enabled
+func newSampler(cfg *config.Config) *storage.Sampler {
+	sampling := cfg.Storage.Sampling
+	if !sampling.Enabled {
+		return nil
+	}
+	return storage.NewSampler(sampling.Rates, time.Duration(sampling.FirstOccurrenceHours)*time.Hour)
+}
+
+// verifyVectorSize makes a Qdrant collection hold vectors of the stored dimension, failing if an
+// existing collection does not unless it is to be recreated
+func verifyVectorSize(cfg *config.Config, storageConfig storage.StorageConfig) storage.StorageConfig {
+	if storageConfig.Type != storage.StorageTypeQdrant {
+		return storageConfig
+	}
+	if size := storedDimension(cfg); storageConfig.VectorSize != size {
+		log.Printf("Warning: storage.qdrant.vectorSize %d does not match the embedding dimension %d; using %d",
+			storageConfig.VectorSize, size, size)
+		storageConfig.VectorSize = size
+	}
+	storageConfig.Qdrant.VerifyVectorSize = true
+	storageConfig.Qdrant.RecreateOnMismatch = cfg.Storage.Qdrant.RecreateOnDimensionMismatch
+	return storageConfig
+}
+
+// newEmbeddingModel creates the configured embedding model. Unless the embedding degradation mode
+// is "fail", a model service that is not ready does not prevent startup.
+// If alerts are stored, the model is probed for the dimension of its vectors, which replaces the
+// configured embedding dimension. The resize and normalize wrappers apply whether or not the model
+// is ready, so a collection never mixes in vectors that skipped them.
+func newEmbeddingModel(cfg *config.Config) (embedding.Model, error) {
+	model, err := buildEmbeddingModel(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Storage.StoreAlerts {
+		if err := embedding.Ping(model); err != nil {
+			if cfg.Degradation.Embedding.Mode == config.DegradeFail {
+				return nil, fmt.Errorf("embedding model is unavailable: %v", err)
+			}
+			// Only the dimension probe is skipped; the embeddings are still resized and normalized
+			log.Printf("Warning: embedding model is unavailable, embeddings will fail until it is ready: %v", err)
+		} else if model, err = detectDimension(cfg, model); err != nil {
+			return nil, err
+		}
+	}
+
+	if size := storedDimension(cfg); size != cfg.Embedding.Dimension {
+		log.Printf("Resizing %d-dimensional embeddings to the collection's vector size %d", cfg.Embedding.Dimension, size)
+		model = embedding.NewResizingModel(model, size)
+	}
+	if cfg.Embedding.Normalize {
+		model = embedding.NewNormalizingModel(model)
+	}
+	return model, nil
+}
+
+// embeddingModel identifies the configured embedding model, recorded with stored alerts so that
+// searches can be restricted to the vectors it produced
+func embeddingModel(cfg *config.Config) types.EmbeddingModel {
+	var name string
+	switch cfg.Embedding.Type {
+	case "openai":
+		name = cfg.Embedding.OpenAI.Model
+	case "sentence-transformers":
+		name = cfg.Embedding.SentenceTransformers.Model
+	case "ollama":
+		name = cfg.Embedding.Ollama.Model
+	case "azure-openai":
+		name = cfg.Embedding.AzureOpenAI.Deployment
+	case "cohere":
+		name = cfg.Embedding.Cohere.Model
+	case "huggingface":
+		// Dedicated endpoints serve a single model, identified by their URL
+		name = cfg.Embedding.HuggingFace.Model
+		if name == "" {
+			name = cfg.Embedding.HuggingFace.URL
+		}
+	case "vertex-ai":
+		name = cfg.Embedding.VertexAI.Model
+	}
+	return types.EmbeddingModel{Provider: cfg.Embedding.Type, Model: name, Dimension: storedDimension(cfg)}
+}
+
+// storedDimension returns the dimension of the vectors to store: the embedding dimension, or the
+// vector size of the collection if embeddings are resized to it
+func storedDimension(cfg *config.Config) int {
+	if !cfg.Embedding.Resize.Enabled {
+		return cfg.Embedding.Dimension
+	}
+	if storage.StorageType(cfg.Storage.Type) == storage.StorageTypeMilvus {
+		return cfg.Storage.Milvus.VectorSize
+	}
+	return cfg.Storage.Qdrant.VectorSize
+}
+
+// detectDimension probes the model for the dimension of its vectors. If it is not the configured
+// dimension, the dimension is corrected in cfg and the model recreated with it. A model that
+// cannot be probed is assumed to have the configured dimension unless the embedding degradation
+// mode is "fail".
+func detectDimension(cfg *config.Config, model embedding.Model) (embedding.Model, error) {
+	dimension, err := embedding.ProbeDimension(model)
+	if err != nil {
+		if cfg.Degradation.Embedding.Mode == config.DegradeFail {
+			return nil, fmt.Errorf("failed to detect the embedding dimension: %v", err)
+		}
+		log.Printf("Warning: failed to detect the embedding dimension, assuming %d: %v", cfg.Embedding.Dimension, err)
+		return model, nil
+	}
+	if dimension == cfg.Embedding.Dimension {
+		return model, nil
+	}
+
+	log.Printf("Warning: the %s embedding model produces %d-dimensional vectors, not the configured %d; using %d",
+		cfg.Embedding.Type, dimension, cfg.Embedding.Dimension, dimension)
+	cfg.Embedding.Dimension = dimension
+	return buildEmbeddingModel(cfg)
+}
+
+// buildEmbeddingModel creates the configured embedding model, retrying failed embeddings
+func buildEmbeddingModel(cfg *config.Config) (embedding.Model, error) {
+	var model embedding.Model
+	switch cfg.Embedding.Type {
+	case "simple":
+		model = embedding.NewSimpleModel(cfg.Embedding.Dimension)
+	case "openai":
+		model = embedding.NewOpenAIModel(cfg.Embedding.OpenAI.APIKey, cfg.Embedding.OpenAI.Model, cfg.Embedding.Dimension)
+	case "sentence-transformers":
+		st := cfg.Embedding.SentenceTransformers
+		model = embedding.NewSentenceTransformersModel(st.URL, st.Model, cfg.Embedding.Dimension, st.BatchSize)
+	case "ollama":
+		ollama := cfg.Embedding.Ollama
+		model = embedding.NewOllamaModel(ollama.URL, ollama.Model, cfg.Embedding.Dimension, ollama.KeepAlive, ollama.Pull)
+	case "azure-openai":
+		azure := cfg.Embedding.AzureOpenAI
+		if azure.APIKey == "" {
+			azure.APIKey = os.Getenv("AZURE_OPENAI_API_KEY")
+		}
+		var err error
+		model, err = embedding.NewAzureOpenAIModel(azure.Endpoint, azure.Deployment, azure.APIVersion, azure.APIKey,
+			cfg.Embedding.Dimension, azure.BatchSize)
+		if err != nil {
+			return nil, err
+		}
+	case "cohere":
+		cohere := cfg.Embedding.Cohere
+		if cohere.APIKey == "" {
+			cohere.APIKey = os.Getenv("COHERE_API_KEY")
+		}
+		var err error
+		model, err = embedding.NewCohereModel(cohere.URL, cohere.APIKey, cohere.Model, cohere.InputType, cohere.QueryInputType,
+			cfg.Embedding.Dimension, cohere.BatchSize)
+		if err != nil {
+			return nil, err
+		}
+	case "huggingface":
+		hf := cfg.Embedding.HuggingFace
+		if hf.Token == "" {
+			hf.Token = os.Getenv("HF_TOKEN")
+		}
+		model = embedding.NewHuggingFaceModel(hf.URL, hf.Model, hf.Token, cfg.Embedding.Dimension, hf.BatchSize)
+	case "vertex-ai":
+		vertex := cfg.Embedding.VertexAI
+		if vertex.Project == "" {
+			vertex.Project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+		}
+		var err error
+		model, err = embedding.NewVertexAIModel(vertex.Project, vertex.Location, vertex.Model, vertex.CredentialsFile,
+			cfg.Embedding.Dimension, vertex.BatchSize)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported embedding type: %s", cfg.Embedding.Type)
+	}
+	retry := cfg.Embedding.Retry
+	model = embedding.NewRetryingModel(model, retry.MaxAttempts, time.Duration(retry.InitialBackoffMs)*time.Millisecond,
+		time.Duration(retry.MaxBackoffMs)*time.Millisecond, time.Duration(retry.TimeoutSeconds)*time.Second)
+	return model, nil
+}
+
+// handleStorageHealth reports the state of the storage circuit breaker, if any, in the logs and
+// metrics
+func handleStorageHealth(breaker *storage.CircuitBreaker, exporter *metrics.PrometheusExporter) {
+	if breaker == nil {
+		return
+	}
+	breaker.SetStateHandler(func(healthy bool, err error) {
+		if healthy {
+			log.Printf("Storage is reachable again, resuming anomaly storage")
+		} else {
+			log.Printf("Warning: storage is unavailable, pausing anomaly storage: %v", err)
+		}
+		if exporter != nil {
+			exporter.SetStorageHealthy(healthy)
+		}
+	})
+}
+
+// handleStorageFlushes observes the writes of a batched or write-behind storage. Alerts of failed
+// batch writes are queued according to the storage degradation mode; the write-behind queue
+// retries them itself.
+func handleStorageFlushes(client storage.Storage, exporter *metrics.PrometheusExporter, degradation *degradation) {
+	observe := func(alerts []storage.StoreRequest, duration time.Duration) {
+		if exporter == nil {
+			return
+		}
+		observed := make(map[string]bool)
+		for _, alert := range alerts {
+			if !observed[alert.Anomaly.ClusterID] {
+				observed[alert.Anomaly.ClusterID] = true
+				exporter.ObserveStorageWrite(alert.Anomaly.ClusterID, alert.Anomaly.ClusterName, duration)
+			}
+		}
+	}
+
+	switch client := client.(type) {
+	case *storage.BatchedStorage:
+		client.SetFlushHandler(func(alerts []storage.StoreRequest, duration time.Duration, err error) {
+			observe(alerts, duration)
+			if err != nil {
+				log.Printf("Failed to store %d anomalies in vector database: %v", len(alerts), err)
+				degradation.storage.Add(func() error {
+					return client.StoreAlerts(alerts)
+				})
+			}
+		})
+	case *storage.WriteBehindStorage:
+		client.SetFlushHandler(func(alerts []storage.StoreRequest, duration time.Duration, err error) {
+			observe(alerts, duration)
+			if err != nil {
+				log.Printf("Failed to store %d anomalies in vector database, %d queued for retry: %v", len(alerts), client.Len(), err)
+			}
+		})
+	}
+}
+
+// openJournal opens the anomaly journal if it is enabled
+func openJournal(cfg *config.Config) (*journal.Journal, error) {
+	if !cfg.Notification.Journal.Enabled {
+		return nil, nil
+	}
+	retention := time.Duration(cfg.Notification.Journal.RetentionHours) * time.Hour
+	j, err := journal.Open(cfg.Notification.Journal.Path, retention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open anomaly journal: %v", err)
+	}
+	return j, nil
+}
+
+// openCatchUp opens the cycle checkpoints and creates the Prometheus client used to analyze
+// downtime if catch-up is enabled
+func openCatchUp(cfg *config.Config) (*catchup.Checkpoints, *catchup.PrometheusClient, error) {
+	if !cfg.CatchUp.Enabled {
+		return nil, nil, nil
+	}
+	checkpoints, err := catchup.OpenCheckpoints(cfg.CatchUp.CheckpointPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open checkpoints: %v", err)
+	}
+	var prometheus *catchup.PrometheusClient
+	if cfg.CatchUp.Prometheus.URL != "" {
+		prometheus = catchup.NewPrometheusClient(cfg.CatchUp.Prometheus)
+	}
+	return checkpoints, prometheus, nil
+}
+
+// newVolumeTracker creates the notification volume tracker if forecasting is enabled, seeded
+// with the notifications recorded in the journal
+func newVolumeTracker(cfg *config.Config, j *journal.Journal) *notification.VolumeTracker {
+	if !cfg.Notification.Volume.Enabled {
+		return nil
+	}
+	volume := notification.NewVolumeTracker(cfg.Notification.Volume.WindowHours)
+	if j == nil {
+		return volume
+	}
+
+	records, err := j.Since(time.Now().Add(-time.Duration(cfg.Notification.Volume.WindowHours) * time.Hour))
+	if err != nil {
+		log.Printf("Warning: failed to read notification history from journal: %v", err)
+		return volume
+	}
+	for _, record := range records {
+		if record.Notified {
+			volume.Record(cfg.Notification.Type, record.NotifiedAt)
+		}
+	}
+	return volume
+}
+
 // Agent represents the main agent that observes and learns from the cluster
 type Agent struct {
 	k8sClient     *kubernetes.Clientset
@@ -156,13 +708,32 @@ type Agent struct {
 	state         types.ClusterState
 	observations  []types.Observation
 	detector      *anomaly.Detector
+	cancel        context.CancelFunc // Stops background work such as feature flag refresh
 	notifier      notification.Notifier
 	storage       storage.Storage
+	breaker       *storage.CircuitBreaker // Optional health checker of the storage backend
+	publisher     *stream.NATSPublisher   // Optional publisher of anomalies to NATS
+	timeSeries    *timeseries.Client      // Optional export of the detector's observations
 	model         embedding.Model
 	metrics       *metrics.PrometheusExporter
 	metricsServer *metrics.MetricsServer
-	clusterID     string // Cluster ID for multi-cluster mode
-	clusterName   string // Cluster name for multi-cluster mode
+	ids           *alertid.Generator
+	journal       *journal.Journal // Optional record of detected anomalies for notification replay
+	checkpoints   *catchup.Checkpoints
+	prometheus    *catchup.PrometheusClient                 // Optional source of node usage history for catch-up
+	caughtUp      bool                                      // Whether the downtime before startup has been analyzed
+	volume        *notification.VolumeTracker               // Optional count of sent notifications for forecasting
+	capabilities  ClusterCapabilities                       // Result of the startup capability probe
+	cycle         int64                                     // Completed observation cycles
+	learnedCycles int                                       // Observations since the detector was last tuned
+	tuningBase    map[string]float64                        // Configured thresholds tuned ones stay close to
+	degradation   *degradation                              // Retry queues of work failed by unavailable integrations
+	workers       *workerPool                               // Optional background embedding and storage of anomalies
+	sampler       *storage.Sampler                          // Optional policy of which anomalies are stored
+	hooks         *hooks.Pipeline                           // Optional hooks mutating, enriching or vetoing anomalies
+	source        func(clusterID string) types.ClusterState // Replaces collection from the API server in simulation mode
+	clusterID     string                                    // Cluster ID for multi-cluster mode
+	clusterName   string                                    // Cluster name for multi-cluster mode
 }
 
 // NewAgent creates a new agent instance
@@ -187,98 +758,92 @@ func NewAgent(cfg *config.Config) (*Agent, error) {
 	}
 
 	// Create detector
-	detector := anomaly.NewDetector(
-		cfg.AnomalyDetection.CPUThreshold,
-		cfg.AnomalyDetection.MemoryThreshold,
-		cfg.AnomalyDetection.PodRestartThreshold,
-		cfg.AnomalyDetection.MaxHistorySize,
-		cfg.AnomalyDetection.CPUAlpha,
-		cfg.AnomalyDetection.MemoryAlpha,
-		cfg.AnomalyDetection.RestartAlpha,
-		false, // debug mode - set to true to enable detailed logging
-		cfg.AnomalyDetection.MinStdDev,
-	)
+	detector := newDetector(cfg)
+	flags := features.NewFlags(cfg.FeatureFlags)
+	detector.SetFeatureFlags(flags)
 
 	// Create Prometheus metrics exporter
 	metricsExporter := metrics.NewPrometheusExporter(detector, cfg)
 
 	// Create metrics server
-	metricsServer := metrics.NewMetricsServer(":8080", metricsExporter)
+	metricsServer := metrics.NewMetricsServer(cfg.MetricsServer.Address, metricsExporter)
+	metricsServer.SetTLS(cfg.MetricsServer.TLS)
+	metricsServer.SetAuth(cfg.MetricsServer.Auth)
+	metricsServer.SetDebug(cfg.MetricsServer.Debug)
+
+	// Create embedding model first, the storage expects vectors of its dimension
+	model, err := newEmbeddingModel(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	var storageClient storage.Storage
+	var storageBreaker *storage.CircuitBreaker
 	if cfg.Storage.StoreAlerts {
 		// Create storage client
-		storageConfig := storage.StorageConfig{
-			Type:       storage.StorageType(cfg.Storage.Type),
-			URL:        cfg.Storage.Qdrant.URL,
-			Password:   cfg.Storage.Redis.Password,
-			DB:         cfg.Storage.Redis.DB,
-			Collection: cfg.Storage.Qdrant.Collection,
-			VectorSize: cfg.Storage.Qdrant.VectorSize,
-			Distance:   cfg.Storage.Qdrant.DistanceMetric,
-		}
+		storageConfig := newStorageConfig(cfg, cfg.Storage.Type)
 
-		storageClient, err = storage.NewStorage(storageConfig)
+		storageClient, storageBreaker, err = newStorage(cfg, storageConfig)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create storage client: %v", err)
+			return nil, err
 		}
 	}
 
-	// Create embedding model
-	var model embedding.Model
-	switch cfg.Embedding.Type {
-	case "simple":
-		model = embedding.NewSimpleModel(cfg.Embedding.Dimension)
-	case "openai":
-		model = embedding.NewOpenAIModel(cfg.Embedding.OpenAI.APIKey, cfg.Embedding.OpenAI.Model, cfg.Embedding.Dimension)
-	case "sentence-transformers":
-		model = embedding.NewSentenceTransformersModel(cfg.Embedding.SentenceTransformers.Model, cfg.Embedding.SentenceTransformers.Device, cfg.Embedding.Dimension)
-	case "ollama":
-		model = embedding.NewOllamaModel(cfg.Embedding.Ollama.URL, cfg.Embedding.Ollama.Model, cfg.Embedding.Dimension)
-	default:
-		return nil, fmt.Errorf("unsupported embedding type: %s", cfg.Embedding.Type)
+	// Create notifier
+	notifier, err := notification.NewNotifier(cfg.Notification)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create notifier
-	var notifier notification.Notifier
-	switch cfg.Notification.Type {
-	case "slack":
-		notifier = &notification.SlackNotifier{WebhookURL: cfg.Notification.Slack.WebhookURL}
-	case "email":
-		notifier = &notification.EmailNotifier{
-			SMTPHost:     cfg.Notification.Email.SMTPHost,
-			SMTPPort:     cfg.Notification.Email.SMTPPort,
-			SMTPUser:     cfg.Notification.Email.SMTPUser,
-			SMTPPassword: cfg.Notification.Email.SMTPPassword,
-			From:         cfg.Notification.Email.From,
-			To:           cfg.Notification.Email.To,
-		}
-	case "webhook":
-		notifier = &notification.WebhookNotifier{
-			URL:     cfg.Notification.Webhook.URL,
-			Headers: cfg.Notification.Webhook.Headers,
-		}
-	case "alertmanager":
-		notifier = &notification.AlertmanagerNotifier{
-			URL:           cfg.Notification.Alertmanager.URL,
-			DefaultLabels: cfg.Notification.Alertmanager.DefaultLabels,
-		}
-	default:
-		return nil, fmt.Errorf("unsupported notification type: %s", cfg.Notification.Type)
+	ids, err := alertid.NewGenerator(cfg.Storage.AlertID, cfg.Notification)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Agent{
+	pipeline, err := hooks.New(cfg.Hooks)
+	if err != nil {
+		return nil, err
+	}
+
+	anomalyJournal, err := openJournal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints, prometheus, err := openCatchUp(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refresh feature flags from the flag service, if one is configured, until the agent stops
+	ctx, cancel := context.WithCancel(context.Background())
+	flags.Start(ctx)
+
+	agent := &Agent{
 		k8sClient:     clientset,
 		restConfig:    config,
 		detector:      detector,
+		cancel:        cancel,
 		notifier:      notifier,
+		ids:           ids,
+		degradation:   newDegradation(cfg.Degradation),
+		workers:       newWorkerPool(cfg.Embedding.Workers),
+		sampler:       newSampler(cfg),
+		hooks:         pipeline,
+		journal:       anomalyJournal,
+		checkpoints:   checkpoints,
+		prometheus:    prometheus,
 		storage:       storageClient,
+		breaker:       storageBreaker,
 		model:         model,
 		config:        cfg,
 		observations:  make([]types.Observation, 0),
 		metrics:       metricsExporter,
 		metricsServer: metricsServer,
-	}, nil
+	}
+	handleStorageFlushes(storageClient, metricsExporter, agent.degradation)
+	handleStorageHealth(storageBreaker, metricsExporter)
+	return agent, nil
 }
 
 // NewAgentWithoutMetrics creates a new agent instance without metrics, storage, notifier, and model
@@ -304,17 +869,7 @@ func NewAgentWithoutMetrics(cfg *config.Config) (*Agent, error) {
 	}
 
 	// Create detector
-	detector := anomaly.NewDetector(
-		cfg.AnomalyDetection.CPUThreshold,
-		cfg.AnomalyDetection.MemoryThreshold,
-		cfg.AnomalyDetection.PodRestartThreshold,
-		cfg.AnomalyDetection.MaxHistorySize,
-		cfg.AnomalyDetection.CPUAlpha,
-		cfg.AnomalyDetection.MemoryAlpha,
-		cfg.AnomalyDetection.RestartAlpha,
-		false, // debug mode - set to true to enable detailed logging
-		cfg.AnomalyDetection.MinStdDev,
-	)
+	detector := newDetector(cfg)
 
 	return &Agent{
 		k8sClient:    clientset,
@@ -326,6 +881,13 @@ func NewAgentWithoutMetrics(cfg *config.Config) (*Agent, error) {
 	}, nil
 }
 
+// Stop stops the agent's background work, such as refreshing feature flags
+func (a *Agent) Stop() {
+	if a.cancel != nil {
+		a.cancel()
+	}
+}
+
 // SetClusterInfo sets the cluster information for multi-cluster mode
 func (a *Agent) SetClusterInfo(clusterID, clusterName string) {
 	a.clusterID = clusterID
@@ -339,6 +901,16 @@ func (a *Agent) ObserveCluster() error {
 
 // ObserveClusterWithContext collects the current state of the cluster with context cancellation support
 func (a *Agent) ObserveClusterWithContext(ctx context.Context) error {
+	cycleStart := time.Now()
+
+	if a.source != nil {
+		a.state = a.source(a.clusterID)
+		a.state.Cycle = a.cycle + 1
+		a.state.CycleStart = cycleStart
+		a.state.CycleEnd = time.Now()
+		a.cycle++
+		return nil
+	}
 
 	// Create metrics client
 	metricsClient, err := metricsv.NewForConfig(a.restConfig)
@@ -398,6 +970,11 @@ func (a *Agent) ObserveClusterWithContext(ctx context.Context) error {
 			resourceList.Pods = pods
 		}
 
+		// Build the image inventory if configured (reuse collected pods)
+		if (a.shouldCollectResource("images") || a.shouldCollectResource("pods")) && err == nil {
+			resourceList.Images = buildImageInventory(pods)
+		}
+
 		// Collect services if configured
 		if a.shouldCollectResource("services") {
 			services, err := a.collectServices(ctx, ns.Name)
@@ -428,8 +1005,32 @@ func (a *Agent) ObserveClusterWithContext(ctx context.Context) error {
 			}
 		}
 
-		// Only add namespace to resources if we collected any data
-		if len(resourceList.Pods) > 0 || len(resourceList.Services) > 0 || len(resourceList.Deployments) > 0 {
+		// Collect secrets if configured
+		if a.shouldCollectResource("secrets") {
+			secrets, err := a.collectSecrets(ctx, ns.Name)
+			if err != nil {
+				log.Printf("Warning: failed to collect secrets in namespace %s: %v", ns.Name, err)
+			} else {
+				resourceList.Secrets = secrets
+				resourceList.SecretsCollected = true
+			}
+		}
+
+		// Collect config maps if configured
+		if a.shouldCollectResource("configmaps") {
+			configMaps, err := a.collectConfigMaps(ctx, ns.Name)
+			if err != nil {
+				log.Printf("Warning: failed to collect config maps in namespace %s: %v", ns.Name, err)
+			} else {
+				resourceList.ConfigMaps = configMaps
+				resourceList.ConfigMapsCollected = true
+			}
+		}
+
+		// Only add namespace to resources if we collected any data. Listed configuration objects
+		// count even if there are none, so their deletion is noticed.
+		if len(resourceList.Pods) > 0 || len(resourceList.Services) > 0 || len(resourceList.Deployments) > 0 || len(resourceList.Images) > 0 ||
+			resourceList.SecretsCollected || resourceList.ConfigMapsCollected || len(resourceList.PersistentVolumeClaims) > 0 {
 			resources[ns.Name] = resourceList
 		}
 	}
@@ -485,7 +1086,11 @@ func (a *Agent) ObserveClusterWithContext(ctx context.Context) error {
 		Resources:         resources,
 		Events:            events,
 		PersistentVolumes: pvs,
+		Cycle:             a.cycle + 1,
+		CycleStart:        cycleStart,
+		CycleEnd:          time.Now(),
 	}
+	a.cycle++
 	return nil
 }
 
@@ -526,6 +1131,16 @@ func (a *Agent) collectNodes(ctx context.Context, metricsClient *metricsv.Client
 		cpuUsagePercent := calculateCPUPercentage(cpuUsage, cpuCapacity)
 		memoryUsagePercent := calculateMemoryPercentage(memoryUsage, memoryCapacity)
 
+		// Filesystem usage comes from the kubelet, which is only asked when configured
+		var nodefsUsagePercent, imagefsUsagePercent float64
+		if a.shouldCollectResource("nodestats") {
+			nodefsUsagePercent, imagefsUsagePercent, err = a.collectNodeFilesystems(ctx, node.Name)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		diskPressure, diskPressureSince := getNodeDiskPressure(&node)
+
 		// Get namespaces running on this node
 		namespaces := nodeNamespaces[node.Name]
 		if namespaces == nil {
@@ -544,6 +1159,18 @@ func (a *Agent) collectNodes(ctx context.Context, metricsClient *metricsv.Client
 			ConditionStatus:    getNodeConditionStatus(&node),
 			Status:             string(node.Status.Phase),
 			Namespaces:         namespaces,
+			Labels:             node.Labels,
+			CreatedAt:          node.CreationTimestamp.Time,
+
+			NodeFSUsagePercent:  nodefsUsagePercent,
+			ImageFSUsagePercent: imagefsUsagePercent,
+			DiskPressure:        diskPressure,
+			DiskPressureSince:   diskPressureSince,
+
+			KubeletVersion:          node.Status.NodeInfo.KubeletVersion,
+			KernelVersion:           node.Status.NodeInfo.KernelVersion,
+			ContainerRuntimeVersion: node.Status.NodeInfo.ContainerRuntimeVersion,
+			OSImage:                 node.Status.NodeInfo.OSImage,
 		})
 	}
 
@@ -649,6 +1276,7 @@ func (a *Agent) collectPods(ctx context.Context, namespace string) ([]types.Pod,
 
 	for _, pod := range podList.Items {
 		nodeName := pod.Spec.NodeName
+		ownerKind, ownerName := getPodOwner(&pod)
 
 		// Aggregate effective requests/limits for the pod
 		// For regular containers: sum of requests/limits
@@ -737,6 +1365,12 @@ func (a *Agent) collectPods(ctx context.Context, namespace string) ([]types.Pod,
 			CPULimits:      effCPULim.String(),
 			MemoryRequests: effMemReq.String(),
 			MemoryLimits:   effMemLim.String(),
+			OwnerKind:      ownerKind,
+			OwnerName:      ownerName,
+			Labels:         pod.Labels,
+			CreatedAt:      pod.CreationTimestamp.Time,
+			Containers:     getPodContainers(&pod),
+			Security:       getPodSecurity(&pod),
 		})
 
 		// Store the node name for this pod
@@ -780,8 +1414,9 @@ func (a *Agent) collectDeployments(ctx context.Context, namespace string) ([]typ
 			replicas = *deployment.Spec.Replicas
 		}
 		deployments = append(deployments, types.Deployment{
-			Name:     deployment.Name,
-			Replicas: replicas,
+			Name:              deployment.Name,
+			Replicas:          replicas,
+			AvailableReplicas: deployment.Status.AvailableReplicas,
 		})
 	}
 
@@ -826,6 +1461,59 @@ func (a *Agent) collectPVCs(ctx context.Context, namespace string) ([]types.Pers
 	return pvcs, nil
 }
 
+// collectSecrets collects Secret metadata and sizes for a specific namespace
+func (a *Agent) collectSecrets(ctx context.Context, namespace string) ([]types.ConfigObject, error) {
+	secretList, err := a.k8sClient.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets in namespace %s: %v", namespace, err)
+	}
+
+	secrets := make([]types.ConfigObject, 0, len(secretList.Items))
+	for _, secret := range secretList.Items {
+		size := 0
+		for k, v := range secret.Data {
+			size += len(k) + len(v)
+		}
+		secrets = append(secrets, types.ConfigObject{
+			Name:            secret.Name,
+			Namespace:       secret.Namespace,
+			ResourceVersion: secret.ResourceVersion,
+			CreatedAt:       secret.CreationTimestamp.Time,
+			SizeBytes:       size,
+		})
+	}
+
+	return secrets, nil
+}
+
+// collectConfigMaps collects ConfigMap metadata and sizes for a specific namespace
+func (a *Agent) collectConfigMaps(ctx context.Context, namespace string) ([]types.ConfigObject, error) {
+	configMapList, err := a.k8sClient.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config maps in namespace %s: %v", namespace, err)
+	}
+
+	configMaps := make([]types.ConfigObject, 0, len(configMapList.Items))
+	for _, cm := range configMapList.Items {
+		size := 0
+		for k, v := range cm.Data {
+			size += len(k) + len(v)
+		}
+		for k, v := range cm.BinaryData {
+			size += len(k) + len(v)
+		}
+		configMaps = append(configMaps, types.ConfigObject{
+			Name:            cm.Name,
+			Namespace:       cm.Namespace,
+			ResourceVersion: cm.ResourceVersion,
+			CreatedAt:       cm.CreationTimestamp.Time,
+			SizeBytes:       size,
+		})
+	}
+
+	return configMaps, nil
+}
+
 // collectPVs collects PersistentVolumes cluster-wide
 func (a *Agent) collectPVs(ctx context.Context) ([]types.PersistentVolume, error) {
 	pvList, err := a.k8sClient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
@@ -881,6 +1569,9 @@ func (a *Agent) shouldCollectResource(resourceType string) bool {
 	}
 	// Normalize desired type and provide aliases
 	wanted := strings.ToLower(resourceType)
+	if !a.collectable(wanted) {
+		return false
+	}
 	for _, resource := range a.config.Clusters[0].Resources {
 		r := strings.ToLower(resource)
 		if r == wanted {
@@ -926,6 +1617,160 @@ func getPodRestartCount(pod *v1.Pod) int32 {
 	return restarts
 }
 
+// getPodOwner returns the kind and name of the workload controlling a pod.
+// Pods owned by a ReplicaSet are attributed to the Deployment that created it.
+func getPodOwner(pod *v1.Pod) (string, string) {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		if ref.Kind == "ReplicaSet" {
+			// ReplicaSets created by a Deployment are named "<deployment>-<pod-template-hash>"
+			if i := strings.LastIndex(ref.Name, "-"); i > 0 {
+				return "Deployment", ref.Name[:i]
+			}
+		}
+		return ref.Kind, ref.Name
+	}
+	return "Pod", pod.Name
+}
+
+// getPodContainers returns the init and regular containers declared in a pod spec
+func getPodContainers(pod *v1.Pod) []types.Container {
+	containers := make([]types.Container, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	for _, c := range pod.Spec.InitContainers {
+		containers = append(containers, newContainer(c.Name, c.Image, true))
+	}
+	for _, c := range pod.Spec.Containers {
+		containers = append(containers, newContainer(c.Name, c.Image, false))
+	}
+	return containers
+}
+
+// getPodSecurity summarizes the privileges a pod spec grants its containers
+func getPodSecurity(pod *v1.Pod) types.PodSecurity {
+	security := types.PodSecurity{
+		HostNetwork: pod.Spec.HostNetwork,
+		HostPID:     pod.Spec.HostPID,
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.HostPath != nil {
+			security.HostPathMounts = append(security.HostPathMounts, volume.HostPath.Path)
+		}
+	}
+
+	podContext := pod.Spec.SecurityContext
+	containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range containers {
+		sc := c.SecurityContext
+		if sc != nil && sc.Privileged != nil && *sc.Privileged {
+			security.Privileged = true
+		}
+
+		// Container settings take precedence over pod settings
+		var runAsUser *int64
+		var runAsNonRoot *bool
+		if podContext != nil {
+			runAsUser, runAsNonRoot = podContext.RunAsUser, podContext.RunAsNonRoot
+		}
+		if sc != nil && sc.RunAsUser != nil {
+			runAsUser = sc.RunAsUser
+		}
+		if sc != nil && sc.RunAsNonRoot != nil {
+			runAsNonRoot = sc.RunAsNonRoot
+		}
+		if runAsUser != nil {
+			if *runAsUser == 0 {
+				security.RunAsRoot = true
+			}
+		} else if runAsNonRoot == nil || !*runAsNonRoot {
+			security.RunAsRoot = true
+		}
+	}
+	return security
+}
+
+// newContainer builds a types.Container with the image reference split into its parts
+func newContainer(name, image string, init bool) types.Container {
+	registry, tag, digest := parseImageReference(image)
+	return types.Container{
+		Name:     name,
+		Image:    image,
+		Registry: registry,
+		Tag:      tag,
+		Digest:   digest,
+		Init:     init,
+	}
+}
+
+// buildImageInventory aggregates the images used by a namespace's pods
+func buildImageInventory(pods []types.Pod) []types.ImageUsage {
+	usage := make(map[string]*types.ImageUsage)
+	workloads := make(map[string]map[string]bool)
+	for _, pod := range pods {
+		seen := make(map[string]bool) // count each image once per pod
+		for _, c := range pod.Containers {
+			entry, exists := usage[c.Image]
+			if !exists {
+				entry = &types.ImageUsage{
+					Image:    c.Image,
+					Registry: c.Registry,
+					Tag:      c.Tag,
+					Digest:   c.Digest,
+				}
+				usage[c.Image] = entry
+				workloads[c.Image] = make(map[string]bool)
+			}
+			if !seen[c.Image] {
+				seen[c.Image] = true
+				entry.Pods++
+			}
+			workload := pod.OwnerKind + "/" + pod.OwnerName
+			if !workloads[c.Image][workload] {
+				workloads[c.Image][workload] = true
+				entry.Workloads = append(entry.Workloads, workload)
+			}
+		}
+	}
+
+	images := make([]types.ImageUsage, 0, len(usage))
+	for _, entry := range usage {
+		sort.Strings(entry.Workloads)
+		images = append(images, *entry)
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Image < images[j].Image })
+	return images
+}
+
+// parseImageReference splits an image reference into registry, tag and digest using
+// the same defaulting rules as the container runtime (docker.io registry, latest tag)
+func parseImageReference(image string) (registry, tag, digest string) {
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		digest = name[i+1:]
+		name = name[:i]
+	}
+
+	// The first path component is a registry host only if it looks like one
+	registry = "docker.io"
+	if i := strings.Index(name, "/"); i >= 0 {
+		first := name[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			registry = first
+			name = name[i+1:]
+		}
+	}
+
+	// A tag is whatever follows the last colon after the last slash
+	if i := strings.LastIndex(name, ":"); i >= 0 && i > strings.LastIndex(name, "/") {
+		tag = name[i+1:]
+	}
+	if tag == "" && digest == "" {
+		tag = "latest"
+	}
+	return registry, tag, digest
+}
+
 // getPodOverallStatus derives a single summary status for a pod similar to kubectl output.
 func getPodOverallStatus(pod *v1.Pod) string {
 	if pod == nil {
@@ -1030,21 +1875,19 @@ func getPodOverallStatus(pod *v1.Pod) string {
 // 	a.detector.PrintHistory()
 // }
 
-// Learn processes the current state and updates the agent's knowledge
+// Learn processes the current state and updates the agent's knowledge. With learning enabled,
+// the detector is tuned once every window of observations.
 func (a *Agent) Learn() error {
 	// Calculate reward based on cluster health
-	reward := 1.0
-	for _, node := range a.state.Nodes {
-		if node.Status != "Ready" {
-			reward -= 0.5
-		}
-	}
+	reward := clusterReward(a.state)
 
 	// Store observation
 	observation := types.Observation{
-		Timestamp: time.Now(),
-		State:     a.state,
-		Reward:    reward,
+		ClusterID:   a.state.ClusterID,
+		ClusterName: a.state.ClusterName,
+		Timestamp:   time.Now(),
+		State:       a.state,
+		Reward:      reward,
 	}
 	a.observations = append(a.observations, observation)
 
@@ -1053,6 +1896,13 @@ func (a *Agent) Learn() error {
 		a.observations = a.observations[len(a.observations)-a.config.AnomalyDetection.MaxHistorySize:]
 	}
 
+	if a.config.AnomalyDetection.Learning.Enabled {
+		a.learnedCycles++
+		if a.learnedCycles >= a.config.AnomalyDetection.Learning.WindowCycles {
+			a.tune()
+			a.learnedCycles = 0
+		}
+	}
 	return nil
 }
 
@@ -1060,10 +1910,159 @@ func (a *Agent) Learn() error {
 func (a *Agent) DetectAnomalies() ([]types.Anomaly, error) {
 	// Update Prometheus metrics with current cluster state (if metrics exist)
 	if a.metrics != nil {
-		a.metrics.UpdateMetrics(a.state)
+		a.metrics.UpdateMetrics(a.state, a.detector)
 	}
 
+	start := time.Now()
 	anomalies := a.detector.DetectAnomalies(a.state)
+	a.exportObservations()
+	// The first observed cycle after startup also covers the time the agent was not running
+	observed := a.state.ClusterID != ""
+	if observed && a.metrics != nil {
+		a.metrics.ObserveDetection(a.state.ClusterID, a.state.ClusterName, time.Since(start))
+	}
+	if observed && !a.caughtUp {
+		caughtUp := a.catchUp()
+		anomalies = append(caughtUp, withoutCaughtUp(anomalies, caughtUp)...)
+		a.caughtUp = true
+	}
+	anomalies = a.processAnomalies(anomalies)
+	a.recordOutcome(anomalies)
+	if observed && a.metrics != nil {
+		a.metrics.RecordOpenAnomalies(a.state.ClusterID, a.state.ClusterName, a.detector.OpenAnomalies(a.state.ClusterID))
+		if health, ok := a.detector.LastHealthScore(); ok {
+			a.metrics.RecordHealthScore(a.state.ClusterID, a.state.ClusterName, health.Score)
+		}
+	}
+
+	if observed && a.checkpoints != nil {
+		if err := a.checkpoints.Mark(a.state.ClusterID, time.Now()); err != nil {
+			log.Printf("Warning: failed to write checkpoint for cluster %s: %v", a.state.ClusterID, err)
+		}
+	}
+
+	return anomalies, nil
+}
+
+// withoutCaughtUp drops the live anomalies the catch-up already reported, such as those of the
+// events recorded in the gap, which the live pass analyzes again
+func withoutCaughtUp(anomalies, caughtUp []types.Anomaly) []types.Anomaly {
+	if len(caughtUp) == 0 {
+		return anomalies
+	}
+	type occurrence struct {
+		fingerprint string
+		timestamp   time.Time
+	}
+	reported := make(map[occurrence]bool, len(caughtUp))
+	for _, anomaly := range caughtUp {
+		reported[occurrence{alertid.Fingerprint(anomaly), anomaly.Timestamp.UTC()}] = true
+	}
+	var live []types.Anomaly
+	for _, anomaly := range anomalies {
+		if !reported[occurrence{alertid.Fingerprint(anomaly), anomaly.Timestamp.UTC()}] {
+			live = append(live, anomaly)
+		}
+	}
+	return live
+}
+
+// catchUp analyzes the gap since the cluster's last checkpoint, flagging incidents that began
+// while the agent was not running
+func (a *Agent) catchUp() []types.Anomaly {
+	if a.checkpoints == nil {
+		return nil
+	}
+	last, exists := a.checkpoints.Last(a.state.ClusterID)
+	if !exists {
+		return nil
+	}
+
+	now := time.Now()
+	// A gap of less than two cycles is an ordinary restart
+	if now.Sub(last) < 2*time.Duration(a.config.ObservationInterval)*time.Second {
+		return nil
+	}
+	maxGap := time.Duration(a.config.CatchUp.MaxGapHours) * time.Hour
+	if now.Sub(last) > maxGap {
+		log.Printf("Warning: cluster %s was not observed for %s, only analyzing the last %s", a.state.ClusterID, now.Sub(last).Round(time.Second), maxGap)
+		last = now.Add(-maxGap)
+	}
+
+	var history []types.NodeMetricHistory
+	if a.prometheus != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		var err error
+		history, err = a.prometheus.NodeHistory(ctx, a.state.ClusterName, last, now)
+		if err != nil {
+			log.Printf("Warning: failed to fetch node history for catch-up of cluster %s: %v", a.state.ClusterID, err)
+		}
+	}
+
+	anomalies := a.detector.DetectDowntimeAnomalies(a.state, last, history)
+	log.Printf("Catch-up for cluster %s analyzed %s of downtime and found %d anomalies", a.state.ClusterID, now.Sub(last).Round(time.Second), len(anomalies))
+	return anomalies
+}
+
+// setTimeSeries exports the detector's observations to client from now on, after restoring the
+// history exported before the agent started
+func (a *Agent) setTimeSeries(client *timeseries.Client) {
+	a.timeSeries = client
+	if client == nil {
+		return
+	}
+	observations, err := client.Restore(a.clusterID)
+	if err != nil {
+		log.Printf("Warning: failed to restore the history of cluster %s: %v", a.clusterID, err)
+	} else if len(observations) > 0 {
+		a.detector.RestoreHistory(observations)
+		log.Printf("Restored %d observations of cluster %s", len(observations), a.clusterID)
+	}
+	a.detector.SetRecording(true)
+}
+
+// exportObservations exports the observations recorded by the detector in the last cycle
+func (a *Agent) exportObservations() {
+	if a.timeSeries == nil {
+		return
+	}
+	if err := a.timeSeries.Write(a.clusterID, a.clusterName, a.detector.TakeObservations()); err != nil {
+		log.Printf("Warning: failed to export observations of cluster %s: %v", a.clusterID, err)
+	}
+}
+
+// processAnomalies runs the detected anomalies through the pipeline: post-detect hooks may
+// modify or veto them, the record stage persists them and the notify stage decides which ones
+// to send. It returns the anomalies that were not vetoed.
+func (a *Agent) processAnomalies(anomalies []types.Anomaly) []types.Anomaly {
+	// Retry work that failed while an integration was unavailable before adding more
+	a.degradation.Flush()
+
+	anomalies = a.hooks.Run(context.Background(), hooks.PostDetect, anomalies)
+	sortByScore(anomalies)
+	records := a.recordAnomalies(anomalies)
+	a.notifyRecords(records)
+	return anomalies
+}
+
+// sortByScore orders anomalies from the highest score down, so the most significant ones are
+// listed and notified first
+func sortByScore(anomalies []types.Anomaly) {
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Score > anomalies[j].Score
+	})
+}
+
+// recordAnomalies assigns IDs to anomalies and records them in Prometheus, the journal and the vector database
+func (a *Agent) recordAnomalies(anomalies []types.Anomaly) []journal.Record {
+	if a.ids != nil {
+		for i := range anomalies {
+			if err := a.ids.Assign(&anomalies[i]); err != nil {
+				log.Printf("Failed to assign alert ID: %v", err)
+			}
+		}
+	}
 
 	// Record anomalies in Prometheus (if metrics exist)
 	if a.metrics != nil {
@@ -1072,62 +2071,247 @@ func (a *Agent) DetectAnomalies() ([]types.Anomaly, error) {
 		}
 	}
 
+	records := make([]journal.Record, 0, len(anomalies))
+	for _, anomaly := range anomalies {
+		records = append(records, journal.NewRecord(anomaly))
+	}
+
+	// Persist the records so notifications can be replayed without re-detection
+	if a.journal != nil && len(records) > 0 {
+		if err := a.journal.Append(records...); err != nil {
+			log.Printf("Failed to write anomalies to journal: %v", err)
+		}
+	}
+
+	// Publish anomalies to the event stream, independently of the vector database
+	if a.publisher != nil && len(anomalies) > 0 {
+		if err := a.publisher.Publish(anomalies); err != nil {
+			log.Printf("Failed to publish anomalies to NATS: %v", err)
+		}
+	}
+
 	// Store anomalies in vector database if enabled and storage exists
 	if a.config.Storage.StoreAlerts && a.storage != nil && a.model != nil {
+		var stored []types.Anomaly
 		for _, anomaly := range anomalies {
-			// Generate embedding for the anomaly
-			text, err := formatAnomalyForEncoding(anomaly, a.config)
-			if err != nil {
-				log.Printf("Failed to format anomaly for encoding: %v", err)
-				continue
+			if a.hooks.RunOne(context.Background(), hooks.PreStore, &anomaly) {
+				stored = append(stored, anomaly)
 			}
+		}
+		// Sampled-out anomalies are dropped before they are embedded
+		a.queueAnomalies(a.sampler.Sample(stored))
+	}
 
-			// Validate that the formatted text is not empty or whitespace-only
-			if strings.TrimSpace(text) == "" {
-				log.Printf("Skipping embedding for anomaly with empty formatted text: %+v", anomaly)
-				continue
+	return records
+}
+
+// errEmbeddingQueueFull is returned when retrying anomalies that did not fit in the queue of the
+// embedding workers while it is still full
+var errEmbeddingQueueFull = errors.New("embedding queue is full")
+
+// queueAnomalies embeds and stores anomalies on the embedding workers if they are enabled, and
+// right away otherwise. Anomalies that do not fit in the queue are retried on later cycles
+// according to the embedding degradation mode.
+func (a *Agent) queueAnomalies(anomalies []types.Anomaly) {
+	if len(anomalies) == 0 {
+		return
+	}
+	if a.workers == nil {
+		a.storeAnomalies(anomalies)
+		return
+	}
+
+	store := func() { a.storeAnomalies(anomalies) }
+	if a.workers.Submit(store) {
+		return
+	}
+	log.Printf("Warning: embedding queue is full, deferring %d anomalies", len(anomalies))
+	a.degradation.embedding.Add(func() error {
+		if !a.workers.Submit(store) {
+			return errEmbeddingQueueFull
+		}
+		return nil
+	})
+}
+
+// storeAnomalies embeds anomalies with as few requests as the model allows and stores them in the
+// vector database. If the batch fails, the anomalies are embedded and stored one by one.
+func (a *Agent) storeAnomalies(anomalies []types.Anomaly) {
+	if len(anomalies) < 2 || !a.breaker.Healthy() {
+		for _, anomaly := range anomalies {
+			a.storeAnomaly(anomaly)
+		}
+		return
+	}
+
+	var texts []string
+	var embedded []types.Anomaly
+	for _, anomaly := range anomalies {
+		text, err := formatAnomalyForEncoding(anomaly, a.config)
+		if err != nil {
+			log.Printf("Failed to format anomaly for encoding: %v", err)
+			continue
+		}
+		if strings.TrimSpace(text) == "" {
+			log.Printf("Skipping embedding for anomaly with empty formatted text: %+v", anomaly)
+			continue
+		}
+		texts = append(texts, text)
+		embedded = append(embedded, anomaly)
+	}
+	if len(texts) == 0 {
+		return
+	}
+
+	start := time.Now()
+	vectors, err := embedding.EncodeAll(a.model, texts)
+	if a.metrics != nil {
+		a.metrics.ObserveEmbedding(embedded[0].ClusterID, embedded[0].ClusterName, time.Since(start))
+	}
+	if err != nil {
+		log.Printf("Failed to generate embeddings for %d anomalies, retrying one by one: %v", len(texts), err)
+		for _, anomaly := range embedded {
+			a.storeAnomaly(anomaly)
+		}
+		return
+	}
+	for i, vector := range vectors {
+		a.storeVector(vector, embedded[i])
+	}
+}
+
+// storeAnomaly embeds an anomaly and stores it in the vector database. Failed embeddings are
+// queued for backfill according to the embedding degradation mode. While the storage circuit is
+// open the anomaly is not embedded but queued according to the storage degradation mode.
+func (a *Agent) storeAnomaly(anomaly types.Anomaly) {
+	if !a.breaker.Healthy() {
+		a.degradation.storage.Add(func() error {
+			if err := a.breaker.Err(); err != nil {
+				return err
 			}
+			a.storeAnomaly(anomaly)
+			return nil
+		})
+		return
+	}
+
+	// Generate embedding for the anomaly
+	text, err := formatAnomalyForEncoding(anomaly, a.config)
+	if err != nil {
+		log.Printf("Failed to format anomaly for encoding: %v", err)
+		return
+	}
+
+	// Validate that the formatted text is not empty or whitespace-only
+	if strings.TrimSpace(text) == "" {
+		log.Printf("Skipping embedding for anomaly with empty formatted text: %+v", anomaly)
+		return
+	}
 
+	start := time.Now()
+	vector, err := a.model.Encode(text)
+	if a.metrics != nil {
+		a.metrics.ObserveEmbedding(anomaly.ClusterID, anomaly.ClusterName, time.Since(start))
+	}
+	if err != nil {
+		log.Printf("Failed to generate embedding for anomaly: %v (text length: %d, text: '%.200s')",
+			err, len(text), text)
+		a.degradation.embedding.Add(func() error {
 			vector, err := a.model.Encode(text)
 			if err != nil {
-				log.Printf("Failed to generate embedding for anomaly: %v (text length: %d, text: '%.200s')",
-					err, len(text), text)
-				continue
+				return err
 			}
+			a.storeVector(vector, anomaly)
+			return nil
+		})
+		return
+	}
+	a.storeVector(vector, anomaly)
+}
 
-			// Store in vector database
-			err = a.storage.StoreAlert(vector, anomaly)
-			if err != nil {
-				log.Printf("Failed to store anomaly in vector database: %v", err)
-				continue
-			}
+// storeVector stores an embedded anomaly in the vector database. Failed stores are buffered
+// according to the storage degradation mode.
+func (a *Agent) storeVector(vector []float32, anomaly types.Anomaly) {
+	anomaly.Embedding = embeddingModel(a.config)
+	req := storage.StoreRequest{Anomaly: anomaly, Events: anomaly.Events, Vector: vector}
+	// Batched and write-behind writes are observed and retried when they are flushed
+	switch a.storage.(type) {
+	case *storage.BatchedStorage, *storage.WriteBehindStorage:
+		if err := a.storage.StoreAlert(req); err != nil {
+			log.Printf("Failed to queue anomaly for the vector database: %v", err)
 		}
+		return
 	}
 
-	// Send notifications if severity is high enough and notifications are enabled
-	if a.config.Notification.Enabled && a.notifier != nil {
-		for _, anomaly := range anomalies {
-			if shouldNotify(anomaly, a.config.Notification.MinSeverity) {
-				err := a.notifier.Notify(anomaly)
-				if err != nil {
-					log.Printf("Failed to send notification for anomaly: %v", err)
+	start := time.Now()
+	err := a.storage.StoreAlert(req)
+	if a.metrics != nil {
+		a.metrics.ObserveStorageWrite(anomaly.ClusterID, anomaly.ClusterName, time.Since(start))
+	}
+	if err != nil {
+		log.Printf("Failed to store anomaly in vector database: %v", err)
+		a.degradation.storage.Add(func() error {
+			return a.storage.StoreAlert(req)
+		})
+	}
+}
+
+// notifyRecords sends notifications for records whose severity is high enough when
+// notifications are enabled, and marks them as notified in the journal
+func (a *Agent) notifyRecords(records []journal.Record) {
+	if !a.config.Notification.Enabled || a.notifier == nil {
+		return
+	}
+
+	var notified []journal.Record
+	for _, record := range records {
+		if !notification.ShouldNotify(record.Anomaly, a.config.Notification.MinSeverity) || record.Anomaly.Score < a.config.Notification.MinScore {
+			continue
+		}
+		// Pre-notify hooks change what is sent, not what is journaled
+		anomaly := record.Anomaly
+		if !a.hooks.RunOne(context.Background(), hooks.PreNotify, &anomaly) {
+			continue
+		}
+		if err := a.notifier.Notify(anomaly); err != nil {
+			log.Printf("Failed to send notification for anomaly: %v", err)
+			a.degradation.notifier.Add(func() error {
+				if err := a.notifier.Notify(anomaly); err != nil {
+					return err
 				}
-			}
+				a.markNotified(record)
+				return nil
+			})
+			continue
 		}
+		notified = append(notified, a.notified(record))
 	}
 
-	return anomalies, nil
+	if a.journal != nil && len(notified) > 0 {
+		if err := a.journal.Append(notified...); err != nil {
+			log.Printf("Failed to write notification status to journal: %v", err)
+		}
+	}
 }
 
-// shouldNotify determines if a notification should be sent based on severity
-func shouldNotify(anomaly types.Anomaly, minSeverity string) bool {
-	severityLevels := map[string]int{
-		"low":    1,
-		"medium": 2,
-		"high":   3,
+// notified marks a record as notified now and counts the notification for volume forecasting
+func (a *Agent) notified(record journal.Record) journal.Record {
+	record.Notified = true
+	record.NotifiedAt = time.Now()
+	if a.volume != nil {
+		a.volume.Record(a.config.Notification.Type, record.NotifiedAt)
 	}
+	return record
+}
 
-	return severityLevels[anomaly.Severity] >= severityLevels[minSeverity]
+// markNotified records a late notification, e.g. a queued one, in the journal
+func (a *Agent) markNotified(record journal.Record) {
+	record = a.notified(record)
+	if a.journal != nil {
+		if err := a.journal.Append(record); err != nil {
+			log.Printf("Failed to write notification status to journal: %v", err)
+		}
+	}
 }
 
 // PrintState prints the current state of the cluster
@@ -1159,6 +2343,18 @@ func (a *Agent) PrintState() {
 	} else {
 		fmt.Printf("Events: 0\n")
 	}
+
+	// Print the image inventory if any
+	for ns, resources := range a.state.Resources {
+		if len(resources.Images) == 0 {
+			continue
+		}
+		fmt.Printf("Images in %s: %d\n", ns, len(resources.Images))
+		for _, image := range resources.Images {
+			fmt.Printf("  - %s (registry: %s, pods: %d, workloads: %s)\n",
+				image.Image, image.Registry, image.Pods, strings.Join(image.Workloads, ", "))
+		}
+	}
 }
 
 // PrintAnomalies prints the detected anomalies
@@ -1203,6 +2399,7 @@ func (a *Agent) collectEvents(ctx context.Context) ([]types.ClusterEvent, error)
 	for _, event := range eventList.Items {
 		// Convert Kubernetes event to our ClusterEvent type
 		clusterEvent := types.ClusterEvent{
+			UID:       string(event.UID),
 			Type:      event.Type,
 			Reason:    event.Reason,
 			Message:   event.Message,
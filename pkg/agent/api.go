@@ -0,0 +1,375 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/anomaly"
+	"github.com/rodolfo-mora/huginn/pkg/embedding"
+	"github.com/rodolfo-mora/huginn/pkg/metrics"
+	"github.com/rodolfo-mora/huginn/pkg/storage"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// registerAPI registers the agent's API endpoints on the metrics server
+func (m *MultiClusterAgent) registerAPI() {
+	m.metricsServer.Handle("GET /api/v1/metrics/trend", http.HandlerFunc(m.handleTrend))
+	m.metricsServer.Handle("GET /api/v1/alerts/summary", http.HandlerFunc(m.handleAlertSummary))
+	m.metricsServer.Handle("GET /api/v1/alerts/{id}/explain", http.HandlerFunc(m.handleExplain))
+	m.metricsServer.Handle("POST /api/v1/alerts/{id}/feedback", http.HandlerFunc(m.handleFeedback))
+	m.metricsServer.Handle("GET /api/v1/capabilities", http.HandlerFunc(m.handleCapabilities))
+	m.metricsServer.Handle("GET /api/v1/topology", http.HandlerFunc(m.handleTopology))
+}
+
+// handleCapabilities serves GET /api/v1/capabilities, the startup capability matrix of every cluster
+func (m *MultiClusterAgent) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	capabilities := make([]ClusterCapabilities, 0, len(m.agents))
+	for _, agent := range m.agents {
+		capabilities = append(capabilities, agent.capabilities)
+	}
+	sort.Slice(capabilities, func(i, j int) bool { return capabilities[i].ClusterID < capabilities[j].ClusterID })
+	metrics.WriteJSON(w, http.StatusOK, capabilities)
+}
+
+// handleTrend serves GET /api/v1/metrics/trend?cluster=prod&resource=node/worker-3&metric=cpu&window=30d.
+// cluster may be omitted when a single cluster is configured.
+func (m *MultiClusterAgent) handleTrend(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	agent, err := m.clusterAgent(query.Get("cluster"))
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !agent.config.AnomalyDetection.TrendHistory.Enabled {
+		apiError(w, http.StatusNotFound, "trend history is disabled (anomalyDetection.trendHistory.enabled)")
+		return
+	}
+
+	resourceType, resource, ok := strings.Cut(query.Get("resource"), "/")
+	if !ok || resourceType == "" || resource == "" {
+		apiError(w, http.StatusBadRequest, "resource must be given as type/name, e.g. node/worker-3")
+		return
+	}
+	metric := query.Get("metric")
+	if metric == "" {
+		apiError(w, http.StatusBadRequest, "metric is required")
+		return
+	}
+	window := 24 * time.Hour
+	if value := query.Get("window"); value != "" {
+		if window, err = parseWindow(value); err != nil {
+			apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	trend, ok := agent.detector.Trend(resourceType, resource, metric, window)
+	if !ok {
+		apiError(w, http.StatusNotFound, fmt.Sprintf("no history for %s %s", query.Get("resource"), metric))
+		return
+	}
+	metrics.WriteJSON(w, http.StatusOK, trend)
+}
+
+// handleAlertSummary serves GET /api/v1/alerts/summary?window=7d&groupBy=type,cluster&bucket=1h,
+// the counts of stored alerts per group. cluster and namespace restrict the counted alerts.
+func (m *MultiClusterAgent) handleAlertSummary(w http.ResponseWriter, r *http.Request) {
+	if m.storage == nil {
+		apiError(w, http.StatusNotFound, "alert summaries require stored alerts (storage.storeAlerts)")
+		return
+	}
+	query, err := ParseAggregateQuery(r.URL.Query().Get("window"), r.URL.Query().Get("groupBy"), r.URL.Query().Get("bucket"))
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Cluster = r.URL.Query().Get("cluster")
+	query.Namespace = r.URL.Query().Get("namespace")
+
+	counts, err := storage.AggregateAlerts(m.storage, query)
+	if errors.Is(err, storage.ErrAggregationUnsupported) {
+		apiError(w, http.StatusNotImplemented, fmt.Sprintf("%v: %s", err, m.config.Storage.Type))
+		return
+	}
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	total := 0
+	for _, count := range counts {
+		total += count.Count
+	}
+	metrics.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"start":  query.Start,
+		"end":    query.End,
+		"total":  total,
+		"groups": counts,
+	})
+}
+
+// ParseAggregateQuery parses an alert summary query ending now: a window such as 24h or 7d
+// (default 24h), comma-separated group by fields and an optional bucket duration
+func ParseAggregateQuery(window, groupBy, bucket string) (storage.AggregateQuery, error) {
+	query := storage.AggregateQuery{End: time.Now()}
+	span := 24 * time.Hour
+	if window != "" {
+		var err error
+		if span, err = parseWindow(window); err != nil {
+			return query, err
+		}
+	}
+	query.Start = query.End.Add(-span)
+	for _, field := range strings.Split(groupBy, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			query.GroupBy = append(query.GroupBy, field)
+		}
+	}
+	if bucket != "" {
+		var err error
+		if query.Bucket, err = parseWindow(bucket); err != nil {
+			return query, fmt.Errorf("invalid bucket: %s", bucket)
+		}
+	}
+	return query, query.Validate()
+}
+
+// Explanation is everything known about an alert, for the on-call engineer paged by it
+type Explanation struct {
+	Anomaly    types.Anomaly     `json:"anomaly"`
+	DetectedAt time.Time         `json:"detectedAt"`
+	Notified   bool              `json:"notified"`
+	Evidence   *anomaly.Evidence `json:"evidence,omitempty"` // Only for metric-based anomalies
+	Events     []types.Event     `json:"events"`
+	Correlated []types.Anomaly   `json:"correlated"` // Anomalies on the same node or namespace around the same time
+	Similar    []types.Anomaly   `json:"similar"`    // Similar historical incidents from the vector database
+}
+
+// explainWindow is how far around an alert history and correlated anomalies are collected
+const explainWindow = 15 * time.Minute
+
+// handleExplain serves GET /api/v1/alerts/{id}/explain for an alert in the anomaly journal.
+// With ?scope=cluster similar incidents are searched in the alert's cluster only.
+func (m *MultiClusterAgent) handleExplain(w http.ResponseWriter, r *http.Request) {
+	if m.journal == nil {
+		apiError(w, http.StatusNotFound, "alert lookup requires the anomaly journal (notification.journal.enabled)")
+		return
+	}
+
+	id := r.PathValue("id")
+	record, ok, err := m.journal.Get(id)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		apiError(w, http.StatusNotFound, fmt.Sprintf("unknown alert: %s", id))
+		return
+	}
+
+	alert := record.Anomaly
+	explanation := Explanation{
+		Anomaly:    alert,
+		DetectedAt: record.DetectedAt,
+		Notified:   record.Notified,
+		Events:     alert.Events,
+		Correlated: []types.Anomaly{},
+		Similar:    []types.Anomaly{},
+	}
+	if explanation.Events == nil {
+		explanation.Events = []types.Event{}
+	}
+
+	if agent, ok := m.agents[alert.ClusterID]; ok {
+		if evidence, ok := agent.detector.Evidence(alert, explainWindow); ok {
+			explanation.Evidence = &evidence
+		}
+	}
+
+	// Correlate by node or namespace; the journal is ordered by detection time
+	nearby, err := m.journal.Since(alert.Timestamp.Add(-explainWindow))
+	if err != nil {
+		log.Printf("Warning: failed to read journal for correlated anomalies: %v", err)
+	}
+	for _, other := range nearby {
+		if other.ID == record.ID || other.Anomaly.Timestamp.After(alert.Timestamp.Add(explainWindow)) {
+			continue
+		}
+		if other.Anomaly.ClusterID == alert.ClusterID && correlated(alert, other.Anomaly) {
+			explanation.Correlated = append(explanation.Correlated, other.Anomaly)
+		}
+	}
+
+	if m.storage != nil && m.model != nil {
+		// Similar incidents come from every cluster unless scoped to the alert's
+		similar, err := m.similarAlerts(alert, r.URL.Query().Get("scope") == "cluster")
+		if err != nil {
+			log.Printf("Warning: failed to search similar alerts: %v", err)
+		}
+		explanation.Similar = append(explanation.Similar, similar...)
+	}
+
+	metrics.WriteJSON(w, http.StatusOK, explanation)
+}
+
+// FeedbackRequest labels an alert as a true or false positive
+type FeedbackRequest struct {
+	Feedback string `json:"feedback"` // "true_positive" or "false_positive"
+}
+
+// handleFeedback serves POST /api/v1/alerts/{id}/feedback for an alert in the anomaly journal. The
+// label is recorded in the journal and the vector database, and the cluster's detector adjusts the
+// thresholds of the alert's resource.
+func (m *MultiClusterAgent) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	if m.journal == nil {
+		apiError(w, http.StatusNotFound, "alert lookup requires the anomaly journal (notification.journal.enabled)")
+		return
+	}
+
+	var request FeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apiError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if request.Feedback != types.FeedbackTruePositive && request.Feedback != types.FeedbackFalsePositive {
+		apiError(w, http.StatusBadRequest, fmt.Sprintf("feedback must be %q or %q", types.FeedbackTruePositive, types.FeedbackFalsePositive))
+		return
+	}
+
+	id := r.PathValue("id")
+	record, ok, err := m.journal.Get(id)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		apiError(w, http.StatusNotFound, fmt.Sprintf("unknown alert: %s", id))
+		return
+	}
+
+	// Relabeling an alert takes back its previous label
+	previous := record.Anomaly.Feedback
+	record.Anomaly.Feedback = request.Feedback
+	if err := m.journal.Append(record); err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if m.storage != nil && record.Anomaly.ID != "" {
+		if err := m.storage.SetFeedback(record.Anomaly.ID, request.Feedback); err != nil {
+			log.Printf("Warning: failed to store feedback for alert %s: %v", record.Anomaly.ID, err)
+		}
+	}
+	if agent, ok := m.agents[record.Anomaly.ClusterID]; ok && previous != request.Feedback {
+		if previous != "" {
+			agent.detector.RecordFeedback(record.Anomaly, oppositeFeedback(previous))
+		}
+		agent.detector.RecordFeedback(record.Anomaly, request.Feedback)
+	}
+
+	metrics.WriteJSON(w, http.StatusOK, record)
+}
+
+// oppositeFeedback returns the label that cancels out feedback
+func oppositeFeedback(feedback string) string {
+	if feedback == types.FeedbackFalsePositive {
+		return types.FeedbackTruePositive
+	}
+	return types.FeedbackFalsePositive
+}
+
+// correlated reports whether two anomalies affect the same node or namespace
+func correlated(a, b types.Anomaly) bool {
+	if a.NodeName != "" && a.NodeName == b.NodeName {
+		return true
+	}
+	return a.Namespace != "" && a.Namespace == b.Namespace
+}
+
+// similarAlerts returns stored alerts similar to an anomaly, excluding the anomaly itself, of
+// the anomaly's cluster only if inCluster is set
+func (m *MultiClusterAgent) similarAlerts(alert types.Anomaly, inCluster bool) ([]types.Anomaly, error) {
+	text, err := formatAnomalyForEncoding(alert, m.config)
+	if err != nil {
+		return nil, err
+	}
+	vector, err := embedding.EncodeQuery(m.model, text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embedding: %v", err)
+	}
+	// Backends with hybrid search also match the keywords of the description
+	var found []types.Anomaly
+	if m.config.Embedding.SearchSameModel {
+		cluster := ""
+		if inCluster {
+			cluster = alert.ClusterName
+		}
+		found, err = storage.SearchSimilarAlertsOfModel(m.storage, embeddingModel(m.config), cluster, vector, 6)
+	} else if inCluster {
+		found, err = storage.SearchSimilarAlertsInCluster(m.storage, alert.ClusterName, vector, 6)
+	} else {
+		found, err = storage.SearchHybrid(m.storage, alert.Description, vector, 6)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	similar := make([]types.Anomaly, 0, len(found))
+	for _, other := range found {
+		if alert.ID != "" && other.ID == alert.ID {
+			continue
+		}
+		similar = append(similar, other)
+	}
+	if len(similar) > 5 {
+		similar = similar[:5]
+	}
+	return similar, nil
+}
+
+// clusterAgent returns the agent of a cluster given by ID or name, or the only agent if cluster is empty
+func (m *MultiClusterAgent) clusterAgent(cluster string) (*Agent, error) {
+	if cluster == "" {
+		if len(m.agents) == 1 {
+			for _, agent := range m.agents {
+				return agent, nil
+			}
+		}
+		return nil, fmt.Errorf("cluster is required when more than one cluster is configured")
+	}
+	if agent, ok := m.agents[cluster]; ok {
+		return agent, nil
+	}
+	for _, agent := range m.agents {
+		if agent.clusterName == cluster {
+			return agent, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown cluster: %s", cluster)
+}
+
+// parseWindow parses a Go duration, additionally accepting whole days such as "30d"
+func parseWindow(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid window: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("invalid window: %s", value)
+	}
+	return window, nil
+}
+
+// apiError writes a JSON error response
+func apiError(w http.ResponseWriter, status int, message string) {
+	metrics.WriteJSON(w, status, map[string]string{"error": message})
+}
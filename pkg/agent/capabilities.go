@@ -0,0 +1,187 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Capability statuses of a resource type on a cluster
+const (
+	CapabilityAvailable   = "available"   // API served and listing permitted
+	CapabilityDegraded    = "degraded"    // Collected, but with missing data (e.g. no metrics API)
+	CapabilityForbidden   = "forbidden"   // API served but listing is not permitted
+	CapabilityUnavailable = "unavailable" // API group or resource not served by the cluster
+	CapabilityUnknown     = "unknown"     // Probe failed, collection is attempted anyway
+)
+
+// capabilityProbeTimeout bounds the startup probe of a cluster
+const capabilityProbeTimeout = 15 * time.Second
+
+// ResourceCapability is the probed status of collecting one resource type
+type ResourceCapability struct {
+	Resource string `json:"resource"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ClusterCapabilities is the capability matrix of a cluster
+type ClusterCapabilities struct {
+	ClusterID   string               `json:"clusterId"`
+	ClusterName string               `json:"clusterName"`
+	ProbedAt    time.Time            `json:"probedAt"`
+	Resources   []ResourceCapability `json:"resources"`
+}
+
+// probedResource maps a configured resource type to the API it is collected from
+type probedResource struct {
+	name     string // Name as accepted in the cluster's resources list
+	group    string
+	version  string
+	resource string
+}
+
+// probedResources lists the collectable resource types; namespaces are always probed
+var probedResources = []probedResource{
+	{"namespaces", "", "v1", "namespaces"},
+	{"nodes", "", "v1", "nodes"},
+	{"pods", "", "v1", "pods"},
+	{"images", "", "v1", "pods"},
+	{"services", "", "v1", "services"},
+	{"deployments", "apps", "v1", "deployments"},
+	{"persistentvolumeclaims", "", "v1", "persistentvolumeclaims"},
+	{"persistentvolumes", "", "v1", "persistentvolumes"},
+	{"secrets", "", "v1", "secrets"},
+	{"configmaps", "", "v1", "configmaps"},
+	{"events", "", "v1", "events"},
+	{"nodestats", "", "v1", "nodes/proxy"},
+}
+
+// ProbeCapabilities checks API availability, list permissions and metrics availability of every
+// configured resource type, logs the result and stops collecting resources that cannot be collected
+func (a *Agent) ProbeCapabilities(ctx context.Context) ClusterCapabilities {
+	ctx, cancel := context.WithTimeout(ctx, capabilityProbeTimeout)
+	defer cancel()
+
+	capabilities := ClusterCapabilities{
+		ClusterID:   a.clusterID,
+		ClusterName: a.clusterName,
+		ProbedAt:    time.Now(),
+		Resources:   []ResourceCapability{},
+	}
+	for _, probed := range probedResources {
+		if probed.name != "namespaces" && !a.shouldCollectResource(probed.name) {
+			continue
+		}
+		capabilities.Resources = append(capabilities.Resources, a.probeResource(ctx, probed))
+	}
+	a.capabilities = capabilities
+
+	summary := make([]string, 0, len(capabilities.Resources))
+	for _, capability := range capabilities.Resources {
+		summary = append(summary, capability.Resource+"="+capability.Status)
+		if capability.Status != CapabilityAvailable {
+			log.Printf("Warning: cluster %s: %s is %s: %s", a.clusterName, capability.Resource, capability.Status, capability.Reason)
+		}
+	}
+	log.Printf("Cluster %s capabilities: %s", a.clusterName, strings.Join(summary, " "))
+	return capabilities
+}
+
+// probeResource probes a single resource type
+func (a *Agent) probeResource(ctx context.Context, probed probedResource) ResourceCapability {
+	capability := ResourceCapability{Resource: probed.name, Status: CapabilityAvailable}
+
+	groupVersion := probed.version
+	if probed.group != "" {
+		groupVersion = probed.group + "/" + probed.version
+	}
+	served, err := a.servesResource(groupVersion, probed.resource)
+	if err != nil {
+		capability.Status = CapabilityUnknown
+		capability.Reason = fmt.Sprintf("API discovery failed: %v", err)
+		return capability
+	}
+	if !served {
+		capability.Status = CapabilityUnavailable
+		capability.Reason = fmt.Sprintf("%s is not served by %s", probed.resource, groupVersion)
+		return capability
+	}
+
+	// Subresources such as nodes/proxy are read per object rather than listed
+	attributes := &authorizationv1.ResourceAttributes{
+		Verb:     "list",
+		Group:    probed.group,
+		Version:  probed.version,
+		Resource: probed.resource,
+	}
+	if resource, subresource, ok := strings.Cut(probed.resource, "/"); ok {
+		attributes.Verb = "get"
+		attributes.Resource = resource
+		attributes.Subresource = subresource
+	}
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attributes},
+	}
+	result, err := a.k8sClient.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		capability.Status = CapabilityUnknown
+		capability.Reason = fmt.Sprintf("access review failed: %v", err)
+		return capability
+	}
+	if !result.Status.Allowed {
+		capability.Status = CapabilityForbidden
+		capability.Reason = fmt.Sprintf("listing %s cluster-wide is not permitted", probed.resource)
+		if attributes.Verb == "get" {
+			capability.Reason = fmt.Sprintf("reading %s is not permitted", probed.resource)
+		}
+		if result.Status.Reason != "" {
+			capability.Reason += ": " + result.Status.Reason
+		}
+		return capability
+	}
+
+	// Node usage comes from the metrics API
+	if probed.name == "nodes" {
+		served, err := a.servesResource("metrics.k8s.io/v1beta1", "nodes")
+		if err != nil || !served {
+			capability.Status = CapabilityDegraded
+			capability.Reason = "metrics.k8s.io is not available, node CPU and memory usage will read as zero"
+		}
+	}
+	return capability
+}
+
+// servesResource reports whether the cluster serves a resource in a group version
+func (a *Agent) servesResource(groupVersion, resource string) (bool, error) {
+	resources, err := a.k8sClient.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// collectable reports whether the capability probe found a resource type collectable. Resources
+// that were not probed are assumed collectable.
+func (a *Agent) collectable(resourceType string) bool {
+	for _, capability := range a.capabilities.Resources {
+		if capability.Resource == resourceType {
+			return capability.Status != CapabilityForbidden && capability.Status != CapabilityUnavailable
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"log"
+	"sync"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+)
+
+// retryQueue holds work that failed because an integration was unavailable, retried in order
+// on later cycles. A nil queue or one in drop mode discards work.
+type retryQueue struct {
+	name    string
+	size    int
+	mu      sync.Mutex
+	pending []func() error
+	dropped int // Operations dropped because the queue was full since the last flush
+}
+
+// degradation holds the retry queues of the optional integrations
+type degradation struct {
+	embedding *retryQueue
+	storage   *retryQueue
+	notifier  *retryQueue
+}
+
+// newDegradation creates the retry queues for integrations configured to queue failed work
+func newDegradation(cfg config.DegradationConfig) *degradation {
+	return &degradation{
+		embedding: newRetryQueue("embedding", cfg.Embedding),
+		storage:   newRetryQueue("storage", cfg.Storage),
+		notifier:  newRetryQueue("notifier", cfg.Notifier),
+	}
+}
+
+func newRetryQueue(name string, cfg config.IntegrationDegradation) *retryQueue {
+	if cfg.Mode != config.DegradeQueue {
+		return nil
+	}
+	return &retryQueue{name: name, size: cfg.QueueSize}
+}
+
+// Add queues an operation, dropping the oldest one if the queue is full
+func (q *retryQueue) Add(op func() error) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) >= q.size {
+		q.pending = q.pending[1:]
+		q.dropped++
+	}
+	q.pending = append(q.pending, op)
+}
+
+// Flush retries queued operations in order, stopping at the first failure since the
+// integration is most likely still unavailable
+func (q *retryQueue) Flush() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	pending := q.pending
+	dropped := q.dropped
+	q.pending = nil
+	q.dropped = 0
+	q.mu.Unlock()
+
+	if dropped > 0 {
+		log.Printf("Warning: dropped %d queued %s operations, the queue is full", dropped, q.name)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	done := 0
+	for _, op := range pending {
+		if err := op(); err != nil {
+			log.Printf("Warning: %s still unavailable, %d operations queued: %v", q.name, len(pending)-done, err)
+			break
+		}
+		done++
+	}
+	if done > 0 {
+		log.Printf("Retried %d queued %s operations", done, q.name)
+	}
+
+	// Put the remaining operations back ahead of anything queued during the flush
+	q.mu.Lock()
+	q.pending = append(pending[done:len(pending):len(pending)], q.pending...)
+	if overflow := len(q.pending) - q.size; overflow > 0 {
+		q.pending = q.pending[overflow:]
+		q.dropped += overflow
+	}
+	q.mu.Unlock()
+}
+
+// Flush retries the queued work of all integrations. Embeddings go first since a backfilled
+// embedding is stored, or queued for storage, right away.
+func (d *degradation) Flush() {
+	if d == nil {
+		return
+	}
+	d.embedding.Flush()
+	d.storage.Flush()
+	d.notifier.Flush()
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/notification"
+)
+
+// staleAfter is how long after its last observation a cluster is considered stale: three
+// observation intervals, but at least a minute
+func (m *MultiClusterAgent) staleAfter() time.Duration {
+	return max(3*time.Duration(m.config.ObservationInterval)*time.Second, time.Minute)
+}
+
+// registerHealthChecks registers the liveness and readiness checks served at /healthz and /readyz.
+// The agent is live while it keeps attempting observations, and ready once every cluster has been
+// observed recently and the storage backend and notification channel are reachable.
+func (m *MultiClusterAgent) registerHealthChecks() {
+	started := time.Now()
+	m.metricsServer.AddLivenessCheck("observation", func(ctx context.Context) error {
+		last := started
+		for id := range m.agents {
+			if cluster, ok := m.clusterManager.GetClusterStatus(id); ok && cluster.LastUpdated.After(last) {
+				last = cluster.LastUpdated
+			}
+		}
+		if since := time.Since(last); since > m.staleAfter() {
+			return fmt.Errorf("no observation attempted for %s", since.Round(time.Second))
+		}
+		return nil
+	})
+
+	for id, agent := range m.agents {
+		id, name := id, agent.clusterName
+		m.metricsServer.AddReadinessCheck("cluster:"+name, func(ctx context.Context) error {
+			cluster, ok := m.clusterManager.GetClusterStatus(id)
+			if !ok {
+				return fmt.Errorf("unknown cluster")
+			}
+			if cluster.LastObserved.IsZero() {
+				if cluster.Error != nil {
+					return fmt.Errorf("not observed yet: %v", cluster.Error)
+				}
+				return fmt.Errorf("not observed yet")
+			}
+			if since := time.Since(cluster.LastObserved); since > m.staleAfter() {
+				return fmt.Errorf("last observed %s ago: %v", since.Round(time.Second), cluster.Error)
+			}
+			return nil
+		})
+	}
+
+	if m.storage != nil {
+		m.metricsServer.AddReadinessCheck("storage", func(ctx context.Context) error {
+			return m.storage.Ping()
+		})
+	}
+	if tracker, ok := m.notifier.(*notification.TrackingNotifier); ok && m.config.Notification.Enabled {
+		m.metricsServer.AddReadinessCheck("notifier", func(ctx context.Context) error {
+			return tracker.Check()
+		})
+	}
+}
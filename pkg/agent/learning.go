@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"log"
+	"math"
+
+	"github.com/rodolfo-mora/huginn/pkg/anomaly"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// clusterReward scores the health of a cluster state from 0 to 1: half for the share of ready
+// nodes and half for the share of pods that are running or completed
+func clusterReward(state types.ClusterState) float64 {
+	reward := 1.0
+	if len(state.Nodes) > 0 {
+		notReady := 0
+		for _, node := range state.Nodes {
+			if node.ConditionStatus != "True" {
+				notReady++
+			}
+		}
+		reward -= 0.5 * float64(notReady) / float64(len(state.Nodes))
+	}
+
+	pods, failing := 0, 0
+	for _, resources := range state.Resources {
+		for _, pod := range resources.Pods {
+			pods++
+			if pod.Status != "Running" && pod.Status != "Succeeded" {
+				failing++
+			}
+		}
+	}
+	if pods > 0 {
+		reward -= 0.5 * float64(failing) / float64(pods)
+	}
+	return reward
+}
+
+// recordOutcome attaches the anomalies detected from the current state to its observation, so
+// tuning can tell whether they fired while the cluster was healthy
+func (a *Agent) recordOutcome(anomalies []types.Anomaly) {
+	if len(a.observations) == 0 {
+		return
+	}
+	observation := &a.observations[len(a.observations)-1]
+	if observation.State.Cycle != a.state.Cycle {
+		return
+	}
+	observation.Anomalies = make(map[string]int)
+	for _, anomaly := range anomalies {
+		if anomaly.ClusterID != a.state.ClusterID {
+			continue
+		}
+		if metric := anomalyMetric(anomaly); metric != "" {
+			observation.Anomalies[metric]++
+		}
+	}
+}
+
+// anomalyMetric returns the tunable metric an anomaly was raised on, or "" for other anomalies
+func anomalyMetric(a types.Anomaly) string {
+	switch a.Type {
+	case "HighCPUUsage", "HighMemoryUsage", "HighDiskUsage", "HighPodRestarts":
+		return anomaly.MetricOf(a)
+	}
+	return ""
+}
+
+// mostStrained returns the node metric closest to (or furthest above) its threshold, the one an
+// unhealthy cycle without anomalies most likely went unnoticed on
+func (a *Agent) mostStrained(state types.ClusterState) string {
+	strained, highest := "", 0.0
+	for _, node := range state.Nodes {
+		for metric, value := range map[string]float64{
+			"cpu":    node.CPUUsagePercent,
+			"memory": node.MemoryUsagePercent,
+			"disk":   math.Max(node.NodeFSUsagePercent, node.ImageFSUsagePercent),
+		} {
+			threshold := a.detector.Threshold(metric)
+			if threshold <= 0 {
+				continue
+			}
+			if ratio := value / threshold; ratio > highest {
+				strained, highest = metric, ratio
+			}
+		}
+	}
+	return strained
+}
+
+// tune adjusts the detector's thresholds and alphas from the observations of the last window.
+// A metric whose anomalies fire in too many healthy cycles gets a higher threshold and a smoother
+// baseline; one that misses too many unhealthy cycles gets a lower threshold and a faster one.
+func (a *Agent) tune() {
+	cfg := a.config.AnomalyDetection.Learning
+	cluster := a.clusterName
+	if cluster == "" {
+		cluster = a.state.ClusterName
+	}
+	if a.tuningBase == nil {
+		a.tuningBase = make(map[string]float64, len(anomaly.TunableMetrics))
+		for _, metric := range anomaly.TunableMetrics {
+			a.tuningBase[metric] = a.detector.Threshold(metric)
+		}
+	}
+
+	var window []types.Observation
+	for i := len(a.observations) - 1; i >= 0 && len(window) < cfg.WindowCycles; i-- {
+		if a.observations[i].Anomalies != nil {
+			window = append(window, a.observations[i])
+		}
+	}
+
+	healthy, unhealthy := 0, 0
+	falsePositives := make(map[string]int)
+	misses := make(map[string]int)
+	for _, observation := range window {
+		total := 0
+		for _, count := range observation.Anomalies {
+			total += count
+		}
+		switch {
+		case observation.Reward >= cfg.HealthyReward:
+			healthy++
+			for metric, count := range observation.Anomalies {
+				if count > 0 {
+					falsePositives[metric]++
+				}
+			}
+		case observation.Reward < cfg.UnhealthyReward:
+			unhealthy++
+			if total == 0 {
+				if metric := a.mostStrained(observation.State); metric != "" {
+					misses[metric]++
+				}
+			}
+		}
+	}
+
+	for _, metric := range anomaly.TunableMetrics {
+		base := a.tuningBase[metric]
+		previous, previousAlpha := a.detector.Threshold(metric), a.detector.Alpha(metric)
+		direction := 0.0
+		switch {
+		case healthy > 0 && float64(falsePositives[metric])/float64(healthy) > cfg.FalsePositiveRate:
+			direction = 1
+		case unhealthy > 0 && float64(misses[metric])/float64(unhealthy) > cfg.MissRate:
+			direction = -1
+		}
+
+		if base > 0 && direction != 0 {
+			threshold := previous * (1 + direction*cfg.Step)
+			low, high := base*(1-cfg.MaxAdjustment), base*(1+cfg.MaxAdjustment)
+			if metric == "restarts" {
+				// Restart thresholds are counts and move by at least one restart
+				threshold = math.Round(threshold)
+				if threshold == previous {
+					threshold += direction
+				}
+				low, high = math.Max(1, math.Floor(low)), math.Ceil(high)
+			} else {
+				high = math.Min(high, 100)
+			}
+			threshold = math.Max(low, math.Min(high, threshold))
+			// Alphas configured outside 0.05-0.9 are only moved towards that range
+			alpha := previousAlpha - direction*cfg.AlphaStep
+			alpha = math.Max(math.Min(0.05, previousAlpha), math.Min(math.Max(0.9, previousAlpha), alpha))
+
+			if threshold != previous || alpha != previousAlpha {
+				log.Printf("Cluster %s: tuned %s threshold %.2f -> %.2f, alpha %.2f -> %.2f (%d healthy cycles, %d with anomalies; %d unhealthy, %d missed)",
+					cluster, metric, previous, threshold, previousAlpha, alpha, healthy, falsePositives[metric], unhealthy, misses[metric])
+				a.detector.SetThreshold(metric, threshold)
+				a.detector.SetAlpha(metric, alpha)
+			}
+		}
+		if a.metrics != nil {
+			a.metrics.RecordTuning(a.state.ClusterID, cluster, metric, a.detector.Threshold(metric), a.detector.Alpha(metric))
+		}
+	}
+}
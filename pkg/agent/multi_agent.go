@@ -7,13 +7,21 @@ import (
 	"sync"
 	"time"
 
+	"github.com/rodolfo-mora/huginn/pkg/alertid"
 	"github.com/rodolfo-mora/huginn/pkg/anomaly"
+	"github.com/rodolfo-mora/huginn/pkg/archive"
+	"github.com/rodolfo-mora/huginn/pkg/catchup"
 	"github.com/rodolfo-mora/huginn/pkg/cluster"
 	"github.com/rodolfo-mora/huginn/pkg/config"
 	"github.com/rodolfo-mora/huginn/pkg/embedding"
+	"github.com/rodolfo-mora/huginn/pkg/features"
+	"github.com/rodolfo-mora/huginn/pkg/hooks"
+	"github.com/rodolfo-mora/huginn/pkg/journal"
 	"github.com/rodolfo-mora/huginn/pkg/metrics"
 	"github.com/rodolfo-mora/huginn/pkg/notification"
 	"github.com/rodolfo-mora/huginn/pkg/storage"
+	"github.com/rodolfo-mora/huginn/pkg/stream"
+	"github.com/rodolfo-mora/huginn/pkg/timeseries"
 	"github.com/rodolfo-mora/huginn/pkg/types"
 )
 
@@ -25,15 +33,39 @@ type MultiClusterAgent struct {
 	detector       *anomaly.Detector
 	notifier       notification.Notifier
 	storage        storage.Storage
+	breaker        *storage.CircuitBreaker
+	publisher      *stream.NATSPublisher
+	timeSeries     *timeseries.Client
 	model          embedding.Model
 	metrics        *metrics.PrometheusExporter
 	metricsServer  *metrics.MetricsServer
-	ctx            context.Context
-	cancel         context.CancelFunc
+	flags          *features.Flags
+	ids            *alertid.Generator
+	degradation    *degradation
+	workers        *workerPool
+	sampler        *storage.Sampler
+	hooks          *hooks.Pipeline
+	journal        *journal.Journal
+	checkpoints    *catchup.Checkpoints
+	prometheus     *catchup.PrometheusClient
+	volume         *notification.VolumeTracker
+	volumeReported map[string]time.Time // route -> last volume forecast anomaly
+	// Anomalies of the last hour, counted in the fleet topology
+	recentMu        sync.Mutex
+	recentAnomalies []types.Anomaly
+	source          func(clusterID string) types.ClusterState // Synthetic cluster state in simulation mode
+	ctx             context.Context
+	cancel          context.CancelFunc
 }
 
 // NewMultiClusterAgent creates a new multi-cluster agent
 func NewMultiClusterAgent(cfg *config.Config) (*MultiClusterAgent, error) {
+	return newMultiClusterAgent(cfg, nil)
+}
+
+// newMultiClusterAgent creates a multi-cluster agent, observing clusters through source instead
+// of their API servers if it is set
+func newMultiClusterAgent(cfg *config.Config, source func(clusterID string) types.ClusterState) (*MultiClusterAgent, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Create cluster manager
@@ -43,89 +75,91 @@ func NewMultiClusterAgent(cfg *config.Config) (*MultiClusterAgent, error) {
 		return nil, fmt.Errorf("failed to initialize clusters: %v", err)
 	}
 
+	// Create feature flags, refreshed from the flag service if one is configured
+	flags := features.NewFlags(cfg.FeatureFlags)
+	flags.Start(ctx)
+
 	// Create detector
-	detector := anomaly.NewDetector(
-		cfg.AnomalyDetection.CPUThreshold,
-		cfg.AnomalyDetection.MemoryThreshold,
-		cfg.AnomalyDetection.PodRestartThreshold,
-		cfg.AnomalyDetection.MaxHistorySize,
-		cfg.AnomalyDetection.CPUAlpha,
-		cfg.AnomalyDetection.MemoryAlpha,
-		cfg.AnomalyDetection.RestartAlpha,
-		false, // debug mode
-		cfg.AnomalyDetection.MinStdDev,
-	)
+	detector := newDetector(cfg)
+	detector.SetFeatureFlags(flags)
 
 	// Create Prometheus metrics exporter
 	metricsExporter := metrics.NewPrometheusExporter(detector, cfg)
+	metricsExporter.Registry().MustRegister(clusterManager)
 
 	// Create metrics server
-	metricsServer := metrics.NewMetricsServer(":8080", metricsExporter)
+	metricsServer := metrics.NewMetricsServer(cfg.MetricsServer.Address, metricsExporter)
+	metricsServer.SetTLS(cfg.MetricsServer.TLS)
+	metricsServer.SetAuth(cfg.MetricsServer.Auth)
+	metricsServer.SetDebug(cfg.MetricsServer.Debug)
+
+	// Push metrics where the agent cannot be scraped
+	if pusher := metrics.NewPusher(metricsExporter, cfg.MetricsPush); pusher != nil {
+		pusher.Start(ctx)
+	}
+
+	// Create embedding model first, the storage expects vectors of its dimension
+	model, err := newEmbeddingModel(cfg)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
 
 	// Create storage client
 	var storageClient storage.Storage
+	var storageBreaker *storage.CircuitBreaker
 	if cfg.Storage.StoreAlerts {
-		storageConfig := storage.StorageConfig{
-			Type:       storage.StorageType(cfg.Storage.Type),
-			URL:        cfg.Storage.Qdrant.URL,
-			Password:   cfg.Storage.Redis.Password,
-			DB:         cfg.Storage.Redis.DB,
-			Collection: cfg.Storage.Qdrant.Collection,
-			VectorSize: cfg.Storage.Qdrant.VectorSize,
-			Distance:   cfg.Storage.Qdrant.DistanceMetric,
-		}
+		storageConfig := newStorageConfig(cfg, cfg.Storage.Type)
 
-		var err error
-		storageClient, err = storage.NewStorage(storageConfig)
+		storageClient, storageBreaker, err = newStorage(cfg, storageConfig)
 		if err != nil {
 			cancel()
-			return nil, fmt.Errorf("failed to create storage client: %v", err)
+			return nil, err
 		}
 	}
 
-	// Create embedding model
-	var model embedding.Model
-	switch cfg.Embedding.Type {
-	case "simple":
-		model = embedding.NewSimpleModel(cfg.Embedding.Dimension)
-	case "openai":
-		model = embedding.NewOpenAIModel(cfg.Embedding.OpenAI.APIKey, cfg.Embedding.OpenAI.Model, cfg.Embedding.Dimension)
-	case "sentence-transformers":
-		model = embedding.NewSentenceTransformersModel(cfg.Embedding.SentenceTransformers.Model, cfg.Embedding.SentenceTransformers.Device, cfg.Embedding.Dimension)
-	case "ollama":
-		model = embedding.NewOllamaModel(cfg.Embedding.Ollama.URL, cfg.Embedding.Ollama.Model, cfg.Embedding.Dimension)
-	default:
+	// Create notifier
+	baseNotifier, err := notification.NewNotifier(cfg.Notification)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	notifier := notification.NewTrackingNotifier(baseNotifier)
+
+	ids, err := alertid.NewGenerator(cfg.Storage.AlertID, cfg.Notification)
+	if err != nil {
 		cancel()
-		return nil, fmt.Errorf("unsupported embedding type: %s", cfg.Embedding.Type)
+		return nil, err
 	}
 
-	// Create notifier
-	var notifier notification.Notifier
-	switch cfg.Notification.Type {
-	case "slack":
-		notifier = &notification.SlackNotifier{WebhookURL: cfg.Notification.Slack.WebhookURL}
-	case "email":
-		notifier = &notification.EmailNotifier{
-			SMTPHost:     cfg.Notification.Email.SMTPHost,
-			SMTPPort:     cfg.Notification.Email.SMTPPort,
-			SMTPUser:     cfg.Notification.Email.SMTPUser,
-			SMTPPassword: cfg.Notification.Email.SMTPPassword,
-			From:         cfg.Notification.Email.From,
-			To:           cfg.Notification.Email.To,
-		}
-	case "webhook":
-		notifier = &notification.WebhookNotifier{
-			URL:     cfg.Notification.Webhook.URL,
-			Headers: cfg.Notification.Webhook.Headers,
-		}
-	case "alertmanager":
-		notifier = &notification.AlertmanagerNotifier{
-			URL:           cfg.Notification.Alertmanager.URL,
-			DefaultLabels: cfg.Notification.Alertmanager.DefaultLabels,
-		}
-	default:
+	pipeline, err := hooks.New(cfg.Hooks)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	anomalyJournal, err := openJournal(cfg)
+	if err != nil {
 		cancel()
-		return nil, fmt.Errorf("unsupported notification type: %s", cfg.Notification.Type)
+		return nil, err
+	}
+
+	checkpoints, prometheus, err := openCatchUp(cfg)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	publisher, err := stream.NewNATSPublisher(cfg.Storage.NATS)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	timeSeries, err := timeseries.NewClient(cfg.Storage.TimeSeries)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
 
 	multiAgent := &MultiClusterAgent{
@@ -135,18 +169,50 @@ func NewMultiClusterAgent(cfg *config.Config) (*MultiClusterAgent, error) {
 		detector:       detector,
 		notifier:       notifier,
 		storage:        storageClient,
+		breaker:        storageBreaker,
+		publisher:      publisher,
+		timeSeries:     timeSeries,
 		model:          model,
 		metrics:        metricsExporter,
 		metricsServer:  metricsServer,
+		flags:          flags,
+		ids:            ids,
+		degradation:    newDegradation(cfg.Degradation),
+		workers:        newWorkerPool(cfg.Embedding.Workers),
+		sampler:        newSampler(cfg),
+		hooks:          pipeline,
+		journal:        anomalyJournal,
+		checkpoints:    checkpoints,
+		prometheus:     prometheus,
+		volume:         newVolumeTracker(cfg, anomalyJournal),
+		volumeReported: make(map[string]time.Time),
+		source:         source,
 		ctx:            ctx,
 		cancel:         cancel,
 	}
 
+	handleStorageFlushes(storageClient, metricsExporter, multiAgent.degradation)
+	handleStorageHealth(storageBreaker, metricsExporter)
+
+	archiver, err := archive.NewArchiver(cfg.Storage.Archive, anomalyJournal, func() map[string]types.ClusterState {
+		return clusterManager.GetMultiClusterState().Clusters
+	})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create archiver: %v", err)
+	}
+	if archiver != nil {
+		archiver.Start(ctx)
+	}
+
 	// Create individual cluster agents
 	if err := multiAgent.createClusterAgents(); err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to create cluster agents: %v", err)
 	}
+	multiAgent.replayFeedback()
+	multiAgent.registerAPI()
+	multiAgent.registerHealthChecks()
 
 	return multiAgent, nil
 }
@@ -160,15 +226,24 @@ func (m *MultiClusterAgent) createClusterAgents() error {
 
 		// Create a single-cluster config for this cluster
 		singleClusterConfig := &config.Config{
-			Clusters:         []config.ClusterConfig{clusterConfig},
-			AnomalyDetection: m.config.AnomalyDetection,
-			Storage:          m.config.Storage,
-			Embedding:        m.config.Embedding,
-			Notification:     m.config.Notification,
+			Clusters:            []config.ClusterConfig{clusterConfig},
+			AnomalyDetection:    m.config.AnomalyDetection,
+			Storage:             m.config.Storage,
+			Embedding:           m.config.Embedding,
+			Notification:        m.config.Notification,
+			CatchUp:             m.config.CatchUp,
+			Degradation:         m.config.Degradation,
+			ObservationInterval: m.config.ObservationInterval,
 		}
 
 		// Create agent without metrics (we'll use the shared metrics from multi-agent)
-		agent, err := NewAgentWithoutMetrics(singleClusterConfig)
+		var agent *Agent
+		var err error
+		if m.source != nil {
+			agent = newSimulatedAgent(singleClusterConfig, m.source)
+		} else {
+			agent, err = NewAgentWithoutMetrics(singleClusterConfig)
+		}
 		if err != nil {
 			log.Printf("Warning: failed to create agent for cluster %s: %v", clusterConfig.Name, err)
 			m.clusterManager.SetClusterHealth(clusterConfig.ID, false, err)
@@ -178,11 +253,29 @@ func (m *MultiClusterAgent) createClusterAgents() error {
 		// Set cluster information on the agent
 		agent.SetClusterInfo(clusterConfig.ID, clusterConfig.Name)
 
+		// Surface missing APIs and permissions once instead of as per-cycle warnings
+		if agent.source == nil {
+			agent.ProbeCapabilities(m.ctx)
+		}
+
 		// Set the shared metrics and storage
 		agent.metrics = m.metrics
 		agent.storage = m.storage
+		agent.breaker = m.breaker
+		agent.publisher = m.publisher
+		agent.setTimeSeries(m.timeSeries)
 		agent.notifier = m.notifier
 		agent.model = m.model
+		agent.ids = m.ids
+		agent.degradation = m.degradation
+		agent.workers = m.workers
+		agent.sampler = m.sampler
+		agent.hooks = m.hooks
+		agent.journal = m.journal
+		agent.checkpoints = m.checkpoints
+		agent.prometheus = m.prometheus
+		agent.volume = m.volume
+		agent.detector.SetFeatureFlags(m.flags)
 
 		m.agents[clusterConfig.ID] = agent
 		log.Printf("Created agent for cluster: %s (%s)", clusterConfig.Name, clusterConfig.ID)
@@ -191,6 +284,42 @@ func (m *MultiClusterAgent) createClusterAgents() error {
 	return nil
 }
 
+// replayFeedback hands the alert labels recorded in the journal to the cluster detectors, so
+// threshold adjustments from feedback survive restarts for as long as the journal retains the alerts
+func (m *MultiClusterAgent) replayFeedback() {
+	if m.journal == nil {
+		return
+	}
+	records, err := m.journal.Since(time.Time{})
+	if err != nil {
+		log.Printf("Warning: failed to read alert feedback from journal: %v", err)
+		return
+	}
+	// An alert detected again or relabeled has several labeled records; as live, only its
+	// latest label counts
+	latest := make(map[string]types.Anomaly)
+	var order []string
+	for _, record := range records {
+		if record.Anomaly.Feedback == "" {
+			continue
+		}
+		id := record.Anomaly.ID
+		if id == "" {
+			id = record.ID
+		}
+		if _, exists := latest[id]; !exists {
+			order = append(order, id)
+		}
+		latest[id] = record.Anomaly
+	}
+	for _, id := range order {
+		alert := latest[id]
+		if agent, ok := m.agents[alert.ClusterID]; ok {
+			agent.detector.RecordFeedback(alert, alert.Feedback)
+		}
+	}
+}
+
 // ObserveAllClusters observes all enabled clusters
 func (m *MultiClusterAgent) ObserveAllClusters() error {
 	return m.ObserveAllClustersWithContext(context.Background())
@@ -237,6 +366,13 @@ func (m *MultiClusterAgent) ObserveAllClustersWithContext(ctx context.Context) e
 			if err := m.clusterManager.UpdateClusterState(id, &state); err != nil {
 				log.Printf("Error updating cluster state for %s: %v", id, err)
 			}
+
+			// Keep the metrics of the cycle in storages that analyze them over time
+			if m.storage != nil && m.breaker.Healthy() {
+				if err := storage.StoreObservations(m.storage, state); err != nil {
+					log.Printf("Warning: failed to store observations of cluster %s: %v", id, err)
+				}
+			}
 		}(clusterID, agent)
 	}
 
@@ -325,10 +461,80 @@ func (m *MultiClusterAgent) DetectAllAnomaliesWithContext(ctx context.Context) (
 			allAnomalies = append(allAnomalies, anomalies...)
 		}
 
+		// Compare clusters against each other
+		allAnomalies = append(allAnomalies, m.detectFleetAnomalies()...)
+
+		// Check whether the notification route can keep up
+		allAnomalies = append(allAnomalies, m.detectVolumeAnomalies()...)
+
+		m.recordRecentAnomalies(allAnomalies)
+		sortByScore(allAnomalies)
+
 		return allAnomalies, nil
 	}
 }
 
+// detectFleetAnomalies runs detections that need the state of every cluster and hands each
+// anomaly to the agent of the cluster it belongs to for recording, storage and notification
+func (m *MultiClusterAgent) detectFleetAnomalies() []types.Anomaly {
+	multiState := m.clusterManager.GetMultiClusterState()
+	states := make([]types.ClusterState, 0, len(multiState.Clusters))
+	for _, state := range multiState.Clusters {
+		states = append(states, state)
+	}
+
+	var anomalies []types.Anomaly
+	for _, anomaly := range m.detector.DetectFleetDrift(states) {
+		if agent, exists := m.agents[anomaly.ClusterID]; exists {
+			anomalies = append(anomalies, agent.processAnomalies([]types.Anomaly{anomaly})...)
+		} else {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	return anomalies
+}
+
+// detectVolumeAnomalies reports notification routes whose volume routinely exceeds, or is forecast
+// to exceed, the route's rate limit. These self-diagnostic anomalies are recorded in the metrics but
+// not notified, which would only add to the volume, and are reported at most every six hours per route.
+func (m *MultiClusterAgent) detectVolumeAnomalies() []types.Anomaly {
+	var anomalies []types.Anomaly
+	if m.volume == nil {
+		return anomalies
+	}
+
+	cfg := m.config.Notification.Volume
+	limit := float64(cfg.RateLimitPerHour)
+	now := time.Now()
+	for _, route := range m.volume.Routes() {
+		forecast, atRisk := m.volume.Forecast(route, limit, time.Duration(cfg.HorizonHours)*time.Hour, now)
+		if !atRisk || now.Sub(m.volumeReported[route]) < 6*time.Hour {
+			continue
+		}
+
+		outlook := fmt.Sprintf("was over the limit in %d of the last 24 hours", forecast.HoursOverLimit)
+		if forecast.ExceedsIn > 0 {
+			outlook = fmt.Sprintf("is forecast to exceed the limit in ~%s", forecast.ExceedsIn.Round(time.Hour))
+		}
+		anomaly := types.Anomaly{
+			Type:         "NotificationVolumeForecast",
+			ResourceType: "notifier",
+			Resource:     route,
+			Severity:     types.SeverityLow,
+			Description: fmt.Sprintf("Notification route %s sends %.1f notifications/hour (trend: %+.2f/hour per hour) and %s of %d/hour; consider a digest mode or raising thresholds or minSeverity",
+				route, forecast.Rate, forecast.Slope, outlook, cfg.RateLimitPerHour),
+			Value:     forecast.Rate,
+			Threshold: limit,
+			Timestamp: now,
+			Labels:    map[string]string{"category": "selfDiagnostic", "route": route},
+		}
+		m.metrics.RecordAnomaly(anomaly)
+		m.volumeReported[route] = now
+		anomalies = append(anomalies, anomaly)
+	}
+	return anomalies
+}
+
 // LearnFromAllClusters learns from observations across all clusters
 func (m *MultiClusterAgent) LearnFromAllClusters() error {
 	for clusterID, agent := range m.agents {
@@ -395,6 +601,20 @@ func (m *MultiClusterAgent) StartMetricsServer() {
 func (m *MultiClusterAgent) Stop() {
 	m.cancel()
 	m.clusterManager.Stop()
+	// Finish embedding queued anomalies before flushing the storage they are written to
+	m.workers.Close()
+	switch client := m.storage.(type) {
+	case *storage.BatchedStorage:
+		client.Close()
+	case *storage.WriteBehindStorage:
+		client.Close()
+	}
+	if m.breaker != nil {
+		m.breaker.Close()
+	}
+	if m.publisher != nil {
+		m.publisher.Close()
+	}
 }
 
 // GetClusterManager returns the cluster manager
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// fsStats is a filesystem of the kubelet stats summary (/stats/summary)
+type fsStats struct {
+	CapacityBytes *uint64 `json:"capacityBytes"`
+	UsedBytes     *uint64 `json:"usedBytes"`
+}
+
+// statsSummary holds the parts of the kubelet stats summary used for disk usage
+type statsSummary struct {
+	Node struct {
+		Fs      *fsStats `json:"fs"`
+		Runtime *struct {
+			ImageFs *fsStats `json:"imageFs"`
+		} `json:"runtime"`
+	} `json:"node"`
+}
+
+// usagePercent returns the used share of the filesystem, or 0 when it was not reported
+func (fs *fsStats) usagePercent() float64 {
+	if fs == nil || fs.CapacityBytes == nil || fs.UsedBytes == nil || *fs.CapacityBytes == 0 {
+		return 0
+	}
+	return float64(*fs.UsedBytes) / float64(*fs.CapacityBytes) * 100
+}
+
+// collectNodeFilesystems returns the nodefs and imagefs usage percentages of a node from the
+// kubelet stats summary, read through the API server's node proxy
+func (a *Agent) collectNodeFilesystems(ctx context.Context, nodeName string) (nodefs, imagefs float64, err error) {
+	data, err := a.k8sClient.CoreV1().RESTClient().Get().
+		Resource("nodes").Name(nodeName).SubResource("proxy").Suffix("stats/summary").
+		DoRaw(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get stats summary of node %s: %v", nodeName, err)
+	}
+
+	var summary statsSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse stats summary of node %s: %v", nodeName, err)
+	}
+	nodefs = summary.Node.Fs.usagePercent()
+	if summary.Node.Runtime != nil {
+		imagefs = summary.Node.Runtime.ImageFs.usagePercent()
+	}
+	return nodefs, imagefs, nil
+}
+
+// getNodeDiskPressure returns whether a node reports the DiskPressure condition and since when
+func getNodeDiskPressure(node *v1.Node) (bool, time.Time) {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeDiskPressure && condition.Status == v1.ConditionTrue {
+			return true, condition.LastTransitionTime.Time
+		}
+	}
+	return false, time.Time{}
+}
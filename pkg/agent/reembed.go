@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/embedding"
+	"github.com/rodolfo-mora/huginn/pkg/storage"
+)
+
+// reembedProgressInterval is how many re-embedded alerts are logged at a time
+const reembedProgressInterval = 1000
+
+// ReembedAlerts re-encodes every stored alert with the configured embedding model and stores it,
+// with its new vector, in the collection named target of the same backend: the Qdrant or Milvus
+// collection, the Weaviate class, the ClickHouse table or the local file. The stored alerts are
+// left untouched, so the configuration can be switched to target once it is filled. Alerts are
+// upserted by ID, so an interrupted run can be repeated. It returns the number of alerts stored.
+func ReembedAlerts(cfg *config.Config, target string, batchSize int) (int, error) {
+	source, err := OpenStorage(cfg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to storage: %v", err)
+	}
+
+	// The target follows the model, whose dimension is probed; failures are not worth degrading
+	// for in a one-off job
+	targetCfg := *cfg
+	targetCfg.Storage.StoreAlerts = true
+	targetCfg.Degradation.Embedding.Mode = config.DegradeFail
+	if err := setCollection(&targetCfg, target); err != nil {
+		return 0, err
+	}
+	model, err := newEmbeddingModel(&targetCfg)
+	if err != nil {
+		return 0, err
+	}
+	targetCfg.Storage.Milvus.VectorSize = storedDimension(&targetCfg)
+	targetConfig := verifyVectorSize(&targetCfg, newStorageConfig(&targetCfg, targetCfg.Storage.Type))
+	targetConfig.Qdrant.RecreateOnMismatch = false
+	client, err := storage.NewStorage(targetConfig)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to the target collection: %v", err)
+	}
+	if client, err = encryptStorage(&targetCfg, client); err != nil {
+		return 0, err
+	}
+
+	recorded := embeddingModel(&targetCfg)
+	count, skipped := 0, 0
+	var batch []storage.StoreRequest
+	var texts []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		vectors, err := embedding.EncodeAll(model, texts)
+		if err != nil {
+			return fmt.Errorf("failed to embed alerts %d-%d: %v", count+1, count+len(batch), err)
+		}
+		for i := range batch {
+			batch[i].Vector = vectors[i]
+			batch[i].Anomaly.Embedding = recorded
+		}
+		if err := storage.StoreAll(client, batch); err != nil {
+			return fmt.Errorf("failed to store alerts %d-%d: %v", count+1, count+len(batch), err)
+		}
+		if count/reembedProgressInterval != (count+len(batch))/reembedProgressInterval {
+			log.Printf("Re-embedded %d alerts", count+len(batch))
+		}
+		count += len(batch)
+		batch, texts = batch[:0], texts[:0]
+		return nil
+	}
+
+	err = storage.DumpAlerts(source, func(request storage.StoreRequest) error {
+		text, err := formatAnomalyForEncoding(request.Anomaly, cfg)
+		if err != nil || strings.TrimSpace(text) == "" {
+			skipped++
+			return nil
+		}
+		batch = append(batch, request)
+		texts = append(texts, text)
+		if len(batch) == batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if errors.Is(err, storage.ErrDumpUnsupported) {
+		return 0, fmt.Errorf("%s storage cannot list its alerts for re-embedding", cfg.Storage.Type)
+	}
+	if err == nil {
+		err = flush()
+	}
+	if skipped > 0 {
+		log.Printf("Warning: skipped %d alerts without text to embed", skipped)
+	}
+	if err != nil {
+		return count, fmt.Errorf("%v (%d alerts re-embedded)", err, count)
+	}
+	return count, nil
+}
+
+// setCollection points the configured storage at another collection, which must not be the
+// current one
+func setCollection(cfg *config.Config, collection string) error {
+	var current *string
+	switch storage.StorageType(cfg.Storage.Type) {
+	case storage.StorageTypeQdrant:
+		current = &cfg.Storage.Qdrant.Collection
+	case storage.StorageTypeMilvus:
+		current = &cfg.Storage.Milvus.Collection
+	case storage.StorageTypeWeaviate:
+		current = &cfg.Storage.Weaviate.Class
+	case storage.StorageTypeClickHouse:
+		current = &cfg.Storage.ClickHouse.Table
+	case storage.StorageTypeLocal:
+		current = &cfg.Storage.Local.Path
+	default:
+		return fmt.Errorf("re-embedding into another collection is not supported for %s storage", cfg.Storage.Type)
+	}
+	if collection == "" || collection == *current {
+		return fmt.Errorf("the target collection must differ from the current one, %q", *current)
+	}
+	*current = collection
+	return nil
+}
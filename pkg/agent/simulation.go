@@ -0,0 +1,25 @@
+package agent
+
+import (
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/simulation"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// NewSimulatedMultiClusterAgent creates a multi-cluster agent observing the synthetic clusters of
+// generator instead of real ones. Detection, storage, notification and metrics run unchanged.
+func NewSimulatedMultiClusterAgent(cfg *config.Config, generator *simulation.Generator) (*MultiClusterAgent, error) {
+	simulated := *cfg
+	simulated.Clusters = generator.Clusters()
+	return newMultiClusterAgent(&simulated, generator.State)
+}
+
+// newSimulatedAgent creates a cluster agent whose state comes from source
+func newSimulatedAgent(cfg *config.Config, source func(clusterID string) types.ClusterState) *Agent {
+	return &Agent{
+		config:       cfg,
+		detector:     newDetector(cfg),
+		observations: make([]types.Observation, 0),
+		source:       source,
+	}
+}
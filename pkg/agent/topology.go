@@ -0,0 +1,242 @@
+package agent
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/metrics"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// topologyWindow is how long anomalies count towards the topology's anomaly counts
+const topologyWindow = time.Hour
+
+// nodePoolLabels are the node labels naming a node's pool, by provider
+var nodePoolLabels = []string{
+	"cloud.google.com/gke-nodepool",
+	"eks.amazonaws.com/nodegroup",
+	"kubernetes.azure.com/agentpool",
+	"karpenter.sh/nodepool",
+	"node.kubernetes.io/instance-type",
+}
+
+// Topology is the fleet as a graph: clusters contain node pools, pools contain nodes, nodes run
+// namespaces and workloads, and namespaces contain workloads
+type Topology struct {
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Window      string         `json:"window"` // Period the anomaly counts cover
+	Nodes       []TopologyNode `json:"nodes"`
+	Edges       []TopologyEdge `json:"edges"`
+}
+
+// TopologyNode is a vertex of the topology graph
+type TopologyNode struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"` // cluster, nodePool, node, namespace or workload
+	Name       string            `json:"name"`
+	Healthy    bool              `json:"healthy"`
+	Anomalies  int               `json:"anomalies"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// TopologyEdge is a directed edge of the topology graph
+type TopologyEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"` // contains or runs
+}
+
+// recordRecentAnomalies keeps the anomalies of the topology window for the topology's counts
+func (m *MultiClusterAgent) recordRecentAnomalies(anomalies []types.Anomaly) {
+	m.recentMu.Lock()
+	defer m.recentMu.Unlock()
+
+	cutoff := time.Now().Add(-topologyWindow)
+	kept := m.recentAnomalies[:0]
+	for _, anomaly := range m.recentAnomalies {
+		if anomaly.Timestamp.After(cutoff) {
+			kept = append(kept, anomaly)
+		}
+	}
+	m.recentAnomalies = append(kept, anomalies...)
+}
+
+// handleTopology serves GET /api/v1/topology
+func (m *MultiClusterAgent) handleTopology(w http.ResponseWriter, r *http.Request) {
+	metrics.WriteJSON(w, http.StatusOK, m.Topology())
+}
+
+// Topology builds the fleet topology from the latest state of every cluster
+func (m *MultiClusterAgent) Topology() Topology {
+	topology := topologyBuilder{
+		Topology: Topology{
+			GeneratedAt: time.Now(),
+			Window:      topologyWindow.String(),
+			Nodes:       []TopologyNode{},
+			Edges:       []TopologyEdge{},
+		},
+		index: make(map[string]int),
+	}
+
+	clusters := m.clusterManager.GetAllClusters()
+	ids := make([]string, 0, len(clusters))
+	for id := range clusters {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		cluster := clusters[id]
+		clusterID := "cluster/" + id
+		attributes := map[string]string{"clusterId": id}
+		for k, v := range cluster.ClusterConfig.Labels {
+			attributes["label."+k] = v
+		}
+		topology.add(TopologyNode{ID: clusterID, Kind: "cluster", Name: cluster.ClusterConfig.Name, Healthy: cluster.Healthy, Attributes: attributes}, "", "")
+		if cluster.State == nil {
+			continue
+		}
+		state := *cluster.State
+
+		for _, node := range state.Nodes {
+			poolID := clusterID + "/pool/" + nodePool(node)
+			topology.add(TopologyNode{ID: poolID, Kind: "nodePool", Name: nodePool(node), Healthy: true}, clusterID, "contains")
+
+			nodeID := clusterID + "/node/" + node.Name
+			healthy := node.Condition == "Ready" && node.ConditionStatus == "True"
+			topology.add(TopologyNode{ID: nodeID, Kind: "node", Name: node.Name, Healthy: healthy, Attributes: map[string]string{
+				"kubeletVersion": node.KubeletVersion,
+			}}, poolID, "contains")
+			if !healthy {
+				topology.unhealthy(poolID)
+			}
+		}
+
+		for _, ns := range state.Namespaces {
+			topology.add(TopologyNode{ID: clusterID + "/ns/" + ns, Kind: "namespace", Name: ns, Healthy: true}, clusterID, "contains")
+		}
+
+		for ns, resources := range state.Resources {
+			nsID := clusterID + "/ns/" + ns
+			for _, pod := range resources.Pods {
+				workload := podWorkload(pod)
+				workloadID := nsID + "/workload/" + workload
+				topology.add(TopologyNode{ID: workloadID, Kind: "workload", Name: workload, Healthy: true}, nsID, "contains")
+				if pod.Status != "Running" && pod.Status != "Succeeded" {
+					topology.unhealthy(workloadID)
+					topology.unhealthy(nsID)
+				}
+				if pod.NodeName != "" {
+					nodeID := clusterID + "/node/" + pod.NodeName
+					topology.edge(nodeID, nsID, "runs")
+					topology.edge(nodeID, workloadID, "runs")
+				}
+			}
+		}
+	}
+
+	// Count recent anomalies on the most specific vertex they can be attributed to
+	m.recentMu.Lock()
+	recent := append([]types.Anomaly(nil), m.recentAnomalies...)
+	m.recentMu.Unlock()
+	cutoff := time.Now().Add(-topologyWindow)
+	for _, anomaly := range recent {
+		if anomaly.Timestamp.Before(cutoff) {
+			continue
+		}
+		topology.count(anomalyVertices(anomaly))
+	}
+
+	return topology.Topology
+}
+
+// topologyBuilder deduplicates vertices and edges while building a topology
+type topologyBuilder struct {
+	Topology
+	index map[string]int // vertex ID -> position in Nodes
+	edges map[TopologyEdge]bool
+}
+
+// add adds a vertex once, linked from its parent
+func (b *topologyBuilder) add(node TopologyNode, parent, kind string) {
+	if _, ok := b.index[node.ID]; !ok {
+		b.index[node.ID] = len(b.Nodes)
+		b.Nodes = append(b.Nodes, node)
+	}
+	if parent != "" {
+		b.edge(parent, node.ID, kind)
+	}
+}
+
+// edge adds an edge once
+func (b *topologyBuilder) edge(source, target, kind string) {
+	if b.edges == nil {
+		b.edges = make(map[TopologyEdge]bool)
+	}
+	edge := TopologyEdge{Source: source, Target: target, Kind: kind}
+	if !b.edges[edge] {
+		b.edges[edge] = true
+		b.Edges = append(b.Edges, edge)
+	}
+}
+
+// unhealthy marks a vertex unhealthy
+func (b *topologyBuilder) unhealthy(id string) {
+	if i, ok := b.index[id]; ok {
+		b.Nodes[i].Healthy = false
+	}
+}
+
+// count adds an anomaly to the first existing vertex of candidates and to its cluster
+func (b *topologyBuilder) count(candidates []string) {
+	for _, id := range candidates {
+		if i, ok := b.index[id]; ok {
+			b.Nodes[i].Anomalies++
+			if id != candidates[len(candidates)-1] {
+				if i, ok := b.index[candidates[len(candidates)-1]]; ok {
+					b.Nodes[i].Anomalies++
+				}
+			}
+			return
+		}
+	}
+}
+
+// anomalyVertices returns the vertex IDs an anomaly may be attributed to, most specific first and
+// the cluster last
+func anomalyVertices(anomaly types.Anomaly) []string {
+	clusterID := "cluster/" + anomaly.ClusterID
+	var candidates []string
+	if anomaly.Namespace != "" {
+		nsID := clusterID + "/ns/" + anomaly.Namespace
+		if kind, name := anomaly.Labels["workload_kind"], anomaly.Labels["workload"]; kind != "" && name != "" {
+			candidates = append(candidates, nsID+"/workload/"+kind+"/"+name)
+		}
+		candidates = append(candidates, nsID)
+	}
+	if anomaly.NodeName != "" {
+		candidates = append(candidates, clusterID+"/node/"+anomaly.NodeName)
+	} else if anomaly.ResourceType == "node" {
+		candidates = append(candidates, clusterID+"/node/"+anomaly.Resource)
+	}
+	return append(candidates, clusterID)
+}
+
+// nodePool returns the pool a node belongs to according to its provider labels
+func nodePool(node types.Node) string {
+	for _, label := range nodePoolLabels {
+		if pool := node.Labels[label]; pool != "" {
+			return pool
+		}
+	}
+	return "default"
+}
+
+// podWorkload returns the "Kind/Name" of the workload controlling a pod, or the pod itself
+func podWorkload(pod types.Pod) string {
+	if pod.OwnerKind != "" && pod.OwnerName != "" {
+		return pod.OwnerKind + "/" + pod.OwnerName
+	}
+	return "Pod/" + pod.Name
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+)
+
+// workerPool runs jobs on a fixed number of goroutines, so slow embeddings and stores do not
+// hold up the observation cycle. Jobs wait in a bounded queue; a nil pool runs nothing.
+type workerPool struct {
+	jobs   chan func()
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+}
+
+// newWorkerPool starts the embedding workers if they are enabled
+func newWorkerPool(cfg config.EmbeddingWorkersConfig) *workerPool {
+	if !cfg.Enabled {
+		return nil
+	}
+	p := &workerPool{jobs: make(chan func(), cfg.QueueSize)}
+	for i := 0; i < cfg.Concurrency; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	return p
+}
+
+// Submit queues a job without blocking, returning false if the pool is nil, closed or full
+func (p *workerPool) Submit(job func()) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops accepting jobs and waits for the queued ones to finish
+func (p *workerPool) Close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
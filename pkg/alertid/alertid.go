@@ -0,0 +1,153 @@
+package alertid
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/google/uuid"
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/notification"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// ID schemes
+const (
+	SchemeUUID        = "uuid"        // Random UUID per occurrence
+	SchemeFingerprint = "fingerprint" // Fingerprint of the anomaly followed by its unix timestamp
+	SchemeTemplate    = "template"    // Go template executed with the anomaly
+)
+
+// Correlation keys added to every anomaly
+const (
+	KeyPagerDutyDedup          = "pagerdutyDedupKey"
+	KeyAlertmanagerFingerprint = "alertmanagerFingerprint"
+)
+
+// Generator assigns IDs and external correlation keys to anomalies
+type Generator struct {
+	scheme                  string
+	template                *template.Template
+	correlationKeys         map[string]*template.Template
+	alertmanagerLabels      map[string]string // Default labels of the Alertmanager notifier
+	alertmanagerFingerprint bool              // Whether anomalies are sent to Alertmanager
+}
+
+// NewGenerator creates a generator from the alert ID configuration. The notification configuration
+// determines whether Alertmanager fingerprints are computed and with which default labels.
+func NewGenerator(cfg config.AlertIDConfig, notificationCfg config.NotificationConfig) (*Generator, error) {
+	funcs := template.FuncMap{
+		"fingerprint": Fingerprint,
+		"uuid":        func() string { return uuid.New().String() },
+	}
+
+	g := &Generator{
+		scheme:                  cfg.Scheme,
+		correlationKeys:         make(map[string]*template.Template, len(cfg.CorrelationKeys)),
+		alertmanagerLabels:      notificationCfg.Alertmanager.DefaultLabels,
+		alertmanagerFingerprint: notificationCfg.Type == "alertmanager",
+	}
+	switch cfg.Scheme {
+	case SchemeUUID, SchemeFingerprint:
+	case SchemeTemplate:
+		tmpl, err := template.New("id").Funcs(funcs).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse alert ID template: %v", err)
+		}
+		g.template = tmpl
+	default:
+		return nil, fmt.Errorf("unsupported alert ID scheme: %s", cfg.Scheme)
+	}
+
+	for name, text := range cfg.CorrelationKeys {
+		tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse correlation key %s: %v", name, err)
+		}
+		g.correlationKeys[name] = tmpl
+	}
+	return g, nil
+}
+
+// Assign sets the ID, fingerprint and correlation keys of an anomaly. Existing correlation keys are kept.
+func (g *Generator) Assign(anomaly *types.Anomaly) error {
+	if anomaly.CorrelationKeys == nil {
+		anomaly.CorrelationKeys = make(map[string]string)
+	}
+	fingerprint := Fingerprint(*anomaly)
+	anomaly.Fingerprint = fingerprint
+	anomaly.CorrelationKeys[KeyPagerDutyDedup] = fingerprint
+	if g.alertmanagerFingerprint {
+		anomaly.CorrelationKeys[KeyAlertmanagerFingerprint] = LabelsFingerprint(notification.AlertmanagerLabels(*anomaly, g.alertmanagerLabels))
+	}
+	for name, tmpl := range g.correlationKeys {
+		value, err := execute(tmpl, *anomaly)
+		if err != nil {
+			return fmt.Errorf("failed to compute correlation key %s: %v", name, err)
+		}
+		if value != "" {
+			anomaly.CorrelationKeys[name] = value
+		}
+	}
+
+	switch g.scheme {
+	case SchemeFingerprint:
+		anomaly.ID = fmt.Sprintf("%s-%d", fingerprint, anomaly.Timestamp.Unix())
+	case SchemeTemplate:
+		id, err := execute(g.template, *anomaly)
+		if err != nil {
+			return fmt.Errorf("failed to compute alert ID: %v", err)
+		}
+		if id == "" {
+			return fmt.Errorf("alert ID template produced an empty ID")
+		}
+		anomaly.ID = id
+	default:
+		anomaly.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// Fingerprint identifies what an anomaly is about independent of when it occurred, so repeated
+// occurrences of the same problem share a fingerprint
+func Fingerprint(anomaly types.Anomaly) string {
+	h := sha256.New()
+	for _, part := range []string{anomaly.ClusterID, anomaly.Type, anomaly.ResourceType, anomaly.Namespace, anomaly.Resource} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// LabelsFingerprint computes the fingerprint Alertmanager assigns to an alert with the given
+// labels (FNV-1a over the sorted label names and values)
+func LabelsFingerprint(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := fnv.New64a()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{255})
+		h.Write([]byte(labels[name]))
+		h.Write([]byte{255})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// execute runs a template with the anomaly and returns the trimmed output
+func execute(tmpl *template.Template, anomaly types.Anomaly) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, anomaly); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,95 @@
+package anomaly
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// DetectDowntimeAnomalies runs a condensed analysis of the gap between since and the current
+// state, covering events recorded in the gap and node usage history if available. It flags
+// incidents that began while the agent was not running; the anomalies are labeled "catchUp"
+// so they can be told apart from live detections.
+func (d *Detector) DetectDowntimeAnomalies(state types.ClusterState, since time.Time, history []types.NodeMetricHistory) []types.Anomaly {
+	d.indexResources(state)
+	var events []types.ClusterEvent
+	for _, event := range state.Events {
+		if event.Timestamp.After(since) {
+			events = append(events, event)
+		}
+	}
+	anomalies := d.detectEventAnomalies(state, events)
+
+	nodes := make(map[string]types.Node, len(state.Nodes))
+	for _, node := range state.Nodes {
+		nodes[node.Name] = node
+	}
+	for _, h := range history {
+		// History of hosts that are not nodes of this cluster, e.g. of another cluster sharing the
+		// Prometheus server, is skipped
+		node, exists := historyNode(nodes, h.Node)
+		if !exists {
+			continue
+		}
+		cpuThreshold, memoryThreshold := d.nodeThresholds(node)
+		if a, ok := d.downtimePeak(state, node.Name, "HighCPUUsage", "CPU", h.CPU, cpuThreshold); ok {
+			anomalies = append(anomalies, a)
+		}
+		if a, ok := d.downtimePeak(state, node.Name, "HighMemoryUsage", "Memory", h.Memory, memoryThreshold); ok {
+			anomalies = append(anomalies, a)
+		}
+	}
+
+	for i := range anomalies {
+		if anomalies[i].Labels == nil {
+			anomalies[i].Labels = make(map[string]string)
+		}
+		anomalies[i].Labels["catchUp"] = "true"
+		anomalies[i].Description = fmt.Sprintf("While huginn was down (since %s): %s", since.Format(time.RFC3339), anomalies[i].Description)
+	}
+	return anomalies
+}
+
+// historyNode returns the node a history series belongs to. The series label holds either the node
+// name or, like the instance label of node-exporter, its host and port.
+func historyNode(nodes map[string]types.Node, host string) (types.Node, bool) {
+	if node, exists := nodes[host]; exists {
+		return node, true
+	}
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		node, exists := nodes[name]
+		return node, exists
+	}
+	return types.Node{}, false
+}
+
+// downtimePeak reports a node anomaly at the peak of samples when any sample breached the threshold
+func (d *Detector) downtimePeak(state types.ClusterState, nodeName, anomalyType, metric string, samples []types.MetricSample, threshold float64) (types.Anomaly, bool) {
+	var peak types.MetricSample
+	breaches := 0
+	for _, s := range samples {
+		if s.Value > threshold {
+			breaches++
+		}
+		if s.Value > peak.Value {
+			peak = s
+		}
+	}
+	if breaches == 0 {
+		return types.Anomaly{}, false
+	}
+
+	return d.newAnomaly(state, anomalyParams{
+		Type:         anomalyType,
+		ResourceType: "node",
+		Resource:     nodeName,
+		NodeName:     nodeName,
+		Severity:     types.SeverityHigh,
+		Description:  fmt.Sprintf("%s usage peaked at %.2f%% on node %s at %s (%d of %d samples above threshold)", metric, peak.Value, nodeName, peak.Timestamp.Format(time.RFC3339), breaches, len(samples)),
+		Value:        peak.Value,
+		Threshold:    threshold,
+		Timestamp:    peak.Timestamp,
+	}), true
+}
@@ -0,0 +1,136 @@
+package anomaly
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/features"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// SetConfigChurn sets the Secret and ConfigMap churn detection configuration
+func (d *Detector) SetConfigChurn(churn config.ConfigChurnConfig) {
+	d.configChurn = churn
+}
+
+// detectConfigChurnAnomalies flags namespaces whose Secrets or ConfigMaps change unusually often
+// and individual objects that are large enough to put pressure on etcd
+func (d *Detector) detectConfigChurnAnomalies(state types.ClusterState) []types.Anomaly {
+	var anomalies []types.Anomaly
+	if !d.flags.Enabled(features.ConfigChurn, state.ClusterID, true) {
+		return anomalies
+	}
+
+	// Kinds whose listing failed are skipped, keeping the previous versions to compare against
+	// next cycle rather than counting every object as deleted and then created again
+	visited := make(map[string]bool)
+	for ns, resources := range state.Resources {
+		if resources.SecretsCollected {
+			visited["Secret:"+ns] = true
+			anomalies = append(anomalies, d.checkConfigChurn(state, ns, "Secret", resources.Secrets)...)
+		}
+		if resources.ConfigMapsCollected {
+			visited["ConfigMap:"+ns] = true
+			anomalies = append(anomalies, d.checkConfigChurn(state, ns, "ConfigMap", resources.ConfigMaps)...)
+		}
+	}
+
+	// The objects of namespaces that no longer exist were deleted with them
+	if len(state.Namespaces) == 0 {
+		return anomalies
+	}
+	existing := make(map[string]bool, len(state.Namespaces))
+	for _, ns := range state.Namespaces {
+		existing[ns] = true
+	}
+	for key := range d.configVersions {
+		kind, ns, _ := strings.Cut(key, ":")
+		if visited[key] || existing[ns] {
+			continue
+		}
+		anomalies = append(anomalies, d.checkConfigChurn(state, ns, kind, nil)...)
+		delete(d.configVersions, key)
+	}
+	return anomalies
+}
+
+// checkConfigChurn compares a namespace's objects of one kind against the previous cycle
+func (d *Detector) checkConfigChurn(state types.ClusterState, ns, kind string, objects []types.ConfigObject) []types.Anomaly {
+	var anomalies []types.Anomaly
+
+	current := make(map[string]string, len(objects))
+	for _, obj := range objects {
+		current[obj.Name] = obj.ResourceVersion
+
+		if d.configChurn.MaxObjectSizeBytes > 0 && obj.SizeBytes > d.configChurn.MaxObjectSizeBytes {
+			key := ns + "/" + obj.Name
+			if !d.shouldSuppressAlert("LargeConfigObject", key, kind) {
+				anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+					Type:         "LargeConfigObject",
+					ResourceType: strings.ToLower(kind),
+					Resource:     obj.Name,
+					Namespace:    ns,
+					Severity:     types.SeverityMedium,
+					Description:  fmt.Sprintf("%s %s is %d bytes (limit: %d bytes)", kind, obj.Name, obj.SizeBytes, d.configChurn.MaxObjectSizeBytes),
+					Value:        float64(obj.SizeBytes),
+					Threshold:    float64(d.configChurn.MaxObjectSizeBytes),
+				}))
+				d.recordAlertTime("LargeConfigObject", key, kind)
+			}
+		}
+	}
+
+	key := kind + ":" + ns
+	previous, seen := d.configVersions[key]
+	d.configVersions[key] = current
+	// Churn needs a previous cycle to compare against
+	if !seen || (len(previous) == 0 && len(current) == 0) {
+		return anomalies
+	}
+
+	changes := 0
+	for name, version := range current {
+		if prev, ok := previous[name]; !ok || prev != version {
+			changes++
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			changes++
+		}
+	}
+
+	resourceID := ns + "/" + strings.ToLower(kind) + "s"
+	value := float64(changes)
+	threshold := float64(d.configChurn.ChurnThreshold)
+	d.recordObservation("namespace", resourceID, "churn", value)
+
+	anomalous := false
+	description := ""
+	if d.sampleCount("namespace", resourceID, "churn") < d.minSamples("churn") {
+		anomalous = value > threshold
+		description = fmt.Sprintf("%d %s changes in namespace %s this cycle (insufficient history for statistical analysis)", changes, kind, ns)
+	} else {
+		mean, stddev, ewma := d.seriesBaseline("namespace", resourceID, "churn")
+		anomalous = isAnomalyHistory(value, mean, stddev, ewma, threshold, d.minStdDev)
+		description = fmt.Sprintf("%d %s changes in namespace %s this cycle (%s)", changes, kind, ns, d.describeBaseline(mean, stddev, ""))
+	}
+
+	if anomalous && changes > 0 && !d.shouldSuppressAlert("ConfigChurn", resourceID, "churn") {
+		anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+			Type:         "ConfigChurn",
+			ResourceType: "namespace",
+			Resource:     ns,
+			Namespace:    ns,
+			Severity:     types.SeverityMedium,
+			Description:  description,
+			Value:        value,
+			Threshold:    threshold,
+			Labels:       map[string]string{"kind": kind},
+		}))
+		d.recordAlertTime("ConfigChurn", resourceID, "churn")
+	}
+
+	return anomalies
+}
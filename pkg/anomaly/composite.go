@@ -0,0 +1,184 @@
+package anomaly
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/rules"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// compiledComposite is a composite anomaly with its parsed conditions
+type compiledComposite struct {
+	config.CompositeConfig
+	conditions []*compiledRule
+	held       map[string][][]time.Time // cluster ID -> per condition, the cycles it held within the window
+}
+
+// SetComposites compiles the composite anomalies. Composites with an invalid condition are ignored.
+func (d *Detector) SetComposites(composites []config.CompositeConfig) {
+	d.composites = nil
+	for _, cc := range composites {
+		if cc.Name == "" || len(cc.Conditions) == 0 {
+			log.Printf("Warning: ignoring composite %q without a name or conditions", cc.Name)
+			continue
+		}
+		composite := &compiledComposite{CompositeConfig: cc, held: make(map[string][][]time.Time)}
+		for i, condition := range cc.Conditions {
+			if _, ok := ruleResourceTypes[condition.Resource]; !ok && condition.Resource != "anomaly" {
+				log.Printf("Warning: ignoring composite %s: condition %d has unknown resource %q", cc.Name, i+1, condition.Resource)
+				composite = nil
+				break
+			}
+			program, err := rules.Compile(condition.Expr)
+			if err != nil {
+				log.Printf("Warning: ignoring composite %s: condition %d is invalid: %v", cc.Name, i+1, err)
+				composite = nil
+				break
+			}
+			composite.conditions = append(composite.conditions, &compiledRule{
+				RuleConfig: config.RuleConfig{Name: fmt.Sprintf("%s[%d]", cc.Name, i+1), Resource: condition.Resource, Expr: condition.Expr},
+				program:    program,
+			})
+		}
+		if composite == nil {
+			continue
+		}
+		if composite.Type == "" {
+			composite.Type = cc.Name
+		}
+		if composite.Severity == "" {
+			composite.Severity = string(types.SeverityHigh)
+		}
+		severity, err := types.ParseSeverity(composite.Severity)
+		if err != nil {
+			log.Printf("Warning: ignoring composite %s: %v", cc.Name, err)
+			continue
+		}
+		composite.Severity = string(severity)
+		d.composites = append(d.composites, composite)
+	}
+}
+
+// detectCompositeAnomalies records which composite conditions hold this cycle and raises a composite
+// once all of them held within its window, in order if it is a sequence. Anomaly conditions see
+// the anomalies raised this cycle; repeats suppressed by deduplication are not raised, so anomaly
+// conditions need a window of several minutes to stay satisfied.
+func (d *Detector) detectCompositeAnomalies(state types.ClusterState, raised []types.Anomaly) []types.Anomaly {
+	var anomalies []types.Anomaly
+	if len(d.composites) == 0 {
+		return anomalies
+	}
+
+	now := state.ObservedAt()
+	targets := make(map[string][]ruleTarget)
+	for _, composite := range d.composites {
+		window := time.Duration(composite.WithinMinutes) * time.Minute
+		held, ok := composite.held[state.ClusterID]
+		if !ok {
+			held = make([][]time.Time, len(composite.conditions))
+		}
+
+		for i, condition := range composite.conditions {
+			// Forget cycles that left the window
+			kept := held[i][:0]
+			for _, t := range held[i] {
+				if now.Sub(t) <= window {
+					kept = append(kept, t)
+				}
+			}
+			held[i] = kept
+
+			if _, ok := targets[condition.Resource]; !ok {
+				if condition.Resource == "anomaly" {
+					for _, anomaly := range raised {
+						targets["anomaly"] = append(targets["anomaly"], ruleTarget{env: map[string]interface{}{"anomaly": anomaly, "cluster": &state}})
+					}
+				} else {
+					targets[condition.Resource] = ruleTargets(&state, condition.Resource)
+				}
+			}
+			if d.conditionHolds(condition, targets[condition.Resource]) {
+				held[i] = append(held[i], now)
+			}
+		}
+		composite.held[state.ClusterID] = held
+
+		if !composite.satisfied(held) || d.shouldSuppressAlert(composite.Type, state.ClusterID, "composite:"+composite.Name) {
+			continue
+		}
+		description := composite.Description
+		if description == "" {
+			exprs := make([]string, 0, len(composite.conditions))
+			for _, condition := range composite.conditions {
+				exprs = append(exprs, condition.Resource+": "+condition.Expr)
+			}
+			joiner := " and "
+			if composite.Sequence {
+				joiner = " then "
+			}
+			description = fmt.Sprintf("Composite %s held in cluster %s within %dm: %s", composite.Name, state.ClusterName, composite.WithinMinutes, strings.Join(exprs, joiner))
+		}
+		anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+			Type:         composite.Type,
+			ResourceType: "cluster",
+			Resource:     state.ClusterName,
+			Severity:     types.Severity(composite.Severity),
+			Description:  description,
+			Labels:       map[string]string{"category": "composite", "composite": composite.Name},
+		}))
+		d.recordAlertTime(composite.Type, state.ClusterID, "composite:"+composite.Name)
+
+		// A new occurrence needs all conditions to hold again
+		delete(composite.held, state.ClusterID)
+	}
+	return anomalies
+}
+
+// conditionHolds reports whether a condition matches any of its targets
+func (d *Detector) conditionHolds(condition *compiledRule, targets []ruleTarget) bool {
+	for _, target := range targets {
+		matched, err := condition.program.Match(target.env)
+		if err != nil {
+			if !condition.failing {
+				log.Printf("Warning: composite condition %s failed on %s %s: %v", condition.Name, condition.Resource, target.resource, err)
+			}
+			condition.failing = true
+			continue
+		}
+		condition.failing = false
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// satisfied reports whether every condition held within the window and, for a sequence, whether
+// they held in order: each condition at or after a cycle the previous one held in
+func (c *compiledComposite) satisfied(held [][]time.Time) bool {
+	var after time.Time
+	for _, times := range held {
+		if len(times) == 0 {
+			return false
+		}
+		if !c.Sequence {
+			continue
+		}
+		found := false
+		for _, t := range times {
+			if !t.Before(after) {
+				after = t
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
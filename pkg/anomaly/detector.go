@@ -3,9 +3,11 @@ package anomaly
 import (
 	"fmt"
 	"math"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/features"
 	"github.com/rodolfo-mora/huginn/pkg/types"
 )
 
@@ -14,25 +16,93 @@ type Detector struct {
 	cpuThreshold    float64
 	memoryThreshold float64
 	podRestarts     int
-	history         []MetricObservation
-	maxHistorySize  int
+	history         map[string]*metricSeries // key: "resourceType/resourceID/metricType"
+	historyPrunedAt time.Time
+	maxHistorySize  int                 // Observations kept per multivariate window
+	seriesSize      int                 // Observations kept per series
+	historyMu       sync.RWMutex        // Guards history and trends against API readers; detection is the only writer
+	recording       bool                // Whether observations are kept for TakeObservations
+	recorded        []MetricObservation // Observations since the last TakeObservations
+	observedAt      time.Time           // Measurement time of the state being analyzed
 	debug           bool
 	minStdDev       float64
+	minHistory      map[string]int         // Samples per metric type required before statistical analysis
+	disabled        map[string]bool        // Detectors that are not evaluated
+	eventReasons    []eventReason          // Problematic event reasons
+	occurrences     map[string]*occurrence // Reported anomalies, key: "clusterID:type:namespace/resource"
+	statistics      string                 // StatisticsStandard or StatisticsRobust
 	// Statistical measures
 	cpuStats     *MetricStats
 	memoryStats  *MetricStats
 	restartStats *MetricStats
 	// Alert deduplication
 	recentAlerts map[string]time.Time // key: "type:resource:metric", value: last alert time
+	// Hysteresis and flap suppression of threshold anomalies, key: "type:resource:metric"
+	hysteresis          config.HysteresisConfig
+	alertStates         map[string]*alertState
+	alertStatesPrunedAt time.Time
+	// Policy checks
+	imagePolicy    config.ImagePolicyConfig
+	securityPolicy config.SecurityPolicyConfig
+	drift          config.DriftConfig
+	// Secret/ConfigMap churn tracking
+	configChurn    config.ConfigChurnConfig
+	configVersions map[string]map[string]string // key: "kind:namespace", value: object name -> resource version
+	// PVC checks, key: "namespace/name", value: consecutive Pending cycles
+	pvc              config.PVCConfig
+	pvcPendingCycles map[string]int
+	// PV hygiene checks, key: volume name, value: consecutive Released cycles
+	pv               config.PVConfig
+	pvReleasedCycles map[string]int
+	// Pod restart tracking, key: "namespace/pod"
+	restartWindow  time.Duration
+	restartCounts  map[string]*restartHistory
+	restartRates   map[string]int // Restarts within the window as of the current cycle
+	restartsPrimed bool           // Whether a previous cycle's restart counts are known
+	// Labeled anomalies, key: "type:namespace/resource", value: false minus true positives
+	feedback       map[string]int
+	feedbackConfig config.FeedbackConfig
+	feedbackMu     sync.Mutex // Feedback is recorded by API handlers
+	// Grace periods after the first observation and after resource creation
+	warmUp    config.WarmUpConfig
+	startedAt time.Time
+	// Node filesystem usage and DiskPressure checks
+	diskThreshold   float64
+	diskAlpha       float64
+	diskPressureFor time.Duration
+	// Multivariate detection, key: "clusterID:node" or "clusterID:pod", value: training window
+	multivariate        config.MultivariateConfig
+	multivariateHistory map[string][][]float64
+	// Rate-of-change detection and capacity forecasting
+	slope    config.SlopeConfig
+	forecast config.ForecastConfig
+	// Severity scoring of threshold anomalies
+	severity config.SeverityConfig
+	// Downsampled long-term history, key: "resourceType/resourceID/metricType"
+	trendHistory   config.TrendHistoryConfig
+	trends         map[string][]*trendTier
+	trendsPrunedAt time.Time
+	// Custom rules over the collected state
+	rules      []*compiledRule
+	composites []*compiledComposite
+	// Cluster health score
+	health     config.HealthScoreConfig
+	lastHealth HealthScore
+	// Nodes of the resources in the analyzed state
+	resources resourceIndex
+	// Per-resource threshold overrides
+	thresholdOverrides []thresholdOverride
+	// Feature flags gating detectors per cluster (nil uses each detector's configuration)
+	flags *features.Flags
 }
 
 // MetricObservation holds a single metric sample for history-based analysis
 type MetricObservation struct {
-	Timestamp    time.Time
-	ResourceType string // "node", "pod", "namespace"
-	ResourceID   string // node name, pod name, etc.
-	MetricType   string // "cpu", "memory", "restarts"
-	Value        float64
+	Timestamp    time.Time `json:"timestamp"`
+	ResourceType string    `json:"resourceType"` // "node", "pod", "namespace"
+	ResourceID   string    `json:"resourceId"`   // node name, pod name, etc.
+	MetricType   string    `json:"metric"`       // "cpu", "memory", "restarts"
+	Value        float64   `json:"value"`
 }
 
 // MetricStats holds statistical measures for a metric
@@ -59,8 +129,10 @@ func NewDetector(cpuThreshold, memoryThreshold float64, podRestarts int, maxHist
 		memoryThreshold: memoryThreshold,
 		podRestarts:     podRestarts,
 		maxHistorySize:  maxHistorySize,
+		seriesSize:      defaultSeriesSize,
 		debug:           debug,
 		minStdDev:       minStdDev,
+		statistics:      StatisticsStandard,
 		cpuStats: &MetricStats{
 			alpha: cpuAlpha,
 		},
@@ -70,7 +142,13 @@ func NewDetector(cpuThreshold, memoryThreshold float64, podRestarts int, maxHist
 		restartStats: &MetricStats{
 			alpha: restartAlpha,
 		},
-		recentAlerts: make(map[string]time.Time),
+		recentAlerts:        make(map[string]time.Time),
+		alertStates:         make(map[string]*alertState),
+		configVersions:      make(map[string]map[string]string),
+		multivariateHistory: make(map[string][][]float64),
+		trends:              make(map[string][]*trendTier),
+		history:             make(map[string]*metricSeries),
+		occurrences:         make(map[string]*occurrence),
 	}
 }
 
@@ -81,15 +159,22 @@ func (d *Detector) SetThresholds(cpu, memory float64, podRestarts int) {
 	d.podRestarts = podRestarts
 }
 
+// SetFeatureFlags sets the feature flags used to enable detectors per cluster
+func (d *Detector) SetFeatureFlags(flags *features.Flags) {
+	d.flags = flags
+}
+
 // SetMaxHistorySize sets the maximum history size
 func (d *Detector) SetMaxHistorySize(size int) {
 	d.maxHistorySize = size
 }
 
-func (d *Detector) PrintHistory() {
-	for _, obs := range d.history {
-		fmt.Printf("%s/%s %s: %f\n", obs.ResourceType, obs.ResourceID, obs.MetricType, obs.Value)
-	}
+// SetSeriesHistorySize sets how many observations are kept per series; memory grows with the
+// number of series times this size
+func (d *Detector) SetSeriesHistorySize(size int) {
+	d.historyMu.Lock()
+	defer d.historyMu.Unlock()
+	d.seriesSize = size
 }
 
 // updateStats updates statistical measures for a metric
@@ -130,32 +215,6 @@ func (d *Detector) PrintHistory() {
 // 	return zScore > 3 || value > threshold || math.Abs(value-s.ewma) > 2*s.stdDev
 // }
 
-// recordObservation records a single metric observation with resource context
-func (d *Detector) recordObservation(resourceType, resourceID, metricType string, value float64) {
-	obs := MetricObservation{
-		Timestamp:    time.Now(),
-		ResourceType: resourceType,
-		ResourceID:   resourceID,
-		MetricType:   metricType,
-		Value:        value,
-	}
-	d.history = append(d.history, obs)
-	if len(d.history) > d.maxHistorySize {
-		d.history = d.history[len(d.history)-d.maxHistorySize:]
-	}
-}
-
-// GetMetricHistory extracts a slice of float64 values for a specific resource and metric type
-func (d *Detector) GetMetricHistory(resourceType, resourceID, metricType string) []float64 {
-	values := make([]float64, 0, len(d.history))
-	for _, obs := range d.history {
-		if obs.ResourceType == resourceType && obs.ResourceID == resourceID && obs.MetricType == metricType {
-			values = append(values, obs.Value)
-		}
-	}
-	return values
-}
-
 // ComputeStats calculates mean, stddev, and ewma for a metric from history
 func (d *Detector) ComputeStats(values []float64, alpha float64) (mean, stddev, ewma float64) {
 	if len(values) == 0 {
@@ -222,7 +281,7 @@ type anomalyParams struct {
 	Resource             string
 	Namespace            string
 	NodeName             string
-	Severity             string
+	Severity             types.Severity
 	Description          string
 	Value                float64
 	Threshold            float64
@@ -233,11 +292,25 @@ type anomalyParams struct {
 	Metadata             map[string]interface{}
 }
 
-// newAnomaly builds a types.Anomaly with cluster context and sensible defaults
+// newAnomaly builds a types.Anomaly with cluster context and sensible defaults. Anomalies without
+// a node get the node their resource is or runs on, and the namespaces on it; node and pod
+// anomalies without events get the recent events of their resource.
 func (d *Detector) newAnomaly(state types.ClusterState, p anomalyParams) types.Anomaly {
 	ts := p.Timestamp
 	if ts.IsZero() {
-		ts = time.Now()
+		ts = state.ObservedAt()
+	}
+	nodeName := p.NodeName
+	if nodeName == "" {
+		nodeName = d.resourceNode(p.ResourceType, p.Namespace, p.Resource)
+	}
+	namespacesOnThisNode := p.NamespacesOnThisNode
+	if namespacesOnThisNode == "" && nodeName != "" {
+		namespacesOnThisNode = d.resources.nodeNamespaces[nodeName]
+	}
+	events := p.Events
+	if events == nil {
+		events = relatedEvents(state, p.ResourceType, p.Namespace, p.Resource, ts)
 	}
 	return types.Anomaly{
 		ClusterID:            state.ClusterID,
@@ -246,43 +319,46 @@ func (d *Detector) newAnomaly(state types.ClusterState, p anomalyParams) types.A
 		ResourceType:         p.ResourceType,
 		Resource:             p.Resource,
 		Namespace:            p.Namespace,
-		NodeName:             p.NodeName,
+		NodeName:             nodeName,
 		Severity:             p.Severity,
 		Description:          p.Description,
 		Value:                p.Value,
 		Threshold:            p.Threshold,
 		Timestamp:            ts,
-		NamespacesOnThisNode: p.NamespacesOnThisNode,
+		NamespacesOnThisNode: namespacesOnThisNode,
 		Labels:               p.Labels,
-		Events:               p.Events,
+		Events:               events,
 		Metadata:             p.Metadata,
+		Cycle:                state.Cycle,
+		CycleStart:           state.CycleStart,
+		CycleEnd:             state.CycleEnd,
 	}
 }
 
 // DetectAnomalies checks for anomalies in the current state using history-based stats
 func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 	var anomalies []types.Anomaly
+	// Observations are recorded at the time the state was measured
+	d.observedAt = state.ObservedAt()
+	d.indexResources(state)
 
 	// For each node, record and analyze metrics
 	for _, node := range state.Nodes {
 		// Use pre-calculated percentage values instead of raw resource values
 		cpuUsagePercent := node.CPUUsagePercent
 		memoryUsagePercent := node.MemoryUsagePercent
+		cpuThreshold, memoryThreshold := d.nodeThresholds(node)
 
 		d.recordObservation("node", node.Name, "cpu", cpuUsagePercent)
 		d.recordObservation("node", node.Name, "memory", memoryUsagePercent)
 
 		// Build namespace information for anomaly descriptions
-		namespacesInfo := ""
-		if len(node.Namespaces) > 0 {
-			namespacesInfo = fmt.Sprintf(" namespaces on this node: %s)", strings.Join(node.Namespaces, ", "))
-		}
+		namespacesInfo := nodeNamespacesInfo(node)
 
-		cpuVals := d.GetMetricHistory("node", node.Name, "cpu")
 		// Require minimum history for statistical analysis
-		if len(cpuVals) < 5 {
+		if d.sampleCount("node", node.Name, "cpu") < d.minSamples("cpu") {
 			// With insufficient history, only check absolute threshold
-			if cpuUsagePercent > d.cpuThreshold {
+			if d.detects(DetectorCPU) && d.settle("HighCPUUsage", node.Name, "cpu", cpuUsagePercent, cpuThreshold, cpuUsagePercent > cpuThreshold) {
 				// Check if we should suppress this alert
 				if !d.shouldSuppressAlert("HighCPUUsage", node.Name, "cpu") {
 					anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
@@ -290,11 +366,11 @@ func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 						ResourceType:         "node",
 						Resource:             node.Name,
 						NodeName:             node.Name,
-						Severity:             "High",
+						Severity:             d.scoreSeverity("cpu", cpuUsagePercent, 0, 0, types.SeverityHigh),
 						Description:          fmt.Sprintf("CPU usage is %.2f%% (insufficient history for statistical analysis)", cpuUsagePercent),
 						NamespacesOnThisNode: namespacesInfo,
 						Value:                cpuUsagePercent,
-						Threshold:            d.cpuThreshold,
+						Threshold:            cpuThreshold,
 					}))
 					d.recordAlertTime("HighCPUUsage", node.Name, "cpu")
 				}
@@ -302,11 +378,12 @@ func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 			continue
 		}
 
-		cpuMean, cpuStd, cpuEwma := d.ComputeStats(cpuVals, d.cpuStats.alpha)
+		cpuMean, cpuStd, cpuEwma := d.seriesBaseline("node", node.Name, "cpu")
 		if d.debug {
-			d.DebugAnomalyCheck("node", node.Name, "cpu", cpuUsagePercent, d.cpuThreshold)
+			d.DebugAnomalyCheck("node", node.Name, "cpu", cpuUsagePercent, cpuThreshold)
 		}
-		if isAnomalyHistory(cpuUsagePercent, cpuMean, cpuStd, cpuEwma, d.cpuThreshold, d.minStdDev) {
+		breached := isAnomalyHistory(cpuUsagePercent, cpuMean, cpuStd, cpuEwma, cpuThreshold, d.minStdDev)
+		if d.detects(DetectorCPU) && d.settle("HighCPUUsage", node.Name, "cpu", cpuUsagePercent, cpuThreshold, breached) {
 			// Check if we should suppress this alert
 			if !d.shouldSuppressAlert("HighCPUUsage", node.Name, "cpu") {
 				anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
@@ -314,30 +391,32 @@ func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 					ResourceType:         "node",
 					Resource:             node.Name,
 					NodeName:             node.Name,
-					Severity:             "High",
-					Description:          fmt.Sprintf("CPU usage is %.2f%% on node %s (mean: %.2f%%, stddev: %.2f%%)%s", cpuUsagePercent, node.Name, cpuMean, cpuStd, namespacesInfo),
+					Severity:             d.scoreSeverity("cpu", cpuUsagePercent, cpuMean, cpuStd, types.SeverityHigh),
+					Description:          fmt.Sprintf("CPU usage is %.2f%% on node %s (%s)%s", cpuUsagePercent, node.Name, d.describeBaseline(cpuMean, cpuStd, "%"), namespacesInfo),
 					NamespacesOnThisNode: namespacesInfo,
 					Value:                cpuUsagePercent,
-					Threshold:            d.cpuThreshold,
+					Threshold:            cpuThreshold,
 				}))
 				d.recordAlertTime("HighCPUUsage", node.Name, "cpu")
 			}
 		}
 
-		memoryVals := d.GetMetricHistory("node", node.Name, "memory")
 		// Require minimum history for statistical analysis
-		if len(memoryVals) < 5 {
+		if d.sampleCount("node", node.Name, "memory") < d.minSamples("memory") {
 			// With insufficient history, only check absolute threshold
-			if memoryUsagePercent > d.memoryThreshold {
+			if d.detects(DetectorMemory) && d.settle("HighMemoryUsage", node.Name, "memory", memoryUsagePercent, memoryThreshold, memoryUsagePercent > memoryThreshold) {
 				// Check if we should suppress this alert
 				if !d.shouldSuppressAlert("HighMemoryUsage", node.Name, "memory") {
 					anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
-						Type:        "HighMemoryUsage",
-						Resource:    node.Name,
-						Severity:    "High",
-						Description: fmt.Sprintf("Memory usage is %.2f%% (insufficient history for statistical analysis)%s", memoryUsagePercent, namespacesInfo),
-						Value:       memoryUsagePercent,
-						Threshold:   d.memoryThreshold,
+						Type:                 "HighMemoryUsage",
+						ResourceType:         "node",
+						Resource:             node.Name,
+						NodeName:             node.Name,
+						Severity:             d.scoreSeverity("memory", memoryUsagePercent, 0, 0, types.SeverityHigh),
+						Description:          fmt.Sprintf("Memory usage is %.2f%% (insufficient history for statistical analysis)%s", memoryUsagePercent, namespacesInfo),
+						NamespacesOnThisNode: namespacesInfo,
+						Value:                memoryUsagePercent,
+						Threshold:            memoryThreshold,
 					}))
 					d.recordAlertTime("HighMemoryUsage", node.Name, "memory")
 				}
@@ -345,39 +424,54 @@ func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 			continue
 		}
 
-		memMean, memStd, memEwma := d.ComputeStats(memoryVals, d.memoryStats.alpha)
+		memMean, memStd, memEwma := d.seriesBaseline("node", node.Name, "memory")
 		if d.debug {
-			d.DebugAnomalyCheck("node", node.Name, "memory", memoryUsagePercent, d.memoryThreshold)
+			d.DebugAnomalyCheck("node", node.Name, "memory", memoryUsagePercent, memoryThreshold)
 		}
-		if isAnomalyHistory(memoryUsagePercent, memMean, memStd, memEwma, d.memoryThreshold, d.minStdDev) {
+		breached = isAnomalyHistory(memoryUsagePercent, memMean, memStd, memEwma, memoryThreshold, d.minStdDev)
+		if d.detects(DetectorMemory) && d.settle("HighMemoryUsage", node.Name, "memory", memoryUsagePercent, memoryThreshold, breached) {
 			// Check if we should suppress this alert
 			if !d.shouldSuppressAlert("HighMemoryUsage", node.Name, "memory") {
 				anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
-					Type:         "HighMemoryUsage",
-					ResourceType: "node",
-					Resource:     node.Name,
-					NodeName:     node.Name,
-					Severity:     "High",
-					Description:  fmt.Sprintf("Memory usage is %.2f%% (mean: %.2f%%, stddev: %.2f%%)%s", memoryUsagePercent, memMean, memStd, namespacesInfo),
-					Value:        memoryUsagePercent,
-					Threshold:    d.memoryThreshold,
+					Type:                 "HighMemoryUsage",
+					ResourceType:         "node",
+					Resource:             node.Name,
+					NodeName:             node.Name,
+					Severity:             d.scoreSeverity("memory", memoryUsagePercent, memMean, memStd, types.SeverityHigh),
+					Description:          fmt.Sprintf("Memory usage is %.2f%% (%s)%s", memoryUsagePercent, d.describeBaseline(memMean, memStd, "%"), namespacesInfo),
+					NamespacesOnThisNode: namespacesInfo,
+					Value:                memoryUsagePercent,
+					Threshold:            memoryThreshold,
 				}))
 				d.recordAlertTime("HighMemoryUsage", node.Name, "memory")
 			}
 		}
 	}
 
+	// Check node filesystems
+	anomalies = append(anomalies, d.detectDiskAnomalies(state)...)
+
+	// Turn cumulative restart counts into restarts within the window
+	d.updateRestartRates(state)
+
+	// Check for steady growth of node metrics
+	anomalies = append(anomalies, d.detectGrowthAnomalies(state)...)
+
+	// Predict nodes running out of capacity
+	anomalies = append(anomalies, d.detectCapacityForecasts(state)...)
+
 	// For each pod, record and analyze restarts
 	for ns, resources := range state.Resources {
 		for _, pod := range resources.Pods {
-			restartCount := float64(pod.RestartCount)
+			restarts := d.restartRates[ns+"/"+pod.Name]
+			restartCount := float64(restarts)
+			restartThreshold := d.podRestartThreshold(pod, ns)
 			d.recordObservation("pod", pod.Name, "restarts", restartCount)
-			restartVals := d.GetMetricHistory("pod", pod.Name, "restarts")
 
 			// Require minimum history for statistical analysis
-			if len(restartVals) < 3 {
+			if d.sampleCount("pod", pod.Name, "restarts") < d.minSamples("restarts") {
 				// With insufficient history, only check absolute threshold
-				if restartCount > float64(d.podRestarts) {
+				if d.detects(DetectorRestarts) && d.settle("HighPodRestarts", pod.Name, "restarts", restartCount, float64(restartThreshold), restartCount > float64(restartThreshold)) {
 					// Check if we should suppress this alert
 					if !d.shouldSuppressAlert("HighPodRestarts", pod.Name, "restarts") {
 						anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
@@ -386,10 +480,10 @@ func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 							Resource:     pod.Name,
 							Namespace:    ns,
 							NodeName:     pod.NodeName,
-							Severity:     "Medium",
-							Description:  fmt.Sprintf("Pod has restarted %d times (insufficient history for statistical analysis)", pod.RestartCount),
+							Severity:     d.scoreSeverity("restarts", restartCount, 0, 0, types.SeverityMedium),
+							Description:  fmt.Sprintf("Pod has restarted %d times in the last %s (insufficient history for statistical analysis)", restarts, d.restartWindow),
 							Value:        restartCount,
-							Threshold:    float64(d.podRestarts),
+							Threshold:    float64(restartThreshold),
 						}))
 						d.recordAlertTime("HighPodRestarts", pod.Name, "restarts")
 					}
@@ -397,8 +491,9 @@ func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 				continue
 			}
 
-			rMean, rStd, rEwma := d.ComputeStats(restartVals, d.restartStats.alpha)
-			if isAnomalyHistory(restartCount, rMean, rStd, rEwma, float64(d.podRestarts), d.minStdDev) {
+			rMean, rStd, rEwma := d.seriesBaseline("pod", pod.Name, "restarts")
+			breached := isAnomalyHistory(restartCount, rMean, rStd, rEwma, float64(restartThreshold), d.minStdDev)
+			if d.detects(DetectorRestarts) && d.settle("HighPodRestarts", pod.Name, "restarts", restartCount, float64(restartThreshold), breached) {
 				// Check if we should suppress this alert
 				if !d.shouldSuppressAlert("HighPodRestarts", pod.Name, "restarts") {
 					anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
@@ -407,15 +502,15 @@ func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 						Resource:     pod.Name,
 						Namespace:    ns,
 						NodeName:     pod.NodeName,
-						Severity:     "Medium",
-						Description:  fmt.Sprintf("Pod has restarted %d times (mean: %.2f, stddev: %.2f)", pod.RestartCount, rMean, rStd),
+						Severity:     d.scoreSeverity("restarts", restartCount, rMean, rStd, types.SeverityMedium),
+						Description:  fmt.Sprintf("Pod has restarted %d times in the last %s (%s)", restarts, d.restartWindow, d.describeBaseline(rMean, rStd, "")),
 						Value:        restartCount,
-						Threshold:    float64(d.podRestarts),
+						Threshold:    float64(restartThreshold),
 					}))
 					d.recordAlertTime("HighPodRestarts", pod.Name, "restarts")
 				}
 			}
-			if pod.Status != "Running" {
+			if d.detects(DetectorPodStatus) && pod.Status != "Running" {
 				// Check if we should suppress this alert
 				if !d.shouldSuppressAlert("PodNotRunning", pod.Name, "status") {
 					anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
@@ -424,7 +519,7 @@ func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 						Resource:     pod.Name,
 						Namespace:    ns,
 						NodeName:     pod.NodeName,
-						Severity:     "High",
+						Severity:     types.SeverityHigh,
 						Description:  fmt.Sprintf("Pod is in %s state", pod.Status),
 					}))
 					d.recordAlertTime("PodNotRunning", pod.Name, "status")
@@ -434,7 +529,48 @@ func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 	}
 
 	// Check for problematic events
-	for _, event := range state.Events {
+	if d.detects(DetectorEvents) {
+		anomalies = append(anomalies, d.detectEventAnomalies(state, state.Events)...)
+	}
+
+	// Check container image provenance
+	anomalies = append(anomalies, d.detectImagePolicyAnomalies(state)...)
+
+	// Check privileged workloads
+	anomalies = append(anomalies, d.detectSecurityPolicyAnomalies(state)...)
+
+	// Check PersistentVolumeClaims stuck Pending or Lost
+	anomalies = append(anomalies, d.detectPVCAnomalies(state)...)
+
+	// Check PersistentVolumes that are failed, stuck or accumulating
+	anomalies = append(anomalies, d.detectPVAnomalies(state)...)
+
+	// Check Secret and ConfigMap churn
+	anomalies = append(anomalies, d.detectConfigChurnAnomalies(state)...)
+
+	// Check unusual combinations of metrics
+	anomalies = append(anomalies, d.detectMultivariateAnomalies(state)...)
+
+	// Evaluate custom rules
+	anomalies = append(anomalies, d.detectRuleAnomalies(state)...)
+
+	// Evaluate composite conditions, including the anomalies raised so far
+	anomalies = append(anomalies, d.detectCompositeAnomalies(state, anomalies)...)
+
+	// Score the cluster's health, including the anomalies raised so far
+	anomalies = append(anomalies, d.detectHealthDrop(state, anomalies)...)
+
+	// Drop anomalies of the warm-up period and of freshly created resources
+	anomalies = d.filterWarmUp(state, anomalies)
+
+	d.scoreAnomalies(anomalies)
+	return anomalies
+}
+
+// detectEventAnomalies checks events for errors, recurring warnings and known problematic reasons
+func (d *Detector) detectEventAnomalies(state types.ClusterState, events []types.ClusterEvent) []types.Anomaly {
+	var anomalies []types.Anomaly
+	for _, event := range events {
 		// Check for error events
 		if event.Severity == "Error" {
 			anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
@@ -442,7 +578,7 @@ func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 				ResourceType: "event",
 				Resource:     event.Resource,
 				Namespace:    event.Namespace,
-				Severity:     "High",
+				Severity:     types.SeverityHigh,
 				Description:  fmt.Sprintf("Error event: %s - %s (count: %d)", event.Reason, event.Message, event.Count),
 				Timestamp:    event.Timestamp,
 			}))
@@ -455,37 +591,27 @@ func (d *Detector) DetectAnomalies(state types.ClusterState) []types.Anomaly {
 				ResourceType: "event",
 				Resource:     event.Resource,
 				Namespace:    event.Namespace,
-				Severity:     "Medium",
+				Severity:     types.SeverityMedium,
 				Description:  fmt.Sprintf("Recurring warning: %s - %s (count: %d)", event.Reason, event.Message, event.Count),
 				Timestamp:    event.Timestamp,
 			}))
 		}
 
-		// Check for specific problematic event types
-		problematicReasons := []string{
-			"FailedScheduling",
-			"FailedMount",
-			"FailedAttachVolume",
-			"FailedCreate",
-			"FailedDelete",
-			"BackOff",
-			"CrashLoopBackOff",
-			"ImagePullBackOff",
-		}
-
-		for _, reason := range problematicReasons {
-			if event.Reason == reason {
-				anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
-					Type:         "ClusterEvent",
-					ResourceType: "event",
-					Resource:     event.Resource,
-					Namespace:    event.Namespace,
-					Severity:     "High",
-					Description:  fmt.Sprintf("Problematic event: %s - %s (count: %d)", event.Reason, event.Message, event.Count),
-					Timestamp:    event.Timestamp,
-				}))
-				break
+		// Check for specific problematic event reasons; the first matching entry wins
+		for _, reason := range d.eventReasons {
+			if !reason.matches(event) {
+				continue
 			}
+			anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+				Type:         "ClusterEvent",
+				ResourceType: "event",
+				Resource:     event.Resource,
+				Namespace:    event.Namespace,
+				Severity:     reason.severity,
+				Description:  fmt.Sprintf("Problematic event: %s - %s (count: %d)", event.Reason, event.Message, event.Count),
+				Timestamp:    event.Timestamp,
+			}))
+			break
 		}
 	}
 
@@ -517,9 +643,8 @@ func (d *Detector) DebugAnomalyCheck(resourceType, resourceID, metricType string
 		return
 	}
 
-	mean, stddev, ewma := d.ComputeStats(history, d.getAlphaForMetric(metricType))
-	fmt.Printf("Mean: %.2f\n", mean)
-	fmt.Printf("StdDev: %.2f\n", stddev)
+	mean, stddev, ewma := d.baseline(history, d.getAlphaForMetric(metricType))
+	fmt.Printf("Baseline (%s): %s\n", d.statistics, d.describeBaseline(mean, stddev, ""))
 	fmt.Printf("EWMA: %.2f\n", ewma)
 
 	zScore := math.Abs((currentValue - mean) / stddev)
@@ -542,7 +667,8 @@ func (d *Detector) DebugAnomalyCheck(resourceType, resourceID, metricType string
 	fmt.Printf("================================\n")
 }
 
-// getAlphaForMetric returns the appropriate alpha value for a metric type
+// getAlphaForMetric returns the appropriate alpha value for a metric type. Callers outside the
+// detection goroutine must hold d.historyMu, which guards the alphas against SetAlpha.
 func (d *Detector) getAlphaForMetric(metricType string) float64 {
 	switch metricType {
 	case "cpu":
@@ -551,11 +677,33 @@ func (d *Detector) getAlphaForMetric(metricType string) float64 {
 		return d.memoryStats.alpha
 	case "restarts":
 		return d.restartStats.alpha
+	case "disk":
+		if d.diskAlpha > 0 {
+			return d.diskAlpha
+		}
+		return 0.3
 	default:
 		return 0.3
 	}
 }
 
+// SetMinHistory sets the number of samples per metric type required before statistical analysis
+func (d *Detector) SetMinHistory(minHistory map[string]int) {
+	d.minHistory = minHistory
+}
+
+// minSamples returns the number of samples of a metric type required before statistical analysis;
+// with fewer only the absolute threshold is checked
+func (d *Detector) minSamples(metricType string) int {
+	if samples, ok := d.minHistory[metricType]; ok {
+		return samples
+	}
+	if metricType == "restarts" {
+		return 3
+	}
+	return 5
+}
+
 // shouldSuppressAlert checks if an alert should be suppressed due to recent duplicates
 func (d *Detector) shouldSuppressAlert(alertType, resource, metric string) bool {
 	key := fmt.Sprintf("%s:%s:%s", alertType, resource, metric)
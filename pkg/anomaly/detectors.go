@@ -0,0 +1,32 @@
+package anomaly
+
+import "log"
+
+// Names of the core detectors that can be disabled. Metrics are still recorded for disabled
+// detectors, so history, trends and the detectors built on them keep working.
+const (
+	DetectorCPU       = "cpu"       // HighCPUUsage
+	DetectorMemory    = "memory"    // HighMemoryUsage
+	DetectorDisk      = "disk"      // HighDiskUsage
+	DetectorRestarts  = "restarts"  // HighPodRestarts
+	DetectorPodStatus = "podStatus" // PodNotRunning
+	DetectorEvents    = "events"    // ClusterEvent
+)
+
+// SetDisabledDetectors sets the core detectors that are not evaluated. Unknown names are ignored.
+func (d *Detector) SetDisabledDetectors(names []string) {
+	d.disabled = make(map[string]bool, len(names))
+	for _, name := range names {
+		switch name {
+		case DetectorCPU, DetectorMemory, DetectorDisk, DetectorRestarts, DetectorPodStatus, DetectorEvents:
+			d.disabled[name] = true
+		default:
+			log.Printf("Warning: unknown detector %q in disabledDetectors", name)
+		}
+	}
+}
+
+// detects reports whether a core detector is evaluated
+func (d *Detector) detects(name string) bool {
+	return !d.disabled[name]
+}
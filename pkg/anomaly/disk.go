@@ -0,0 +1,99 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// SetDisk sets the node filesystem usage threshold, its EWMA smoothing factor and how long the
+// DiskPressure condition may hold before it is flagged
+func (d *Detector) SetDisk(threshold, alpha float64, pressureFor time.Duration) {
+	d.diskThreshold = threshold
+	d.diskAlpha = alpha
+	d.diskPressureFor = pressureFor
+}
+
+// detectDiskAnomalies records the usage of the fuller of each node's filesystems (nodefs or
+// imagefs) and flags nodes whose usage is anomalous or that have reported DiskPressure for too long
+func (d *Detector) detectDiskAnomalies(state types.ClusterState) []types.Anomaly {
+	var anomalies []types.Anomaly
+	for _, node := range state.Nodes {
+		usage, filesystem := node.NodeFSUsagePercent, "nodefs"
+		if node.ImageFSUsagePercent > usage {
+			usage, filesystem = node.ImageFSUsagePercent, "imagefs"
+		}
+		// Nodes without filesystem stats (not collected) only report DiskPressure
+		hasStats := usage > 0
+		if hasStats {
+			d.recordObservation("node", node.Name, "disk", usage)
+		}
+		if !d.detects(DetectorDisk) || d.diskThreshold <= 0 {
+			continue
+		}
+		threshold := math.Min(100, d.diskThreshold*d.sensitivity("HighDiskUsage", "", node.Name))
+
+		var pressureFor time.Duration
+		if node.DiskPressure && !node.DiskPressureSince.IsZero() {
+			pressureFor = d.observedAt.Sub(node.DiskPressureSince).Round(time.Minute)
+		}
+		pressure := node.DiskPressure && pressureFor >= d.diskPressureFor
+
+		var high bool
+		var center, spread float64
+		var description string
+		if hasStats {
+			if d.sampleCount("node", node.Name, "disk") < d.minSamples("disk") {
+				high = usage > threshold
+				description = fmt.Sprintf("Disk usage (%s) is %.2f%% on node %s (insufficient history for statistical analysis)", filesystem, usage, node.Name)
+			} else {
+				var ewma float64
+				center, spread, ewma = d.seriesBaseline("node", node.Name, "disk")
+				high = isAnomalyHistory(usage, center, spread, ewma, threshold, d.minStdDev)
+				description = fmt.Sprintf("Disk usage (%s) is %.2f%% on node %s (%s)", filesystem, usage, node.Name, d.describeBaseline(center, spread, "%"))
+			}
+		}
+		if hasStats {
+			high = d.settle("HighDiskUsage", node.Name, "disk", usage, threshold, high)
+		}
+		switch {
+		case high && pressure:
+			description += fmt.Sprintf(" and the node has reported DiskPressure for %s", pressureFor)
+		case pressure:
+			description = fmt.Sprintf("Node %s has reported DiskPressure for %s", node.Name, pressureFor)
+		case !high:
+			continue
+		}
+		if d.shouldSuppressAlert("HighDiskUsage", node.Name, "disk") {
+			continue
+		}
+
+		// DiskPressure means the kubelet is already evicting pods
+		severity := d.scoreSeverity("disk", usage, center, spread, types.SeverityHigh)
+		if pressure && !severity.AtLeast(types.SeverityHigh) {
+			severity = types.SeverityHigh
+		}
+		labels := map[string]string{}
+		if hasStats {
+			labels["filesystem"] = filesystem
+		}
+		if node.DiskPressure {
+			labels["disk_pressure"] = "true"
+		}
+		anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+			Type:         "HighDiskUsage",
+			ResourceType: "node",
+			Resource:     node.Name,
+			NodeName:     node.Name,
+			Severity:     severity,
+			Description:  description,
+			Value:        usage,
+			Threshold:    threshold,
+			Labels:       labels,
+		}))
+		d.recordAlertTime("HighDiskUsage", node.Name, "disk")
+	}
+	return anomalies
+}
@@ -0,0 +1,142 @@
+package anomaly
+
+import (
+	"fmt"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/features"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// nodeVersionAttributes lists the node software versions compared for drift
+var nodeVersionAttributes = []struct {
+	name string
+	get  func(types.Node) string
+}{
+	{"kubelet", func(n types.Node) string { return n.KubeletVersion }},
+	{"kernel", func(n types.Node) string { return n.KernelVersion }},
+	{"runtime", func(n types.Node) string { return n.ContainerRuntimeVersion }},
+	{"os", func(n types.Node) string { return n.OSImage }},
+}
+
+// SetDrift sets the fleet drift detection configuration
+func (d *Detector) SetDrift(drift config.DriftConfig) {
+	d.drift = drift
+}
+
+// DetectFleetDrift compares node software versions and, if enabled, workloads across clusters.
+// Nodes that differ from their cluster's majority version and clusters that differ from the fleet
+// majority are reported, which usually points at a half-finished upgrade.
+func (d *Detector) DetectFleetDrift(states []types.ClusterState) []types.Anomaly {
+	var anomalies []types.Anomaly
+
+	// All clusters take part in the comparison, but anomalies are only raised for
+	// clusters the feature is enabled for
+	enabled := make(map[string]bool, len(states))
+	for _, state := range states {
+		if d.flags.Enabled(features.Drift, state.ClusterID, d.drift.Enabled) {
+			enabled[state.ClusterID] = true
+		}
+	}
+	if len(enabled) == 0 {
+		return anomalies
+	}
+
+	for _, attr := range nodeVersionAttributes {
+		fleetCounts := make(map[string]int)
+		clusterMajority := make(map[string]string) // cluster ID -> majority version
+
+		for _, state := range states {
+			counts := make(map[string]int)
+			for _, node := range state.Nodes {
+				if v := attr.get(node); v != "" {
+					counts[v]++
+					fleetCounts[v]++
+				}
+			}
+			majority := majorityValue(counts)
+			if majority == "" {
+				continue
+			}
+			clusterMajority[state.ClusterID] = majority
+
+			if !enabled[state.ClusterID] {
+				continue
+			}
+			for _, node := range state.Nodes {
+				version := attr.get(node)
+				if version == "" || version == majority {
+					continue
+				}
+				key := state.ClusterID + "/" + node.Name
+				if d.shouldSuppressAlert("NodeVersionDrift", key, attr.name) {
+					continue
+				}
+				anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+					Type:         "NodeVersionDrift",
+					ResourceType: "node",
+					Resource:     node.Name,
+					NodeName:     node.Name,
+					Severity:     types.SeverityLow,
+					Description:  fmt.Sprintf("Node %s runs %s version %s while %d of %d nodes in the cluster run %s", node.Name, attr.name, version, counts[majority], len(state.Nodes), majority),
+					Labels:       driftLabels(attr.name, majority, version),
+				}))
+				d.recordAlertTime("NodeVersionDrift", key, attr.name)
+			}
+		}
+
+		// A fleet comparison needs at least two clusters reporting this attribute
+		if len(clusterMajority) < 2 {
+			continue
+		}
+		fleetMajority := majorityValue(fleetCounts)
+		for _, state := range states {
+			version, ok := clusterMajority[state.ClusterID]
+			if !ok || version == fleetMajority || !enabled[state.ClusterID] {
+				continue
+			}
+			if d.shouldSuppressAlert("ClusterVersionDrift", state.ClusterID, attr.name) {
+				continue
+			}
+			anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+				Type:         "ClusterVersionDrift",
+				ResourceType: "cluster",
+				Resource:     state.ClusterName,
+				Severity:     types.SeverityLow,
+				Description:  fmt.Sprintf("Cluster %s mostly runs %s version %s while the fleet majority is %s", state.ClusterName, attr.name, version, fleetMajority),
+				Labels:       driftLabels(attr.name, fleetMajority, version),
+			}))
+			d.recordAlertTime("ClusterVersionDrift", state.ClusterID, attr.name)
+		}
+	}
+
+	// Compare workloads deployed to several clusters
+	anomalies = append(anomalies, d.detectWorkloadDrift(states, enabled)...)
+
+	d.scoreAnomalies(anomalies)
+	return anomalies
+}
+
+// majorityValue returns the most common value, preferring the greater value on ties
+// so the result is deterministic
+func majorityValue(counts map[string]int) string {
+	best := ""
+	bestCount := 0
+	for value, count := range counts {
+		if count > bestCount || (count == bestCount && value > best) {
+			best = value
+			bestCount = count
+		}
+	}
+	return best
+}
+
+// driftLabels describes which attribute drifted and from what expected value
+func driftLabels(attribute, expected, actual string) map[string]string {
+	return map[string]string{
+		"category":  "drift",
+		"attribute": attribute,
+		"expected":  expected,
+		"actual":    actual,
+	}
+}
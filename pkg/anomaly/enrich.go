@@ -0,0 +1,45 @@
+package anomaly
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+const (
+	// relatedEventWindow is how far before an anomaly events of its resource are attached to it
+	relatedEventWindow = 30 * time.Minute
+	// maxRelatedEvents is how many of the most recent related events are attached
+	maxRelatedEvents = 10
+)
+
+// relatedEvents returns the events of a node or pod within relatedEventWindow before at, most
+// recent first. Node events are matched by name only, since they are recorded in the default
+// namespace rather than the node's.
+func relatedEvents(state types.ClusterState, resourceType, namespace, resource string, at time.Time) []types.Event {
+	if resource == "" || (resourceType != "node" && resourceType != "pod") {
+		return nil
+	}
+
+	var events []types.Event
+	for _, event := range state.Events {
+		if event.Resource != resource || (resourceType == "pod" && event.Namespace != namespace) {
+			continue
+		}
+		if event.Timestamp.After(at) || at.Sub(event.Timestamp) > relatedEventWindow {
+			continue
+		}
+		events = append(events, types.Event{
+			Type:      event.Type,
+			Reason:    event.Reason,
+			Message:   event.Message,
+			Timestamp: event.Timestamp,
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.After(events[j].Timestamp) })
+	if len(events) > maxRelatedEvents {
+		events = events[:maxRelatedEvents]
+	}
+	return events
+}
@@ -0,0 +1,53 @@
+package anomaly
+
+import (
+	"log"
+	"regexp"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// eventReason is a problematic event reason with its compiled message pattern
+type eventReason struct {
+	reason   string
+	message  *regexp.Regexp
+	severity types.Severity
+}
+
+// SetEventReasons sets the problematic event reasons. Reasons with an invalid message pattern are ignored.
+func (d *Detector) SetEventReasons(reasons []config.EventReasonConfig) {
+	d.eventReasons = nil
+	for _, rc := range reasons {
+		if rc.Reason == "" && rc.MessagePattern == "" {
+			log.Printf("Warning: ignoring event reason without a reason or message pattern")
+			continue
+		}
+		reason := eventReason{reason: rc.Reason, severity: types.SeverityHigh}
+		if rc.Severity != "" {
+			severity, err := types.ParseSeverity(rc.Severity)
+			if err != nil {
+				log.Printf("Warning: ignoring event reason %q: %v", rc.Reason, err)
+				continue
+			}
+			reason.severity = severity
+		}
+		if rc.MessagePattern != "" {
+			pattern, err := regexp.Compile(rc.MessagePattern)
+			if err != nil {
+				log.Printf("Warning: ignoring event reason %q with invalid message pattern: %v", rc.Reason, err)
+				continue
+			}
+			reason.message = pattern
+		}
+		d.eventReasons = append(d.eventReasons, reason)
+	}
+}
+
+// matches reports whether the event has the reason and a message matching the pattern, if any
+func (r eventReason) matches(event types.ClusterEvent) bool {
+	if r.reason != "" && r.reason != event.Reason {
+		return false
+	}
+	return r.message == nil || r.message.MatchString(event.Message)
+}
@@ -0,0 +1,72 @@
+package anomaly
+
+import (
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// Evidence is the metric history an anomaly was detected from
+type Evidence struct {
+	Metric     string              `json:"metric"`
+	Statistics string              `json:"statistics"`
+	Center     float64             `json:"center"` // Baseline mean or median before the trigger
+	Spread     float64             `json:"spread"` // Baseline standard deviation or scaled MAD before the trigger
+	EWMA       float64             `json:"ewma"`
+	Samples    int                 `json:"samples"`           // Observations the baseline was computed from
+	Deviation  float64             `json:"deviation"`         // Distance of the value from the baseline in spreads
+	History    []MetricObservation `json:"history,omitempty"` // Observations around the trigger, oldest first
+}
+
+// MetricOf returns the metric an anomaly was detected on, or "" for anomalies not based on a metric
+func MetricOf(anomaly types.Anomaly) string {
+	if metric := anomaly.Labels["metric"]; metric != "" {
+		return metric
+	}
+	switch anomaly.Type {
+	case "HighCPUUsage":
+		return "cpu"
+	case "HighMemoryUsage":
+		return "memory"
+	case "HighDiskUsage":
+		return "disk"
+	case "HighPodRestarts":
+		return "restarts"
+	}
+	return ""
+}
+
+// Evidence returns the baseline before an anomaly and the observations within around of it. It
+// returns false if the anomaly is not metric based or its history is no longer retained.
+func (d *Detector) Evidence(anomaly types.Anomaly, around time.Duration) (Evidence, bool) {
+	metric := MetricOf(anomaly)
+	if metric == "" {
+		return Evidence{}, false
+	}
+
+	d.historyMu.RLock()
+	defer d.historyMu.RUnlock()
+
+	evidence := Evidence{Metric: metric, Statistics: d.statistics}
+	var before []float64
+	for _, obs := range d.getMetricObservations(anomaly.ResourceType, anomaly.Resource, metric) {
+		if obs.Timestamp.Before(anomaly.Timestamp) {
+			before = append(before, obs.Value)
+		}
+		if !obs.Timestamp.Before(anomaly.Timestamp.Add(-around)) && !obs.Timestamp.After(anomaly.Timestamp.Add(around)) {
+			evidence.History = append(evidence.History, obs)
+		}
+	}
+	if len(before) == 0 && len(evidence.History) == 0 {
+		return Evidence{}, false
+	}
+
+	evidence.Samples = len(before)
+	if len(before) > 0 {
+		evidence.Center, evidence.Spread, evidence.EWMA = d.baseline(before, d.getAlphaForMetric(metric))
+		if evidence.Spread > 0 {
+			evidence.Deviation = (anomaly.Value - evidence.Center) / evidence.Spread
+		}
+	}
+	return evidence, true
+}
@@ -0,0 +1,51 @@
+package anomaly
+
+import (
+	"math"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// SetFeedback sets how labeled anomalies adjust the thresholds of their resources
+func (d *Detector) SetFeedback(feedback config.FeedbackConfig) {
+	d.feedbackMu.Lock()
+	defer d.feedbackMu.Unlock()
+	d.feedbackConfig = feedback
+}
+
+// RecordFeedback records that an anomaly was labeled a true or false positive. Every false
+// positive raises the thresholds the anomaly's type and resource are checked against by one step,
+// every true positive lowers them, within the configured maximum adjustment.
+func (d *Detector) RecordFeedback(anomaly types.Anomaly, feedback string) {
+	d.feedbackMu.Lock()
+	defer d.feedbackMu.Unlock()
+	if d.feedback == nil {
+		d.feedback = make(map[string]int)
+	}
+	key := feedbackKey(anomaly.Type, anomaly.Namespace, anomaly.Resource)
+	switch feedback {
+	case types.FeedbackFalsePositive:
+		d.feedback[key]++
+	case types.FeedbackTruePositive:
+		d.feedback[key]--
+	}
+}
+
+// sensitivity returns the factor the threshold of an anomaly type on a resource is multiplied with
+// from the feedback it received: above 1 after false positives, below 1 after true positives
+func (d *Detector) sensitivity(anomalyType, namespace, resource string) float64 {
+	d.feedbackMu.Lock()
+	defer d.feedbackMu.Unlock()
+	net := d.feedback[feedbackKey(anomalyType, namespace, resource)]
+	if net == 0 {
+		return 1
+	}
+	maxAdjustment := d.feedbackConfig.MaxAdjustment
+	return 1 + math.Max(-maxAdjustment, math.Min(maxAdjustment, float64(net)*d.feedbackConfig.Step))
+}
+
+// feedbackKey identifies the labeled anomalies of a type on a resource
+func feedbackKey(anomalyType, namespace, resource string) string {
+	return anomalyType + ":" + namespace + "/" + resource
+}
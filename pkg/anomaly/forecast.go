@@ -0,0 +1,81 @@
+package anomaly
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// SetForecast sets the capacity exhaustion forecasting configuration
+func (d *Detector) SetForecast(forecast config.ForecastConfig) {
+	d.forecast = forecast
+}
+
+// detectCapacityForecasts fits a trend to recent node usage and emits predictive anomalies
+// for nodes that will reach 100% within the forecast horizon at their current rate
+func (d *Detector) detectCapacityForecasts(state types.ClusterState) []types.Anomaly {
+	var anomalies []types.Anomaly
+	if !d.forecast.Enabled {
+		return anomalies
+	}
+	horizon := time.Duration(d.forecast.HorizonHours) * time.Hour
+
+	for _, node := range state.Nodes {
+		for _, metric := range []string{"cpu", "memory"} {
+			observations := d.getMetricObservations("node", node.Name, metric)
+			if len(observations) < d.forecast.Intervals {
+				continue
+			}
+			observations = observations[len(observations)-d.forecast.Intervals:]
+
+			// Fit usage against seconds since the first observation
+			start := observations[0].Timestamp
+			xs := make([]float64, len(observations))
+			ys := make([]float64, len(observations))
+			for i, obs := range observations {
+				xs[i] = obs.Timestamp.Sub(start).Seconds()
+				ys[i] = obs.Value
+			}
+			slope, intercept, r2 := fitLine(xs, ys)
+			if slope <= 0 || r2 < d.forecast.MinFit {
+				continue
+			}
+
+			last := observations[len(observations)-1]
+			current := intercept + slope*last.Timestamp.Sub(start).Seconds()
+			if current >= 100 {
+				continue // Already exhausted; the threshold checks report this
+			}
+			remaining := time.Duration((100 - current) / slope * float64(time.Second))
+			if remaining > horizon || d.shouldSuppressAlert("CapacityExhaustionForecast", node.Name, metric) {
+				continue
+			}
+
+			exhaustionAt := last.Timestamp.Add(remaining)
+			anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+				Type:         "CapacityExhaustionForecast",
+				ResourceType: "node",
+				Resource:     node.Name,
+				NodeName:     node.Name,
+				Severity:     types.SeverityMedium,
+				Description:  fmt.Sprintf("Node %s will hit 100%% %s in ~%s at the current rate (now %.2f%%, +%.2f%%/hour)", node.Name, metric, formatForecastDuration(remaining), last.Value, slope*3600),
+				Value:        remaining.Hours(),
+				Threshold:    float64(d.forecast.HorizonHours),
+				Labels:       map[string]string{"metric": metric},
+				Metadata:     map[string]interface{}{"exhaustionAt": exhaustionAt},
+			}))
+			d.recordAlertTime("CapacityExhaustionForecast", node.Name, metric)
+		}
+	}
+	return anomalies
+}
+
+// formatForecastDuration rounds a forecast to a readable precision, e.g. "6h" or "45m"
+func formatForecastDuration(d time.Duration) string {
+	if d >= time.Hour {
+		return d.Round(time.Hour).String()
+	}
+	return d.Round(time.Minute).String()
+}
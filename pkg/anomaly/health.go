@@ -0,0 +1,128 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+const (
+	// healthEventsPerNode is the number of warning events per node at which the events component
+	// of the health score reaches zero
+	healthEventsPerNode = 5
+	// healthAnomaliesPerNode is the number of anomalies per node at which the anomalies component
+	// of the health score reaches zero
+	healthAnomaliesPerNode = 1
+)
+
+// HealthScore is the composite health of a cluster from 0 (down) to 100 (healthy) and the
+// components it was computed from
+type HealthScore struct {
+	Score         float64 `json:"score"`
+	ReadyNodes    float64 `json:"readyNodes"`    // Share of nodes that are Ready
+	ScheduledPods float64 `json:"scheduledPods"` // Share of pods that are not Pending
+	WarningEvents int     `json:"warningEvents"` // Warning events of the cycle
+	Anomalies     int     `json:"anomalies"`     // Anomalies raised in the cycle
+}
+
+// SetHealthScore sets the cluster health score configuration
+func (d *Detector) SetHealthScore(health config.HealthScoreConfig) {
+	d.health = health
+}
+
+// LastHealthScore returns the health score computed from the latest state, if enabled
+func (d *Detector) LastHealthScore() (HealthScore, bool) {
+	return d.lastHealth, d.health.Enabled && !d.observedAt.IsZero()
+}
+
+// scoreHealth computes the health score of a cluster state on which anomalies were raised
+func (d *Detector) scoreHealth(state types.ClusterState, anomalies []types.Anomaly) HealthScore {
+	health := HealthScore{ReadyNodes: 1, ScheduledPods: 1, Anomalies: len(anomalies)}
+	if len(state.Nodes) > 0 {
+		ready := 0
+		for _, node := range state.Nodes {
+			if node.ConditionStatus == "True" {
+				ready++
+			}
+		}
+		health.ReadyNodes = float64(ready) / float64(len(state.Nodes))
+	}
+
+	pods, pending := 0, 0
+	for _, resources := range state.Resources {
+		for _, pod := range resources.Pods {
+			pods++
+			if pod.Status == "Pending" {
+				pending++
+			}
+		}
+	}
+	if pods > 0 {
+		health.ScheduledPods = 1 - float64(pending)/float64(pods)
+	}
+
+	// Events are listed beyond the cycle; only those of the cycle count
+	for _, event := range state.Events {
+		if event.Type == "Warning" && (state.CycleStart.IsZero() || !event.Timestamp.Before(state.CycleStart)) {
+			health.WarningEvents++
+		}
+	}
+
+	nodes := float64(max(1, len(state.Nodes)))
+	events := 1 - math.Min(1, float64(health.WarningEvents)/(nodes*healthEventsPerNode))
+	anomalyScore := 1 - math.Min(1, float64(health.Anomalies)/(nodes*healthAnomaliesPerNode))
+
+	w := d.health.Weights
+	total := w.Nodes + w.Pods + w.Events + w.Anomalies
+	if total <= 0 {
+		return health
+	}
+	health.Score = 100 * (w.Nodes*health.ReadyNodes + w.Pods*health.ScheduledPods + w.Events*events + w.Anomalies*anomalyScore) / total
+	return health
+}
+
+// detectHealthDrop records the cluster's health score and flags a drop of at least the configured
+// number of points below the mean of the recent scores
+func (d *Detector) detectHealthDrop(state types.ClusterState, anomalies []types.Anomaly) []types.Anomaly {
+	if !d.health.Enabled {
+		return nil
+	}
+	health := d.scoreHealth(state, anomalies)
+	d.lastHealth = health
+
+	// The baseline excludes the current score
+	recent := d.GetMetricHistory("cluster", state.ClusterID, "health")
+	d.recordObservation("cluster", state.ClusterID, "health", health.Score)
+	if len(recent) < d.health.Intervals {
+		return nil
+	}
+	recent = recent[len(recent)-d.health.Intervals:]
+	baseline := 0.0
+	for _, score := range recent {
+		baseline += score
+	}
+	baseline /= float64(len(recent))
+
+	drop := baseline - health.Score
+	if drop < d.health.DropThreshold || d.shouldSuppressAlert("ClusterHealthDrop", state.ClusterID, "health") {
+		return nil
+	}
+	severity := types.SeverityHigh
+	if drop >= 2*d.health.DropThreshold {
+		severity = types.SeverityCritical
+	}
+	d.recordAlertTime("ClusterHealthDrop", state.ClusterID, "health")
+	return []types.Anomaly{d.newAnomaly(state, anomalyParams{
+		Type:         "ClusterHealthDrop",
+		ResourceType: "cluster",
+		Resource:     state.ClusterName,
+		Severity:     severity,
+		Description: fmt.Sprintf("Health score of cluster %s dropped to %.1f from %.1f over the last %d cycles (%.0f%% of nodes ready, %.0f%% of pods scheduled, %d warning events, %d anomalies)",
+			state.ClusterName, health.Score, baseline, len(recent), 100*health.ReadyNodes, 100*health.ScheduledPods, health.WarningEvents, health.Anomalies),
+		Value:     health.Score,
+		Threshold: baseline - d.health.DropThreshold,
+		Metadata:  map[string]interface{}{"health": health, "baseline": baseline},
+	})}
+}
@@ -0,0 +1,194 @@
+package anomaly
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// defaultSeriesSize is how many observations a series keeps unless configured otherwise
+const defaultSeriesSize = 100
+
+// staleSeriesAge is how long a series keeps its history after its resource stopped reporting,
+// e.g. a deleted pod
+const staleSeriesAge = 24 * time.Hour
+
+// metricSeries is the history of one metric of one resource: a ring buffer of the latest
+// observations and the streaming statistics over them
+type metricSeries struct {
+	observations []MetricObservation
+	start        int // Index of the oldest observation once the buffer is full
+	stats        seriesStats
+}
+
+// add appends an observation, evicting and returning the oldest one if the buffer holds capacity
+// observations
+func (s *metricSeries) add(obs MetricObservation, capacity int) (evicted []MetricObservation) {
+	// Shrink a buffer whose capacity was lowered
+	if len(s.observations) > capacity {
+		ordered := s.ordered()
+		evicted = append(evicted, ordered[:len(ordered)-capacity]...)
+		s.observations = ordered[len(ordered)-capacity:]
+		s.start = 0
+	}
+	if len(s.observations) < capacity {
+		s.observations = append(s.observations, obs)
+		return evicted
+	}
+	if capacity == 0 {
+		return append(evicted, obs)
+	}
+	evicted = append(evicted, s.observations[s.start])
+	s.observations[s.start] = obs
+	s.start = (s.start + 1) % len(s.observations)
+	return evicted
+}
+
+// ordered returns the observations oldest first
+func (s *metricSeries) ordered() []MetricObservation {
+	ordered := make([]MetricObservation, 0, len(s.observations))
+	ordered = append(ordered, s.observations[s.start:]...)
+	return append(ordered, s.observations[:s.start]...)
+}
+
+// values returns the observed values oldest first
+func (s *metricSeries) values() []float64 {
+	values := make([]float64, 0, len(s.observations))
+	for _, obs := range s.observations[s.start:] {
+		values = append(values, obs.Value)
+	}
+	for _, obs := range s.observations[:s.start] {
+		values = append(values, obs.Value)
+	}
+	return values
+}
+
+// latest returns the time of the newest observation
+func (s *metricSeries) latest() time.Time {
+	if len(s.observations) == 0 {
+		return time.Time{}
+	}
+	return s.observations[(s.start+len(s.observations)-1)%len(s.observations)].Timestamp
+}
+
+// seriesKey identifies a metric series
+func seriesKey(resourceType, resourceID, metricType string) string {
+	return resourceType + "/" + resourceID + "/" + metricType
+}
+
+// recordObservation records a single metric observation with resource context
+func (d *Detector) recordObservation(resourceType, resourceID, metricType string, value float64) {
+	observedAt := d.observedAt
+	if observedAt.IsZero() {
+		observedAt = time.Now()
+	}
+	obs := MetricObservation{
+		Timestamp:    observedAt,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		MetricType:   metricType,
+		Value:        value,
+	}
+	d.historyMu.Lock()
+	defer d.historyMu.Unlock()
+
+	if d.recording {
+		d.recorded = append(d.recorded, obs)
+	}
+	d.addObservation(obs)
+}
+
+// addObservation adds an observation to the history of its series. Callers must hold
+// d.historyMu.
+func (d *Detector) addObservation(obs MetricObservation) {
+	key := seriesKey(obs.ResourceType, obs.ResourceID, obs.MetricType)
+	series, ok := d.history[key]
+	if !ok {
+		series = &metricSeries{}
+		d.history[key] = series
+	}
+	series.stats.add(obs.Value, d.getAlphaForMetric(obs.MetricType))
+	for _, old := range series.add(obs, d.seriesSize) {
+		series.stats.remove(old.Value)
+	}
+
+	// Drop series of resources that no longer report once an hour
+	if obs.Timestamp.Sub(d.historyPrunedAt) >= time.Hour {
+		for key, series := range d.history {
+			if obs.Timestamp.Sub(series.latest()) > staleSeriesAge {
+				delete(d.history, key)
+			}
+		}
+		d.historyPrunedAt = obs.Timestamp
+	}
+
+	if d.trendHistory.Enabled {
+		d.recordTrend(obs)
+	}
+}
+
+// SetRecording sets whether the observations are kept until taken with TakeObservations, e.g. to
+// export them
+func (d *Detector) SetRecording(enabled bool) {
+	d.historyMu.Lock()
+	defer d.historyMu.Unlock()
+	d.recording = enabled
+	d.recorded = nil
+}
+
+// TakeObservations returns the observations recorded since the last call, oldest first
+func (d *Detector) TakeObservations() []MetricObservation {
+	d.historyMu.Lock()
+	defer d.historyMu.Unlock()
+	recorded := d.recorded
+	d.recorded = nil
+	return recorded
+}
+
+// RestoreHistory adds observations recorded before a restart to the history, so baselines do not
+// have to be rebuilt. Observations are added oldest first and are not recorded again.
+func (d *Detector) RestoreHistory(observations []MetricObservation) {
+	sorted := make([]MetricObservation, len(observations))
+	copy(sorted, observations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	d.historyMu.Lock()
+	defer d.historyMu.Unlock()
+	for _, obs := range sorted {
+		d.addObservation(obs)
+	}
+}
+
+// GetMetricHistory returns the values of a resource metric, oldest first
+func (d *Detector) GetMetricHistory(resourceType, resourceID, metricType string) []float64 {
+	series, ok := d.history[seriesKey(resourceType, resourceID, metricType)]
+	if !ok {
+		return []float64{}
+	}
+	return series.values()
+}
+
+// getMetricObservations returns the timestamped observations of a resource metric, oldest first
+func (d *Detector) getMetricObservations(resourceType, resourceID, metricType string) []MetricObservation {
+	series, ok := d.history[seriesKey(resourceType, resourceID, metricType)]
+	if !ok {
+		return []MetricObservation{}
+	}
+	return series.ordered()
+}
+
+// PrintHistory prints the history of every series
+func (d *Detector) PrintHistory() {
+	keys := make([]string, 0, len(d.history))
+	for key := range d.history {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, obs := range d.history[key].ordered() {
+			fmt.Printf("%s/%s %s: %f\n", obs.ResourceType, obs.ResourceID, obs.MetricType, obs.Value)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package anomaly
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+)
+
+// alertState is whether a threshold anomaly of a resource is firing and when it last changed state
+type alertState struct {
+	firing      bool
+	transitions []time.Time // State changes within the flap window
+	seenAt      time.Time
+}
+
+// SetHysteresis sets the clear threshold and flap suppression of threshold anomalies. A clear ratio
+// outside (0, 1] disables hysteresis.
+func (d *Detector) SetHysteresis(hysteresis config.HysteresisConfig) {
+	if hysteresis.Enabled && (hysteresis.ClearRatio <= 0 || hysteresis.ClearRatio > 1) {
+		log.Printf("Warning: ignoring hysteresis with clear ratio %.2f outside (0, 1]", hysteresis.ClearRatio)
+		hysteresis.Enabled = false
+	}
+	d.hysteresis = hysteresis
+}
+
+// settle applies hysteresis to a threshold check and reports whether the anomaly is to be raised.
+// An anomaly fires when breached and only clears once value drops below the clear threshold, so a
+// value hovering around the threshold does not fire every other cycle. A resource that changed
+// state more than MaxFlaps times within the flap window is flapping and not reported until it
+// holds its state.
+func (d *Detector) settle(alertType, resource, metric string, value, threshold float64, breached bool) bool {
+	if !d.hysteresis.Enabled {
+		return breached
+	}
+	now := d.observedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	d.pruneAlertStates(now)
+
+	key := fmt.Sprintf("%s:%s:%s", alertType, resource, metric)
+	state, exists := d.alertStates[key]
+	if !exists {
+		state = &alertState{}
+		d.alertStates[key] = state
+	}
+	state.seenAt = now
+
+	window := time.Duration(d.hysteresis.FlapWindowMinutes) * time.Minute
+	recent := state.transitions[:0]
+	for _, t := range state.transitions {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	state.transitions = recent
+
+	firing := breached || (state.firing && value >= threshold*d.hysteresis.ClearRatio)
+	if firing != state.firing {
+		state.firing = firing
+		state.transitions = append(state.transitions, now)
+	}
+	if firing && len(state.transitions) > d.hysteresis.MaxFlaps {
+		if d.debug {
+			log.Printf("[DEBUG] Suppressing %s on %s: %d state changes within %s", alertType, resource, len(state.transitions), window)
+		}
+		return false
+	}
+	return firing
+}
+
+// pruneAlertStates forgets the state of resources that stopped reporting, at most once an hour
+func (d *Detector) pruneAlertStates(now time.Time) {
+	if now.Sub(d.alertStatesPrunedAt) < time.Hour {
+		return
+	}
+	for key, state := range d.alertStates {
+		if now.Sub(state.seenAt) > staleSeriesAge {
+			delete(d.alertStates, key)
+		}
+	}
+	d.alertStatesPrunedAt = now
+}
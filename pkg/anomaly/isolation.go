@@ -0,0 +1,271 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/features"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// Features of the vectors scored by the multivariate detector
+var (
+	nodeFeatures = []string{"cpu", "memory", "restarts", "events"}
+	podFeatures  = []string{"restarts", "events", "nodeCpu", "nodeMemory"}
+)
+
+// isolationTree is a node of a randomly built isolation tree. Leaves have no children.
+type isolationTree struct {
+	feature     int
+	split       float64
+	left, right *isolationTree
+	size        int       // Number of training samples that reached a leaf
+	lo, hi      []float64 // Range of each feature among the samples of a leaf
+}
+
+// isolationForest scores how easily a vector is separated from the training data. Unusual
+// combinations are isolated by fewer random splits and score closer to 1.
+type isolationForest struct {
+	trees      []*isolationTree
+	sampleSize int
+}
+
+// SetMultivariate sets the multivariate detection configuration
+func (d *Detector) SetMultivariate(multivariate config.MultivariateConfig) {
+	d.multivariate = multivariate
+}
+
+// detectMultivariateAnomalies scores nodes and pods on several metrics at once, catching cases
+// where every metric is within its threshold but the combination has not been seen before
+func (d *Detector) detectMultivariateAnomalies(state types.ClusterState) []types.Anomaly {
+	var anomalies []types.Anomaly
+	if !d.flags.Enabled(features.Multivariate, state.ClusterID, d.multivariate.Enabled) {
+		return anomalies
+	}
+
+	// Count warning and error events per involved object
+	eventCounts := make(map[string]float64)
+	for _, event := range state.Events {
+		if event.Severity != "Normal" {
+			eventCounts[event.Namespace+"/"+event.Resource] += float64(event.Count)
+		}
+	}
+
+	nodes := make(map[string]types.Node, len(state.Nodes))
+	nodeRestarts := make(map[string]float64)
+	nodeEvents := make(map[string]float64)
+	for _, node := range state.Nodes {
+		nodes[node.Name] = node
+		nodeEvents[node.Name] += eventCounts["/"+node.Name] + eventCounts["default/"+node.Name]
+	}
+
+	var podIDs []string
+	var podVectors [][]float64
+	var podRefs []types.Pod
+	for ns, resources := range state.Resources {
+		for _, pod := range resources.Pods {
+			events := eventCounts[ns+"/"+pod.Name]
+			restarts := float64(d.restartRates[ns+"/"+pod.Name])
+			nodeRestarts[pod.NodeName] += restarts
+			nodeEvents[pod.NodeName] += events
+
+			node := nodes[pod.NodeName]
+			podIDs = append(podIDs, ns+"/"+pod.Name)
+			podVectors = append(podVectors, []float64{restarts, events, node.CPUUsagePercent, node.MemoryUsagePercent})
+			podRefs = append(podRefs, pod)
+		}
+	}
+
+	nodeVectors := make([][]float64, 0, len(state.Nodes))
+	for _, node := range state.Nodes {
+		nodeVectors = append(nodeVectors, []float64{node.CPUUsagePercent, node.MemoryUsagePercent, nodeRestarts[node.Name], nodeEvents[node.Name]})
+	}
+
+	for i, score := range d.scoreMultivariate(state.ClusterID+":node", nodeVectors) {
+		node := state.Nodes[i]
+		if score <= d.multivariate.ScoreThreshold || d.shouldSuppressAlert("MultivariateAnomaly", node.Name, "node") {
+			continue
+		}
+		anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+			Type:         "MultivariateAnomaly",
+			ResourceType: "node",
+			Resource:     node.Name,
+			NodeName:     node.Name,
+			Severity:     types.SeverityMedium,
+			Description:  fmt.Sprintf("Unusual combination of metrics on node %s (score: %.2f): %s", node.Name, score, describeVector(nodeFeatures, nodeVectors[i])),
+			Value:        score,
+			Threshold:    d.multivariate.ScoreThreshold,
+		}))
+		d.recordAlertTime("MultivariateAnomaly", node.Name, "node")
+	}
+
+	for i, score := range d.scoreMultivariate(state.ClusterID+":pod", podVectors) {
+		pod := podRefs[i]
+		if score <= d.multivariate.ScoreThreshold || d.shouldSuppressAlert("MultivariateAnomaly", podIDs[i], "pod") {
+			continue
+		}
+		anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+			Type:         "MultivariateAnomaly",
+			ResourceType: "pod",
+			Resource:     pod.Name,
+			Namespace:    pod.Namespace,
+			NodeName:     pod.NodeName,
+			Severity:     types.SeverityMedium,
+			Description:  fmt.Sprintf("Unusual combination of metrics for pod %s (score: %.2f): %s", pod.Name, score, describeVector(podFeatures, podVectors[i])),
+			Value:        score,
+			Threshold:    d.multivariate.ScoreThreshold,
+		}))
+		d.recordAlertTime("MultivariateAnomaly", podIDs[i], "pod")
+	}
+
+	return anomalies
+}
+
+// scoreMultivariate scores vectors against a forest trained on the vectors seen in previous
+// cycles, then adds them to the training window. Nothing is scored until enough samples exist.
+func (d *Detector) scoreMultivariate(key string, vectors [][]float64) []float64 {
+	var scores []float64
+	window := d.multivariateHistory[key]
+	if len(window) >= d.multivariate.MinSamples && len(vectors) > 0 {
+		forest := newIsolationForest(window, d.multivariate.Trees, d.multivariate.SampleSize)
+		scores = make([]float64, len(vectors))
+		for i, v := range vectors {
+			scores[i] = forest.score(v)
+		}
+	}
+
+	window = append(window, vectors...)
+	if len(window) > d.maxHistorySize {
+		window = window[len(window)-d.maxHistorySize:]
+	}
+	d.multivariateHistory[key] = window
+	return scores
+}
+
+// newIsolationForest builds a forest of trees, each trained on a random subsample of data
+func newIsolationForest(data [][]float64, trees, sampleSize int) *isolationForest {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	if sampleSize > len(data) {
+		sampleSize = len(data)
+	}
+	maxDepth := int(math.Ceil(math.Log2(float64(sampleSize))))
+
+	forest := &isolationForest{sampleSize: sampleSize}
+	for i := 0; i < trees; i++ {
+		sample := make([][]float64, sampleSize)
+		for j, k := range rng.Perm(len(data))[:sampleSize] {
+			sample[j] = data[k]
+		}
+		forest.trees = append(forest.trees, buildIsolationTree(sample, 0, maxDepth, rng))
+	}
+	return forest
+}
+
+// buildIsolationTree recursively splits data on a random feature at a random value
+func buildIsolationTree(data [][]float64, depth, maxDepth int, rng *rand.Rand) *isolationTree {
+	if depth >= maxDepth || len(data) <= 1 {
+		return newIsolationLeaf(data)
+	}
+
+	// Try features in random order until one has values to split
+	for _, feature := range rng.Perm(len(data[0])) {
+		lo, hi := data[0][feature], data[0][feature]
+		for _, v := range data[1:] {
+			lo = math.Min(lo, v[feature])
+			hi = math.Max(hi, v[feature])
+		}
+		if lo == hi {
+			continue
+		}
+
+		split := lo + rng.Float64()*(hi-lo)
+		var left, right [][]float64
+		for _, v := range data {
+			if v[feature] < split {
+				left = append(left, v)
+			} else {
+				right = append(right, v)
+			}
+		}
+		return &isolationTree{
+			feature: feature,
+			split:   split,
+			left:    buildIsolationTree(left, depth+1, maxDepth, rng),
+			right:   buildIsolationTree(right, depth+1, maxDepth, rng),
+		}
+	}
+	return newIsolationLeaf(data)
+}
+
+// newIsolationLeaf creates a leaf holding data
+func newIsolationLeaf(data [][]float64) *isolationTree {
+	leaf := &isolationTree{size: len(data)}
+	if len(data) > 0 {
+		leaf.lo = append([]float64(nil), data[0]...)
+		leaf.hi = append([]float64(nil), data[0]...)
+		for _, v := range data[1:] {
+			for i := range v {
+				leaf.lo[i] = math.Min(leaf.lo[i], v[i])
+				leaf.hi[i] = math.Max(leaf.hi[i], v[i])
+			}
+		}
+	}
+	return leaf
+}
+
+// pathLength returns the number of splits needed to isolate v, estimating the remaining
+// depth of leaves holding several samples. A vector outside the range of a leaf's samples is
+// already isolated; this also catches deviations in features that never varied in training.
+func (t *isolationTree) pathLength(v []float64, depth int) float64 {
+	if t.left == nil {
+		for i := range t.lo {
+			if v[i] < t.lo[i] || v[i] > t.hi[i] {
+				return float64(depth)
+			}
+		}
+		return float64(depth) + averagePathLength(t.size)
+	}
+	if v[t.feature] < t.split {
+		return t.left.pathLength(v, depth+1)
+	}
+	return t.right.pathLength(v, depth+1)
+}
+
+// score returns the anomaly score of v between 0 and 1; values well above 0.5 are unusual
+func (f *isolationForest) score(v []float64) float64 {
+	if len(f.trees) == 0 || f.sampleSize < 2 {
+		return 0
+	}
+	var total float64
+	for _, t := range f.trees {
+		total += t.pathLength(v, 0)
+	}
+	mean := total / float64(len(f.trees))
+	return math.Pow(2, -mean/averagePathLength(f.sampleSize))
+}
+
+// averagePathLength is the average path length of an unsuccessful search in a binary
+// search tree of n samples, used to normalize path lengths
+func averagePathLength(n int) float64 {
+	switch {
+	case n <= 1:
+		return 0
+	case n == 2:
+		return 1
+	}
+	harmonic := math.Log(float64(n-1)) + 0.5772156649
+	return 2*harmonic - 2*float64(n-1)/float64(n)
+}
+
+// describeVector formats a feature vector as "name=value" pairs
+func describeVector(names []string, values []float64) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%s=%.2f", names[i], v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,98 @@
+package anomaly
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/features"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// SetImagePolicy sets the container image provenance policy
+func (d *Detector) SetImagePolicy(policy config.ImagePolicyConfig) {
+	d.imagePolicy = policy
+}
+
+// detectImagePolicyAnomalies flags images pulled from registries outside the allowlist and
+// images using mutable tags in production namespaces, once per workload running the image
+func (d *Detector) detectImagePolicyAnomalies(state types.ClusterState) []types.Anomaly {
+	var anomalies []types.Anomaly
+	if !d.flags.Enabled(features.ImagePolicy, state.ClusterID, d.imagePolicy.Enabled) {
+		return anomalies
+	}
+
+	for ns, resources := range state.Resources {
+		for _, image := range resources.Images {
+			untrusted := len(d.imagePolicy.AllowedRegistries) > 0 && !matchesAny(image.Registry, d.imagePolicy.AllowedRegistries)
+			mutable := image.Tag == "latest" && image.Digest == "" && d.isProductionNamespace(ns)
+			if !untrusted && !mutable {
+				continue
+			}
+
+			for _, workload := range image.Workloads {
+				key := ns + "/" + workload
+				if untrusted && !d.shouldSuppressAlert("UntrustedImageRegistry", key, image.Image) {
+					anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+						Type:         "UntrustedImageRegistry",
+						ResourceType: "workload",
+						Resource:     workload,
+						Namespace:    ns,
+						Severity:     types.SeverityLow,
+						Description:  fmt.Sprintf("%s uses image %s from registry %s which is not in the allowed registries", workload, image.Image, image.Registry),
+						Labels:       policyLabels(workload, image),
+					}))
+					d.recordAlertTime("UntrustedImageRegistry", key, image.Image)
+				}
+
+				if mutable && !d.shouldSuppressAlert("MutableImageTag", key, image.Image) {
+					anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+						Type:         "MutableImageTag",
+						ResourceType: "workload",
+						Resource:     workload,
+						Namespace:    ns,
+						Severity:     types.SeverityLow,
+						Description:  fmt.Sprintf("%s uses mutable image tag %s in production namespace %s", workload, image.Image, ns),
+						Labels:       policyLabels(workload, image),
+					}))
+					d.recordAlertTime("MutableImageTag", key, image.Image)
+				}
+			}
+		}
+	}
+
+	return anomalies
+}
+
+// isProductionNamespace reports whether mutable image tags should be flagged in a namespace
+func (d *Detector) isProductionNamespace(namespace string) bool {
+	if len(d.imagePolicy.ProductionNamespaces) == 0 {
+		return true
+	}
+	return matchesAny(namespace, d.imagePolicy.ProductionNamespaces)
+}
+
+// policyLabels attributes a policy anomaly to the workload and image that caused it
+func policyLabels(workload string, image types.ImageUsage) map[string]string {
+	kind, name := splitWorkload(workload)
+	return map[string]string{
+		"category":      "policy",
+		"workload_kind": kind,
+		"workload":      name,
+		"image":         image.Image,
+		"registry":      image.Registry,
+	}
+}
+
+// matchesAny reports whether value matches any of the given glob patterns
+func matchesAny(value string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == value {
+			return true
+		}
+		if ok, err := path.Match(pattern, value); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
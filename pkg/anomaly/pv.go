@@ -0,0 +1,91 @@
+package anomaly
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// SetPV sets the PersistentVolume hygiene check configuration
+func (d *Detector) SetPV(pv config.PVConfig) {
+	d.pv = pv
+}
+
+// detectPVAnomalies flags failed volumes, volumes stuck Released although their reclaim policy
+// should delete them, and Retain-policy volumes accumulating without claims
+func (d *Detector) detectPVAnomalies(state types.ClusterState) []types.Anomaly {
+	var anomalies []types.Anomaly
+
+	released := make(map[string]int)
+	var retained []string
+	for _, pv := range state.PersistentVolumes {
+		labels := map[string]string{"category": "storage", "storage_class": pv.StorageClassName, "reclaim_policy": pv.ReclaimPolicy}
+		claim := "no claim"
+		if pv.ClaimName != "" {
+			claim = "claim " + pv.ClaimNamespace + "/" + pv.ClaimName
+		}
+
+		switch {
+		case pv.Status == "Failed":
+			if d.shouldSuppressAlert("PVFailed", pv.Name, "status") {
+				continue
+			}
+			anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+				Type:         "PVFailed",
+				ResourceType: "persistentvolume",
+				Resource:     pv.Name,
+				Severity:     types.SeverityMedium,
+				Description:  fmt.Sprintf("PV %s failed automatic reclamation (reclaim policy: %s, %s, storage class: %s)", pv.Name, pv.ReclaimPolicy, claim, pv.StorageClassName),
+				Labels:       labels,
+			}))
+			d.recordAlertTime("PVFailed", pv.Name, "status")
+
+		case pv.ReclaimPolicy == "Retain" && (pv.Status == "Released" || pv.Status == "Available"):
+			// Retained volumes are kept on purpose; only their number is checked
+			retained = append(retained, pv.Name)
+
+		case pv.Status == "Released":
+			cycles := d.pvReleasedCycles[pv.Name] + 1
+			released[pv.Name] = cycles
+			if cycles < d.pv.ReleasedCycles || d.shouldSuppressAlert("PVStuckReleased", pv.Name, "status") {
+				continue
+			}
+			anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+				Type:         "PVStuckReleased",
+				ResourceType: "persistentvolume",
+				Resource:     pv.Name,
+				Severity:     types.SeverityMedium,
+				Description:  fmt.Sprintf("PV %s has been Released for %d cycles but not reclaimed (reclaim policy: %s, %s, storage class: %s)", pv.Name, cycles, pv.ReclaimPolicy, claim, pv.StorageClassName),
+				Value:        float64(cycles),
+				Threshold:    float64(d.pv.ReleasedCycles),
+				Labels:       labels,
+			}))
+			d.recordAlertTime("PVStuckReleased", pv.Name, "status")
+		}
+	}
+	// Only volumes that are still Released keep their count
+	d.pvReleasedCycles = released
+
+	if len(retained) > d.pv.MaxRetainedVolumes && !d.shouldSuppressAlert("RetainedPVsAccumulating", state.ClusterID, "count") {
+		sort.Strings(retained)
+		examples := retained
+		if len(examples) > 5 {
+			examples = examples[:5]
+		}
+		anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+			Type:         "RetainedPVsAccumulating",
+			ResourceType: "persistentvolume",
+			Resource:     state.ClusterName,
+			Severity:     types.SeverityLow,
+			Description:  fmt.Sprintf("%d unclaimed PVs with Retain policy are accumulating (limit: %d), e.g. %s", len(retained), d.pv.MaxRetainedVolumes, strings.Join(examples, ", ")),
+			Value:        float64(len(retained)),
+			Threshold:    float64(d.pv.MaxRetainedVolumes),
+			Labels:       map[string]string{"category": "storage"},
+		}))
+		d.recordAlertTime("RetainedPVsAccumulating", state.ClusterID, "count")
+	}
+	return anomalies
+}
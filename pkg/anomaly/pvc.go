@@ -0,0 +1,71 @@
+package anomaly
+
+import (
+	"fmt"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// SetPVC sets the PersistentVolumeClaim check configuration
+func (d *Detector) SetPVC(pvc config.PVCConfig) {
+	d.pvc = pvc
+}
+
+// detectPVCAnomalies flags claims that stay Pending for too many consecutive cycles and claims
+// whose volume was lost
+func (d *Detector) detectPVCAnomalies(state types.ClusterState) []types.Anomaly {
+	var anomalies []types.Anomaly
+
+	pending := make(map[string]int)
+	for ns, resources := range state.Resources {
+		for _, pvc := range resources.PersistentVolumeClaims {
+			key := ns + "/" + pvc.Name
+			storageClass := pvc.StorageClassName
+			if storageClass == "" {
+				storageClass = "<none>"
+			}
+			labels := map[string]string{"category": "storage", "storage_class": pvc.StorageClassName}
+
+			switch pvc.Status {
+			case "Pending":
+				cycles := d.pvcPendingCycles[key] + 1
+				pending[key] = cycles
+				if cycles < d.pvc.PendingCycles || d.shouldSuppressAlert("PVCPending", key, "status") {
+					continue
+				}
+				anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+					Type:         "PVCPending",
+					ResourceType: "persistentvolumeclaim",
+					Resource:     pvc.Name,
+					Namespace:    ns,
+					Severity:     types.SeverityMedium,
+					Description:  fmt.Sprintf("PVC %s/%s has been Pending for %d cycles (storage class: %s, requested: %s)", ns, pvc.Name, cycles, storageClass, pvc.RequestedStorage),
+					Value:        float64(cycles),
+					Threshold:    float64(d.pvc.PendingCycles),
+					Labels:       labels,
+				}))
+				d.recordAlertTime("PVCPending", key, "status")
+
+			case "Lost":
+				if d.shouldSuppressAlert("PVCLost", key, "status") {
+					continue
+				}
+				anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+					Type:         "PVCLost",
+					ResourceType: "persistentvolumeclaim",
+					Resource:     pvc.Name,
+					Namespace:    ns,
+					Severity:     types.SeverityHigh,
+					Description:  fmt.Sprintf("PVC %s/%s is Lost, its volume %s no longer exists (storage class: %s)", ns, pvc.Name, pvc.VolumeName, storageClass),
+					Labels:       labels,
+				}))
+				d.recordAlertTime("PVCLost", key, "status")
+			}
+		}
+	}
+
+	// Only claims that are still Pending keep their count
+	d.pvcPendingCycles = pending
+	return anomalies
+}
@@ -0,0 +1,64 @@
+package anomaly
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// resourceIndex maps the resources of the analyzed state to the nodes they run on, so every
+// anomaly can carry its node
+type resourceIndex struct {
+	podNodes       map[string]string // key: "namespace/pod", value: node name
+	nodes          map[string]bool
+	nodeNamespaces map[string]string // key: node name, value: NamespacesOnThisNode of its anomalies
+}
+
+// indexResources indexes the nodes and pods of the state anomalies are detected in
+func (d *Detector) indexResources(state types.ClusterState) {
+	index := resourceIndex{
+		podNodes:       make(map[string]string),
+		nodes:          make(map[string]bool, len(state.Nodes)),
+		nodeNamespaces: make(map[string]string, len(state.Nodes)),
+	}
+	for _, node := range state.Nodes {
+		index.nodes[node.Name] = true
+		index.nodeNamespaces[node.Name] = nodeNamespacesInfo(node)
+	}
+	for ns, resources := range state.Resources {
+		for _, pod := range resources.Pods {
+			if pod.NodeName != "" {
+				index.podNodes[ns+"/"+pod.Name] = pod.NodeName
+			}
+		}
+	}
+	d.resources = index
+}
+
+// resourceNode returns the node an anomaly's resource is or runs on: the node itself, or the node
+// of a pod, including the pod or node an event is about
+func (d *Detector) resourceNode(resourceType, namespace, resource string) string {
+	switch resourceType {
+	case "node":
+		return resource
+	case "pod":
+		return d.resources.podNodes[namespace+"/"+resource]
+	case "event":
+		if node, ok := d.resources.podNodes[namespace+"/"+resource]; ok {
+			return node
+		}
+		if d.resources.nodes[resource] {
+			return resource
+		}
+	}
+	return ""
+}
+
+// nodeNamespacesInfo describes the namespaces running on a node for anomaly descriptions
+func nodeNamespacesInfo(node types.Node) string {
+	if len(node.Namespaces) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" namespaces on this node: %s)", strings.Join(node.Namespaces, ", "))
+}
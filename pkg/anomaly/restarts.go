@@ -0,0 +1,80 @@
+package anomaly
+
+import (
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// restartGracePeriod is how long the restart count of a pod missing from the state is kept, so a
+// partial or failed pod listing does not make returning pods count all their restarts as new
+const restartGracePeriod = 15 * time.Minute
+
+// restartHistory tracks the restarts of a pod between cycles
+type restartHistory struct {
+	count    int32          // Cumulative restart count at the last cycle the pod was seen
+	deltas   []restartDelta // Restarts observed within the window
+	lastSeen time.Time
+}
+
+// restartDelta is the number of restarts observed in one cycle
+type restartDelta struct {
+	at       time.Time
+	restarts int
+}
+
+// SetRestartWindow sets the window over which pod restarts are counted
+func (d *Detector) SetRestartWindow(window time.Duration) {
+	d.restartWindow = window
+}
+
+// updateRestartRates counts each pod's restarts within the restart window. RestartCount never
+// resets, so the rate is built from the increase between cycles: a pod that restarted often
+// long ago but is stable now counts zero.
+func (d *Detector) updateRestartRates(state types.ClusterState) {
+	tracked := make(map[string]*restartHistory)
+	rates := make(map[string]int)
+	for ns, resources := range state.Resources {
+		for _, pod := range resources.Pods {
+			key := ns + "/" + pod.Name
+			var delta int32
+			previous, seen := d.restartCounts[key]
+			switch {
+			case seen && pod.RestartCount >= previous.count:
+				delta = pod.RestartCount - previous.count
+			case seen || d.restartsPrimed:
+				// The count was reset by a recreated pod, or the pod started since the last
+				// cycle; either way all of its restarts are recent
+				delta = pod.RestartCount
+			}
+
+			current := &restartHistory{count: pod.RestartCount, lastSeen: d.observedAt}
+			if previous != nil {
+				for _, sample := range previous.deltas {
+					if d.observedAt.Sub(sample.at) < d.restartWindow {
+						current.deltas = append(current.deltas, sample)
+					}
+				}
+			}
+			if delta > 0 {
+				current.deltas = append(current.deltas, restartDelta{at: d.observedAt, restarts: int(delta)})
+			}
+
+			for _, sample := range current.deltas {
+				rates[key] += sample.restarts
+			}
+			tracked[key] = current
+		}
+	}
+
+	// Pods missing from this state keep their counts for a grace period, after which they are
+	// assumed to be gone
+	for key, previous := range d.restartCounts {
+		if _, exists := tracked[key]; !exists && d.observedAt.Sub(previous.lastSeen) < restartGracePeriod {
+			tracked[key] = previous
+		}
+	}
+	d.restartCounts = tracked
+	d.restartRates = rates
+	d.restartsPrimed = true
+}
@@ -0,0 +1,82 @@
+package anomaly
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+)
+
+// Statistics modes used to build the baseline a value is compared against
+const (
+	StatisticsStandard = "standard" // mean and standard deviation
+	StatisticsRobust   = "robust"   // median and median absolute deviation
+)
+
+// madScale makes the median absolute deviation comparable to the standard deviation of normally
+// distributed data, so the same z-score conditions and minimum deviation apply in both modes
+const madScale = 1.4826
+
+// SetStatistics sets the statistics mode. Unknown modes fall back to standard statistics.
+func (d *Detector) SetStatistics(mode string) {
+	switch mode {
+	case StatisticsStandard, StatisticsRobust:
+		d.statistics = mode
+	case "":
+		d.statistics = StatisticsStandard
+	default:
+		log.Printf("Warning: unknown statistics mode %q, using %s", mode, StatisticsStandard)
+		d.statistics = StatisticsStandard
+	}
+}
+
+// ComputeRobustStats calculates the median, the scaled median absolute deviation and the ewma
+// for a metric from history. Unlike the standard deviation, a single large outlier barely moves
+// the MAD, so it does not mask later anomalies.
+func (d *Detector) ComputeRobustStats(values []float64, alpha float64) (median, mad, ewma float64) {
+	if len(values) == 0 {
+		return 0, 0, 0
+	}
+	median = medianOf(values)
+
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - median)
+	}
+	mad = medianOf(deviations) * madScale
+
+	ewma = values[0]
+	for i := 1; i < len(values); i++ {
+		ewma = alpha*values[i] + (1-alpha)*ewma
+	}
+	return median, mad, ewma
+}
+
+// baseline returns the center, spread and ewma of history using the configured statistics mode
+func (d *Detector) baseline(values []float64, alpha float64) (center, spread, ewma float64) {
+	if d.statistics == StatisticsRobust {
+		return d.ComputeRobustStats(values, alpha)
+	}
+	return d.ComputeStats(values, alpha)
+}
+
+// describeBaseline formats a baseline for anomaly descriptions, e.g. "mean: 40.00%, stddev: 2.00%"
+func (d *Detector) describeBaseline(center, spread float64, unit string) string {
+	if d.statistics == StatisticsRobust {
+		return fmt.Sprintf("median: %.2f%s, MAD: %.2f%s", center, unit, spread, unit)
+	}
+	return fmt.Sprintf("mean: %.2f%s, stddev: %.2f%s", center, unit, spread, unit)
+}
+
+// medianOf returns the median of values without modifying them
+func medianOf(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,158 @@
+package anomaly
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/rules"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// ruleResourceTypes maps the resources rules can be written for to the resource type of their anomalies
+var ruleResourceTypes = map[string]string{
+	"node":    "node",
+	"pod":     "pod",
+	"pvc":     "persistentvolumeclaim",
+	"pv":      "persistentvolume",
+	"event":   "event",
+	"cluster": "cluster",
+}
+
+// compiledRule is a custom rule with its parsed expression
+type compiledRule struct {
+	config.RuleConfig
+	program *rules.Program
+	failing bool // Whether the last evaluation failed, so the error is logged once
+}
+
+// SetRules compiles the custom anomaly rules. Invalid rules are ignored.
+func (d *Detector) SetRules(ruleConfigs []config.RuleConfig) {
+	d.rules = nil
+	for _, rc := range ruleConfigs {
+		if rc.Name == "" {
+			log.Printf("Warning: ignoring rule without a name: %q", rc.Expr)
+			continue
+		}
+		if _, ok := ruleResourceTypes[rc.Resource]; !ok {
+			log.Printf("Warning: ignoring rule %s with unknown resource %q", rc.Name, rc.Resource)
+			continue
+		}
+		program, err := rules.Compile(rc.Expr)
+		if err != nil {
+			log.Printf("Warning: ignoring rule %s with invalid expression: %v", rc.Name, err)
+			continue
+		}
+		if rc.Type == "" {
+			rc.Type = rc.Name
+		}
+		if rc.Severity == "" {
+			rc.Severity = string(types.SeverityMedium)
+		}
+		severity, err := types.ParseSeverity(rc.Severity)
+		if err != nil {
+			log.Printf("Warning: ignoring rule %s: %v", rc.Name, err)
+			continue
+		}
+		rc.Severity = string(severity)
+		d.rules = append(d.rules, &compiledRule{RuleConfig: rc, program: program})
+	}
+}
+
+// ruleTarget is a resource a rule expression is evaluated against
+type ruleTarget struct {
+	env       map[string]interface{}
+	resource  string
+	namespace string
+	nodeName  string
+}
+
+// ruleTargets returns the resources of a kind with the variables their expressions see: the
+// resource under its kind (node, pod, pvc, pv, event) and the whole state as cluster. Pod
+// expressions also see the pod's node and namespace, pvc expressions the namespace.
+func ruleTargets(state *types.ClusterState, resource string) []ruleTarget {
+	var targets []ruleTarget
+	switch resource {
+	case "node":
+		for _, node := range state.Nodes {
+			targets = append(targets, ruleTarget{map[string]interface{}{"node": node}, node.Name, "", node.Name})
+		}
+	case "pod":
+		nodes := make(map[string]types.Node, len(state.Nodes))
+		for _, node := range state.Nodes {
+			nodes[node.Name] = node
+		}
+		for ns, resources := range state.Resources {
+			for _, pod := range resources.Pods {
+				targets = append(targets, ruleTarget{map[string]interface{}{"pod": pod, "node": nodes[pod.NodeName], "namespace": ns}, pod.Name, ns, pod.NodeName})
+			}
+		}
+	case "pvc":
+		for ns, resources := range state.Resources {
+			for _, pvc := range resources.PersistentVolumeClaims {
+				targets = append(targets, ruleTarget{map[string]interface{}{"pvc": pvc, "namespace": ns}, pvc.Name, ns, ""})
+			}
+		}
+	case "pv":
+		for _, pv := range state.PersistentVolumes {
+			targets = append(targets, ruleTarget{map[string]interface{}{"pv": pv}, pv.Name, "", ""})
+		}
+	case "event":
+		for _, event := range state.Events {
+			targets = append(targets, ruleTarget{map[string]interface{}{"event": event}, event.Resource, event.Namespace, ""})
+		}
+	case "cluster":
+		targets = append(targets, ruleTarget{map[string]interface{}{}, state.ClusterName, "", ""})
+	}
+	for _, target := range targets {
+		target.env["cluster"] = state
+	}
+	return targets
+}
+
+// detectRuleAnomalies evaluates the custom rules against every resource of their kind
+func (d *Detector) detectRuleAnomalies(state types.ClusterState) []types.Anomaly {
+	var anomalies []types.Anomaly
+	if len(d.rules) == 0 {
+		return anomalies
+	}
+
+	targets := make(map[string][]ruleTarget) // resource kind -> targets
+	for _, rule := range d.rules {
+		if _, ok := targets[rule.Resource]; !ok {
+			targets[rule.Resource] = ruleTargets(&state, rule.Resource)
+		}
+		for _, target := range targets[rule.Resource] {
+			matched, err := rule.program.Match(target.env)
+			if err != nil {
+				if !rule.failing {
+					log.Printf("Warning: rule %s failed on %s %s: %v", rule.Name, rule.Resource, target.resource, err)
+				}
+				rule.failing = true
+				continue
+			}
+			rule.failing = false
+
+			key := target.namespace + "/" + target.resource
+			if !matched || d.shouldSuppressAlert(rule.Type, key, "rule:"+rule.Name) {
+				continue
+			}
+			description := rule.Description
+			if description == "" {
+				description = fmt.Sprintf("Rule %s matched %s %s: %s", rule.Name, rule.Resource, target.resource, rule.Expr)
+			}
+			anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+				Type:         rule.Type,
+				ResourceType: ruleResourceTypes[rule.Resource],
+				Resource:     target.resource,
+				Namespace:    target.namespace,
+				NodeName:     target.nodeName,
+				Severity:     types.Severity(rule.Severity),
+				Description:  description,
+				Labels:       map[string]string{"category": "rule", "rule": rule.Name},
+			}))
+			d.recordAlertTime(rule.Type, key, "rule:"+rule.Name)
+		}
+	}
+	return anomalies
+}
@@ -0,0 +1,83 @@
+package anomaly
+
+import (
+	"math"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// persistenceGap is how long an anomaly may go unreported before it counts as a new occurrence.
+// It exceeds the five minute deduplication window, so suppressed repeats keep it alive.
+const persistenceGap = 15 * time.Minute
+
+// Weights of the components of the anomaly score; they add up to 100
+const (
+	severityWeight    = 40.0 // Severity rank
+	deviationWeight   = 30.0 // Spreads from the baseline center, full at 6
+	breachWeight      = 20.0 // Relative excess over the threshold, full at 100%
+	persistenceWeight = 10.0 // Time the anomaly has persisted, full at an hour
+)
+
+// occurrence is the span over which an anomaly has been reported without a gap
+type occurrence struct {
+	first, last time.Time
+	clusterID   string
+	anomalyType string
+	severity    types.Severity // Of the latest report
+}
+
+// OpenAnomalies counts the anomalies of a cluster that are still open, i.e. were reported within
+// the persistence gap, by type and severity
+func (d *Detector) OpenAnomalies(clusterID string) map[string]map[types.Severity]int {
+	open := make(map[string]map[types.Severity]int)
+	for _, o := range d.occurrences {
+		if o.clusterID != clusterID {
+			continue
+		}
+		if open[o.anomalyType] == nil {
+			open[o.anomalyType] = make(map[types.Severity]int)
+		}
+		open[o.anomalyType][o.severity]++
+	}
+	return open
+}
+
+// scoreAnomalies sets the score of each anomaly: a 0-100 ranking combining its severity, the
+// deviation of its value from the baseline, how far it exceeds its threshold and how long it has
+// persisted
+func (d *Detector) scoreAnomalies(anomalies []types.Anomaly) {
+	now := d.observedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	for key, o := range d.occurrences {
+		if now.Sub(o.last) > persistenceGap {
+			delete(d.occurrences, key)
+		}
+	}
+
+	for i := range anomalies {
+		anomaly := &anomalies[i]
+		key := anomaly.ClusterID + ":" + anomaly.Type + ":" + anomaly.Namespace + "/" + anomaly.Resource
+		o, ok := d.occurrences[key]
+		if !ok {
+			o = &occurrence{first: now, clusterID: anomaly.ClusterID, anomalyType: anomaly.Type}
+			d.occurrences[key] = o
+		}
+		o.last = now
+		o.severity = anomaly.Severity
+
+		score := severityWeight * float64(max(anomaly.Severity.Rank()-1, 0)) / float64(len(types.Severities)-1)
+		if metric := MetricOf(*anomaly); metric != "" {
+			if center, spread, _ := d.seriesBaseline(anomaly.ResourceType, anomaly.Resource, metric); spread > 0 {
+				score += deviationWeight * math.Min(math.Abs(anomaly.Value-center)/spread/6, 1)
+			}
+		}
+		if anomaly.Threshold > 0 && anomaly.Value > anomaly.Threshold {
+			score += breachWeight * math.Min((anomaly.Value-anomaly.Threshold)/anomaly.Threshold, 1)
+		}
+		score += persistenceWeight * math.Min(o.last.Sub(o.first).Hours(), 1)
+		anomaly.Score = math.Round(score*10) / 10
+	}
+}
@@ -0,0 +1,104 @@
+package anomaly
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/features"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// SetSecurityPolicy sets the privileged workload policy
+func (d *Detector) SetSecurityPolicy(policy config.SecurityPolicyConfig) {
+	d.securityPolicy = policy
+}
+
+// detectSecurityPolicyAnomalies flags workloads running with host or root privileges in
+// namespaces where privileged workloads are not expected, once per workload
+func (d *Detector) detectSecurityPolicyAnomalies(state types.ClusterState) []types.Anomaly {
+	var anomalies []types.Anomaly
+	if !d.flags.Enabled(features.SecurityPolicy, state.ClusterID, d.securityPolicy.Enabled) {
+		return anomalies
+	}
+
+	for ns, resources := range state.Resources {
+		if matchesAny(ns, d.securityPolicy.PrivilegedNamespaces) {
+			continue
+		}
+
+		// Pods of the same workload share a spec, so collect the privileges per workload
+		privileges := make(map[string]map[string]bool)
+		for _, pod := range resources.Pods {
+			workload := pod.OwnerKind + "/" + pod.OwnerName
+			for _, privilege := range d.podPrivileges(pod.Security) {
+				if privileges[workload] == nil {
+					privileges[workload] = make(map[string]bool)
+				}
+				privileges[workload][privilege] = true
+			}
+		}
+
+		for workload, set := range privileges {
+			granted := make([]string, 0, len(set))
+			for privilege := range set {
+				granted = append(granted, privilege)
+			}
+			sort.Strings(granted)
+			reasons := strings.Join(granted, ",")
+
+			key := ns + "/" + workload
+			if d.shouldSuppressAlert("PrivilegedWorkload", key, reasons) {
+				continue
+			}
+			kind, name := splitWorkload(workload)
+			anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+				Type:         "PrivilegedWorkload",
+				ResourceType: "workload",
+				Resource:     workload,
+				Namespace:    ns,
+				Severity:     types.SeverityMedium,
+				Description:  fmt.Sprintf("%s in namespace %s runs with %s, which is not expected in this namespace", workload, ns, strings.Join(granted, ", ")),
+				Labels: map[string]string{
+					"category":      "policy",
+					"workload_kind": kind,
+					"workload":      name,
+					"privileges":    reasons,
+				},
+			}))
+			d.recordAlertTime("PrivilegedWorkload", key, reasons)
+		}
+	}
+
+	return anomalies
+}
+
+// podPrivileges lists the privileges of a pod that the policy flags
+func (d *Detector) podPrivileges(security types.PodSecurity) []string {
+	var privileges []string
+	if security.Privileged {
+		privileges = append(privileges, "privileged")
+	}
+	if security.HostNetwork {
+		privileges = append(privileges, "hostNetwork")
+	}
+	if security.HostPID {
+		privileges = append(privileges, "hostPID")
+	}
+	for _, p := range security.HostPathMounts {
+		privileges = append(privileges, "hostPath:"+p)
+	}
+	if security.RunAsRoot && d.securityPolicy.CheckRunAsRoot {
+		privileges = append(privileges, "runAsRoot")
+	}
+	return privileges
+}
+
+// splitWorkload splits a "Kind/Name" workload reference
+func splitWorkload(workload string) (kind, name string) {
+	if i := strings.Index(workload, "/"); i >= 0 {
+		return workload[:i], workload[i+1:]
+	}
+	return workload, ""
+}
@@ -0,0 +1,44 @@
+package anomaly
+
+import (
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// SetSeverity sets the severity scoring configuration
+func (d *Detector) SetSeverity(severity config.SeverityConfig) {
+	d.severity = severity
+}
+
+// scoreSeverity returns the severity of a threshold anomaly on metric from how far value exceeds
+// the metric's value boundaries and how many spreads it lies above the baseline center, whichever
+// is higher. Without scoring enabled the fallback severity is returned. A zero spread skips the
+// deviation, e.g. while history is insufficient.
+func (d *Detector) scoreSeverity(metric string, value, center, spread float64, fallback types.Severity) types.Severity {
+	if !d.severity.Enabled {
+		return fallback
+	}
+
+	rank := 0
+	if boundaries, ok := d.severity.Metrics[metric]; ok {
+		rank = boundaryRank(value, boundaries)
+	}
+	if spread > 0 {
+		rank = max(rank, boundaryRank((value-center)/spread, d.severity.Deviation))
+	}
+	return types.Severities[rank]
+}
+
+// boundaryRank returns the index in types.Severities of the highest boundary reached by value
+func boundaryRank(value float64, boundaries config.SeverityBoundaries) int {
+	switch {
+	case boundaries.Critical > 0 && value >= boundaries.Critical:
+		return 3
+	case boundaries.High > 0 && value >= boundaries.High:
+		return 2
+	case boundaries.Medium > 0 && value >= boundaries.Medium:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,98 @@
+package anomaly
+
+import (
+	"fmt"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// SetSlope sets the rate-of-change detection configuration
+func (d *Detector) SetSlope(slope config.SlopeConfig) {
+	d.slope = slope
+}
+
+// detectGrowthAnomalies flags node metrics that have been climbing steadily over the last
+// intervals, such as a memory leak, even while usage is still below the threshold
+func (d *Detector) detectGrowthAnomalies(state types.ClusterState) []types.Anomaly {
+	var anomalies []types.Anomaly
+	if !d.slope.Enabled {
+		return anomalies
+	}
+
+	for _, node := range state.Nodes {
+		for _, metric := range []string{"cpu", "memory"} {
+			vals := d.GetMetricHistory("node", node.Name, metric)
+			if len(vals) < d.slope.Intervals {
+				continue
+			}
+			recent := vals[len(vals)-d.slope.Intervals:]
+			slope, _, r2 := fitTrend(recent)
+			// Require a consistent climb so a single jump does not count as growth
+			if slope < d.slope.MinSlope || r2 < d.slope.MinFit {
+				continue
+			}
+			if d.shouldSuppressAlert("HighGrowthRate", node.Name, metric) {
+				continue
+			}
+			anomalies = append(anomalies, d.newAnomaly(state, anomalyParams{
+				Type:         "HighGrowthRate",
+				ResourceType: "node",
+				Resource:     node.Name,
+				NodeName:     node.Name,
+				Severity:     types.SeverityMedium,
+				Description:  fmt.Sprintf("%s usage on node %s grew %.2f%% per interval over the last %d intervals (now %.2f%%, fit: %.2f)", metric, node.Name, slope, len(recent), recent[len(recent)-1], r2),
+				Value:        slope,
+				Threshold:    d.slope.MinSlope,
+				Labels:       map[string]string{"metric": metric},
+			}))
+			d.recordAlertTime("HighGrowthRate", node.Name, metric)
+		}
+	}
+	return anomalies
+}
+
+// fitTrend fits values against their index by least squares, returning the slope per
+// interval, the intercept and the coefficient of determination (1 is a perfect line)
+func fitTrend(values []float64) (slope, intercept, r2 float64) {
+	xs := make([]float64, len(values))
+	for i := range values {
+		xs[i] = float64(i)
+	}
+	return fitLine(xs, values)
+}
+
+// fitLine fits ys against xs by least squares, returning the slope, the intercept and the
+// coefficient of determination
+func fitLine(xs, ys []float64) (slope, intercept, r2 float64) {
+	n := float64(len(ys))
+	if n < 2 {
+		return 0, 0, 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range ys {
+		x := xs[i]
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, sumY / n, 0
+	}
+	slope = (n*sumXY - sumX*sumY) / denominator
+	intercept = (sumY - slope*sumX) / n
+
+	mean := sumY / n
+	var ssTot, ssRes float64
+	for i, y := range ys {
+		predicted := intercept + slope*xs[i]
+		ssTot += (y - mean) * (y - mean)
+		ssRes += (y - predicted) * (y - predicted)
+	}
+	if ssTot == 0 {
+		return slope, intercept, 0
+	}
+	return slope, intercept, 1 - ssRes/ssTot
+}
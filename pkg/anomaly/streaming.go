@@ -0,0 +1,66 @@
+package anomaly
+
+import "math"
+
+// seriesStats holds the mean, variance and ewma of one metric series, updated as observations are
+// added to and evicted from its history so a baseline costs O(1) instead of a history scan
+type seriesStats struct {
+	count int
+	mean  float64
+	m2    float64 // Sum of squared differences from the mean (Welford)
+	ewma  float64
+}
+
+// add includes a new observation
+func (s *seriesStats) add(value, alpha float64) {
+	s.count++
+	delta := value - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (value - s.mean)
+	if s.count == 1 {
+		s.ewma = value
+	} else {
+		s.ewma = alpha*value + (1-alpha)*s.ewma
+	}
+}
+
+// remove excludes the oldest observation. The ewma keeps it, but its weight has long decayed.
+func (s *seriesStats) remove(value float64) {
+	if s.count <= 1 {
+		*s = seriesStats{}
+		return
+	}
+	delta := value - s.mean
+	s.count--
+	s.mean -= delta / float64(s.count)
+	s.m2 = math.Max(0, s.m2-delta*(value-s.mean))
+}
+
+// stddev returns the sample standard deviation
+func (s *seriesStats) stddev() float64 {
+	if s.count < 2 {
+		return 0
+	}
+	return math.Sqrt(s.m2 / float64(s.count-1))
+}
+
+// sampleCount returns the number of observations of a series in the history
+func (d *Detector) sampleCount(resourceType, resourceID, metricType string) int {
+	if series, ok := d.history[seriesKey(resourceType, resourceID, metricType)]; ok {
+		return series.stats.count
+	}
+	return 0
+}
+
+// seriesBaseline returns the center, spread and ewma of a series using the configured statistics
+// mode. Standard statistics are maintained incrementally; the median needs the full history.
+func (d *Detector) seriesBaseline(resourceType, resourceID, metricType string) (center, spread, ewma float64) {
+	if d.statistics == StatisticsRobust {
+		return d.baseline(d.GetMetricHistory(resourceType, resourceID, metricType), d.getAlphaForMetric(metricType))
+	}
+	series, ok := d.history[seriesKey(resourceType, resourceID, metricType)]
+	if !ok {
+		return 0, 0, 0
+	}
+	return series.stats.mean, series.stats.stddev(), series.stats.ewma
+}
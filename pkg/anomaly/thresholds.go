@@ -0,0 +1,87 @@
+package anomaly
+
+import (
+	"log"
+	"math"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// thresholdOverride is a config.ThresholdOverride with its label selector parsed
+type thresholdOverride struct {
+	config.ThresholdOverride
+	selector labels.Selector // nil when no selector is configured
+}
+
+// SetThresholdOverrides sets per-resource thresholds that take precedence over the global ones.
+// Overrides with an invalid label selector are skipped.
+func (d *Detector) SetThresholdOverrides(overrides []config.ThresholdOverride) {
+	d.thresholdOverrides = make([]thresholdOverride, 0, len(overrides))
+	for _, o := range overrides {
+		override := thresholdOverride{ThresholdOverride: o}
+		if o.LabelSelector != "" {
+			selector, err := labels.Parse(o.LabelSelector)
+			if err != nil {
+				log.Printf("Warning: ignoring threshold override with invalid label selector %q: %v", o.LabelSelector, err)
+				continue
+			}
+			override.selector = selector
+		}
+		d.thresholdOverrides = append(d.thresholdOverrides, override)
+	}
+}
+
+// nodeThresholds returns the CPU and memory thresholds that apply to a node, adjusted by the
+// feedback its anomalies received
+func (d *Detector) nodeThresholds(node types.Node) (cpu, memory float64) {
+	cpu, memory = d.cpuThreshold, d.memoryThreshold
+	for _, o := range d.thresholdOverrides {
+		// Namespace-scoped overrides only apply to pods
+		if o.Namespace != "" || !o.matches(node.Name, node.Labels) {
+			continue
+		}
+		if o.CPUThreshold > 0 {
+			cpu = o.CPUThreshold
+		}
+		if o.MemoryThreshold > 0 {
+			memory = o.MemoryThreshold
+		}
+		break
+	}
+	cpu = math.Min(100, cpu*d.sensitivity("HighCPUUsage", "", node.Name))
+	memory = math.Min(100, memory*d.sensitivity("HighMemoryUsage", "", node.Name))
+	return cpu, memory
+}
+
+// podRestartThreshold returns the restart threshold that applies to a pod, adjusted by the feedback
+// its anomalies received
+func (d *Detector) podRestartThreshold(pod types.Pod, namespace string) int {
+	threshold := d.podRestarts
+	for _, o := range d.thresholdOverrides {
+		if o.Namespace != "" && !matchesAny(namespace, []string{o.Namespace}) {
+			continue
+		}
+		if !o.matches(pod.NodeName, pod.Labels) {
+			continue
+		}
+		if o.PodRestartThreshold > 0 {
+			threshold = o.PodRestartThreshold
+		}
+		break
+	}
+	return int(math.Round(float64(threshold) * d.sensitivity("HighPodRestarts", namespace, pod.Name)))
+}
+
+// matches reports whether the node name pattern and label selector of an override match
+func (o thresholdOverride) matches(nodeName string, resourceLabels map[string]string) bool {
+	if o.NodeNamePattern != "" && !matchesAny(nodeName, []string{o.NodeNamePattern}) {
+		return false
+	}
+	if o.selector != nil && !o.selector.Matches(labels.Set(resourceLabels)) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package anomaly
+
+import (
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+)
+
+// trendBucket aggregates the observations of one resolution interval
+type trendBucket struct {
+	start time.Time
+	count int
+	sum   float64
+	min   float64
+	max   float64
+}
+
+// trendTier holds the buckets of one resolution, oldest first
+type trendTier struct {
+	resolution time.Duration
+	retention  time.Duration
+	buckets    []trendBucket
+}
+
+// TrendPoint is a downsampled history point
+type TrendPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Mean      float64   `json:"mean"`
+	Min       float64   `json:"min"`
+	Max       float64   `json:"max"`
+	Count     int       `json:"count"`
+}
+
+// Trend is the downsampled history of a resource metric with its fitted trend and the
+// baseline the detector currently judges new observations against
+type Trend struct {
+	ResourceType string       `json:"resourceType"`
+	Resource     string       `json:"resource"`
+	Metric       string       `json:"metric"`
+	Window       string       `json:"window"`
+	Resolution   string       `json:"resolution"`
+	Points       []TrendPoint `json:"points"`
+	Slope        float64      `json:"slopePerHour"` // Change of the bucket mean per hour
+	Intercept    float64      `json:"intercept"`    // Fitted value at the first point
+	R2           float64      `json:"r2"`
+	Baseline     struct {
+		Statistics string  `json:"statistics"`
+		Center     float64 `json:"center"` // Mean or median
+		Spread     float64 `json:"spread"` // Standard deviation or scaled MAD
+		EWMA       float64 `json:"ewma"`
+		Samples    int     `json:"samples"`
+	} `json:"baseline"`
+}
+
+// SetTrendHistory sets the downsampled history configuration
+func (d *Detector) SetTrendHistory(trendHistory config.TrendHistoryConfig) {
+	d.historyMu.Lock()
+	defer d.historyMu.Unlock()
+	d.trendHistory = trendHistory
+	d.trends = make(map[string][]*trendTier)
+}
+
+// recordTrend adds an observation to every tier of its series. Callers must hold d.historyMu.
+func (d *Detector) recordTrend(obs MetricObservation) {
+	key := obs.ResourceType + "/" + obs.ResourceID + "/" + obs.MetricType
+	tiers, ok := d.trends[key]
+	if !ok {
+		for _, tierCfg := range d.trendHistory.Tiers {
+			tiers = append(tiers, &trendTier{
+				resolution: time.Duration(tierCfg.ResolutionMinutes) * time.Minute,
+				retention:  time.Duration(tierCfg.RetentionHours) * time.Hour,
+			})
+		}
+		d.trends[key] = tiers
+	}
+
+	for _, tier := range tiers {
+		start := obs.Timestamp.Truncate(tier.resolution)
+		if n := len(tier.buckets); n > 0 && tier.buckets[n-1].start.Equal(start) {
+			bucket := &tier.buckets[n-1]
+			bucket.count++
+			bucket.sum += obs.Value
+			bucket.min = min(bucket.min, obs.Value)
+			bucket.max = max(bucket.max, obs.Value)
+		} else {
+			tier.buckets = append(tier.buckets, trendBucket{start: start, count: 1, sum: obs.Value, min: obs.Value, max: obs.Value})
+		}
+		tier.prune(obs.Timestamp)
+	}
+
+	// Drop series of resources that no longer report, e.g. deleted pods, once an hour
+	if obs.Timestamp.Sub(d.trendsPrunedAt) >= time.Hour {
+		for key, tiers := range d.trends {
+			empty := true
+			for _, tier := range tiers {
+				tier.prune(obs.Timestamp)
+				if len(tier.buckets) > 0 {
+					empty = false
+				}
+			}
+			if empty {
+				delete(d.trends, key)
+			}
+		}
+		d.trendsPrunedAt = obs.Timestamp
+	}
+}
+
+// prune drops buckets older than the tier's retention
+func (t *trendTier) prune(now time.Time) {
+	cutoff := now.Add(-t.retention)
+	i := 0
+	for i < len(t.buckets) && t.buckets[i].start.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		t.buckets = append(t.buckets[:0], t.buckets[i:]...)
+	}
+}
+
+// Trend returns the downsampled history of a resource metric over window, served from the finest
+// tier retaining the whole window. It returns false if the metric has no downsampled history.
+func (d *Detector) Trend(resourceType, resourceID, metricType string, window time.Duration) (Trend, bool) {
+	d.historyMu.RLock()
+	defer d.historyMu.RUnlock()
+
+	tiers, ok := d.trends[resourceType+"/"+resourceID+"/"+metricType]
+	if !ok || len(tiers) == 0 {
+		return Trend{}, false
+	}
+	tier := tiers[len(tiers)-1]
+	for _, candidate := range tiers {
+		if candidate.retention >= window {
+			tier = candidate
+			break
+		}
+	}
+
+	trend := Trend{
+		ResourceType: resourceType,
+		Resource:     resourceID,
+		Metric:       metricType,
+		Window:       window.String(),
+		Resolution:   tier.resolution.String(),
+		Points:       []TrendPoint{},
+	}
+
+	cutoff := time.Now().Add(-window)
+	var xs, ys []float64
+	for _, bucket := range tier.buckets {
+		if bucket.start.Before(cutoff) {
+			continue
+		}
+		mean := bucket.sum / float64(bucket.count)
+		trend.Points = append(trend.Points, TrendPoint{
+			Timestamp: bucket.start,
+			Mean:      mean,
+			Min:       bucket.min,
+			Max:       bucket.max,
+			Count:     bucket.count,
+		})
+		xs = append(xs, bucket.start.Sub(trend.Points[0].Timestamp).Hours())
+		ys = append(ys, mean)
+	}
+	if len(trend.Points) >= 2 {
+		trend.Slope, trend.Intercept, trend.R2 = fitLine(xs, ys)
+	}
+
+	values := d.GetMetricHistory(resourceType, resourceID, metricType)
+	trend.Baseline.Statistics = d.statistics
+	trend.Baseline.Samples = len(values)
+	if len(values) > 0 {
+		trend.Baseline.Center, trend.Baseline.Spread, trend.Baseline.EWMA = d.baseline(values, d.getAlphaForMetric(metricType))
+	}
+	return trend, true
+}
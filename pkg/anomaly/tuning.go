@@ -0,0 +1,63 @@
+package anomaly
+
+// TunableMetrics lists the metrics whose threshold and EWMA smoothing factor can be tuned at runtime
+var TunableMetrics = []string{"cpu", "memory", "disk", "restarts"}
+
+// Threshold returns the global threshold of a tunable metric, or 0 for other metrics
+func (d *Detector) Threshold(metric string) float64 {
+	d.historyMu.RLock()
+	defer d.historyMu.RUnlock()
+	switch metric {
+	case "cpu":
+		return d.cpuThreshold
+	case "memory":
+		return d.memoryThreshold
+	case "disk":
+		return d.diskThreshold
+	case "restarts":
+		return float64(d.podRestarts)
+	}
+	return 0
+}
+
+// SetThreshold sets the global threshold of a tunable metric. Threshold overrides still take
+// precedence for the resources they match.
+func (d *Detector) SetThreshold(metric string, threshold float64) {
+	// API handlers read the thresholds and alphas while holding historyMu
+	d.historyMu.Lock()
+	defer d.historyMu.Unlock()
+	switch metric {
+	case "cpu":
+		d.cpuThreshold = threshold
+	case "memory":
+		d.memoryThreshold = threshold
+	case "disk":
+		d.diskThreshold = threshold
+	case "restarts":
+		d.podRestarts = int(threshold + 0.5)
+	}
+}
+
+// Alpha returns the EWMA smoothing factor of a metric
+func (d *Detector) Alpha(metric string) float64 {
+	d.historyMu.RLock()
+	defer d.historyMu.RUnlock()
+	return d.getAlphaForMetric(metric)
+}
+
+// SetAlpha sets the EWMA smoothing factor of a tunable metric. It applies to observations
+// recorded from now on.
+func (d *Detector) SetAlpha(metric string, alpha float64) {
+	d.historyMu.Lock()
+	defer d.historyMu.Unlock()
+	switch metric {
+	case "cpu":
+		d.cpuStats.alpha = alpha
+	case "memory":
+		d.memoryStats.alpha = alpha
+	case "disk":
+		d.diskAlpha = alpha
+	case "restarts":
+		d.restartStats.alpha = alpha
+	}
+}
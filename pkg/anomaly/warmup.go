@@ -0,0 +1,60 @@
+package anomaly
+
+import (
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// SetWarmUp sets the grace periods during which anomalies are not reported: after the agent's
+// first observation, and for pods and nodes after their creation
+func (d *Detector) SetWarmUp(warmUp config.WarmUpConfig) {
+	d.warmUp = warmUp
+}
+
+// filterWarmUp drops the anomalies raised while the agent warms up, and those of pods and nodes
+// still in their grace period. Observations are recorded regardless, so baselines are built
+// during warm-up.
+func (d *Detector) filterWarmUp(state types.ClusterState, anomalies []types.Anomaly) []types.Anomaly {
+	if d.startedAt.IsZero() {
+		d.startedAt = d.observedAt
+	}
+	if d.observedAt.Sub(d.startedAt) < time.Duration(d.warmUp.AgentMinutes)*time.Minute {
+		return nil
+	}
+	if d.warmUp.PodMinutes <= 0 && d.warmUp.NodeMinutes <= 0 {
+		return anomalies
+	}
+
+	// Resources are keyed by "namespace/name"; nodes have no namespace
+	young := make(map[string]bool)
+	for _, node := range state.Nodes {
+		if d.warmingUp(node.CreatedAt, d.warmUp.NodeMinutes) {
+			young["/"+node.Name] = true
+		}
+	}
+	for ns, resources := range state.Resources {
+		for _, pod := range resources.Pods {
+			if d.warmingUp(pod.CreatedAt, d.warmUp.PodMinutes) {
+				young[ns+"/"+pod.Name] = true
+			}
+		}
+	}
+	if len(young) == 0 {
+		return anomalies
+	}
+
+	reported := anomalies[:0]
+	for _, anomaly := range anomalies {
+		if !young[anomaly.Namespace+"/"+anomaly.Resource] {
+			reported = append(reported, anomaly)
+		}
+	}
+	return reported
+}
+
+// warmingUp reports whether a resource created at createdAt is within a grace period of minutes
+func (d *Detector) warmingUp(createdAt time.Time, minutes int) bool {
+	return minutes > 0 && !createdAt.IsZero() && d.observedAt.Sub(createdAt) < time.Duration(minutes)*time.Minute
+}
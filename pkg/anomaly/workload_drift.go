@@ -0,0 +1,256 @@
+package anomaly
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// workloadSnapshot summarizes one workload in one cluster for the cross-cluster comparison
+type workloadSnapshot struct {
+	state     *types.ClusterState
+	namespace string
+	kind      string
+	name      string
+	replicas  int
+	images    map[string]string // container name -> image run by most of its pods
+	pods      int
+	failing   int // Pods that are not running or reported warning events this cycle
+}
+
+// errorRate returns the share of the workload's pods that are failing
+func (w *workloadSnapshot) errorRate() float64 {
+	if w.pods == 0 {
+		return 0
+	}
+	return float64(w.failing) / float64(w.pods)
+}
+
+// detectWorkloadDrift compares workloads that exist under the same namespace, kind and name in
+// several clusters. Clusters whose replica count, container images or share of failing pods
+// diverge from the rest of the fleet are reported.
+func (d *Detector) detectWorkloadDrift(states []types.ClusterState, enabled map[string]bool) []types.Anomaly {
+	var anomalies []types.Anomaly
+	if !d.drift.Workloads.Enabled {
+		return anomalies
+	}
+
+	fleet := make(map[string][]*workloadSnapshot) // "namespace/kind/name" -> one snapshot per cluster
+	for i := range states {
+		for _, workload := range workloadSnapshots(&states[i]) {
+			key := workload.namespace + "/" + workload.kind + "/" + workload.name
+			fleet[key] = append(fleet[key], workload)
+		}
+	}
+
+	keys := make([]string, 0, len(fleet))
+	for key, workloads := range fleet {
+		if len(workloads) >= 2 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		workloads := fleet[key]
+		anomalies = append(anomalies, d.detectReplicaDrift(workloads, enabled)...)
+		anomalies = append(anomalies, d.detectImageDrift(workloads, enabled)...)
+		anomalies = append(anomalies, d.detectErrorRateDrift(workloads, enabled)...)
+	}
+	return anomalies
+}
+
+// detectReplicaDrift flags clusters running a workload with a replica count far from the fleet median
+func (d *Detector) detectReplicaDrift(workloads []*workloadSnapshot, enabled map[string]bool) []types.Anomaly {
+	var anomalies []types.Anomaly
+	replicas := make([]float64, 0, len(workloads))
+	for _, w := range workloads {
+		replicas = append(replicas, float64(w.replicas))
+	}
+	expected := medianOf(replicas)
+	if expected == 0 {
+		return anomalies
+	}
+
+	for _, w := range workloads {
+		deviation := (float64(w.replicas) - expected) / expected
+		if !enabled[w.state.ClusterID] || deviation <= d.drift.Workloads.ReplicaTolerance && -deviation <= d.drift.Workloads.ReplicaTolerance {
+			continue
+		}
+		anomalies = append(anomalies, d.newWorkloadDrift(w, "WorkloadReplicaDrift", "replicas", types.SeverityLow,
+			fmt.Sprintf("%s %s/%s runs %d replicas in cluster %s while the fleet median is %g", w.kind, w.namespace, w.name, w.replicas, w.state.ClusterName, expected),
+			fmt.Sprintf("%g", expected), fmt.Sprintf("%d", w.replicas), float64(w.replicas), expected)...)
+	}
+	return anomalies
+}
+
+// detectImageDrift flags clusters running a container of a workload with a different image than the
+// rest of the fleet. Without a strict majority every cluster is flagged, since none is authoritative.
+func (d *Detector) detectImageDrift(workloads []*workloadSnapshot, enabled map[string]bool) []types.Anomaly {
+	var anomalies []types.Anomaly
+	containers := make(map[string]bool)
+	for _, w := range workloads {
+		for container := range w.images {
+			containers[container] = true
+		}
+	}
+	names := make([]string, 0, len(containers))
+	for container := range containers {
+		names = append(names, container)
+	}
+	sort.Strings(names)
+
+	for _, container := range names {
+		counts := make(map[string]int)
+		total := 0
+		for _, w := range workloads {
+			if image, ok := w.images[container]; ok {
+				counts[image]++
+				total++
+			}
+		}
+		if len(counts) < 2 {
+			continue
+		}
+		majority := majorityValue(counts)
+		strict := counts[majority]*2 > total
+
+		for _, w := range workloads {
+			image, ok := w.images[container]
+			if !ok || !enabled[w.state.ClusterID] || (strict && image == majority) {
+				continue
+			}
+			expected := majority
+			if !strict {
+				expected = "mixed"
+			}
+			anomalies = append(anomalies, d.newWorkloadDrift(w, "WorkloadImageDrift", "image:"+container, types.SeverityLow,
+				fmt.Sprintf("%s %s/%s runs %s in container %s in cluster %s while the fleet runs %s", w.kind, w.namespace, w.name, image, container, w.state.ClusterName, describeCounts(counts)),
+				expected, image, 0, 0)...)
+		}
+	}
+	return anomalies
+}
+
+// detectErrorRateDrift flags clusters where a workload has a much larger share of failing pods
+// than in the rest of the fleet
+func (d *Detector) detectErrorRateDrift(workloads []*workloadSnapshot, enabled map[string]bool) []types.Anomaly {
+	var anomalies []types.Anomaly
+	rates := make([]float64, 0, len(workloads))
+	for _, w := range workloads {
+		rates = append(rates, w.errorRate())
+	}
+	expected := medianOf(rates)
+
+	for _, w := range workloads {
+		rate := w.errorRate()
+		if !enabled[w.state.ClusterID] || rate-expected <= d.drift.Workloads.ErrorRateTolerance {
+			continue
+		}
+		anomalies = append(anomalies, d.newWorkloadDrift(w, "WorkloadErrorRateDrift", "errors", types.SeverityMedium,
+			fmt.Sprintf("%s %s/%s has %d of %d pods failing in cluster %s (%.0f%%) while the fleet median is %.0f%%", w.kind, w.namespace, w.name, w.failing, w.pods, w.state.ClusterName, rate*100, expected*100),
+			fmt.Sprintf("%.2f", expected), fmt.Sprintf("%.2f", rate), rate, expected)...)
+	}
+	return anomalies
+}
+
+// newWorkloadDrift builds a workload drift anomaly unless an identical one was raised recently
+func (d *Detector) newWorkloadDrift(w *workloadSnapshot, anomalyType, attribute string, severity types.Severity, description, expected, actual string, value, threshold float64) []types.Anomaly {
+	key := w.state.ClusterID + "/" + w.namespace + "/" + w.kind + "/" + w.name
+	if d.shouldSuppressAlert(anomalyType, key, attribute) {
+		return nil
+	}
+	d.recordAlertTime(anomalyType, key, attribute)
+
+	labels := driftLabels(attribute, expected, actual)
+	labels["workload_kind"] = w.kind
+	labels["workload"] = w.name
+	return []types.Anomaly{d.newAnomaly(*w.state, anomalyParams{
+		Type:         anomalyType,
+		ResourceType: "workload",
+		Resource:     w.name,
+		Namespace:    w.namespace,
+		Severity:     severity,
+		Description:  description,
+		Value:        value,
+		Threshold:    threshold,
+		Labels:       labels,
+	})}
+}
+
+// workloadSnapshots summarizes the pod-owning workloads of a cluster. Deployments report their
+// desired replica count if deployments are collected, other workloads the number of their pods.
+func workloadSnapshots(state *types.ClusterState) []*workloadSnapshot {
+	var workloads []*workloadSnapshot
+	for ns, resources := range state.Resources {
+		warnings := make(map[string]bool)
+		for _, event := range state.Events {
+			if event.Namespace == ns && event.Severity != "Normal" {
+				warnings[event.Resource] = true
+			}
+		}
+
+		byOwner := make(map[string]*workloadSnapshot)
+		images := make(map[string]map[string]map[string]int) // owner -> container -> image -> pods
+		for _, pod := range resources.Pods {
+			if pod.OwnerKind == "" || pod.OwnerKind == "Pod" || pod.OwnerName == "" {
+				continue
+			}
+			owner := pod.OwnerKind + "/" + pod.OwnerName
+			w, ok := byOwner[owner]
+			if !ok {
+				w = &workloadSnapshot{state: state, namespace: ns, kind: pod.OwnerKind, name: pod.OwnerName, images: make(map[string]string)}
+				byOwner[owner] = w
+				images[owner] = make(map[string]map[string]int)
+				workloads = append(workloads, w)
+			}
+			w.pods++
+			w.replicas++
+			if (pod.Status != "Running" && pod.Status != "Succeeded") || warnings[pod.Name] {
+				w.failing++
+			}
+			for _, container := range pod.Containers {
+				if images[owner][container.Name] == nil {
+					images[owner][container.Name] = make(map[string]int)
+				}
+				images[owner][container.Name][container.Image]++
+			}
+		}
+
+		// During a rollout the image of most pods represents the workload
+		for owner, containers := range images {
+			for container, counts := range containers {
+				byOwner[owner].images[container] = majorityValue(counts)
+			}
+		}
+
+		for _, deployment := range resources.Deployments {
+			if w, ok := byOwner["Deployment/"+deployment.Name]; ok {
+				w.replicas = int(deployment.Replicas)
+			}
+		}
+	}
+	return workloads
+}
+
+// describeCounts lists values with the number of clusters reporting each, most common first
+func describeCounts(counts map[string]int) string {
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, fmt.Sprintf("%s (%d clusters)", value, counts[value]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,153 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/awsauth"
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/journal"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// Snapshot kinds, available to the prefix template as .Kind
+const (
+	KindAlerts = "alerts"
+	KindStates = "states"
+)
+
+// Archiver periodically uploads the alerts detected since its last run, and optionally the
+// current cluster states, as gzip-compressed JSON lines to object storage
+type Archiver struct {
+	store    *objectStore
+	prefix   *template.Template
+	interval time.Duration
+	journal  *journal.Journal
+	states   func() map[string]types.ClusterState
+	last     time.Time // Alerts detected up to this time are archived
+}
+
+// NewArchiver creates an archiver of the journal's alerts and, if states is not nil, of the
+// cluster states it returns. It returns nil if archiving is disabled.
+func NewArchiver(cfg config.ArchiveConfig, j *journal.Journal, states func() map[string]types.ClusterState) (*Archiver, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if j == nil {
+		return nil, fmt.Errorf("archiving alerts requires the anomaly journal (notification.journal.enabled)")
+	}
+
+	credentials := awsauth.Credentials{
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+		SessionToken:    cfg.SessionToken,
+	}.WithEnvironment()
+	store, err := newObjectStore(cfg.Provider, cfg.Endpoint, cfg.Region, cfg.Bucket, credentials)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := template.New("prefix").Parse(cfg.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive prefix: %v", err)
+	}
+
+	interval := time.Duration(cfg.IntervalMinutes) * time.Minute
+	a := &Archiver{
+		store:    store,
+		prefix:   prefix,
+		interval: interval,
+		journal:  j,
+		last:     time.Now().Add(-interval),
+	}
+	if cfg.ClusterStates {
+		a.states = states
+	}
+	return a, nil
+}
+
+// Start archives every interval until ctx is done
+func (a *Archiver) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := a.Run(ctx); err != nil {
+					log.Printf("Warning: failed to archive alerts: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Run uploads the alerts detected since the last successful run and the cluster states. Alerts
+// of a failed run are uploaded by the next one, as long as the journal still retains them.
+func (a *Archiver) Run(ctx context.Context) error {
+	now := time.Now().UTC()
+	records, err := a.journal.Since(a.last)
+	if err != nil {
+		return err
+	}
+	var alerts []interface{}
+	for _, record := range records {
+		if record.DetectedAt.After(a.last) && record.DetectedAt.Before(now) {
+			alerts = append(alerts, record)
+		}
+	}
+	if len(alerts) > 0 {
+		if err := a.upload(ctx, KindAlerts, now, alerts); err != nil {
+			return err
+		}
+		log.Printf("Archived %d alerts", len(alerts))
+	}
+	a.last = now
+
+	if a.states != nil {
+		var states []interface{}
+		for _, state := range a.states() {
+			states = append(states, state)
+		}
+		if len(states) > 0 {
+			if err := a.upload(ctx, KindStates, now, states); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// upload writes items as gzip-compressed JSON lines to an object under the kind's prefix
+func (a *Archiver) upload(ctx context.Context, kind string, now time.Time, items []interface{}) error {
+	var prefix strings.Builder
+	if err := a.prefix.Execute(&prefix, struct {
+		Kind string
+		Time time.Time
+	}{kind, now}); err != nil {
+		return fmt.Errorf("failed to execute archive prefix: %v", err)
+	}
+	key := path.Join(prefix.String(), fmt.Sprintf("%s-%s.jsonl.gz", kind, now.Format("20060102T150405Z")))
+
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	enc := json.NewEncoder(zw)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return fmt.Errorf("failed to encode %s: %v", kind, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress %s: %v", kind, err)
+	}
+	return a.store.Put(ctx, key, "application/gzip", body.Bytes())
+}
@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/awsauth"
+)
+
+// Object storage endpoints
+const (
+	gcsEndpoint = "https://storage.googleapis.com"
+	s3Endpoint  = "https://s3.%s.amazonaws.com"
+)
+
+// objectStore uploads objects to an S3-compatible API with Signature Version 4. GCS is reached
+// through its S3 interoperability API with HMAC keys.
+type objectStore struct {
+	endpoint    string
+	region      string
+	bucket      string
+	credentials awsauth.Credentials
+	client      *http.Client
+}
+
+// newObjectStore creates a client of the provider's object storage
+func newObjectStore(provider, endpoint, region, bucket string, credentials awsauth.Credentials) (*objectStore, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("archive bucket is required")
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("archive credentials are required")
+	}
+	if endpoint == "" {
+		switch provider {
+		case "s3":
+			endpoint = fmt.Sprintf(s3Endpoint, region)
+		case "gcs":
+			endpoint = gcsEndpoint
+		default:
+			return nil, fmt.Errorf("unsupported archive provider: %s (expected s3 or gcs)", provider)
+		}
+	}
+	return &objectStore{
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		region:      region,
+		bucket:      bucket,
+		credentials: credentials,
+		client:      &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Put uploads an object, addressing the bucket in the path so bucket names with dots work over TLS
+func (s *objectStore) Put(ctx context.Context, key, contentType string, body []byte) error {
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid archive endpoint: %v", err)
+	}
+	u.Path = "/" + s.bucket + "/" + key
+	u.RawPath = awsauth.EscapePath(u.Path)
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	awsauth.Sign(req, body, "s3", s.region, s.credentials, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %v", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("failed to upload %s: %s - %s", key, resp.Status, string(message))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package catchup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Checkpoints records when each cluster last completed an observation cycle, so the
+// agent can tell how long it was not watching a cluster after a restart
+type Checkpoints struct {
+	path  string
+	mu    sync.Mutex
+	times map[string]time.Time // cluster ID -> last completed cycle
+}
+
+// OpenCheckpoints loads checkpoints from path; a missing file means no cluster has a checkpoint
+func OpenCheckpoints(path string) (*Checkpoints, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %v", err)
+	}
+
+	c := &Checkpoints{
+		path:  path,
+		times: make(map[string]time.Time),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %v", err)
+	}
+	if err := json.Unmarshal(data, &c.times); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoints: %v", err)
+	}
+	return c, nil
+}
+
+// Last returns the last checkpoint of a cluster
+func (c *Checkpoints) Last(clusterID string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, exists := c.times[clusterID]
+	return t, exists
+}
+
+// Mark records a completed cycle for a cluster and writes all checkpoints to disk
+func (c *Checkpoints) Mark(clusterID string, t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.times[clusterID] = t
+	data, err := json.Marshal(c.times)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoints: %v", err)
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %v", err)
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,137 @@
+package catchup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// PrometheusClient fetches node usage history from the Prometheus range query API
+type PrometheusClient struct {
+	config config.PrometheusConfig
+	client *http.Client
+}
+
+// queryRangeResponse represents the Prometheus /api/v1/query_range response
+type queryRangeResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Result []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]interface{}  `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// NewPrometheusClient creates a Prometheus client
+func NewPrometheusClient(cfg config.PrometheusConfig) *PrometheusClient {
+	return &PrometheusClient{
+		config: cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NodeHistory returns the CPU and memory usage of every node of cluster between start and end,
+// sorted by node name. Series are only filtered by cluster if a cluster label is configured.
+func (p *PrometheusClient) NodeHistory(ctx context.Context, cluster string, start, end time.Time) ([]types.NodeMetricHistory, error) {
+	cpu, err := p.queryRange(ctx, p.config.CPUQuery, cluster, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query CPU history: %v", err)
+	}
+	memory, err := p.queryRange(ctx, p.config.MemoryQuery, cluster, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query memory history: %v", err)
+	}
+
+	byNode := make(map[string]*types.NodeMetricHistory)
+	get := func(node string) *types.NodeMetricHistory {
+		if byNode[node] == nil {
+			byNode[node] = &types.NodeMetricHistory{Node: node}
+		}
+		return byNode[node]
+	}
+	for node, samples := range cpu {
+		get(node).CPU = samples
+	}
+	for node, samples := range memory {
+		get(node).Memory = samples
+	}
+
+	history := make([]types.NodeMetricHistory, 0, len(byNode))
+	for _, h := range byNode {
+		history = append(history, *h)
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Node < history[j].Node })
+	return history, nil
+}
+
+// queryRange runs a range query and returns the samples of each series of cluster keyed by its
+// node label
+func (p *PrometheusClient) queryRange(ctx context.Context, query, cluster string, start, end time.Time) (map[string][]types.MetricSample, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatInt(start.Unix(), 10))
+	params.Set("end", strconv.FormatInt(end.Unix(), 10))
+	params.Set("step", strconv.Itoa(p.config.Step))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.config.URL+"/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	for key, value := range p.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result queryRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response (status %d): %v", resp.StatusCode, err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("query failed with status %d: %s", resp.StatusCode, result.Error)
+	}
+
+	series := make(map[string][]types.MetricSample)
+	for _, r := range result.Data.Result {
+		node := r.Metric[p.config.NodeLabel]
+		if node == "" {
+			continue
+		}
+		if p.config.ClusterLabel != "" && r.Metric[p.config.ClusterLabel] != cluster {
+			continue
+		}
+		for _, v := range r.Values {
+			ts, ok := v[0].(float64)
+			if !ok {
+				continue
+			}
+			str, ok := v[1].(string)
+			if !ok {
+				continue
+			}
+			value, err := strconv.ParseFloat(str, 64)
+			if err != nil {
+				continue
+			}
+			series[node] = append(series[node], types.MetricSample{
+				Timestamp: time.Unix(int64(ts), 0),
+				Value:     value,
+			})
+		}
+	}
+	return series, nil
+}
@@ -24,7 +24,8 @@ type Manager struct {
 type ClusterAgent struct {
 	ClusterConfig *config.ClusterConfig
 	State         *types.ClusterState
-	LastUpdated   time.Time
+	LastUpdated   time.Time // Last observation attempt
+	LastObserved  time.Time // Last successful observation
 	Healthy       bool
 	Error         error
 }
@@ -80,6 +81,18 @@ func (m *Manager) GetCluster(clusterID string) (*ClusterAgent, bool) {
 	return cluster, exists
 }
 
+// GetClusterStatus returns a copy of a cluster agent, safe to read while the cluster is observed
+func (m *Manager) GetClusterStatus(clusterID string) (ClusterAgent, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cluster, exists := m.clusters[clusterID]
+	if !exists {
+		return ClusterAgent{}, false
+	}
+	return *cluster, true
+}
+
 // GetAllClusters returns all cluster agents
 func (m *Manager) GetAllClusters() map[string]*ClusterAgent {
 	m.mu.RLock()
@@ -104,6 +117,7 @@ func (m *Manager) UpdateClusterState(clusterID string, state *types.ClusterState
 
 	cluster.State = state
 	cluster.LastUpdated = time.Now()
+	cluster.LastObserved = cluster.LastUpdated
 	cluster.Healthy = true
 	cluster.Error = nil
 
@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Descriptors of the fleet metrics, computed from the cluster agents on each scrape
+var (
+	clustersDesc = prometheus.NewDesc(
+		"huginn_clusters",
+		"Number of observed clusters by health",
+		[]string{"health"}, nil,
+	)
+	clusterHealthyDesc = prometheus.NewDesc(
+		"huginn_cluster_healthy",
+		"Whether the latest observation of a cluster succeeded (1) or failed (0)",
+		[]string{"cluster_id", "cluster"}, nil,
+	)
+	clusterObservationAgeDesc = prometheus.NewDesc(
+		"huginn_cluster_last_observation_age_seconds",
+		"Time since the last successful observation of a cluster; absent until it is first observed",
+		[]string{"cluster_id", "cluster"}, nil,
+	)
+	clusterNodesDesc = prometheus.NewDesc(
+		"huginn_cluster_nodes",
+		"Number of nodes of a cluster in its latest observation",
+		[]string{"cluster_id", "cluster"}, nil,
+	)
+	fleetNodesDesc = prometheus.NewDesc(
+		"huginn_fleet_nodes",
+		"Number of nodes across all observed clusters",
+		nil, nil,
+	)
+)
+
+// Describe implements prometheus.Collector
+func (m *Manager) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clustersDesc
+	ch <- clusterHealthyDesc
+	ch <- clusterObservationAgeDesc
+	ch <- clusterNodesDesc
+	ch <- fleetNodesDesc
+}
+
+// Collect implements prometheus.Collector, exporting the cluster summary and the health of each
+// cluster so the fleet can be alerted on
+func (m *Manager) Collect(ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var healthy, unhealthy, nodes int
+	for id, cluster := range m.clusters {
+		name := cluster.ClusterConfig.Name
+		up := 0.0
+		if cluster.Healthy {
+			healthy++
+			up = 1
+		} else {
+			unhealthy++
+		}
+		ch <- prometheus.MustNewConstMetric(clusterHealthyDesc, prometheus.GaugeValue, up, id, name)
+		if !cluster.LastObserved.IsZero() {
+			ch <- prometheus.MustNewConstMetric(clusterObservationAgeDesc, prometheus.GaugeValue, now.Sub(cluster.LastObserved).Seconds(), id, name)
+		}
+		if cluster.State != nil {
+			nodes += len(cluster.State.Nodes)
+			ch <- prometheus.MustNewConstMetric(clusterNodesDesc, prometheus.GaugeValue, float64(len(cluster.State.Nodes)), id, name)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(clustersDesc, prometheus.GaugeValue, float64(healthy), "healthy")
+	ch <- prometheus.MustNewConstMetric(clustersDesc, prometheus.GaugeValue, float64(unhealthy), "unhealthy")
+	ch <- prometheus.MustNewConstMetric(fleetNodesDesc, prometheus.GaugeValue, float64(nodes))
+}
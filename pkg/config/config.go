@@ -16,9 +16,77 @@ type Config struct {
 	Embedding           EmbeddingConfig        `yaml:"embedding"`
 	Notification        NotificationConfig     `yaml:"notification"`
 	Formatting          FormattingConfig       `yaml:"formatting"`
+	FeatureFlags        FeatureFlagsConfig     `yaml:"featureFlags"`
+	CatchUp             CatchUpConfig          `yaml:"catchUp"`
+	Degradation         DegradationConfig      `yaml:"degradation"`
+	Hooks               HooksConfig            `yaml:"hooks"`
+	MetricsServer       MetricsServerConfig    `yaml:"metricsServer"`
+	MetricsPush         MetricsPushConfig      `yaml:"metricsPush"`
+	MetricsExporter     MetricsExporterConfig  `yaml:"metricsExporter"`
 	ObservationInterval int                    `yaml:"observationInterval"` // Interval in seconds
 }
 
+// MetricsServerConfig represents the HTTP server exposing metrics, probes and the API
+type MetricsServerConfig struct {
+	Address string             `yaml:"address"` // Defaults to ":8080"
+	TLS     MetricsTLSConfig   `yaml:"tls"`
+	Auth    MetricsAuthConfig  `yaml:"auth"`
+	Debug   MetricsDebugConfig `yaml:"debug"`
+}
+
+// MetricsTLSConfig represents serving over HTTPS; both files must be set to enable it
+type MetricsTLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// MetricsAuthConfig represents the credentials required on every endpoint except /healthz and
+// /readyz. Requests are accepted with either the basic-auth credentials or the bearer token.
+type MetricsAuthConfig struct {
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password"`
+	BearerToken string `yaml:"bearerToken"`
+}
+
+// MetricsDebugConfig represents the diagnostics used to profile the agent in place
+type MetricsDebugConfig struct {
+	Pprof          bool `yaml:"pprof"`          // Serve /debug/pprof
+	RuntimeMetrics bool `yaml:"runtimeMetrics"` // Export all Go runtime metrics (go_gc_*, go_sched_*, ...)
+}
+
+// MetricsPushConfig represents pushing the exported metrics where the agent cannot be scraped.
+// Each target is enabled by setting its URL.
+type MetricsPushConfig struct {
+	IntervalSeconds int               `yaml:"intervalSeconds"` // Defaults to the observation interval
+	Pushgateway     PushgatewayConfig `yaml:"pushgateway"`
+	RemoteWrite     RemoteWriteConfig `yaml:"remoteWrite"`
+}
+
+// MetricsExporterConfig represents which metric families are exported: anomalies (always
+// exported), nodes, pods, storage, workloads and events. Without families, node and pod metrics
+// follow the collected resources and the other families are exported.
+type MetricsExporterConfig struct {
+	Families []string `yaml:"families"`
+}
+
+// PushgatewayConfig represents a Prometheus Pushgateway the metrics are pushed to
+type PushgatewayConfig struct {
+	URL      string `yaml:"url"`
+	Job      string `yaml:"job"`      // Defaults to "huginn"
+	Instance string `yaml:"instance"` // Grouping label; defaults to the hostname
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// RemoteWriteConfig represents a Prometheus remote-write endpoint, e.g. Prometheus, Mimir or Thanos
+type RemoteWriteConfig struct {
+	URL         string            `yaml:"url"`
+	Username    string            `yaml:"username"`
+	Password    string            `yaml:"password"`
+	BearerToken string            `yaml:"bearerToken"`
+	Headers     map[string]string `yaml:"headers"` // e.g. X-Scope-OrgID
+}
+
 // ClusterConfig represents configuration for a single Kubernetes cluster
 type ClusterConfig struct {
 	Name       string            `yaml:"name"`
@@ -29,34 +97,475 @@ type ClusterConfig struct {
 	Namespace  string            `yaml:"namespace"`
 	Resources  []string          `yaml:"resources"`
 	Enabled    bool              `yaml:"enabled"`
+	// AllowedRegistries overrides the image policy registry allowlist for this cluster
+	AllowedRegistries []string `yaml:"allowedRegistries"`
 }
 
 // AnomalyDetectionConfig represents anomaly detection configuration
 type AnomalyDetectionConfig struct {
+	CPUThreshold        float64              `yaml:"cpuThreshold"`
+	MemoryThreshold     float64              `yaml:"memoryThreshold"`
+	PodRestartThreshold int                  `yaml:"podRestartThreshold"` // Restarts within the restart window
+	MaxHistorySize      int                  `yaml:"maxHistorySize"`      // Observations kept by the agent and per multivariate window
+	SeriesHistorySize   int                  `yaml:"seriesHistorySize"`   // Observations kept per resource metric
+	CPUAlpha            float64              `yaml:"cpuAlpha"`
+	MemoryAlpha         float64              `yaml:"memoryAlpha"`
+	RestartAlpha        float64              `yaml:"restartAlpha"`
+	DiskThreshold       float64              `yaml:"diskThreshold"` // Node filesystem (nodefs/imagefs) usage percentage
+	DiskAlpha           float64              `yaml:"diskAlpha"`
+	DiskPressureMinutes int                  `yaml:"diskPressureMinutes"` // How long DiskPressure may hold before it is flagged
+	MinStdDev           float64              `yaml:"minStdDev"`
+	MinHistory          map[string]int       `yaml:"minHistory"`        // Samples per metric type (cpu, memory, disk, restarts, churn) before statistical analysis
+	DisabledDetectors   []string             `yaml:"disabledDetectors"` // Core detectors not evaluated: cpu, memory, disk, restarts, podStatus, events
+	Statistics          string               `yaml:"statistics"`        // "standard" (mean/stddev) or "robust" (median/MAD)
+	ImagePolicy         ImagePolicyConfig    `yaml:"imagePolicy"`
+	SecurityPolicy      SecurityPolicyConfig `yaml:"securityPolicy"`
+	Drift               DriftConfig          `yaml:"drift"`
+	ConfigChurn         ConfigChurnConfig    `yaml:"configChurn"`
+	PVC                 PVCConfig            `yaml:"pvc"`
+	PV                  PVConfig             `yaml:"pv"`
+	Multivariate        MultivariateConfig   `yaml:"multivariate"`
+	Slope               SlopeConfig          `yaml:"slope"`
+	Forecast            ForecastConfig       `yaml:"forecast"`
+	TrendHistory        TrendHistoryConfig   `yaml:"trendHistory"`
+	Severity            SeverityConfig       `yaml:"severity"`
+	// PodRestartThreshold counts restarts within this window rather than since the pod started
+	RestartWindowMinutes int `yaml:"restartWindowMinutes"`
+	// ThresholdOverrides are evaluated in order; the first override matching a resource wins
+	ThresholdOverrides []ThresholdOverride `yaml:"thresholdOverrides"`
+	// Rules are custom anomalies defined as expressions over the collected state
+	Rules []RuleConfig `yaml:"rules"`
+	// Composites are anomalies raised when several conditions hold at once or in sequence
+	Composites []CompositeConfig `yaml:"composites"`
+	// EventReasons are the problematic event reasons raised as ClusterEvent anomalies
+	EventReasons []EventReasonConfig `yaml:"eventReasons"`
+	// Learning tunes thresholds and alphas from how healthy the cluster was when anomalies fired
+	Learning LearningConfig `yaml:"learning"`
+	// Feedback adjusts the thresholds of resources whose anomalies were labeled true or false positives
+	Feedback FeedbackConfig `yaml:"feedback"`
+	// Hysteresis keeps threshold anomalies from firing every other cycle around the threshold
+	Hysteresis HysteresisConfig `yaml:"hysteresis"`
+	// WarmUp suppresses anomalies while the agent starts and while pods and nodes start up
+	WarmUp WarmUpConfig `yaml:"warmUp"`
+	// HealthScore scores each cluster from node readiness, pending pods, warning events and anomalies
+	HealthScore HealthScoreConfig `yaml:"healthScore"`
+}
+
+// HealthScoreConfig represents the composite cluster health score (0-100) and alerting on its drops
+type HealthScoreConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Weights       HealthWeights `yaml:"weights"`
+	DropThreshold float64       `yaml:"dropThreshold"` // Points below the recent mean that raise ClusterHealthDrop
+	Intervals     int           `yaml:"intervals"`     // Recent scores the mean is taken over
+}
+
+// HealthWeights are the relative weights of the health score components
+type HealthWeights struct {
+	Nodes     float64 `yaml:"nodes"`     // Share of Ready nodes
+	Pods      float64 `yaml:"pods"`      // Share of pods that are not Pending
+	Events    float64 `yaml:"events"`    // Warning events per node
+	Anomalies float64 `yaml:"anomalies"` // Anomalies per node
+}
+
+// WarmUpConfig represents grace periods during which anomalies are not reported; zero disables
+// a grace period. Metrics are still recorded, so baselines are built during warm-up.
+type WarmUpConfig struct {
+	AgentMinutes int `yaml:"agentMinutes"` // After the agent's first observation of a cluster
+	PodMinutes   int `yaml:"podMinutes"`   // After a pod was created
+	NodeMinutes  int `yaml:"nodeMinutes"`  // After a node was created
+}
+
+// HysteresisConfig represents separate fire and clear thresholds for CPU, memory, disk and restart
+// anomalies, and the suppression of resources flapping between the two states
+type HysteresisConfig struct {
+	Enabled           bool    `yaml:"enabled"`
+	ClearRatio        float64 `yaml:"clearRatio"`        // Firing anomalies clear below this share of the threshold
+	FlapWindowMinutes int     `yaml:"flapWindowMinutes"` // Window state changes are counted in
+	MaxFlaps          int     `yaml:"maxFlaps"`          // State changes within the window before a resource is flapping
+}
+
+// FeedbackConfig represents how anomalies labeled through the feedback API adjust the thresholds
+// of their resource
+type FeedbackConfig struct {
+	Step          float64 `yaml:"step"`          // Relative threshold change per labeled anomaly
+	MaxAdjustment float64 `yaml:"maxAdjustment"` // Thresholds stay within this share of the configured ones
+}
+
+// LearningConfig represents the auto-tuning of detector thresholds and EWMA smoothing factors
+// from the rewards of recent observations. Anomalies raised while the cluster is healthy count as
+// false positives; unhealthy cycles without any anomaly count as misses of the most strained metric.
+type LearningConfig struct {
+	Enabled           bool    `yaml:"enabled"`
+	WindowCycles      int     `yaml:"windowCycles"`      // Observations per tuning round
+	HealthyReward     float64 `yaml:"healthyReward"`     // Cycles rewarded at least this are healthy
+	UnhealthyReward   float64 `yaml:"unhealthyReward"`   // Cycles rewarded less than this are unhealthy
+	FalsePositiveRate float64 `yaml:"falsePositiveRate"` // Share of healthy cycles with anomalies that raises a threshold
+	MissRate          float64 `yaml:"missRate"`          // Share of unhealthy cycles missed that lowers a threshold
+	Step              float64 `yaml:"step"`              // Relative threshold change per round
+	AlphaStep         float64 `yaml:"alphaStep"`         // Alpha change per round
+	MaxAdjustment     float64 `yaml:"maxAdjustment"`     // Tuned thresholds stay within this share of the configured ones
+}
+
+// EventReasonConfig represents a problematic event reason. An event matches when its reason is
+// equal and, if a message pattern is set, its message matches the regular expression.
+type EventReasonConfig struct {
+	Reason         string `yaml:"reason"`         // Empty matches any reason
+	MessagePattern string `yaml:"messagePattern"` // Optional regular expression on the event message
+	Severity       string `yaml:"severity"`       // Defaults to High
+}
+
+// CompositeConfig represents an anomaly raised for a cluster once all of its conditions have held
+// within a window, e.g. high CPU, pending pods and FailedScheduling events within 10 minutes
+type CompositeConfig struct {
+	Name          string            `yaml:"name"`
+	Conditions    []ConditionConfig `yaml:"conditions"`
+	WithinMinutes int               `yaml:"withinMinutes"` // 0 requires all conditions in the same cycle
+	Sequence      bool              `yaml:"sequence"`      // Conditions must first hold in the listed order
+	Type          string            `yaml:"type"`          // Anomaly type; defaults to the composite name
+	Severity      string            `yaml:"severity"`      // Defaults to High
+	Description   string            `yaml:"description"`   // Defaults to the composite name and conditions
+}
+
+// ConditionConfig represents a condition of a composite anomaly. It holds when its expression
+// matches any resource of its kind.
+type ConditionConfig struct {
+	Resource string `yaml:"resource"` // node, pod, pvc, pv, event, cluster or anomaly (raised this cycle)
+	Expr     string `yaml:"expr"`
+}
+
+// RuleConfig represents a custom anomaly rule, raised for every resource its expression matches,
+// e.g. `node.MemoryUsagePercent > 90 && len(node.Namespaces) > 10`
+type RuleConfig struct {
+	Name        string `yaml:"name"`
+	Resource    string `yaml:"resource"` // node, pod, pvc, pv, event or cluster
+	Expr        string `yaml:"expr"`
+	Type        string `yaml:"type"`        // Anomaly type; defaults to the rule name
+	Severity    string `yaml:"severity"`    // Defaults to Medium
+	Description string `yaml:"description"` // Defaults to the rule name and expression
+}
+
+// ThresholdOverride represents thresholds applied to matching resources instead of the global ones.
+// All criteria that are set must match; thresholds left at zero fall back to the global values.
+type ThresholdOverride struct {
+	NodeNamePattern     string  `yaml:"nodeNamePattern"` // Glob matched against node names (or the pod's node)
+	Namespace           string  `yaml:"namespace"`       // Glob matched against pod namespaces; pod thresholds only
+	LabelSelector       string  `yaml:"labelSelector"`   // Kubernetes label selector, e.g. "node-pool=batch"
 	CPUThreshold        float64 `yaml:"cpuThreshold"`
 	MemoryThreshold     float64 `yaml:"memoryThreshold"`
 	PodRestartThreshold int     `yaml:"podRestartThreshold"`
-	MaxHistorySize      int     `yaml:"maxHistorySize"`
-	CPUAlpha            float64 `yaml:"cpuAlpha"`
-	MemoryAlpha         float64 `yaml:"memoryAlpha"`
-	RestartAlpha        float64 `yaml:"restartAlpha"`
-	MinStdDev           float64 `yaml:"minStdDev"`
+}
+
+// ConfigChurnConfig represents Secret and ConfigMap churn detection configuration
+type ConfigChurnConfig struct {
+	ChurnThreshold     int `yaml:"churnThreshold"`     // Creates/updates/deletes per namespace and cycle
+	MaxObjectSizeBytes int `yaml:"maxObjectSizeBytes"` // Objects larger than this are flagged
+}
+
+// PVCConfig represents PersistentVolumeClaim checks
+type PVCConfig struct {
+	PendingCycles int `yaml:"pendingCycles"` // Consecutive cycles a claim may stay Pending before it is flagged
+}
+
+// PVConfig represents PersistentVolume hygiene checks
+type PVConfig struct {
+	ReleasedCycles     int `yaml:"releasedCycles"`     // Cycles a Delete-policy volume may stay Released before it is flagged
+	MaxRetainedVolumes int `yaml:"maxRetainedVolumes"` // Unclaimed Retain-policy volumes tolerated per cluster
+}
+
+// MultivariateConfig represents the isolation forest detector that scores several metrics together
+type MultivariateConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	Trees          int     `yaml:"trees"`
+	SampleSize     int     `yaml:"sampleSize"`     // Samples used to build each tree
+	MinSamples     int     `yaml:"minSamples"`     // Samples collected before anything is scored
+	ScoreThreshold float64 `yaml:"scoreThreshold"` // Scores range from 0 to 1; above ~0.6 is unusual
+}
+
+// SeverityConfig represents scoring the severity of threshold anomalies from the deviation magnitude
+type SeverityConfig struct {
+	Enabled   bool                          `yaml:"enabled"`
+	Metrics   map[string]SeverityBoundaries `yaml:"metrics"`   // Value boundaries per metric (cpu, memory, restarts)
+	Deviation SeverityBoundaries            `yaml:"deviation"` // Z-score boundaries for all metrics
+}
+
+// SeverityBoundaries are the values at or above which a severity applies; below medium is low.
+// A zero boundary is not used.
+type SeverityBoundaries struct {
+	Medium   float64 `yaml:"medium"`
+	High     float64 `yaml:"high"`
+	Critical float64 `yaml:"critical"`
+}
+
+// SlopeConfig represents detection of metrics growing steadily, e.g. memory leaks
+type SlopeConfig struct {
+	Enabled   bool    `yaml:"enabled"`
+	Intervals int     `yaml:"intervals"` // Number of recent observations the trend is fitted to
+	MinSlope  float64 `yaml:"minSlope"`  // Growth in percentage points per interval
+	MinFit    float64 `yaml:"minFit"`    // Minimum R² (0-1) so only consistent growth is flagged
+}
+
+// ForecastConfig represents capacity exhaustion forecasting from metric history
+type ForecastConfig struct {
+	Enabled      bool    `yaml:"enabled"`
+	HorizonHours int     `yaml:"horizonHours"` // Forecasts further out than this are not reported
+	Intervals    int     `yaml:"intervals"`    // Number of recent observations the trend is fitted to
+	MinFit       float64 `yaml:"minFit"`       // Minimum R² (0-1) of the trend
+}
+
+// TrendHistoryConfig represents the downsampled long-term metric history served by the trend API
+type TrendHistoryConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Tiers   []TrendTierConfig `yaml:"tiers"` // From finest to coarsest resolution
+}
+
+// TrendTierConfig represents one resolution of the downsampled history
+type TrendTierConfig struct {
+	ResolutionMinutes int `yaml:"resolutionMinutes"` // Width of each bucket
+	RetentionHours    int `yaml:"retentionHours"`    // How long buckets are kept
+}
+
+// DriftConfig represents fleet drift detection configuration
+type DriftConfig struct {
+	Enabled   bool                `yaml:"enabled"`
+	Workloads WorkloadDriftConfig `yaml:"workloads"`
+}
+
+// WorkloadDriftConfig represents the comparison of workloads deployed to several clusters
+type WorkloadDriftConfig struct {
+	Enabled            bool    `yaml:"enabled"`
+	ReplicaTolerance   float64 `yaml:"replicaTolerance"`   // Relative difference from the fleet median replica count that is flagged
+	ErrorRateTolerance float64 `yaml:"errorRateTolerance"` // Share of failing pods above the fleet median that is flagged
+}
+
+// ImagePolicyConfig represents container image provenance policy configuration
+type ImagePolicyConfig struct {
+	Enabled              bool     `yaml:"enabled"`
+	AllowedRegistries    []string `yaml:"allowedRegistries"`    // Registry hosts or glob patterns; empty allows any registry
+	ProductionNamespaces []string `yaml:"productionNamespaces"` // Namespace glob patterns where mutable tags are flagged; empty means all
+}
+
+// SecurityPolicyConfig represents privileged workload policy configuration
+type SecurityPolicyConfig struct {
+	Enabled              bool     `yaml:"enabled"`
+	PrivilegedNamespaces []string `yaml:"privilegedNamespaces"` // Namespace glob patterns where privileged workloads are expected
+	CheckRunAsRoot       bool     `yaml:"checkRunAsRoot"`       // Also flag containers not prevented from running as root
 }
 
 // StorageConfig represents storage configuration
 type StorageConfig struct {
-	Type        string       `yaml:"type"`
-	StoreAlerts bool         `yaml:"storeAlerts"`
-	Qdrant      QdrantConfig `yaml:"qdrant"`
-	Redis       RedisConfig  `yaml:"redis"`
+	Type        string                   `yaml:"type"`
+	StoreAlerts bool                     `yaml:"storeAlerts"`
+	Qdrant      QdrantConfig             `yaml:"qdrant"`
+	Redis       RedisConfig              `yaml:"redis"`
+	Local       LocalStorageConfig       `yaml:"local"`
+	Milvus      MilvusConfig             `yaml:"milvus"`
+	Weaviate    WeaviateConfig           `yaml:"weaviate"`
+	ClickHouse  ClickHouseConfig         `yaml:"clickhouse"`
+	Replicas    StorageReplicasConfig    `yaml:"replicas"`
+	AlertID     AlertIDConfig            `yaml:"alertId"`
+	Dedup       StorageDedupConfig       `yaml:"dedup"`
+	Sampling    SamplingConfig           `yaml:"sampling"`
+	Batch       StorageBatchConfig       `yaml:"batch"`
+	WriteBehind StorageWriteBehindConfig `yaml:"writeBehind"`
+	HealthCheck StorageHealthCheckConfig `yaml:"healthCheck"`
+	Archive     ArchiveConfig            `yaml:"archive"`
+	NATS        NATSConfig               `yaml:"nats"`
+	TimeSeries  TimeSeriesConfig         `yaml:"timeSeries"`
+	Encryption  StorageEncryptionConfig  `yaml:"encryption"`
+}
+
+// ArchiveConfig represents periodic snapshots of detected alerts, and optionally cluster states,
+// to S3 or GCS for long-term audit. Alerts are read from the anomaly journal.
+type ArchiveConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	IntervalMinutes int    `yaml:"intervalMinutes"` // Defaults to 60
+	Provider        string `yaml:"provider"`        // "s3" or "gcs"
+	Bucket          string `yaml:"bucket"`
+	Region          string `yaml:"region"`   // Defaults to us-east-1, or auto for GCS
+	Endpoint        string `yaml:"endpoint"` // Set for S3-compatible stores; defaults to the provider's
+	// Credentials default to AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN; GCS
+	// uses HMAC keys
+	AccessKeyID     string `yaml:"accessKeyId"`
+	SecretAccessKey string `yaml:"secretAccessKey"`
+	SessionToken    string `yaml:"sessionToken"` // Only for temporary credentials, e.g. IRSA
+	// Prefix is a Go template of the object key prefix, executed with the snapshot .Kind
+	// ("alerts" or "states") and .Time
+	Prefix        string `yaml:"prefix"`
+	ClusterStates bool   `yaml:"clusterStates"`
+}
+
+// NATSConfig represents publishing detected alerts to NATS, e.g. into a JetStream stream whose
+// subjects match the subject template
+type NATSConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	URL     string `yaml:"url"` // Defaults to nats://localhost:4222; tls:// connects over TLS
+	// Subject is a Go template of the subject an alert is published to, executed with the
+	// anomaly. Spaces, dots and wildcards in its fields are replaced with underscores.
+	Subject string `yaml:"subject"`
+	// JetStream waits for the stream to acknowledge each alert instead of publishing at most once
+	JetStream      bool   `yaml:"jetStream"`
+	Token          string `yaml:"token"`
+	User           string `yaml:"user"`
+	Password       string `yaml:"password"`
+	TimeoutSeconds int    `yaml:"timeoutSeconds"` // Defaults to 5
+}
+
+// TimeSeriesConfig represents exporting the detector's raw metric observations to a time-series
+// database, from which the detector's history is restored on startup
+type TimeSeriesConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Type        string `yaml:"type"` // influxdb (v2 API) or victoriametrics
+	URL         string `yaml:"url"`
+	Token       string `yaml:"token"`  // InfluxDB API token
+	Org         string `yaml:"org"`    // InfluxDB organization
+	Bucket      string `yaml:"bucket"` // InfluxDB bucket
+	Measurement string `yaml:"measurement"`
+	// RestoreHours is how far back observations are loaded on startup; negative disables restoring
+	RestoreHours int `yaml:"restoreHours"` // Defaults to 24
+}
+
+// StorageEncryptionConfig represents AES-GCM encryption of the description, labels, metadata and
+// events of alerts before they are written to the storage backend. Keys are base64-encoded and 16, 24 or
+// 32 bytes long.
+type StorageEncryptionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// KeyFile holds the key, e.g. a secret synced from a KMS. Without it the key is read from the
+	// HUGINN_ENCRYPTION_KEY environment variable.
+	KeyFile string `yaml:"keyFile"`
+	// PreviousKeyFiles hold keys alerts were encrypted with before a rotation, only used to
+	// decrypt. HUGINN_ENCRYPTION_PREVIOUS_KEYS may list them comma-separated instead.
+	PreviousKeyFiles []string `yaml:"previousKeyFiles"`
+	// KMS decrypts the keys with AWS KMS, so they are stored encrypted by a KMS key
+	KMS EncryptionKMSConfig `yaml:"kms"`
+}
+
+// EncryptionKMSConfig represents AWS KMS decrypting storage encryption keys (envelope encryption).
+// With it enabled the key file, previous key files and environment variables hold base64-encoded
+// data keys encrypted by KMS, e.g. the CiphertextBlob of `aws kms generate-data-key --key-spec AES_256`.
+type EncryptionKMSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Region   string `yaml:"region"`
+	Endpoint string `yaml:"endpoint"` // Defaults to the region's KMS endpoint
+	// Credentials default to AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+	AccessKeyID     string `yaml:"accessKeyId"`
+	SecretAccessKey string `yaml:"secretAccessKey"`
+	SessionToken    string `yaml:"sessionToken"`
+}
+
+// StorageReplicasConfig represents storage backends alerts are copied to besides storage.type,
+// each configured by its own section. Searches use the primary backend only.
+type StorageReplicasConfig struct {
+	Types     []string `yaml:"types"`     // e.g. ["local"]
+	QueueSize int      `yaml:"queueSize"` // Alerts of failed writes retried per replica, defaults to 1000
+}
+
+// StorageHealthCheckConfig represents periodic checks of the storage backend. After
+// FailureThreshold consecutive failures the circuit opens: anomalies are no longer embedded or
+// written, but queued per the storage degradation mode, until the backend answers again.
+type StorageHealthCheckConfig struct {
+	Enabled          bool `yaml:"enabled"`
+	IntervalSeconds  int  `yaml:"intervalSeconds"`  // Defaults to 30
+	FailureThreshold int  `yaml:"failureThreshold"` // Defaults to 3
+}
+
+// StorageWriteBehindConfig represents queueing stored alerts and writing them in the background,
+// retrying with exponential backoff, so a backend outage neither blocks detection nor loses
+// alerts. It replaces storage.batch: the queue is written in batches of storage.batch.size.
+type StorageWriteBehindConfig struct {
+	Enabled           bool   `yaml:"enabled"`
+	QueueSize         int    `yaml:"queueSize"`         // Oldest alerts are dropped beyond it, defaults to 10000
+	Path              string `yaml:"path"`              // Optional file keeping the queue across restarts
+	MaxBackoffSeconds int    `yaml:"maxBackoffSeconds"` // Longest delay between retries, defaults to 300
+}
+
+// StorageBatchConfig represents writing stored alerts in batches instead of one request each
+type StorageBatchConfig struct {
+	Enabled         bool `yaml:"enabled"`
+	Size            int  `yaml:"size"`            // Alerts buffered before a write, defaults to 100
+	IntervalSeconds int  `yaml:"intervalSeconds"` // Longest time an alert stays buffered, defaults to 5
+}
+
+// StorageDedupConfig represents storing repeated occurrences of an alert, by fingerprint, over
+// the point of the first one with occurrence counts instead of as new points
+type StorageDedupConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	WindowMinutes int  `yaml:"windowMinutes"` // Occurrences further apart start a new point, defaults to 60
+}
+
+// SamplingConfig represents severity-aware sampling of stored alerts to limit vector store growth
+type SamplingConfig struct {
+	Enabled              bool               `yaml:"enabled"`
+	Rates                map[string]float64 `yaml:"rates"`                // Fraction (0-1) of alerts stored per severity
+	FirstOccurrenceHours int                `yaml:"firstOccurrenceHours"` // A fingerprint not seen for this long is always stored
+}
+
+// AlertIDConfig represents how stored alerts are identified and correlated with external systems
+type AlertIDConfig struct {
+	Scheme   string `yaml:"scheme"`   // "uuid", "fingerprint" or "template"
+	Template string `yaml:"template"` // Go template executed with the anomaly when scheme is "template"
+	// CorrelationKeys are additional keys computed from templates, e.g. a Jira issue ID from a label
+	CorrelationKeys map[string]string `yaml:"correlationKeys"`
 }
 
 // QdrantConfig represents Qdrant-specific configuration
 type QdrantConfig struct {
+	URL            string          `yaml:"url"`
+	Collection     string          `yaml:"collection"`
+	VectorSize     int             `yaml:"vectorSize"`
+	DistanceMetric string          `yaml:"distanceMetric"`
+	Protocol       string          `yaml:"protocol"` // "rest" or "grpc"
+	GRPCURL        string          `yaml:"grpcUrl"`  // Defaults to the REST host on port 6334
+	APIKey         string          `yaml:"apiKey"`   // Sent in the api-key header
+	TLS            QdrantTLSConfig `yaml:"tls"`
+	TimeoutSeconds int             `yaml:"timeoutSeconds"`
+	// RecreateOnDimensionMismatch recreates a collection whose vector size is not the dimension of
+	// the embedding model, deleting its alerts, instead of failing at startup
+	RecreateOnDimensionMismatch bool `yaml:"recreateOnDimensionMismatch"`
+}
+
+// QdrantTLSConfig represents verification of the Qdrant server certificate on https URLs
+type QdrantTLSConfig struct {
+	CAFile             string `yaml:"caFile"` // Trusted in addition to the system roots
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+// MilvusConfig represents Milvus-specific configuration
+type MilvusConfig struct {
+	URL        string `yaml:"url"`
+	Token      string `yaml:"token"` // API key, or "user:password"
+	Database   string `yaml:"database"`
+	Collection string `yaml:"collection"`
+	VectorSize int    `yaml:"vectorSize"`
+	MetricType string `yaml:"metricType"` // cosine, l2 or ip
+}
+
+// WeaviateConfig represents Weaviate-specific configuration
+type WeaviateConfig struct {
 	URL            string `yaml:"url"`
-	Collection     string `yaml:"collection"`
-	VectorSize     int    `yaml:"vectorSize"`
+	APIKey         string `yaml:"apiKey"`
+	Class          string `yaml:"class"`
 	DistanceMetric string `yaml:"distanceMetric"`
+	// HybridAlpha weighs vector similarity against keyword matching when searching similar
+	// alerts, from 0 (keywords only) to 1 (vector only); defaults to 0.75
+	HybridAlpha float64 `yaml:"hybridAlpha"`
+}
+
+// ClickHouseConfig represents ClickHouse-specific configuration. Alerts and the node and pod
+// metrics of every observation cycle are stored in wide tables for long-range analytics.
+type ClickHouseConfig struct {
+	URL               string `yaml:"url"` // HTTP interface
+	User              string `yaml:"user"`
+	Password          string `yaml:"password"`
+	Database          string `yaml:"database"`
+	Table             string `yaml:"table"`
+	ObservationsTable string `yaml:"observationsTable"`
+	DistanceMetric    string `yaml:"distanceMetric"` // cosine, l2 or dot
+}
+
+// LocalStorageConfig represents alerts stored in a file on local disk
+type LocalStorageConfig struct {
+	Path           string `yaml:"path"`
+	RetentionHours int    `yaml:"retentionHours"` // Alerts older than this are dropped on startup and hourly
 }
 
 // RedisConfig represents Redis-specific configuration
@@ -74,6 +583,43 @@ type EmbeddingConfig struct {
 	OpenAI               OpenAIConfig               `yaml:"openai"`
 	SentenceTransformers SentenceTransformersConfig `yaml:"sentenceTransformers"`
 	Ollama               OllamaConfig               `yaml:"ollama"`
+	AzureOpenAI          AzureOpenAIConfig          `yaml:"azureOpenAI"`
+	Cohere               CohereConfig               `yaml:"cohere"`
+	HuggingFace          HuggingFaceConfig          `yaml:"huggingFace"`
+	VertexAI             VertexAIConfig             `yaml:"vertexAI"`
+	Retry                EmbeddingRetryConfig       `yaml:"retry"`
+	Resize               EmbeddingResizeConfig      `yaml:"resize"`
+	// Normalize scales vectors to unit length before they are stored or searched
+	Normalize bool                   `yaml:"normalize"`
+	Workers   EmbeddingWorkersConfig `yaml:"workers"`
+	// SearchSameModel restricts similarity searches to alerts embedded by the configured model;
+	// alerts stored before models were recorded are still searched
+	SearchSameModel bool `yaml:"searchSameModel"`
+}
+
+// EmbeddingWorkersConfig represents embedding and storing anomalies on background workers instead
+// of during the observation cycle. Anomalies that do not fit in the queue are handled according
+// to the embedding degradation mode.
+type EmbeddingWorkersConfig struct {
+	Enabled     bool `yaml:"enabled"`
+	Concurrency int  `yaml:"concurrency"` // Workers shared by all clusters, defaults to 4
+	QueueSize   int  `yaml:"queueSize"`   // Batches of anomalies waiting for a worker, defaults to 100
+}
+
+// EmbeddingRetryConfig represents retries of failed embedding calls with exponential backoff and
+// jitter. Rejected requests and vectors of the wrong dimension are not retried.
+type EmbeddingRetryConfig struct {
+	MaxAttempts      int `yaml:"maxAttempts"`      // Calls per embedding, defaults to 3; 1 disables retries
+	InitialBackoffMs int `yaml:"initialBackoffMs"` // Delay after the first failure, doubled after each, defaults to 500
+	MaxBackoffMs     int `yaml:"maxBackoffMs"`     // Longest delay between calls, defaults to 5000
+	TimeoutSeconds   int `yaml:"timeoutSeconds"`   // Deadline of each call, defaults to 30
+}
+
+// EmbeddingResizeConfig represents padding or truncating embeddings to the vector size of the
+// storage collection (storage.qdrant.vectorSize, or storage.milvus.vectorSize with Milvus), so a
+// model of another dimension can be used before the collection is rebuilt
+type EmbeddingResizeConfig struct {
+	Enabled bool `yaml:"enabled"`
 }
 
 // OpenAIConfig represents OpenAI-specific configuration
@@ -82,27 +628,93 @@ type OpenAIConfig struct {
 	Model  string `yaml:"model"`
 }
 
-// SentenceTransformersConfig represents Sentence Transformers configuration
+// SentenceTransformersConfig represents a Sentence Transformers model served by an inference
+// service with an OpenAI-compatible embeddings API, e.g. text-embeddings-inference or infinity
 type SentenceTransformersConfig struct {
-	Model  string `yaml:"model"`
-	Device string `yaml:"device"`
+	URL       string `yaml:"url"` // Defaults to http://localhost:8081
+	Model     string `yaml:"model"`
+	BatchSize int    `yaml:"batchSize"` // Texts encoded per request, defaults to 32
+}
+
+// AzureOpenAIConfig represents an embedding model deployed to an Azure OpenAI resource
+type AzureOpenAIConfig struct {
+	Endpoint   string `yaml:"endpoint"`   // e.g. https://my-resource.openai.azure.com
+	Deployment string `yaml:"deployment"` // Name of the embedding model's deployment
+	APIVersion string `yaml:"apiVersion"` // Defaults to 2024-02-01
+	APIKey     string `yaml:"apiKey"`     // Defaults to the AZURE_OPENAI_API_KEY environment variable
+	BatchSize  int    `yaml:"batchSize"`  // Texts encoded per request, defaults to 16
+}
+
+// CohereConfig represents a Cohere embed model
+type CohereConfig struct {
+	URL    string `yaml:"url"`    // Defaults to https://api.cohere.com
+	APIKey string `yaml:"apiKey"` // Defaults to the COHERE_API_KEY environment variable
+	Model  string `yaml:"model"`  // Defaults to embed-english-v3.0
+	// InputType of stored alerts, defaults to search_document; QueryInputType of the alerts
+	// searched for similar ones, defaults to search_query
+	InputType      string `yaml:"inputType"`
+	QueryInputType string `yaml:"queryInputType"`
+	BatchSize      int    `yaml:"batchSize"` // Texts encoded per request, defaults to 96
+}
+
+// HuggingFaceConfig represents an embedding model hosted by HuggingFace
+type HuggingFaceConfig struct {
+	// URL of the Inference API, defaults to https://api-inference.huggingface.co. Without a model,
+	// the URL of an Inference Endpoint or text-embeddings-inference server texts are posted to.
+	URL       string `yaml:"url"`
+	Model     string `yaml:"model"`     // Model of the Inference API, e.g. sentence-transformers/all-MiniLM-L6-v2
+	Token     string `yaml:"token"`     // Defaults to the HF_TOKEN environment variable
+	BatchSize int    `yaml:"batchSize"` // Texts encoded per request, defaults to 32
+}
+
+// VertexAIConfig represents a Google text embedding model served by Vertex AI. Requests are
+// authenticated with application default credentials: the credentials file, a service account key
+// in GOOGLE_APPLICATION_CREDENTIALS, gcloud's credentials or the GKE/GCE metadata server.
+type VertexAIConfig struct {
+	Project         string `yaml:"project"`         // Defaults to GOOGLE_CLOUD_PROJECT or the service account's project
+	Location        string `yaml:"location"`        // Defaults to us-central1
+	Model           string `yaml:"model"`           // Defaults to text-embedding-004
+	CredentialsFile string `yaml:"credentialsFile"` // Service account key or authorized user credentials
+	BatchSize       int    `yaml:"batchSize"`       // Texts encoded per request, defaults to 32
 }
 
 // OllamaConfig represents Ollama-specific configuration
 type OllamaConfig struct {
 	URL   string `yaml:"url"`
 	Model string `yaml:"model"`
+	// KeepAlive is how long Ollama keeps the model loaded after a request, e.g. 30m; a negative
+	// duration keeps it loaded indefinitely. Defaults to Ollama's own 5 minutes.
+	KeepAlive string `yaml:"keepAlive"`
+	Pull      bool   `yaml:"pull"` // Pull the model on startup if the server does not have it
 }
 
 // NotificationConfig represents notification configuration
 type NotificationConfig struct {
 	Enabled      bool               `yaml:"enabled"`
 	Type         string             `yaml:"type"`
-	MinSeverity  string             `yaml:"minSeverity"`
+	MinSeverity  string             `yaml:"minSeverity"` // Low, Medium, High or Critical (case-insensitive)
+	MinScore     float64            `yaml:"minScore"`    // Anomalies scored lower are not notified (0-100)
 	Slack        SlackConfig        `yaml:"slack"`
 	Email        EmailConfig        `yaml:"email"`
 	Webhook      WebhookConfig      `yaml:"webhook"`
 	Alertmanager AlertmanagerConfig `yaml:"alertmanager"`
+	Journal      JournalConfig      `yaml:"journal"`
+	Volume       VolumeConfig       `yaml:"volume"`
+}
+
+// VolumeConfig represents notification volume forecasting against the route's rate limit
+type VolumeConfig struct {
+	Enabled          bool `yaml:"enabled"`
+	RateLimitPerHour int  `yaml:"rateLimitPerHour"` // Notifications per hour the route can take
+	HorizonHours     int  `yaml:"horizonHours"`     // How far ahead to forecast
+	WindowHours      int  `yaml:"windowHours"`      // History used for the forecast
+}
+
+// JournalConfig represents the anomaly journal used to replay notifications
+type JournalConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	Path           string `yaml:"path"`
+	RetentionHours int    `yaml:"retentionHours"`
 }
 
 // SlackConfig represents Slack-specific configuration
@@ -135,6 +747,82 @@ type AlertmanagerConfig struct {
 	DefaultLabels map[string]string `yaml:"defaultLabels"`
 }
 
+// FeatureFlagsConfig represents feature flag configuration for gradual rollout of detectors
+type FeatureFlagsConfig struct {
+	Flags   map[string]FeatureFlag   `yaml:"flags"`
+	Service FeatureFlagServiceConfig `yaml:"service"`
+}
+
+// FeatureFlag represents the rollout of a single feature. A feature is enabled for a cluster
+// when the flag is enabled and the cluster is either listed or falls within the rollout percentage.
+type FeatureFlag struct {
+	Enabled    bool     `yaml:"enabled"`
+	Clusters   []string `yaml:"clusters"`   // Cluster IDs the feature is enabled for; empty means all
+	Percentage *int     `yaml:"percentage"` // Percentage of clusters (0-100) when no clusters are listed; unset means all
+}
+
+// FeatureFlagServiceConfig represents an external service that flags are fetched from
+type FeatureFlagServiceConfig struct {
+	URL             string            `yaml:"url"`
+	Headers         map[string]string `yaml:"headers"`
+	RefreshInterval int               `yaml:"refreshInterval"` // Interval in seconds
+}
+
+// HooksConfig represents external webhooks run at the anomaly pipeline's hook points
+type HooksConfig struct {
+	Webhooks []HookWebhookConfig `yaml:"webhooks"`
+}
+
+// HookWebhookConfig represents a webhook that can mutate, enrich or veto anomalies
+type HookWebhookConfig struct {
+	Name           string            `yaml:"name"`
+	URL            string            `yaml:"url"`
+	Stages         []string          `yaml:"stages"` // postDetect, preStore and/or preNotify
+	Headers        map[string]string `yaml:"headers"`
+	TimeoutSeconds int               `yaml:"timeoutSeconds"`
+	FailClosed     bool              `yaml:"failClosed"` // Veto anomalies when the webhook fails instead of passing them on
+}
+
+// Degradation modes of an optional integration
+const (
+	DegradeQueue = "queue" // Retry failed work on later cycles, e.g. backfill embeddings or buffer alerts
+	DegradeDrop  = "drop"  // Log and drop failed work
+	DegradeFail  = "fail"  // Refuse to start if the integration is unavailable; failed work is dropped
+)
+
+// DegradationConfig represents the behavior when optional integrations fail
+type DegradationConfig struct {
+	Embedding IntegrationDegradation `yaml:"embedding"` // Failed embeddings are backfilled when queued
+	Storage   IntegrationDegradation `yaml:"storage"`   // Failed stores are buffered when queued
+	Notifier  IntegrationDegradation `yaml:"notifier"`  // Failed notifications are resent when queued; fail is not supported
+}
+
+// IntegrationDegradation represents the degradation behavior of one integration
+type IntegrationDegradation struct {
+	Mode      string `yaml:"mode"`      // queue, drop or fail
+	QueueSize int    `yaml:"queueSize"` // Maximum queued operations; the oldest are dropped first
+}
+
+// CatchUpConfig represents the analysis of the gap since the last completed cycle
+// when the agent starts after downtime
+type CatchUpConfig struct {
+	Enabled        bool             `yaml:"enabled"`
+	CheckpointPath string           `yaml:"checkpointPath"`
+	MaxGapHours    int              `yaml:"maxGapHours"` // Longer gaps are only analyzed for this many hours
+	Prometheus     PrometheusConfig `yaml:"prometheus"`
+}
+
+// PrometheusConfig represents a Prometheus server queried for node usage history
+type PrometheusConfig struct {
+	URL          string            `yaml:"url"` // Leave empty to only analyze events
+	Headers      map[string]string `yaml:"headers"`
+	CPUQuery     string            `yaml:"cpuQuery"`     // Must return node CPU usage percentage
+	MemoryQuery  string            `yaml:"memoryQuery"`  // Must return node memory usage percentage
+	NodeLabel    string            `yaml:"nodeLabel"`    // Series label holding the node name
+	ClusterLabel string            `yaml:"clusterLabel"` // Series label holding the cluster name, if the server is shared
+	Step         int               `yaml:"step"`         // Query resolution in seconds
+}
+
 // FormattingConfig represents template-based formatting configuration
 type FormattingConfig struct {
 	AnomalyDisplayTemplate  string `yaml:"anomalyDisplayTemplate"`
@@ -156,9 +844,48 @@ func LoadConfig(path string) (*Config, error) {
 	// Set defaults
 	setDefaults(&config)
 
+	if err := validate(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
 	return &config, nil
 }
 
+// validate rejects settings that cannot be honored
+func validate(config *Config) error {
+	integrations := []struct {
+		name        string
+		degradation IntegrationDegradation
+	}{
+		{"embedding", config.Degradation.Embedding},
+		{"storage", config.Degradation.Storage},
+		{"notifier", config.Degradation.Notifier},
+	}
+	for _, integration := range integrations {
+		switch integration.degradation.Mode {
+		case DegradeQueue, DegradeDrop, DegradeFail:
+		default:
+			return fmt.Errorf("degradation.%s.mode must be %q, %q or %q, not %q",
+				integration.name, DegradeQueue, DegradeDrop, DegradeFail, integration.degradation.Mode)
+		}
+	}
+	// Notifiers cannot be checked at startup, so there is nothing for fail to refuse
+	if config.Degradation.Notifier.Mode == DegradeFail {
+		return fmt.Errorf("degradation.notifier.mode %q is not supported, use %q or %q", DegradeFail, DegradeQueue, DegradeDrop)
+	}
+	if config.MetricsPush.IntervalSeconds <= 0 {
+		return fmt.Errorf("metricsPush.intervalSeconds must be positive, not %d", config.MetricsPush.IntervalSeconds)
+	}
+	for name, flag := range config.FeatureFlags.Flags {
+		if flag.Percentage != nil && (*flag.Percentage < 0 || *flag.Percentage > 100) {
+			return fmt.Errorf("featureFlags.flags.%s.percentage must be between 0 and 100, not %d", name, *flag.Percentage)
+		}
+	}
+	if kms := config.Storage.Encryption.KMS; kms.Enabled && kms.Region == "" {
+		return fmt.Errorf("storage.encryption.kms.region is required")
+	}
+	return nil
+}
+
 // setDefaults sets default values for configuration fields
 func setDefaults(config *Config) {
 	// Handle backward compatibility - if no clusters defined, create default cluster
@@ -216,6 +943,141 @@ func setDefaults(config *Config) {
 	if config.Storage.Qdrant.DistanceMetric == "" {
 		config.Storage.Qdrant.DistanceMetric = "cosine"
 	}
+	if config.Storage.Qdrant.Protocol == "" {
+		config.Storage.Qdrant.Protocol = "rest"
+	}
+	if config.Storage.Qdrant.TimeoutSeconds == 0 {
+		config.Storage.Qdrant.TimeoutSeconds = 10
+	}
+
+	// Milvus defaults
+	if config.Storage.Milvus.URL == "" {
+		config.Storage.Milvus.URL = "http://localhost:19530"
+	}
+	if config.Storage.Milvus.Collection == "" {
+		config.Storage.Milvus.Collection = "huginn_alerts"
+	}
+	if config.Storage.Milvus.VectorSize == 0 {
+		config.Storage.Milvus.VectorSize = 384
+	}
+	if config.Storage.Milvus.MetricType == "" {
+		config.Storage.Milvus.MetricType = "cosine"
+	}
+
+	// Weaviate defaults
+	if config.Storage.Weaviate.URL == "" {
+		config.Storage.Weaviate.URL = "http://localhost:8080"
+	}
+	if config.Storage.Weaviate.Class == "" {
+		config.Storage.Weaviate.Class = "HuginnAlert"
+	}
+	if config.Storage.Weaviate.DistanceMetric == "" {
+		config.Storage.Weaviate.DistanceMetric = "cosine"
+	}
+	if config.Storage.Weaviate.HybridAlpha == 0 {
+		config.Storage.Weaviate.HybridAlpha = 0.75
+	}
+
+	// ClickHouse defaults
+	if config.Storage.ClickHouse.URL == "" {
+		config.Storage.ClickHouse.URL = "http://localhost:8123"
+	}
+	if config.Storage.ClickHouse.Database == "" {
+		config.Storage.ClickHouse.Database = "huginn"
+	}
+	if config.Storage.ClickHouse.Table == "" {
+		config.Storage.ClickHouse.Table = "alerts"
+	}
+	if config.Storage.ClickHouse.ObservationsTable == "" {
+		config.Storage.ClickHouse.ObservationsTable = "observations"
+	}
+	if config.Storage.ClickHouse.DistanceMetric == "" {
+		config.Storage.ClickHouse.DistanceMetric = "cosine"
+	}
+
+	// Local storage defaults
+	if config.Storage.Local.Path == "" {
+		config.Storage.Local.Path = "data/alerts.jsonl"
+	}
+	if config.Storage.Local.RetentionHours == 0 {
+		config.Storage.Local.RetentionHours = 168
+	}
+
+	// Storage batching defaults
+	if config.Storage.Batch.Size == 0 {
+		config.Storage.Batch.Size = 100
+	}
+	if config.Storage.Batch.IntervalSeconds == 0 {
+		config.Storage.Batch.IntervalSeconds = 5
+	}
+	if config.Storage.WriteBehind.QueueSize == 0 {
+		config.Storage.WriteBehind.QueueSize = 10000
+	}
+	if config.Storage.WriteBehind.MaxBackoffSeconds == 0 {
+		config.Storage.WriteBehind.MaxBackoffSeconds = 300
+	}
+	if config.Storage.Replicas.QueueSize == 0 {
+		config.Storage.Replicas.QueueSize = 1000
+	}
+	if config.Storage.HealthCheck.IntervalSeconds == 0 {
+		config.Storage.HealthCheck.IntervalSeconds = 30
+	}
+	if config.Storage.HealthCheck.FailureThreshold == 0 {
+		config.Storage.HealthCheck.FailureThreshold = 3
+	}
+
+	// Archive defaults
+	if config.Storage.Archive.IntervalMinutes == 0 {
+		config.Storage.Archive.IntervalMinutes = 60
+	}
+	if config.Storage.Archive.Provider == "" {
+		config.Storage.Archive.Provider = "s3"
+	}
+	if config.Storage.Archive.Region == "" {
+		config.Storage.Archive.Region = "us-east-1"
+		if config.Storage.Archive.Provider == "gcs" {
+			config.Storage.Archive.Region = "auto"
+		}
+	}
+	if config.Storage.Archive.Prefix == "" {
+		config.Storage.Archive.Prefix = `huginn/{{.Kind}}/{{.Time.Format "2006/01/02"}}`
+	}
+
+	// NATS defaults
+	if config.Storage.NATS.URL == "" {
+		config.Storage.NATS.URL = "nats://localhost:4222"
+	}
+	if config.Storage.NATS.Subject == "" {
+		config.Storage.NATS.Subject = "huginn.alerts.{{.ClusterName}}.{{.Severity}}"
+	}
+	if config.Storage.NATS.TimeoutSeconds == 0 {
+		config.Storage.NATS.TimeoutSeconds = 5
+	}
+
+	// Time-series export defaults
+	if config.Storage.TimeSeries.Type == "" {
+		config.Storage.TimeSeries.Type = "victoriametrics"
+	}
+	if config.Storage.TimeSeries.URL == "" {
+		config.Storage.TimeSeries.URL = "http://localhost:8428"
+		if config.Storage.TimeSeries.Type == "influxdb" {
+			config.Storage.TimeSeries.URL = "http://localhost:8086"
+		}
+	}
+	if config.Storage.TimeSeries.Bucket == "" {
+		config.Storage.TimeSeries.Bucket = "huginn"
+	}
+	if config.Storage.TimeSeries.Measurement == "" {
+		config.Storage.TimeSeries.Measurement = "huginn_observation"
+	}
+	if config.Storage.TimeSeries.RestoreHours == 0 {
+		config.Storage.TimeSeries.RestoreHours = 24
+	}
+
+	// Alert ID defaults
+	if config.Storage.AlertID.Scheme == "" {
+		config.Storage.AlertID.Scheme = "uuid"
+	}
 
 	// Redis defaults
 	if config.Storage.Redis.KeyPrefix == "" {
@@ -232,9 +1094,24 @@ func setDefaults(config *Config) {
 	if config.AnomalyDetection.PodRestartThreshold == 0 {
 		config.AnomalyDetection.PodRestartThreshold = 3
 	}
+	if config.AnomalyDetection.RestartWindowMinutes == 0 {
+		config.AnomalyDetection.RestartWindowMinutes = 5
+	}
+	if config.AnomalyDetection.DiskThreshold == 0 {
+		config.AnomalyDetection.DiskThreshold = 85.0
+	}
+	if config.AnomalyDetection.DiskAlpha == 0 {
+		config.AnomalyDetection.DiskAlpha = 0.3
+	}
+	if config.AnomalyDetection.DiskPressureMinutes == 0 {
+		config.AnomalyDetection.DiskPressureMinutes = 5
+	}
 	if config.AnomalyDetection.MaxHistorySize == 0 {
 		config.AnomalyDetection.MaxHistorySize = 1000
 	}
+	if config.AnomalyDetection.SeriesHistorySize == 0 {
+		config.AnomalyDetection.SeriesHistorySize = 100
+	}
 
 	// Alpha defaults for EWMA smoothing
 	if config.AnomalyDetection.CPUAlpha == 0 {
@@ -247,6 +1124,179 @@ func setDefaults(config *Config) {
 		config.AnomalyDetection.RestartAlpha = 0.3
 	}
 
+	// Statistics mode default
+	if config.AnomalyDetection.Statistics == "" {
+		config.AnomalyDetection.Statistics = "standard"
+	}
+
+	// Security policy defaults
+	if config.AnomalyDetection.SecurityPolicy.PrivilegedNamespaces == nil {
+		config.AnomalyDetection.SecurityPolicy.PrivilegedNamespaces = []string{"kube-system"}
+	}
+
+	// Secret/ConfigMap churn defaults
+	if config.AnomalyDetection.ConfigChurn.ChurnThreshold == 0 {
+		config.AnomalyDetection.ConfigChurn.ChurnThreshold = 10
+	}
+	if config.AnomalyDetection.ConfigChurn.MaxObjectSizeBytes == 0 {
+		config.AnomalyDetection.ConfigChurn.MaxObjectSizeBytes = 512 * 1024 // etcd rejects objects above ~1.5MiB
+	}
+
+	// Workload drift defaults
+	if config.AnomalyDetection.Drift.Workloads.ReplicaTolerance == 0 {
+		config.AnomalyDetection.Drift.Workloads.ReplicaTolerance = 0.5
+	}
+	if config.AnomalyDetection.Drift.Workloads.ErrorRateTolerance == 0 {
+		config.AnomalyDetection.Drift.Workloads.ErrorRateTolerance = 0.25
+	}
+
+	// PVC and PV defaults
+	if config.AnomalyDetection.PVC.PendingCycles == 0 {
+		config.AnomalyDetection.PVC.PendingCycles = 3
+	}
+	if config.AnomalyDetection.PV.ReleasedCycles == 0 {
+		config.AnomalyDetection.PV.ReleasedCycles = 3
+	}
+	if config.AnomalyDetection.PV.MaxRetainedVolumes == 0 {
+		config.AnomalyDetection.PV.MaxRetainedVolumes = 10
+	}
+
+	// Multivariate detection defaults
+	if config.AnomalyDetection.Multivariate.Trees == 0 {
+		config.AnomalyDetection.Multivariate.Trees = 100
+	}
+	if config.AnomalyDetection.Multivariate.SampleSize == 0 {
+		config.AnomalyDetection.Multivariate.SampleSize = 256
+	}
+	if config.AnomalyDetection.Multivariate.MinSamples == 0 {
+		config.AnomalyDetection.Multivariate.MinSamples = 50
+	}
+	if config.AnomalyDetection.Multivariate.ScoreThreshold == 0 {
+		config.AnomalyDetection.Multivariate.ScoreThreshold = 0.65
+	}
+
+	// Rate-of-change defaults
+	if config.AnomalyDetection.Slope.Intervals == 0 {
+		config.AnomalyDetection.Slope.Intervals = 10
+	}
+	if config.AnomalyDetection.Slope.MinSlope == 0 {
+		config.AnomalyDetection.Slope.MinSlope = 2.0
+	}
+	if config.AnomalyDetection.Slope.MinFit == 0 {
+		config.AnomalyDetection.Slope.MinFit = 0.8
+	}
+
+	// Capacity forecast defaults
+	if config.AnomalyDetection.Forecast.HorizonHours == 0 {
+		config.AnomalyDetection.Forecast.HorizonHours = 6
+	}
+	if config.AnomalyDetection.Forecast.Intervals == 0 {
+		config.AnomalyDetection.Forecast.Intervals = 30
+	}
+	if config.AnomalyDetection.Forecast.MinFit == 0 {
+		config.AnomalyDetection.Forecast.MinFit = 0.7
+	}
+
+	// Warm-up defaults; metric types left out of the configuration keep theirs
+	if config.AnomalyDetection.MinHistory == nil {
+		config.AnomalyDetection.MinHistory = make(map[string]int)
+	}
+	for metric, samples := range map[string]int{"cpu": 5, "memory": 5, "disk": 5, "restarts": 3, "churn": 5} {
+		if _, ok := config.AnomalyDetection.MinHistory[metric]; !ok {
+			config.AnomalyDetection.MinHistory[metric] = samples
+		}
+	}
+
+	// Learning defaults
+	if config.AnomalyDetection.Learning.WindowCycles == 0 {
+		config.AnomalyDetection.Learning.WindowCycles = 20
+	}
+	if config.AnomalyDetection.Learning.HealthyReward == 0 {
+		config.AnomalyDetection.Learning.HealthyReward = 0.95
+	}
+	if config.AnomalyDetection.Learning.UnhealthyReward == 0 {
+		config.AnomalyDetection.Learning.UnhealthyReward = 0.8
+	}
+	if config.AnomalyDetection.Learning.FalsePositiveRate == 0 {
+		config.AnomalyDetection.Learning.FalsePositiveRate = 0.5
+	}
+	if config.AnomalyDetection.Learning.MissRate == 0 {
+		config.AnomalyDetection.Learning.MissRate = 0.5
+	}
+	if config.AnomalyDetection.Learning.Step == 0 {
+		config.AnomalyDetection.Learning.Step = 0.05
+	}
+	if config.AnomalyDetection.Learning.AlphaStep == 0 {
+		config.AnomalyDetection.Learning.AlphaStep = 0.05
+	}
+	if config.AnomalyDetection.Learning.MaxAdjustment == 0 {
+		config.AnomalyDetection.Learning.MaxAdjustment = 0.25
+	}
+
+	// Feedback defaults
+	if config.AnomalyDetection.Feedback.Step == 0 {
+		config.AnomalyDetection.Feedback.Step = 0.05
+	}
+	if config.AnomalyDetection.Feedback.MaxAdjustment == 0 {
+		config.AnomalyDetection.Feedback.MaxAdjustment = 0.25
+	}
+
+	// Hysteresis defaults
+	if config.AnomalyDetection.Hysteresis.ClearRatio == 0 {
+		config.AnomalyDetection.Hysteresis.ClearRatio = 0.9
+	}
+	if config.AnomalyDetection.Hysteresis.FlapWindowMinutes == 0 {
+		config.AnomalyDetection.Hysteresis.FlapWindowMinutes = 30
+	}
+	if config.AnomalyDetection.Hysteresis.MaxFlaps == 0 {
+		config.AnomalyDetection.Hysteresis.MaxFlaps = 4
+	}
+
+	// Health score defaults
+	if config.AnomalyDetection.HealthScore.Weights == (HealthWeights{}) {
+		config.AnomalyDetection.HealthScore.Weights = HealthWeights{Nodes: 0.4, Pods: 0.3, Events: 0.15, Anomalies: 0.15}
+	}
+	if config.AnomalyDetection.HealthScore.DropThreshold == 0 {
+		config.AnomalyDetection.HealthScore.DropThreshold = 20
+	}
+	if config.AnomalyDetection.HealthScore.Intervals == 0 {
+		config.AnomalyDetection.HealthScore.Intervals = 10
+	}
+
+	// Problematic event reason defaults; an explicitly empty list disables them
+	if config.AnomalyDetection.EventReasons == nil {
+		for _, reason := range []string{"FailedScheduling", "FailedMount", "FailedAttachVolume", "FailedCreate", "FailedDelete", "BackOff", "CrashLoopBackOff", "ImagePullBackOff"} {
+			config.AnomalyDetection.EventReasons = append(config.AnomalyDetection.EventReasons, EventReasonConfig{Reason: reason})
+		}
+	}
+	for i := range config.AnomalyDetection.EventReasons {
+		if config.AnomalyDetection.EventReasons[i].Severity == "" {
+			config.AnomalyDetection.EventReasons[i].Severity = "High"
+		}
+	}
+
+	// Severity scoring defaults
+	if config.AnomalyDetection.Severity.Metrics == nil {
+		config.AnomalyDetection.Severity.Metrics = map[string]SeverityBoundaries{
+			"cpu":      {Medium: 90, High: 95, Critical: 98},
+			"memory":   {Medium: 90, High: 95, Critical: 98},
+			"disk":     {Medium: 90, High: 95, Critical: 98},
+			"restarts": {Medium: 10, High: 25, Critical: 50},
+		}
+	}
+	if config.AnomalyDetection.Severity.Deviation == (SeverityBoundaries{}) {
+		config.AnomalyDetection.Severity.Deviation = SeverityBoundaries{Medium: 4, High: 6, Critical: 10}
+	}
+
+	// Trend history defaults: 5 minutes for 2 days, 1 hour for 30 days, 1 day for a year
+	if len(config.AnomalyDetection.TrendHistory.Tiers) == 0 {
+		config.AnomalyDetection.TrendHistory.Tiers = []TrendTierConfig{
+			{ResolutionMinutes: 5, RetentionHours: 48},
+			{ResolutionMinutes: 60, RetentionHours: 720},
+			{ResolutionMinutes: 1440, RetentionHours: 8760},
+		}
+	}
+
 	// Minimum standard deviation default
 	if config.AnomalyDetection.MinStdDev == 0 {
 		config.AnomalyDetection.MinStdDev = 1.0
@@ -268,22 +1318,167 @@ func setDefaults(config *Config) {
 		config.Embedding.Ollama.Model = "nomic-embed-text"
 	}
 
+	// Embedding retry defaults
+	if config.Embedding.Retry.MaxAttempts == 0 {
+		config.Embedding.Retry.MaxAttempts = 3
+	}
+	if config.Embedding.Retry.InitialBackoffMs == 0 {
+		config.Embedding.Retry.InitialBackoffMs = 500
+	}
+	if config.Embedding.Retry.MaxBackoffMs == 0 {
+		config.Embedding.Retry.MaxBackoffMs = 5000
+	}
+	if config.Embedding.Retry.TimeoutSeconds == 0 {
+		config.Embedding.Retry.TimeoutSeconds = 30
+	}
+
+	// Embedding worker defaults
+	if config.Embedding.Workers.Concurrency == 0 {
+		config.Embedding.Workers.Concurrency = 4
+	}
+	if config.Embedding.Workers.QueueSize == 0 {
+		config.Embedding.Workers.QueueSize = 100
+	}
+
 	// OpenAI defaults
 	if config.Embedding.OpenAI.Model == "" {
 		config.Embedding.OpenAI.Model = "text-embedding-ada-002"
 	}
 
+	// Azure OpenAI defaults
+	if config.Embedding.AzureOpenAI.APIVersion == "" {
+		config.Embedding.AzureOpenAI.APIVersion = "2024-02-01"
+	}
+	if config.Embedding.AzureOpenAI.BatchSize == 0 {
+		config.Embedding.AzureOpenAI.BatchSize = 16
+	}
+
+	// Cohere defaults
+	if config.Embedding.Cohere.URL == "" {
+		config.Embedding.Cohere.URL = "https://api.cohere.com"
+	}
+	if config.Embedding.Cohere.Model == "" {
+		config.Embedding.Cohere.Model = "embed-english-v3.0"
+	}
+	if config.Embedding.Cohere.InputType == "" {
+		config.Embedding.Cohere.InputType = "search_document"
+	}
+	if config.Embedding.Cohere.QueryInputType == "" {
+		config.Embedding.Cohere.QueryInputType = "search_query"
+	}
+	if config.Embedding.Cohere.BatchSize == 0 {
+		config.Embedding.Cohere.BatchSize = 96
+	}
+
+	// HuggingFace defaults
+	if config.Embedding.HuggingFace.URL == "" {
+		config.Embedding.HuggingFace.URL = "https://api-inference.huggingface.co"
+	}
+	if config.Embedding.HuggingFace.BatchSize == 0 {
+		config.Embedding.HuggingFace.BatchSize = 32
+	}
+
+	// Vertex AI defaults
+	if config.Embedding.VertexAI.Location == "" {
+		config.Embedding.VertexAI.Location = "us-central1"
+	}
+	if config.Embedding.VertexAI.Model == "" {
+		config.Embedding.VertexAI.Model = "text-embedding-004"
+	}
+	if config.Embedding.VertexAI.BatchSize == 0 {
+		config.Embedding.VertexAI.BatchSize = 32
+	}
+
 	// Sentence Transformers defaults
 	if config.Embedding.SentenceTransformers.Model == "" {
-		config.Embedding.SentenceTransformers.Model = "all-MiniLM-L6-v2"
+		config.Embedding.SentenceTransformers.Model = "sentence-transformers/all-MiniLM-L6-v2"
 	}
-	if config.Embedding.SentenceTransformers.Device == "" {
-		config.Embedding.SentenceTransformers.Device = "cpu"
+	if config.Embedding.SentenceTransformers.URL == "" {
+		config.Embedding.SentenceTransformers.URL = "http://localhost:8081"
+	}
+	if config.Embedding.SentenceTransformers.BatchSize == 0 {
+		config.Embedding.SentenceTransformers.BatchSize = 32
 	}
 
 	// Notification defaults
 	if config.Notification.MinSeverity == "" {
-		config.Notification.MinSeverity = "warning"
+		config.Notification.MinSeverity = "Low"
+	}
+
+	// Notification volume defaults
+	if config.Notification.Volume.RateLimitPerHour == 0 {
+		config.Notification.Volume.RateLimitPerHour = 60
+	}
+	if config.Notification.Volume.HorizonHours == 0 {
+		config.Notification.Volume.HorizonHours = 24
+	}
+	if config.Notification.Volume.WindowHours == 0 {
+		config.Notification.Volume.WindowHours = 168
+	}
+
+	// Feature flag defaults
+	if config.FeatureFlags.Service.RefreshInterval == 0 {
+		config.FeatureFlags.Service.RefreshInterval = 60
+	}
+
+	// Journal defaults
+	if config.Notification.Journal.Path == "" {
+		config.Notification.Journal.Path = "data/journal.jsonl"
+	}
+	if config.Notification.Journal.RetentionHours == 0 {
+		config.Notification.Journal.RetentionHours = 24
+	}
+
+	// Storage sampling defaults
+	if config.Storage.Sampling.Rates == nil {
+		config.Storage.Sampling.Rates = map[string]float64{"critical": 1, "high": 1, "medium": 0.5, "low": 0.1}
+	}
+	if config.Storage.Sampling.FirstOccurrenceHours == 0 {
+		config.Storage.Sampling.FirstOccurrenceHours = 168
+	}
+	if config.Storage.Dedup.WindowMinutes == 0 {
+		config.Storage.Dedup.WindowMinutes = 60
+	}
+
+	// Hook webhook defaults
+	for i := range config.Hooks.Webhooks {
+		if config.Hooks.Webhooks[i].TimeoutSeconds == 0 {
+			config.Hooks.Webhooks[i].TimeoutSeconds = 5
+		}
+	}
+
+	// Degradation defaults: keep working and retry failed work later
+	for _, degradation := range []*IntegrationDegradation{&config.Degradation.Embedding, &config.Degradation.Storage, &config.Degradation.Notifier} {
+		if degradation.Mode == "" {
+			degradation.Mode = DegradeQueue
+		}
+		if degradation.QueueSize == 0 {
+			degradation.QueueSize = 1000
+		}
+	}
+
+	// Catch-up defaults
+	if config.CatchUp.CheckpointPath == "" {
+		config.CatchUp.CheckpointPath = "data/checkpoints.json"
+	}
+	if config.CatchUp.MaxGapHours == 0 {
+		config.CatchUp.MaxGapHours = 24
+	}
+	if config.CatchUp.Prometheus.CPUQuery == "" {
+		grouping := "instance"
+		if label := config.CatchUp.Prometheus.ClusterLabel; label != "" {
+			grouping += ", " + label
+		}
+		config.CatchUp.Prometheus.CPUQuery = `100 * (1 - avg by (` + grouping + `) (rate(node_cpu_seconds_total{mode="idle"}[5m])))`
+	}
+	if config.CatchUp.Prometheus.MemoryQuery == "" {
+		config.CatchUp.Prometheus.MemoryQuery = `100 * (1 - node_memory_MemAvailable_bytes / node_memory_MemTotal_bytes)`
+	}
+	if config.CatchUp.Prometheus.NodeLabel == "" {
+		config.CatchUp.Prometheus.NodeLabel = "instance"
+	}
+	if config.CatchUp.Prometheus.Step == 0 {
+		config.CatchUp.Prometheus.Step = 60
 	}
 
 	// Observation interval default
@@ -291,6 +1486,22 @@ func setDefaults(config *Config) {
 		config.ObservationInterval = 30 // Default to 30 seconds
 	}
 
+	// Metrics server default
+	if config.MetricsServer.Address == "" {
+		config.MetricsServer.Address = ":8080"
+	}
+
+	// Metrics push defaults
+	if config.MetricsPush.IntervalSeconds == 0 {
+		config.MetricsPush.IntervalSeconds = config.ObservationInterval
+	}
+	if config.MetricsPush.Pushgateway.Job == "" {
+		config.MetricsPush.Pushgateway.Job = "huginn"
+	}
+	if config.MetricsPush.Pushgateway.Instance == "" {
+		config.MetricsPush.Pushgateway.Instance, _ = os.Hostname()
+	}
+
 	// Formatting defaults
 	if config.Formatting.AnomalyDisplayTemplate == "" {
 		config.Formatting.AnomalyDisplayTemplate = "Cluster {{.ClusterName}} [{{.Severity}}] {{.Type}} in {{.ResourceType}} resource {{.Resource}} in namespace {{.Namespace}}: {{.Description}}\n"
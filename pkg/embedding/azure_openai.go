@@ -0,0 +1,71 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AzureOpenAIModel implements an embedding model deployed to an Azure OpenAI resource. Requests go
+// to the deployment, which determines the model, and are authenticated with the resource's API key.
+type AzureOpenAIModel struct {
+	url       string
+	apiKey    string
+	dimension int
+	batchSize int
+	client    *http.Client
+}
+
+// NewAzureOpenAIModel creates a new Azure OpenAI embedding model for the deployment of the
+// resource at endpoint, e.g. https://my-resource.openai.azure.com
+func NewAzureOpenAIModel(endpoint, deployment, apiVersion, apiKey string, dimension, batchSize int) (*AzureOpenAIModel, error) {
+	if endpoint == "" || deployment == "" {
+		return nil, fmt.Errorf("azure-openai embeddings require an endpoint and a deployment")
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("azure-openai embeddings require an API key")
+	}
+	query := url.Values{"api-version": {apiVersion}}
+	return &AzureOpenAIModel{
+		url: fmt.Sprintf("%s/openai/deployments/%s/embeddings?%s",
+			strings.TrimSuffix(endpoint, "/"), url.PathEscape(deployment), query.Encode()),
+		apiKey:    apiKey,
+		dimension: dimension,
+		batchSize: max(batchSize, 1),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}, nil
+}
+
+// Encode implements the Model interface
+func (m *AzureOpenAIModel) Encode(text string) ([]float32, error) {
+	return m.EncodeContext(context.Background(), text)
+}
+
+// EncodeContext encodes text, giving up when ctx is done
+func (m *AzureOpenAIModel) EncodeContext(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := m.EncodeBatchContext(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EncodeBatch encodes texts with as few requests as the batch size allows
+func (m *AzureOpenAIModel) EncodeBatch(texts []string) ([][]float32, error) {
+	return m.EncodeBatchContext(context.Background(), texts)
+}
+
+// EncodeBatchContext encodes texts with as few requests as the batch size allows, giving up when
+// ctx is done
+func (m *AzureOpenAIModel) EncodeBatchContext(ctx context.Context, texts []string) ([][]float32, error) {
+	header := http.Header{"Api-Key": {m.apiKey}}
+	return encodeBatches(texts, m.batchSize, func(batch []string) ([][]float32, error) {
+		payload := map[string]interface{}{"input": batch}
+		return postOpenAIEmbeddings(ctx, m.client, "Azure OpenAI", m.url, header, payload, batch, m.dimension)
+	})
+}
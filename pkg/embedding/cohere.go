@@ -0,0 +1,133 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CohereModel implements a Cohere embed model. Cohere's v3 models embed stored documents and
+// search queries differently, so stored alerts are encoded with the document input type and
+// alerts searched for similar ones with the query input type.
+type CohereModel struct {
+	url            string
+	apiKey         string
+	model          string
+	inputType      string
+	queryInputType string
+	dimension      int
+	batchSize      int
+	client         *http.Client
+}
+
+// NewCohereModel creates a new Cohere embedding model. inputType is the input type of stored
+// alerts, e.g. search_document, and queryInputType that of search queries, e.g. search_query.
+func NewCohereModel(url, apiKey, model, inputType, queryInputType string, dimension, batchSize int) (*CohereModel, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("cohere embeddings require an API key")
+	}
+	return &CohereModel{
+		url:            strings.TrimSuffix(url, "/"),
+		apiKey:         apiKey,
+		model:          model,
+		inputType:      inputType,
+		queryInputType: queryInputType,
+		dimension:      dimension,
+		batchSize:      max(batchSize, 1),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}, nil
+}
+
+// Encode implements the Model interface
+func (m *CohereModel) Encode(text string) ([]float32, error) {
+	return m.EncodeContext(context.Background(), text)
+}
+
+// EncodeContext encodes text as a document, giving up when ctx is done
+func (m *CohereModel) EncodeContext(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := m.EncodeBatchContext(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EncodeBatch encodes texts as documents with as few requests as the batch size allows
+func (m *CohereModel) EncodeBatch(texts []string) ([][]float32, error) {
+	return m.EncodeBatchContext(context.Background(), texts)
+}
+
+// EncodeBatchContext encodes texts as documents with as few requests as the batch size allows,
+// giving up when ctx is done
+func (m *CohereModel) EncodeBatchContext(ctx context.Context, texts []string) ([][]float32, error) {
+	return encodeBatches(texts, m.batchSize, func(batch []string) ([][]float32, error) {
+		return m.embed(ctx, batch, m.inputType)
+	})
+}
+
+// EncodeQuery encodes a search query with the query input type
+func (m *CohereModel) EncodeQuery(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := encodeBatches([]string{text}, 1, func(batch []string) ([][]float32, error) {
+		return m.embed(ctx, batch, m.queryInputType)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// embed encodes one batch of texts with an input type
+func (m *CohereModel) embed(ctx context.Context, texts []string, inputType string) ([][]float32, error) {
+	payload := map[string]interface{}{
+		"model":    m.model,
+		"texts":    texts,
+		"truncate": "END",
+	}
+	if inputType != "" {
+		payload["input_type"] = inputType
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.url+"/v1/embed", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make Cohere API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, statusError("Cohere", resp.StatusCode, body)
+	}
+
+	var response struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode Cohere API response: %v", err)
+	}
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(response.Embeddings))
+	}
+	for i, embedding := range response.Embeddings {
+		if err := checkDimension(embedding, m.dimension, texts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return response.Embeddings, nil
+}
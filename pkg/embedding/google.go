@@ -0,0 +1,135 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+// googleScope is the OAuth scope of Google Cloud APIs
+const googleScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// googleTokenURL is where Google OAuth tokens are exchanged
+const googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// googleMetadataTokenURL serves access tokens of the service account of GCE instances and, with
+// Workload Identity, of GKE pods
+const googleMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// googleCredentials is a credentials file of a service account key or of gcloud application
+// default credentials
+type googleCredentials struct {
+	Type         string `json:"type"` // service_account or authorized_user
+	ProjectID    string `json:"project_id"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// googleTokenSource finds Google application default credentials: the credentials file given,
+// the file of GOOGLE_APPLICATION_CREDENTIALS, gcloud's application default credentials or the
+// metadata server of GCE and GKE, in that order. It returns the tokens and the project of the
+// credentials, if known.
+func googleTokenSource(credentialsFile string) (oauth2.TokenSource, string, error) {
+	if credentialsFile == "" {
+		credentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if credentialsFile == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			path := filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+			if _, err := os.Stat(path); err == nil {
+				credentialsFile = path
+			}
+		}
+	}
+	if credentialsFile == "" {
+		return oauth2.ReuseTokenSource(nil, &metadataTokenSource{client: &http.Client{Timeout: 10 * time.Second}}), "", nil
+	}
+
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read Google credentials: %v", err)
+	}
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, "", fmt.Errorf("failed to parse Google credentials %s: %v", credentialsFile, err)
+	}
+
+	ctx := context.Background()
+	switch creds.Type {
+	case "service_account":
+		tokenURL := creds.TokenURI
+		if tokenURL == "" {
+			tokenURL = googleTokenURL
+		}
+		config := &jwt.Config{
+			Email:        creds.ClientEmail,
+			PrivateKey:   []byte(creds.PrivateKey),
+			PrivateKeyID: creds.PrivateKeyID,
+			Scopes:       []string{googleScope},
+			TokenURL:     tokenURL,
+		}
+		return config.TokenSource(ctx), creds.ProjectID, nil
+	case "authorized_user":
+		config := &oauth2.Config{
+			ClientID:     creds.ClientID,
+			ClientSecret: creds.ClientSecret,
+			Endpoint:     oauth2.Endpoint{TokenURL: googleTokenURL},
+			Scopes:       []string{googleScope},
+		}
+		return config.TokenSource(ctx, &oauth2.Token{RefreshToken: creds.RefreshToken}), "", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported Google credentials type %q in %s", creds.Type, credentialsFile)
+	}
+}
+
+// metadataTokenSource fetches access tokens from the metadata server
+type metadataTokenSource struct {
+	client *http.Client
+}
+
+// Token implements oauth2.TokenSource
+func (s *metadataTokenSource) Token() (*oauth2.Token, error) {
+	req, err := http.NewRequest("GET", googleMetadataTokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("no Google credentials found and the metadata server is unreachable: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata server returned status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata server token: %v", err)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("metadata server returned an empty token")
+	}
+	return &oauth2.Token{
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		Expiry:      time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,154 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HuggingFaceModel implements an embedding model hosted by HuggingFace: a model of the serverless
+// Inference API run with the feature-extraction pipeline, or the model served by a dedicated
+// Inference Endpoint or a text-embeddings-inference server
+type HuggingFaceModel struct {
+	url        string
+	token      string
+	serverless bool
+	dimension  int
+	batchSize  int
+	client     *http.Client
+}
+
+// NewHuggingFaceModel creates a new HuggingFace embedding model. With a model name, the model is
+// run by the Inference API at url; without one, texts are posted to url itself, e.g. the URL of
+// an Inference Endpoint.
+func NewHuggingFaceModel(url, model, token string, dimension, batchSize int) *HuggingFaceModel {
+	m := &HuggingFaceModel{
+		url:       strings.TrimSuffix(url, "/"),
+		token:     token,
+		dimension: dimension,
+		batchSize: max(batchSize, 1),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+	if model != "" {
+		m.url += "/pipeline/feature-extraction/" + model
+		m.serverless = true
+	}
+	return m
+}
+
+// Encode implements the Model interface
+func (m *HuggingFaceModel) Encode(text string) ([]float32, error) {
+	return m.EncodeContext(context.Background(), text)
+}
+
+// EncodeContext encodes text, giving up when ctx is done
+func (m *HuggingFaceModel) EncodeContext(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := m.EncodeBatchContext(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EncodeBatch encodes texts with as few requests as the batch size allows
+func (m *HuggingFaceModel) EncodeBatch(texts []string) ([][]float32, error) {
+	return m.EncodeBatchContext(context.Background(), texts)
+}
+
+// EncodeBatchContext encodes texts with as few requests as the batch size allows, giving up when
+// ctx is done
+func (m *HuggingFaceModel) EncodeBatchContext(ctx context.Context, texts []string) ([][]float32, error) {
+	return encodeBatches(texts, m.batchSize, func(batch []string) ([][]float32, error) {
+		return m.embed(ctx, batch)
+	})
+}
+
+// embed encodes one batch of texts
+func (m *HuggingFaceModel) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	payload := map[string]interface{}{"inputs": texts}
+	if m.serverless {
+		// Wait for cold models to load instead of failing with 503
+		payload["options"] = map[string]interface{}{"wait_for_model": true}
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.token != "" {
+		req.Header.Set("Authorization", "Bearer "+m.token)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make HuggingFace API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, statusError("HuggingFace", resp.StatusCode, body)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HuggingFace API response: %v", err)
+	}
+	vectors, err := decodeFeatures(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode HuggingFace API response: %v", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(vectors))
+	}
+	for i, vector := range vectors {
+		if err := checkDimension(vector, m.dimension, texts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return vectors, nil
+}
+
+// decodeFeatures decodes the output of the feature-extraction pipeline: one vector per text for
+// sentence embedding models, or one vector per token for other models, which are mean pooled
+func decodeFeatures(body []byte) ([][]float32, error) {
+	var vectors [][]float32
+	if err := json.Unmarshal(body, &vectors); err == nil {
+		return vectors, nil
+	}
+
+	var tokens [][][]float32
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, err
+	}
+	vectors = make([][]float32, len(tokens))
+	for i, text := range tokens {
+		if len(text) == 0 {
+			continue
+		}
+		pooled := make([]float32, len(text[0]))
+		for _, token := range text {
+			for j := range pooled {
+				if j < len(token) {
+					pooled[j] += token[j]
+				}
+			}
+		}
+		for j := range pooled {
+			pooled[j] /= float32(len(text))
+		}
+		vectors[i] = pooled
+	}
+	return vectors, nil
+}
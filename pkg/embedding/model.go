@@ -1,14 +1,11 @@
 package embedding
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"math"
-	"net/http"
-	"strings"
-	"time"
 )
 
 // Model defines the interface for embedding models
@@ -17,6 +14,92 @@ type Model interface {
 	Encode(text string) ([]float32, error)
 }
 
+// BatchModel is implemented by models that encode several texts in one request
+type BatchModel interface {
+	EncodeBatch(texts []string) ([][]float32, error)
+}
+
+// EncodeAll encodes texts in one request if the model supports it and one by one otherwise
+func EncodeAll(model Model, texts []string) ([][]float32, error) {
+	if batch, ok := model.(BatchModel); ok {
+		return batch.EncodeBatch(texts)
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector, err := model.Encode(text)
+		if err != nil {
+			return nil, err
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// QueryModel is implemented by models that embed search queries differently from the documents
+// they are searched against
+type QueryModel interface {
+	EncodeQuery(ctx context.Context, text string) ([]float32, error)
+}
+
+// EncodeQuery encodes a search query as a query if the model distinguishes queries from
+// documents, and like any text otherwise
+func EncodeQuery(model Model, text string) ([]float32, error) {
+	if query, ok := model.(QueryModel); ok {
+		return query.EncodeQuery(context.Background(), text)
+	}
+	return model.Encode(text)
+}
+
+// HealthChecker is implemented by models served by a service that can be checked for readiness
+type HealthChecker interface {
+	Ping() error
+}
+
+// Ping checks that the model's service is ready if it can be checked, and does nothing otherwise
+func Ping(model Model) error {
+	if checker, ok := model.(HealthChecker); ok {
+		return checker.Ping()
+	}
+	return nil
+}
+
+// DimensionError is returned by models whose vectors do not have the configured dimension
+type DimensionError struct {
+	Expected int
+	Actual   int
+	Text     string
+}
+
+func (e *DimensionError) Error() string {
+	return fmt.Sprintf("expected embedding dimension %d, got %d (text length: %d, text preview: '%.100s')",
+		e.Expected, e.Actual, len(e.Text), e.Text)
+}
+
+// checkDimension returns a permanent DimensionError if the vector of text is not of dimension
+func checkDimension(vector []float32, dimension int, text string) error {
+	if len(vector) != dimension {
+		return permanent(&DimensionError{Expected: dimension, Actual: len(vector), Text: text})
+	}
+	return nil
+}
+
+// probeText is encoded to find the dimension of a model's vectors
+const probeText = "huginn embedding dimension probe"
+
+// ProbeDimension encodes a probe text and returns the dimension of the model's vectors, even if
+// it is not the dimension the model was configured with
+func ProbeDimension(model Model) (int, error) {
+	vector, err := model.Encode(probeText)
+	var dimErr *DimensionError
+	if errors.As(err, &dimErr) {
+		return dimErr.Actual, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(vector), nil
+}
+
 // SimpleModel implements a simple hash-based embedding model
 type SimpleModel struct {
 	dimension int
@@ -70,94 +153,3 @@ func (m *OpenAIModel) Encode(text string) ([]float32, error) {
 	simpleModel := NewSimpleModel(m.dimension)
 	return simpleModel.Encode(text)
 }
-
-// SentenceTransformersModel implements a Sentence Transformers-based embedding model
-type SentenceTransformersModel struct {
-	model     string
-	device    string
-	dimension int
-}
-
-// NewSentenceTransformersModel creates a new Sentence Transformers embedding model
-func NewSentenceTransformersModel(model, device string, dimension int) *SentenceTransformersModel {
-	return &SentenceTransformersModel{
-		model:     model,
-		device:    device,
-		dimension: dimension,
-	}
-}
-
-// Encode implements the Model interface
-func (m *SentenceTransformersModel) Encode(text string) ([]float32, error) {
-	// TODO: Implement Sentence Transformers API call
-	// For now, use the simple model as a fallback
-	simpleModel := NewSimpleModel(m.dimension)
-	return simpleModel.Encode(text)
-}
-
-// OllamaModel implements an Ollama-based embedding model
-type OllamaModel struct {
-	url       string
-	model     string
-	dimension int
-	client    *http.Client
-}
-
-// NewOllamaModel creates a new Ollama embedding model
-func NewOllamaModel(url, model string, dimension int) *OllamaModel {
-	return &OllamaModel{
-		url:       url,
-		model:     model,
-		dimension: dimension,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}
-}
-
-// Encode implements the Model interface
-func (m *OllamaModel) Encode(text string) ([]float32, error) {
-	// Handle edge case of empty text
-	if strings.TrimSpace(text) == "" {
-		return nil, fmt.Errorf("cannot generate embedding for empty text")
-	}
-
-	// Prepare the request payload
-	payload := map[string]interface{}{
-		"model":  m.model,
-		"prompt": text,
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request payload: %v", err)
-	}
-
-	// Make the API request
-	resp, err := m.client.Post(m.url+"/api/embeddings", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to make Ollama API request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Ollama API returned status %d", resp.StatusCode)
-	}
-
-	// Parse the response
-	var response struct {
-		Embedding []float32 `json:"embedding"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode Ollama API response: %v", err)
-	}
-
-	// Validate embedding dimension
-	if len(response.Embedding) != m.dimension {
-		return nil, fmt.Errorf("expected embedding dimension %d, got %d (text length: %d, text preview: '%.100s')",
-			m.dimension, len(response.Embedding), len(text), text)
-	}
-
-	return response.Embedding, nil
-}
@@ -0,0 +1,182 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ollamaPullTimeout bounds the download of a missing model, which can take minutes
+const ollamaPullTimeout = 30 * time.Minute
+
+// OllamaModel implements an Ollama-based embedding model
+type OllamaModel struct {
+	url       string
+	model     string
+	dimension int
+	keepAlive string // How long Ollama keeps the model loaded after a request, e.g. 30m
+	pull      bool   // Whether a missing model is pulled when the model is pinged
+	client    *http.Client
+}
+
+// NewOllamaModel creates a new Ollama embedding model. keepAlive is sent with every request so
+// the model stays loaded between cycles; empty leaves Ollama's default of 5 minutes. If pull is
+// set, a model the server does not have is pulled by Ping.
+func NewOllamaModel(url, model string, dimension int, keepAlive string, pull bool) *OllamaModel {
+	return &OllamaModel{
+		url:       strings.TrimSuffix(url, "/"),
+		model:     model,
+		dimension: dimension,
+		keepAlive: keepAlive,
+		pull:      pull,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Encode implements the Model interface
+func (m *OllamaModel) Encode(text string) ([]float32, error) {
+	return m.EncodeContext(context.Background(), text)
+}
+
+// EncodeContext encodes text, giving up when ctx is done
+func (m *OllamaModel) EncodeContext(ctx context.Context, text string) ([]float32, error) {
+	// Handle edge case of empty text
+	if strings.TrimSpace(text) == "" {
+		return nil, permanent(fmt.Errorf("cannot generate embedding for empty text"))
+	}
+
+	// Prepare the request payload
+	payload := map[string]interface{}{
+		"model":  m.model,
+		"prompt": text,
+	}
+	if m.keepAlive != "" {
+		payload["keep_alive"] = m.keepAlive
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request payload: %v", err)
+	}
+
+	// Make the API request
+	req, err := http.NewRequestWithContext(ctx, "POST", m.url+"/api/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make Ollama API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, permanent(m.notFound())
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, statusError("Ollama", resp.StatusCode, body)
+	}
+
+	// Parse the response
+	var response struct {
+		Embedding []float32 `json:"embedding"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode Ollama API response: %v", err)
+	}
+
+	// Validate embedding dimension
+	if err := checkDimension(response.Embedding, m.dimension, text); err != nil {
+		return nil, err
+	}
+
+	return response.Embedding, nil
+}
+
+// Ping checks that the Ollama server has the model, pulling it if configured to
+func (m *OllamaModel) Ping() error {
+	resp, err := m.client.Get(m.url + "/api/tags")
+	if err != nil {
+		return fmt.Errorf("failed to reach Ollama at %s: %v", m.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Ollama at %s is not ready, status %d", m.url, resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("failed to decode Ollama model list: %v", err)
+	}
+	for _, model := range tags.Models {
+		// Models pulled without a tag are listed as name:latest
+		if model.Name == m.model || model.Name == m.model+":latest" {
+			return nil
+		}
+	}
+
+	if !m.pull {
+		return m.notFound()
+	}
+	return m.pullModel()
+}
+
+// pullModel downloads the model to the Ollama server, waiting until it is ready
+func (m *OllamaModel) pullModel() error {
+	jsonData, err := json.Marshal(map[string]interface{}{"model": m.model, "stream": false})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request payload: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaPullTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", m.url+"/api/pull", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	log.Printf("Pulling Ollama model %s, this may take a few minutes", m.model)
+	start := time.Now()
+	// The client timeout would cut the download short; the context bounds it instead
+	client := &http.Client{Transport: m.client.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to pull Ollama model %s: %v", m.model, err)
+	}
+	defer resp.Body.Close()
+
+	var status struct {
+		Error string `json:"error"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	_ = json.Unmarshal(body, &status)
+	if resp.StatusCode != http.StatusOK || status.Error != "" {
+		if status.Error == "" {
+			status.Error = strings.TrimSpace(string(body))
+		}
+		return permanent(fmt.Errorf("failed to pull Ollama model %s, status %d: %s", m.model, resp.StatusCode, status.Error))
+	}
+	log.Printf("Pulled Ollama model %s in %s", m.model, time.Since(start).Round(time.Second))
+	return nil
+}
+
+// notFound returns the error of a model the Ollama server does not have
+func (m *OllamaModel) notFound() error {
+	return fmt.Errorf("Ollama at %s does not have model %s; run `ollama pull %s` or set embedding.ollama.pull",
+		m.url, m.model, m.model)
+}
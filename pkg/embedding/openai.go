@@ -0,0 +1,86 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// encodeBatches encodes texts in order with one call of encode per batch of up to size texts.
+// Empty texts are rejected before any call.
+func encodeBatches(texts []string, size int, encode func(batch []string) ([][]float32, error)) ([][]float32, error) {
+	for _, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			return nil, permanent(fmt.Errorf("cannot generate embedding for empty text"))
+		}
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		end := min(start+size, len(texts))
+		batch, err := encode(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// postOpenAIEmbeddings sends a request to an embeddings API in the format of OpenAI's, which Azure
+// OpenAI and many inference servers also serve, and returns the embeddings of texts in order
+func postOpenAIEmbeddings(ctx context.Context, client *http.Client, api, url string, header http.Header,
+	payload interface{}, texts []string, dimension int) ([][]float32, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make %s API request: %v", api, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, statusError(api, resp.StatusCode, body)
+	}
+
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode %s API response: %v", api, err)
+	}
+	if len(response.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(response.Data))
+	}
+
+	// Embeddings are returned with the index of their input, not necessarily in order
+	sort.Slice(response.Data, func(i, j int) bool { return response.Data[i].Index < response.Data[j].Index })
+	vectors := make([][]float32, len(response.Data))
+	for i, data := range response.Data {
+		if err := checkDimension(data.Embedding, dimension, texts[i]); err != nil {
+			return nil, err
+		}
+		vectors[i] = data.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,171 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ContextModel is implemented by models whose requests can be given a deadline
+type ContextModel interface {
+	EncodeContext(ctx context.Context, text string) ([]float32, error)
+}
+
+// ContextBatchModel is implemented by batch models whose requests can be given a deadline
+type ContextBatchModel interface {
+	EncodeBatchContext(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// permanentError is a failure retrying cannot fix, e.g. a rejected request or a vector of the
+// wrong dimension
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// permanent marks an error as not worth retrying
+func permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether an embedding failed in a way retrying cannot fix
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// statusError returns the error of an API response with an unexpected status. Client errors are
+// permanent, except timeouts and rate limiting.
+func statusError(api string, status int, body []byte) error {
+	err := fmt.Errorf("%s API returned status %d", api, status)
+	if text := strings.TrimSpace(string(body)); text != "" {
+		err = fmt.Errorf("%s API returned status %d: %s", api, status, text)
+	}
+	if status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests {
+		return permanent(err)
+	}
+	return err
+}
+
+// RetryingModel retries failed embeddings with exponential backoff and jitter, giving every call
+// a deadline. Calls to models that do not implement ContextModel are only bounded by the model's
+// own timeout. Permanent failures are not retried.
+type RetryingModel struct {
+	model          Model
+	attempts       int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	timeout        time.Duration
+}
+
+// NewRetryingModel wraps a model with up to attempts calls per embedding, waiting about
+// initialBackoff after the first failure and doubling up to maxBackoff
+func NewRetryingModel(model Model, attempts int, initialBackoff, maxBackoff, timeout time.Duration) *RetryingModel {
+	return &RetryingModel{
+		model:          model,
+		attempts:       max(attempts, 1),
+		initialBackoff: initialBackoff,
+		maxBackoff:     max(maxBackoff, initialBackoff),
+		timeout:        timeout,
+	}
+}
+
+// Encode implements the Model interface
+func (m *RetryingModel) Encode(text string) ([]float32, error) {
+	var vector []float32
+	err := m.retry(context.Background(), func(ctx context.Context) error {
+		var err error
+		if model, ok := m.model.(ContextModel); ok {
+			vector, err = model.EncodeContext(ctx, text)
+		} else {
+			vector, err = m.model.Encode(text)
+		}
+		return err
+	})
+	return vector, err
+}
+
+// EncodeBatch retries the batch as a whole if the model encodes batches, and every text on its
+// own otherwise
+func (m *RetryingModel) EncodeBatch(texts []string) ([][]float32, error) {
+	if _, ok := m.model.(BatchModel); !ok {
+		vectors := make([][]float32, len(texts))
+		for i, text := range texts {
+			vector, err := m.Encode(text)
+			if err != nil {
+				return nil, err
+			}
+			vectors[i] = vector
+		}
+		return vectors, nil
+	}
+
+	var vectors [][]float32
+	err := m.retry(context.Background(), func(ctx context.Context) error {
+		var err error
+		if model, ok := m.model.(ContextBatchModel); ok {
+			vectors, err = model.EncodeBatchContext(ctx, texts)
+		} else {
+			vectors, err = EncodeAll(m.model, texts)
+		}
+		return err
+	})
+	return vectors, err
+}
+
+// EncodeQuery encodes a search query as a query if the wrapped model distinguishes queries from
+// documents
+func (m *RetryingModel) EncodeQuery(ctx context.Context, text string) ([]float32, error) {
+	model, ok := m.model.(QueryModel)
+	if !ok {
+		return m.Encode(text)
+	}
+	var vector []float32
+	err := m.retry(ctx, func(ctx context.Context) error {
+		var err error
+		vector, err = model.EncodeQuery(ctx, text)
+		return err
+	})
+	return vector, err
+}
+
+// Ping checks the wrapped model, without retrying
+func (m *RetryingModel) Ping() error {
+	return Ping(m.model)
+}
+
+// retry calls fn until it succeeds, fails permanently or runs out of attempts
+func (m *RetryingModel) retry(parent context.Context, fn func(ctx context.Context) error) error {
+	backoff := m.initialBackoff
+	for attempt := 1; ; attempt++ {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if m.timeout > 0 {
+			ctx, cancel = context.WithTimeout(parent, m.timeout)
+		} else {
+			ctx, cancel = context.WithCancel(parent)
+		}
+		err := fn(ctx)
+		cancel()
+		if err == nil || IsPermanent(err) || parent.Err() != nil {
+			return err
+		}
+		if attempt >= m.attempts {
+			if attempt > 1 {
+				return fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return err
+		}
+
+		// Wait between half and all of the backoff so failing callers do not retry in lockstep
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		time.Sleep(delay)
+		backoff = min(2*backoff, m.maxBackoff)
+	}
+}
@@ -0,0 +1,77 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SentenceTransformersModel implements a Sentence Transformers-based embedding model served by an
+// inference service with an OpenAI-compatible embeddings API, such as text-embeddings-inference
+// or infinity. Texts are encoded in batches of up to batchSize per request.
+type SentenceTransformersModel struct {
+	url       string
+	model     string
+	dimension int
+	batchSize int
+	client    *http.Client
+}
+
+// NewSentenceTransformersModel creates a new Sentence Transformers embedding model
+func NewSentenceTransformersModel(url, model string, dimension, batchSize int) *SentenceTransformersModel {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &SentenceTransformersModel{
+		url:       strings.TrimSuffix(url, "/"),
+		model:     model,
+		dimension: dimension,
+		batchSize: batchSize,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Encode implements the Model interface
+func (m *SentenceTransformersModel) Encode(text string) ([]float32, error) {
+	return m.EncodeContext(context.Background(), text)
+}
+
+// EncodeContext encodes text, giving up when ctx is done
+func (m *SentenceTransformersModel) EncodeContext(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := m.EncodeBatchContext(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EncodeBatch encodes texts with as few requests as the batch size allows
+func (m *SentenceTransformersModel) EncodeBatch(texts []string) ([][]float32, error) {
+	return m.EncodeBatchContext(context.Background(), texts)
+}
+
+// EncodeBatchContext encodes texts with as few requests as the batch size allows, giving up when
+// ctx is done
+func (m *SentenceTransformersModel) EncodeBatchContext(ctx context.Context, texts []string) ([][]float32, error) {
+	return encodeBatches(texts, m.batchSize, func(batch []string) ([][]float32, error) {
+		payload := map[string]interface{}{"model": m.model, "input": batch}
+		return postOpenAIEmbeddings(ctx, m.client, "Sentence Transformers", m.url+"/v1/embeddings", nil, payload, batch, m.dimension)
+	})
+}
+
+// Ping checks that the inference service is ready to serve requests
+func (m *SentenceTransformersModel) Ping() error {
+	resp, err := m.client.Get(m.url + "/health")
+	if err != nil {
+		return fmt.Errorf("failed to reach Sentence Transformers service at %s: %v", m.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Sentence Transformers service at %s is not ready, status %d", m.url, resp.StatusCode)
+	}
+	return nil
+}
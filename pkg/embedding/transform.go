@@ -0,0 +1,114 @@
+package embedding
+
+import (
+	"context"
+	"math"
+)
+
+// transformedModel applies a transformation to every vector of a model
+type transformedModel struct {
+	model     Model
+	transform func(vector []float32) []float32
+}
+
+// Encode implements the Model interface
+func (m transformedModel) Encode(text string) ([]float32, error) {
+	vector, err := m.model.Encode(text)
+	if err != nil {
+		return nil, err
+	}
+	return m.transform(vector), nil
+}
+
+// EncodeBatch encodes texts in one request if the wrapped model supports it
+func (m transformedModel) EncodeBatch(texts []string) ([][]float32, error) {
+	vectors, err := EncodeAll(m.model, texts)
+	if err != nil {
+		return nil, err
+	}
+	for i, vector := range vectors {
+		vectors[i] = m.transform(vector)
+	}
+	return vectors, nil
+}
+
+// EncodeQuery encodes a search query as a query if the wrapped model distinguishes queries from
+// documents
+func (m transformedModel) EncodeQuery(ctx context.Context, text string) ([]float32, error) {
+	var vector []float32
+	var err error
+	if model, ok := m.model.(QueryModel); ok {
+		vector, err = model.EncodeQuery(ctx, text)
+	} else {
+		vector, err = m.model.Encode(text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.transform(vector), nil
+}
+
+// Ping checks the wrapped model
+func (m transformedModel) Ping() error {
+	return Ping(m.model)
+}
+
+// ResizingModel adapts the vectors of a model to another dimension: shorter vectors are padded
+// with zeros and longer ones truncated and renormalized to unit length. Vectors of models with
+// different native dimensions can then be stored in the same collection until it is rebuilt,
+// though similarity across models remains approximate.
+type ResizingModel struct {
+	transformedModel
+}
+
+// NewResizingModel wraps a model with vectors resized to dimension
+func NewResizingModel(model Model, dimension int) *ResizingModel {
+	return &ResizingModel{transformedModel{
+		model:     model,
+		transform: func(vector []float32) []float32 { return resize(vector, dimension) },
+	}}
+}
+
+// resize pads or truncates a vector to dimension
+func resize(vector []float32, dimension int) []float32 {
+	if len(vector) == dimension {
+		return vector
+	}
+	if len(vector) < dimension {
+		// Zeros add nothing to the norm or to dot products
+		padded := make([]float32, dimension)
+		copy(padded, vector)
+		return padded
+	}
+
+	truncated := make([]float32, dimension)
+	copy(truncated, vector)
+	return normalize(truncated)
+}
+
+// NormalizingModel scales the vectors of a model to unit length, so cosine and dot product
+// distances rank alike whether or not the provider normalizes its outputs
+type NormalizingModel struct {
+	transformedModel
+}
+
+// NewNormalizingModel wraps a model with vectors normalized to unit length
+func NewNormalizingModel(model Model) *NormalizingModel {
+	return &NormalizingModel{transformedModel{model: model, transform: normalize}}
+}
+
+// normalize scales a vector to unit L2 norm in place; zero vectors are left as they are
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+	return vector
+}
@@ -0,0 +1,143 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// VertexAIModel implements a Google text embedding model served by Vertex AI, authenticated with
+// application default credentials. Like Cohere's, the models embed stored documents and search
+// queries differently, so stored alerts are encoded with the RETRIEVAL_DOCUMENT task type and
+// alerts searched for similar ones with RETRIEVAL_QUERY.
+type VertexAIModel struct {
+	url       string
+	tokens    oauth2.TokenSource
+	dimension int
+	batchSize int
+	client    *http.Client
+}
+
+// NewVertexAIModel creates a new Vertex AI embedding model of a project and location, e.g.
+// us-central1. The project defaults to that of the service account credentials.
+func NewVertexAIModel(project, location, model, credentialsFile string, dimension, batchSize int) (*VertexAIModel, error) {
+	tokens, credentialsProject, err := googleTokenSource(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	if project == "" {
+		project = credentialsProject
+	}
+	if project == "" {
+		return nil, fmt.Errorf("vertex-ai embeddings require a project")
+	}
+	return &VertexAIModel{
+		url: fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/google/models/%s:predict",
+			location, project, location, model),
+		tokens:    tokens,
+		dimension: dimension,
+		batchSize: max(batchSize, 1),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}, nil
+}
+
+// Encode implements the Model interface
+func (m *VertexAIModel) Encode(text string) ([]float32, error) {
+	return m.EncodeContext(context.Background(), text)
+}
+
+// EncodeContext encodes text as a document, giving up when ctx is done
+func (m *VertexAIModel) EncodeContext(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := m.EncodeBatchContext(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EncodeBatch encodes texts as documents with as few requests as the batch size allows
+func (m *VertexAIModel) EncodeBatch(texts []string) ([][]float32, error) {
+	return m.EncodeBatchContext(context.Background(), texts)
+}
+
+// EncodeBatchContext encodes texts as documents with as few requests as the batch size allows,
+// giving up when ctx is done
+func (m *VertexAIModel) EncodeBatchContext(ctx context.Context, texts []string) ([][]float32, error) {
+	return encodeBatches(texts, m.batchSize, func(batch []string) ([][]float32, error) {
+		return m.predict(ctx, batch, "RETRIEVAL_DOCUMENT")
+	})
+}
+
+// EncodeQuery encodes a search query with the query task type
+func (m *VertexAIModel) EncodeQuery(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := encodeBatches([]string{text}, 1, func(batch []string) ([][]float32, error) {
+		return m.predict(ctx, batch, "RETRIEVAL_QUERY")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// predict encodes one batch of texts with a task type
+func (m *VertexAIModel) predict(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	instances := make([]map[string]string, len(texts))
+	for i, text := range texts {
+		instances[i] = map[string]string{"content": text, "task_type": taskType}
+	}
+	jsonData, err := json.Marshal(map[string]interface{}{"instances": instances})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request payload: %v", err)
+	}
+
+	token, err := m.tokens.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Google access token: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", m.url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token.SetAuthHeader(req)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make Vertex AI API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, statusError("Vertex AI", resp.StatusCode, body)
+	}
+
+	var response struct {
+		Predictions []struct {
+			Embeddings struct {
+				Values []float32 `json:"values"`
+			} `json:"embeddings"`
+		} `json:"predictions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode Vertex AI API response: %v", err)
+	}
+	if len(response.Predictions) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(response.Predictions))
+	}
+	vectors := make([][]float32, len(texts))
+	for i, prediction := range response.Predictions {
+		if err := checkDimension(prediction.Embeddings.Values, m.dimension, texts[i]); err != nil {
+			return nil, err
+		}
+		vectors[i] = prediction.Embeddings.Values
+	}
+	return vectors, nil
+}
@@ -0,0 +1,251 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/alertid"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+	"github.com/rodolfo-mora/huginn/pkg/version"
+)
+
+// Export formats
+const (
+	FormatSARIF = "sarif"
+	FormatCSV   = "csv"
+)
+
+// Categories holds the anomaly categories exported for compliance tooling
+var Categories = []string{"policy", "drift"}
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// Write writes anomalies in the given format
+func Write(w io.Writer, format string, anomalies []types.Anomaly) error {
+	switch format {
+	case FormatSARIF:
+		return WriteSARIF(w, anomalies)
+	case FormatCSV:
+		return WriteCSV(w, anomalies)
+	default:
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// Filter returns the anomalies in one of the exported categories, optionally limited to a cluster
+// (matched by ID or name)
+func Filter(anomalies []types.Anomaly, cluster string) []types.Anomaly {
+	var result []types.Anomaly
+	for _, anomaly := range anomalies {
+		if cluster != "" && anomaly.ClusterID != cluster && anomaly.ClusterName != cluster {
+			continue
+		}
+		for _, category := range Categories {
+			if anomaly.Labels["category"] == category {
+				result = append(result, anomaly)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// sarifLog is the subset of the SARIF 2.1.0 format written by huginn
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool              sarifTool              `json:"tool"`
+	AutomationDetails sarifAutomationDetails `json:"automationDetails"`
+	Results           []sarifResult          `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	Properties       struct {
+		Category string `json:"category"`
+	} `json:"properties"`
+}
+
+type sarifAutomationDetails struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID              string                 `json:"ruleId"`
+	Level               string                 `json:"level"`
+	Message             sarifMessage           `json:"message"`
+	Locations           []sarifLocation        `json:"locations"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints"`
+	Properties          map[string]interface{} `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// WriteSARIF writes anomalies as a SARIF log with one run per cluster. Resources are reported as
+// logical locations ("cluster/namespace/resource") since findings have no source file.
+func WriteSARIF(w io.Writer, anomalies []types.Anomaly) error {
+	info := version.Get()
+	byCluster := groupByCluster(anomalies)
+
+	out := sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs:    []sarifRun{},
+	}
+	for _, cluster := range sortedKeys(byCluster) {
+		run := sarifRun{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:    "huginn",
+				Version: info.Version,
+			}},
+			AutomationDetails: sarifAutomationDetails{ID: "huginn/" + cluster + "/"},
+			Results:           []sarifResult{},
+		}
+
+		rules := make(map[string]bool)
+		for _, anomaly := range byCluster[cluster] {
+			if !rules[anomaly.Type] {
+				rule := sarifRule{ID: anomaly.Type, ShortDescription: sarifMessage{Text: anomaly.Type}}
+				rule.Properties.Category = anomaly.Labels["category"]
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+				rules[anomaly.Type] = true
+			}
+
+			name := anomaly.Resource
+			qualified := cluster + "/" + anomaly.Resource
+			if anomaly.Namespace != "" {
+				qualified = cluster + "/" + anomaly.Namespace + "/" + anomaly.Resource
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:  anomaly.Type,
+				Level:   sarifLevel(anomaly.Severity),
+				Message: sarifMessage{Text: anomaly.Description},
+				Locations: []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{
+					Name:               name,
+					FullyQualifiedName: qualified,
+					Kind:               "resource",
+				}}}},
+				PartialFingerprints: map[string]string{"huginn/v1": alertid.Fingerprint(anomaly)},
+				Properties: map[string]interface{}{
+					"severity":  anomaly.Severity,
+					"timestamp": anomaly.Timestamp.Format(time.RFC3339),
+					"labels":    anomaly.Labels,
+				},
+			})
+		}
+		out.Runs = append(out.Runs, run)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteCSV writes anomalies as CSV with a header row, ordered by cluster
+func WriteCSV(w io.Writer, anomalies []types.Anomaly) error {
+	cw := csv.NewWriter(w)
+	header := []string{"cluster", "category", "type", "severity", "namespace", "resourceType", "resource", "description", "timestamp", "labels"}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+
+	byCluster := groupByCluster(anomalies)
+	for _, cluster := range sortedKeys(byCluster) {
+		for _, anomaly := range byCluster[cluster] {
+			row := []string{
+				cluster,
+				anomaly.Labels["category"],
+				anomaly.Type,
+				string(anomaly.Severity),
+				anomaly.Namespace,
+				anomaly.ResourceType,
+				anomaly.Resource,
+				anomaly.Description,
+				anomaly.Timestamp.Format(time.RFC3339),
+				formatLabels(anomaly.Labels),
+			}
+			if err := cw.Write(row); err != nil {
+				return fmt.Errorf("failed to write CSV: %v", err)
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// sarifLevel maps an anomaly severity to a SARIF result level
+func sarifLevel(severity types.Severity) string {
+	switch severity.Normalize() {
+	case types.SeverityCritical, types.SeverityHigh:
+		return "error"
+	case types.SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// groupByCluster groups anomalies by cluster name, falling back to the cluster ID
+func groupByCluster(anomalies []types.Anomaly) map[string][]types.Anomaly {
+	groups := make(map[string][]types.Anomaly)
+	for _, anomaly := range anomalies {
+		cluster := anomaly.ClusterName
+		if cluster == "" {
+			cluster = anomaly.ClusterID
+		}
+		groups[cluster] = append(groups[cluster], anomaly)
+	}
+	return groups
+}
+
+func sortedKeys(groups map[string][]types.Anomaly) []string {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels formats labels as sorted "key=value" pairs separated by semicolons
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
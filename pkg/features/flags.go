@@ -0,0 +1,160 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+)
+
+// Names of the features that can be controlled by flags
+const (
+	ImagePolicy    = "imagePolicy"
+	Drift          = "drift"
+	ConfigChurn    = "configChurn"
+	Multivariate   = "multivariate"
+	SecurityPolicy = "securityPolicy"
+)
+
+// Flags resolves feature flags for clusters. Flags from the configuration can be
+// overridden by flags fetched periodically from an external service.
+type Flags struct {
+	mu      sync.RWMutex
+	flags   map[string]config.FeatureFlag
+	service config.FeatureFlagServiceConfig
+	client  *http.Client
+}
+
+// serviceFlag is the representation of a flag returned by the external flag service
+type serviceFlag struct {
+	Enabled    bool     `json:"enabled"`
+	Clusters   []string `json:"clusters"`
+	Percentage *int     `json:"percentage"`
+}
+
+// NewFlags creates feature flags from configuration
+func NewFlags(cfg config.FeatureFlagsConfig) *Flags {
+	flags := make(map[string]config.FeatureFlag, len(cfg.Flags))
+	for name, flag := range cfg.Flags {
+		flags[name] = flag
+	}
+	return &Flags{
+		flags:   flags,
+		service: cfg.Service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Enabled reports whether a feature is enabled for a cluster. When no flag is defined
+// for the feature, defaultValue is returned so features keep their configured behavior.
+func (f *Flags) Enabled(name, clusterID string, defaultValue bool) bool {
+	if f == nil {
+		return defaultValue
+	}
+
+	f.mu.RLock()
+	flag, exists := f.flags[name]
+	f.mu.RUnlock()
+	if !exists {
+		return defaultValue
+	}
+	if !flag.Enabled {
+		return false
+	}
+
+	if len(flag.Clusters) > 0 {
+		for _, id := range flag.Clusters {
+			if id == clusterID {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Without a percentage the feature is rolled out to all clusters; 0 rolls it out to none
+	if flag.Percentage == nil || *flag.Percentage >= 100 {
+		return true
+	}
+	return rolloutBucket(name, clusterID) < *flag.Percentage
+}
+
+// rolloutBucket deterministically places a cluster in a bucket between 0 and 99 for a feature,
+// so percentage rollouts are stable across restarts and differ between features
+func rolloutBucket(name, clusterID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + clusterID))
+	return int(h.Sum32() % 100)
+}
+
+// Start periodically refreshes flags from the external flag service until the context is
+// cancelled. It does nothing when no service is configured.
+func (f *Flags) Start(ctx context.Context) {
+	if f.service.URL == "" {
+		return
+	}
+
+	if err := f.Refresh(ctx); err != nil {
+		log.Printf("Warning: failed to fetch feature flags: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(f.service.RefreshInterval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := f.Refresh(ctx); err != nil {
+					log.Printf("Warning: failed to refresh feature flags: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Refresh fetches flags from the external flag service. Flags returned by the service
+// replace flags with the same name; other flags are kept.
+func (f *Flags) Refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", f.service.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	for key, value := range f.service.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch feature flags: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("feature flag service returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Flags map[string]serviceFlag `json:"flags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode feature flags: %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for name, flag := range result.Flags {
+		f.flags[name] = config.FeatureFlag{
+			Enabled:    flag.Enabled,
+			Clusters:   flag.Clusters,
+			Percentage: flag.Percentage,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// Stage is a point of the anomaly pipeline where hooks run
+type Stage string
+
+// Pipeline stages
+const (
+	PostDetect Stage = "postDetect" // After detection, before anything is recorded
+	PreStore   Stage = "preStore"   // Before an anomaly is embedded and stored
+	PreNotify  Stage = "preNotify"  // Before a notification is sent
+)
+
+// Hook mutates, enriches or vetoes anomalies. Process may modify the anomaly in place and
+// returns false to veto it, which drops it from the rest of the stage.
+type Hook interface {
+	Process(ctx context.Context, stage Stage, anomaly *types.Anomaly) (bool, error)
+}
+
+// HookFunc adapts a function to the Hook interface
+type HookFunc func(ctx context.Context, stage Stage, anomaly *types.Anomaly) (bool, error)
+
+// Process calls f
+func (f HookFunc) Process(ctx context.Context, stage Stage, anomaly *types.Anomaly) (bool, error) {
+	return f(ctx, stage, anomaly)
+}
+
+// namedHook is a hook registered for a stage
+type namedHook struct {
+	name       string
+	hook       Hook
+	failClosed bool // Veto anomalies the hook fails on instead of passing them unchanged
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[Stage][]namedHook)
+)
+
+// Register registers a compile-time hook for a stage, typically from an init function of a
+// package imported for its side effects. Registered hooks run before configured webhooks.
+func Register(stage Stage, name string, hook Hook) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[stage] = append(registry[stage], namedHook{name: name, hook: hook})
+}
+
+// Pipeline runs the hooks of each stage in order
+type Pipeline struct {
+	stages map[Stage][]namedHook
+}
+
+// New creates a pipeline from the registered hooks and the configured webhooks
+func New(cfg config.HooksConfig) (*Pipeline, error) {
+	p := &Pipeline{stages: make(map[Stage][]namedHook)}
+
+	registryMu.Lock()
+	for stage, hooks := range registry {
+		p.stages[stage] = append(p.stages[stage], hooks...)
+	}
+	registryMu.Unlock()
+
+	for _, webhook := range cfg.Webhooks {
+		if webhook.URL == "" {
+			return nil, fmt.Errorf("hook webhook %s has no URL", webhook.Name)
+		}
+		hook := &Webhook{
+			URL:     webhook.URL,
+			Headers: webhook.Headers,
+			Client:  &http.Client{Timeout: time.Duration(webhook.TimeoutSeconds) * time.Second},
+		}
+		for _, stage := range webhook.Stages {
+			switch Stage(stage) {
+			case PostDetect, PreStore, PreNotify:
+			default:
+				return nil, fmt.Errorf("hook webhook %s has unknown stage: %s", webhook.Name, stage)
+			}
+			p.stages[Stage(stage)] = append(p.stages[Stage(stage)], namedHook{name: webhook.Name, hook: hook, failClosed: webhook.FailClosed})
+		}
+	}
+	return p, nil
+}
+
+// Run runs the hooks of a stage on each anomaly and returns the anomalies that were not vetoed.
+// A nil pipeline returns the anomalies unchanged.
+func (p *Pipeline) Run(ctx context.Context, stage Stage, anomalies []types.Anomaly) []types.Anomaly {
+	if p == nil || len(p.stages[stage]) == 0 {
+		return anomalies
+	}
+
+	kept := make([]types.Anomaly, 0, len(anomalies))
+	for _, anomaly := range anomalies {
+		if p.RunOne(ctx, stage, &anomaly) {
+			kept = append(kept, anomaly)
+		}
+	}
+	return kept
+}
+
+// RunOne runs the hooks of a stage on a single anomaly and reports whether it was kept
+func (p *Pipeline) RunOne(ctx context.Context, stage Stage, anomaly *types.Anomaly) bool {
+	if p == nil {
+		return true
+	}
+	for _, h := range p.stages[stage] {
+		// Hooks work on a copy so a failing hook cannot leave a half-modified anomaly
+		candidate := *anomaly
+		keep, err := h.hook.Process(ctx, stage, &candidate)
+		if err != nil {
+			log.Printf("Warning: %s hook %s failed for anomaly %s/%s: %v", stage, h.name, anomaly.Type, anomaly.Resource, err)
+			if h.failClosed {
+				return false
+			}
+			continue
+		}
+		if !keep {
+			return false
+		}
+		*anomaly = candidate
+	}
+	return true
+}
+
+// Webhook is a hook implemented by an external HTTP service. It receives
+// {"stage": ..., "anomaly": ...} and responds with 204 to pass the anomaly unchanged, or 200 and
+// {"veto": bool, "anomaly": ...} to veto it or replace it with the returned anomaly.
+type Webhook struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+type webhookRequest struct {
+	Stage   Stage         `json:"stage"`
+	Anomaly types.Anomaly `json:"anomaly"`
+}
+
+type webhookResponse struct {
+	Veto    bool           `json:"veto"`
+	Anomaly *types.Anomaly `json:"anomaly"`
+}
+
+// Process sends the anomaly to the webhook
+func (w *Webhook) Process(ctx context.Context, stage Stage, anomaly *types.Anomaly) (bool, error) {
+	body, err := json.Marshal(webhookRequest{Stage: stage, Anomaly: *anomaly})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal hook request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create hook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to call hook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusOK:
+	default:
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("hook returned status %d: %s", resp.StatusCode, string(data))
+	}
+
+	var result webhookResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode hook response: %v", err)
+	}
+	if result.Veto {
+		return false, nil
+	}
+	if result.Anomaly != nil {
+		*anomaly = *result.Anomaly
+	}
+	return true, nil
+}
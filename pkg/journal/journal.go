@@ -0,0 +1,341 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// Record is the persisted intermediate result between detection and notification
+type Record struct {
+	ID         string        `json:"id"`
+	Anomaly    types.Anomaly `json:"anomaly"`
+	DetectedAt time.Time     `json:"detectedAt"`
+	Notified   bool          `json:"notified"`
+	NotifiedAt time.Time     `json:"notifiedAt,omitempty"`
+}
+
+// Compaction triggers: the journal is rewritten once it doubled in size since the last
+// compaction and holds at least compactMinSize bytes, and at least every compactInterval so that
+// retention is enforced while the agent runs
+const (
+	compactMinSize  = 16 * 1024 * 1024
+	compactInterval = time.Hour
+)
+
+// Journal is an append-only JSON lines file of detected anomalies. Updates to a record are
+// appended as new lines; the last line for a record ID wins when reading. The offset of the
+// latest line of every record is indexed, so lookups read only the lines they return.
+type Journal struct {
+	path      string
+	retention time.Duration
+	mu        sync.Mutex
+
+	index       map[string]entry  // Latest line of each record ID
+	anomalies   map[string]string // Record ID of the latest record of each anomaly ID
+	size        int64             // Bytes in the journal file
+	compactSize int64             // Bytes in the journal file after the last compaction
+	compactedAt time.Time
+	shared      bool // Opened by OpenShared: never compacted, reads scan the file
+}
+
+// entry locates the latest line of a record in the journal file
+type entry struct {
+	offset     int64
+	length     int
+	detectedAt time.Time
+}
+
+// NewRecord creates a journal record for a freshly detected anomaly
+func NewRecord(anomaly types.Anomaly) Record {
+	return Record{
+		ID:         uuid.New().String(),
+		Anomaly:    anomaly,
+		DetectedAt: time.Now(),
+	}
+}
+
+// Open opens the journal at path, creating it if needed, and drops records older than retention
+func Open(path string, retention time.Duration) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %v", err)
+	}
+
+	j := &Journal{
+		path:      path,
+		retention: retention,
+	}
+	if err := j.Compact(); err != nil {
+		return nil, fmt.Errorf("failed to compact journal: %v", err)
+	}
+	return j, nil
+}
+
+// OpenShared opens the journal at path without compacting it, for commands that run next to an
+// agent writing to it: compaction replaces the file, which would lose the agent's appends. Reads
+// scan the whole file, as the agent's appends are not indexed.
+func OpenShared(path string) (*Journal, error) {
+	if _, err := os.Stat(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open journal: %v", err)
+	}
+	return &Journal{path: path, shared: true}, nil
+}
+
+// Append writes records to the journal, compacting it first if it grew past the compaction
+// triggers
+func (j *Journal) Append(records ...Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.shared && ((j.size >= compactMinSize && j.size >= 2*j.compactSize) || time.Since(j.compactedAt) >= compactInterval) {
+		if err := j.compact(); err != nil {
+			return fmt.Errorf("failed to compact journal: %v", err)
+		}
+	}
+
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %v", err)
+	}
+	defer f.Close()
+	// Commands sharing the journal may have appended to it since
+	if j.size, err = f.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("failed to open journal: %v", err)
+	}
+
+	var data []byte
+	entries := make([]entry, len(records))
+	for i, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to write journal record: %v", err)
+		}
+		entries[i] = entry{offset: j.size + int64(len(data)), length: len(line), detectedAt: record.DetectedAt}
+		data = append(append(data, line...), '\n')
+	}
+
+	n, err := f.Write(data)
+	j.size += int64(n)
+	if err != nil {
+		// The index stays valid; a partial line is skipped when the journal is next compacted
+		return fmt.Errorf("failed to write journal record: %v", err)
+	}
+	if !j.shared {
+		for i, record := range records {
+			j.setEntry(record, entries[i])
+		}
+	}
+	return nil
+}
+
+// Since returns the latest version of every record detected at or after t, oldest first
+func (j *Journal) Since(t time.Time) ([]Record, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.shared {
+		records, err := j.read()
+		if err != nil {
+			return nil, err
+		}
+		result := make([]Record, 0, len(records))
+		for _, record := range records {
+			if !record.DetectedAt.Before(t) {
+				result = append(result, record)
+			}
+		}
+		return result, nil
+	}
+
+	var entries []entry
+	for _, e := range j.index {
+		if !e.detectedAt.Before(t) {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].offset < entries[b].offset })
+	records, err := j.readEntries(entries)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(a, b int) bool { return records[a].DetectedAt.Before(records[b].DetectedAt) })
+	return records, nil
+}
+
+// Get returns the latest version of the record with the given record ID or anomaly ID
+func (j *Journal) Get(id string) (Record, bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.shared {
+		records, err := j.read()
+		if err != nil {
+			return Record{}, false, err
+		}
+		// The latest record of an anomaly ID wins, as in the index
+		for i := len(records) - 1; i >= 0; i-- {
+			if records[i].ID == id || (records[i].Anomaly.ID != "" && records[i].Anomaly.ID == id) {
+				return records[i], true, nil
+			}
+		}
+		return Record{}, false, nil
+	}
+
+	e, ok := j.index[id]
+	if !ok {
+		e, ok = j.index[j.anomalies[id]]
+	}
+	if !ok {
+		return Record{}, false, nil
+	}
+	records, err := j.readEntries([]entry{e})
+	if err != nil {
+		return Record{}, false, err
+	}
+	return records[0], true, nil
+}
+
+// Compact rewrites the journal keeping only the latest version of records within retention
+func (j *Journal) Compact() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.compact()
+}
+
+// compact rewrites the journal and rebuilds its index. Callers must hold j.mu.
+func (j *Journal) compact() error {
+	if j.shared {
+		return fmt.Errorf("a shared journal cannot be compacted")
+	}
+	records, err := j.read()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-j.retention)
+	tmp := j.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create journal: %v", err)
+	}
+
+	index := make(map[string]entry, len(records))
+	var size int64
+	w := bufio.NewWriter(f)
+	for _, record := range records {
+		if j.retention > 0 && record.DetectedAt.Before(cutoff) {
+			continue
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write journal record: %v", err)
+		}
+		index[record.ID] = entry{offset: size, length: len(line), detectedAt: record.DetectedAt}
+		w.Write(line)
+		w.WriteByte('\n')
+		size += int64(len(line)) + 1
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+
+	j.index = index
+	j.anomalies = make(map[string]string)
+	for _, record := range records {
+		if _, kept := index[record.ID]; kept && record.Anomaly.ID != "" {
+			j.anomalies[record.Anomaly.ID] = record.ID
+		}
+	}
+	j.size, j.compactSize, j.compactedAt = size, size, time.Now()
+	return nil
+}
+
+// setEntry indexes the latest line of a record. Callers must hold j.mu.
+func (j *Journal) setEntry(record Record, e entry) {
+	j.index[record.ID] = e
+	if record.Anomaly.ID != "" {
+		j.anomalies[record.Anomaly.ID] = record.ID
+	}
+}
+
+// readEntries reads the records at the indexed lines, which must be sorted by offset. Callers
+// must hold j.mu.
+func (j *Journal) readEntries(entries []entry) ([]Record, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(j.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %v", err)
+	}
+	defer f.Close()
+
+	records := make([]Record, 0, len(entries))
+	var line []byte
+	for _, e := range entries {
+		if cap(line) < e.length {
+			line = make([]byte, e.length)
+		}
+		line = line[:e.length]
+		if _, err := f.ReadAt(line, e.offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read journal: %v", err)
+		}
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("failed to decode journal record at offset %d: %v", e.offset, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// read loads all records, keeping the last version of each ID. Callers must hold j.mu.
+func (j *Journal) read() ([]Record, error) {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %v", err)
+	}
+	defer f.Close()
+
+	latest := make(map[string]Record)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// Skip lines left partially written by a crash
+			continue
+		}
+		latest[record.ID] = record
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %v", err)
+	}
+
+	records := make([]Record, 0, len(latest))
+	for _, record := range latest {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(a, b int) bool { return records[a].DetectedAt.Before(records[b].DetectedAt) })
+	return records, nil
+}
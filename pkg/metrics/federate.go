@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// fleetAggregates are the per-resource metrics summarized per cluster for the fleet view
+var fleetAggregates = []struct {
+	source, name, help string
+	aggregate          string   // sum, avg or max
+	keep               []string // Labels kept besides cluster_id and cluster
+}{
+	{"huginn_anomaly_detected_total", "huginn_cluster_anomalies_detected_total", "Total number of anomalies detected in a cluster by severity", "sum", []string{"severity"}},
+	{"huginn_anomalies_open", "huginn_cluster_anomalies_open", "Anomalies currently open in a cluster by severity", "sum", []string{"severity"}},
+	{"huginn_node_cpu_usage_percent", "huginn_cluster_node_cpu_usage_percent_avg", "Mean CPU usage percentage of the nodes of a cluster", "avg", nil},
+	{"huginn_node_cpu_usage_percent", "huginn_cluster_node_cpu_usage_percent_max", "Highest CPU usage percentage of the nodes of a cluster", "max", nil},
+	{"huginn_node_memory_usage_percent", "huginn_cluster_node_memory_usage_percent_avg", "Mean memory usage percentage of the nodes of a cluster", "avg", nil},
+	{"huginn_node_memory_usage_percent", "huginn_cluster_node_memory_usage_percent_max", "Highest memory usage percentage of the nodes of a cluster", "max", nil},
+	{"huginn_pod_restart_count", "huginn_cluster_pod_restarts", "Restarts of the pods of a cluster", "sum", nil},
+}
+
+// fleetPassThrough are the metrics that are already per cluster and served unchanged
+var fleetPassThrough = []string{
+	"huginn_build_info",
+	"huginn_cluster_health_score",
+	"huginn_cluster_healthy",
+	"huginn_cluster_last_observation_age_seconds",
+	"huginn_cluster_nodes",
+	"huginn_clusters",
+	"huginn_fleet_nodes",
+}
+
+// fleetCollector summarizes the metrics of a gatherer per cluster, for central Prometheus servers
+// that only want fleet-level cardinality
+type fleetCollector struct {
+	gatherer prometheus.Gatherer
+}
+
+// Describe implements prometheus.Collector. The collector is unchecked, since the series it
+// collects depend on the gathered metrics.
+func (c fleetCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (c fleetCollector) Collect(ch chan<- prometheus.Metric) {
+	gathered, err := c.gatherer.Gather()
+	if err != nil {
+		// The families gathered without errors are still summarized
+		log.Printf("Warning: failed to gather metrics for the fleet view: %v", err)
+	}
+	families := make(map[string]*dto.MetricFamily, len(gathered))
+	for _, family := range gathered {
+		families[family.GetName()] = family
+	}
+
+	for _, name := range fleetPassThrough {
+		family, ok := families[name]
+		if !ok || len(family.GetMetric()) == 0 {
+			continue
+		}
+		var labels []string
+		for _, label := range family.GetMetric()[0].GetLabel() {
+			labels = append(labels, label.GetName())
+		}
+		desc := prometheus.NewDesc(name, family.GetHelp(), labels, nil)
+		for _, metric := range family.GetMetric() {
+			values := make([]string, 0, len(labels))
+			for _, label := range metric.GetLabel() {
+				values = append(values, label.GetValue())
+			}
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, metricValue(metric), values...)
+		}
+	}
+
+	for _, aggregate := range fleetAggregates {
+		family, ok := families[aggregate.source]
+		if !ok {
+			continue
+		}
+		labels := append([]string{"cluster_id", "cluster"}, aggregate.keep...)
+		type group struct {
+			values   []string
+			sum, max float64
+			count    int
+		}
+		groups := make(map[string]*group)
+		var order []string
+		for _, metric := range family.GetMetric() {
+			values := labelValues(metric, labels)
+			key := ""
+			for _, value := range values {
+				key += value + "\xff"
+			}
+			g, ok := groups[key]
+			if !ok {
+				g = &group{values: values, max: metricValue(metric)}
+				groups[key] = g
+				order = append(order, key)
+			}
+			value := metricValue(metric)
+			g.sum += value
+			g.max = max(g.max, value)
+			g.count++
+		}
+
+		valueType := prometheus.GaugeValue
+		if family.GetType() == dto.MetricType_COUNTER && aggregate.aggregate == "sum" {
+			valueType = prometheus.CounterValue
+		}
+		desc := prometheus.NewDesc(aggregate.name, aggregate.help, labels, nil)
+		for _, key := range order {
+			g := groups[key]
+			value := g.sum
+			switch aggregate.aggregate {
+			case "avg":
+				value = g.sum / float64(g.count)
+			case "max":
+				value = g.max
+			}
+			ch <- prometheus.MustNewConstMetric(desc, valueType, value, g.values...)
+		}
+	}
+}
+
+// labelValues returns the values of the named labels of a metric, empty for missing labels
+func labelValues(metric *dto.Metric, names []string) []string {
+	values := make([]string, len(names))
+	for i, name := range names {
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == name {
+				values[i] = label.GetValue()
+				break
+			}
+		}
+	}
+	return values
+}
+
+// metricValue returns the value of a gauge, counter or untyped metric
+func metricValue(metric *dto.Metric) float64 {
+	switch {
+	case metric.GetGauge() != nil:
+		return metric.GetGauge().GetValue()
+	case metric.GetCounter() != nil:
+		return metric.GetCounter().GetValue()
+	default:
+		return metric.GetUntyped().GetValue()
+	}
+}
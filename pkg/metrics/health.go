@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout bounds each health check, e.g. a ping of an unreachable storage backend
+const healthCheckTimeout = 5 * time.Second
+
+// HealthCheck reports an error when the component it checks is unhealthy
+type HealthCheck func(ctx context.Context) error
+
+// HealthStatus is the response of the health and readiness endpoints
+type HealthStatus struct {
+	Status string            `json:"status"` // "ok" or "unavailable"
+	Checks map[string]string `json:"checks"` // "ok" or the error of each check
+}
+
+// healthChecks is a named set of checks run on every probe
+type healthChecks struct {
+	mu     sync.Mutex
+	checks map[string]HealthCheck
+}
+
+// add registers a check, replacing any check of the same name
+func (h *healthChecks) add(name string, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.checks == nil {
+		h.checks = make(map[string]HealthCheck)
+	}
+	h.checks[name] = check
+}
+
+// run runs all checks concurrently
+func (h *healthChecks) run(ctx context.Context) HealthStatus {
+	h.mu.Lock()
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]HealthCheck, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	results := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			results[i] = check(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	status := HealthStatus{Status: "ok", Checks: make(map[string]string, len(names))}
+	for i, name := range names {
+		if results[i] != nil {
+			status.Status = "unavailable"
+			status.Checks[name] = results[i].Error()
+			continue
+		}
+		status.Checks[name] = "ok"
+	}
+	return status
+}
+
+// handler serves the status of the checks, with 503 if any check fails
+func (h *healthChecks) handler(w http.ResponseWriter, r *http.Request) {
+	status := h.run(r.Context())
+	code := http.StatusOK
+	if status.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	WriteJSON(w, code, status)
+}
+
+// AddLivenessCheck registers a check served at /healthz. A failing liveness check means the
+// process should be restarted.
+func (s *MetricsServer) AddLivenessCheck(name string, check HealthCheck) {
+	s.liveness.add(name, check)
+}
+
+// AddReadinessCheck registers a check served at /readyz. A failing readiness check means the
+// agent should not receive traffic, e.g. while a dependency is unreachable.
+func (s *MetricsServer) AddReadinessCheck(name string, check HealthCheck) {
+	s.readiness.add(name, check)
+}
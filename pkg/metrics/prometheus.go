@@ -2,18 +2,34 @@ package metrics
 
 import (
 	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rodolfo-mora/huginn/pkg/anomaly"
 	"github.com/rodolfo-mora/huginn/pkg/config"
 	"github.com/rodolfo-mora/huginn/pkg/types"
+	"github.com/rodolfo-mora/huginn/pkg/version"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 // PrometheusExporter exposes anomaly detection metrics to Prometheus
 type PrometheusExporter struct {
 	// Configuration
-	config *config.Config
+	config   *config.Config
+	families map[string]bool // Enabled metric families, see MetricFamilies
+
+	// Registry the metrics are registered with, served by the metrics server
+	registry *prometheus.Registry
+	factory  promauto.Factory
+	// Registry summarizing the metrics per cluster, served at /metrics/federate
+	fleet *prometheus.Registry
 
 	// Current metrics - Raw values (only if nodes enabled)
 	nodeCPURaw    *prometheus.GaugeVec
@@ -46,55 +62,182 @@ type PrometheusExporter struct {
 	// Anomaly detection (always enabled)
 	anomalyDetected *prometheus.CounterVec
 	anomalySeverity *prometheus.GaugeVec
+	anomaliesOpen   *prometheus.GaugeVec
 
 	// Historical data points (always enabled)
 	metricHistory *prometheus.GaugeVec
 
+	// Build information (always enabled)
+	buildInfo *prometheus.GaugeVec
+
+	// Detector thresholds and alphas tuned by learning (always enabled)
+	tunedThreshold *prometheus.GaugeVec
+	tunedAlpha     *prometheus.GaugeVec
+
+	// Composite cluster health score (always enabled)
+	healthScore *prometheus.GaugeVec
+
+	// Durations of the pipeline stages (always enabled)
+	detectionDuration    *prometheus.HistogramVec
+	embeddingDuration    *prometheus.HistogramVec
+	storageWriteDuration *prometheus.HistogramVec
+
+	// Storage backend health as seen by its circuit breaker (always enabled)
+	storageHealthy prometheus.Gauge
+
+	// Persistent volumes and claims (only if the storage family is enabled)
+	pvcRequestedBytes *prometheus.GaugeVec
+	pvCapacityBytes   *prometheus.GaugeVec
+
+	// Workload replicas by kind (only if the workloads family is enabled)
+	workloadReplicasDesired   *prometheus.GaugeVec
+	workloadReplicasAvailable *prometheus.GaugeVec
+
+	// Cluster events by reason, severity and namespace (only if the events family is enabled)
+	eventsTotal *prometheus.CounterVec
+	eventCounts map[string]int32 // Last count of each event, key: "clusterID/eventUID"
+	eventsMu    sync.Mutex
+
 	// Detector instance
 	detector *anomaly.Detector
 }
 
 // NewPrometheusExporter creates a new Prometheus exporter
 func NewPrometheusExporter(detector *anomaly.Detector, cfg *config.Config) *PrometheusExporter {
+	registry := prometheus.NewRegistry()
+	goCollector := collectors.NewGoCollector()
+	if cfg.MetricsServer.Debug.RuntimeMetrics {
+		goCollector = collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsAll))
+	}
+	registry.MustRegister(goCollector, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 	exporter := &PrometheusExporter{
 		detector: detector,
 		config:   cfg,
+		families: enabledFamilies(cfg),
+		registry: registry,
+		factory:  promauto.With(registry),
+		fleet:    prometheus.NewRegistry(),
 	}
+	exporter.fleet.MustRegister(fleetCollector{gatherer: registry})
 
 	// Always create anomaly detection metrics
-	exporter.anomalyDetected = promauto.NewCounterVec(
+	exporter.anomalyDetected = exporter.factory.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "huginn_anomaly_detected_total",
 			Help: "Total number of anomalies detected",
 		},
-		[]string{"type", "resource", "namespace", "severity"},
+		[]string{"cluster_id", "cluster", "type", "resource", "namespace", "severity"},
 	)
 
-	exporter.anomalySeverity = promauto.NewGaugeVec(
+	exporter.anomalySeverity = exporter.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_anomaly_severity_score",
-			Help: "Severity score of detected anomalies (1=low, 2=medium, 3=high)",
+			Help: "Severity score of detected anomalies (1=low, 2=medium, 3=high, 4=critical)",
 		},
-		[]string{"type", "resource", "namespace"},
+		[]string{"cluster_id", "cluster", "type", "resource", "namespace"},
 	)
 
-	exporter.metricHistory = promauto.NewGaugeVec(
+	exporter.anomaliesOpen = exporter.factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "huginn_anomalies_open",
+			Help: "Anomalies currently open (reported within the last 15 minutes) by type and severity",
+		},
+		[]string{"cluster_id", "cluster", "type", "severity"},
+	)
+
+	exporter.metricHistory = exporter.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_metric_history",
 			Help: "Historical metric values for analysis",
 		},
-		[]string{"resource_type", "resource_id", "metric_type"},
+		[]string{"cluster_id", "cluster", "resource_type", "resource_id", "metric_type"},
+	)
+
+	exporter.buildInfo = exporter.factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "huginn_build_info",
+			Help: "Build information of the running huginn binary (always 1)",
+		},
+		[]string{"version", "commit", "go_version"},
+	)
+	info := version.Get()
+	exporter.buildInfo.WithLabelValues(info.Version, info.Commit, info.GoVersion).Set(1)
+
+	exporter.tunedThreshold = exporter.factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "huginn_detector_threshold",
+			Help: "Threshold of a detector metric as tuned by learning",
+		},
+		[]string{"cluster_id", "cluster", "metric"},
+	)
+
+	exporter.tunedAlpha = exporter.factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "huginn_detector_alpha",
+			Help: "EWMA smoothing factor of a detector metric as tuned by learning",
+		},
+		[]string{"cluster_id", "cluster", "metric"},
+	)
+
+	exporter.healthScore = exporter.factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "huginn_cluster_health_score",
+			Help: "Composite health score of a cluster from 0 (down) to 100 (healthy)",
+		},
+		[]string{"cluster_id", "cluster"},
+	)
+
+	exporter.detectionDuration = exporter.factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "huginn_detection_duration_seconds",
+			Help:    "Duration of an anomaly detection cycle of a cluster",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"cluster_id", "cluster"},
+	)
+
+	exporter.embeddingDuration = exporter.factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "huginn_embedding_duration_seconds",
+			Help:    "Duration of embedding an anomaly",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"cluster_id", "cluster"},
+	)
+
+	exporter.storageWriteDuration = exporter.factory.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "huginn_storage_write_duration_seconds",
+			Help:    "Duration of storing an anomaly in the vector database",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"cluster_id", "cluster"},
 	)
 
-	// Create node metrics only if nodes are enabled
-	if exporter.isResourceEnabled("nodes") {
+	exporter.storageHealthy = exporter.factory.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "huginn_storage_healthy",
+			Help: "Whether the storage backend passes its health checks (1) or its circuit is open (0)",
+		},
+	)
+	exporter.storageHealthy.Set(1)
+
+	// Create the optional metric families only if they are enabled
+	if exporter.enabled(FamilyNodes) {
 		exporter.createNodeMetrics()
 	}
-
-	// Create pod metrics only if pods are enabled
-	if exporter.isResourceEnabled("pods") {
+	if exporter.enabled(FamilyPods) {
 		exporter.createPodMetrics()
 	}
+	if exporter.enabled(FamilyStorage) {
+		exporter.createStorageMetrics()
+	}
+	if exporter.enabled(FamilyWorkloads) {
+		exporter.createWorkloadMetrics()
+	}
+	if exporter.enabled(FamilyEvents) {
+		exporter.createEventMetrics()
+	}
 
 	return exporter
 }
@@ -102,164 +245,253 @@ func NewPrometheusExporter(detector *anomaly.Detector, cfg *config.Config) *Prom
 // createNodeMetrics creates all node-related metrics
 func (e *PrometheusExporter) createNodeMetrics() {
 	// Current metrics - Raw values
-	e.nodeCPURaw = promauto.NewGaugeVec(
+	e.nodeCPURaw = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_cpu_raw",
 			Help: "Raw CPU usage for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 
-	e.nodeMemoryRaw = promauto.NewGaugeVec(
+	e.nodeMemoryRaw = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_memory_raw",
 			Help: "Raw memory usage for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 
 	// Current metrics - Percentages
-	e.nodeCPUUsage = promauto.NewGaugeVec(
+	e.nodeCPUUsage = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_cpu_usage_percent",
 			Help: "Current CPU usage percentage for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 
-	e.nodeMemoryUsage = promauto.NewGaugeVec(
+	e.nodeMemoryUsage = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_memory_usage_percent",
 			Help: "Current memory usage percentage for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 
 	// Node capacity metrics
-	e.nodeCPUCapacity = promauto.NewGaugeVec(
+	e.nodeCPUCapacity = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_cpu_capacity",
 			Help: "CPU capacity for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 
-	e.nodeMemoryCapacity = promauto.NewGaugeVec(
+	e.nodeMemoryCapacity = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_memory_capacity",
 			Help: "Memory capacity for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 
 	// Statistical measures
-	e.nodeCPUMean = promauto.NewGaugeVec(
+	e.nodeCPUMean = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_cpu_mean_percent",
 			Help: "Mean CPU usage percentage for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 
-	e.nodeCPUStdDev = promauto.NewGaugeVec(
+	e.nodeCPUStdDev = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_cpu_stddev_percent",
 			Help: "Standard deviation of CPU usage for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 
-	e.nodeCPUEWMA = promauto.NewGaugeVec(
+	e.nodeCPUEWMA = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_cpu_ewma_percent",
 			Help: "Exponentially weighted moving average of CPU usage for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 
-	e.nodeMemoryMean = promauto.NewGaugeVec(
+	e.nodeMemoryMean = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_memory_mean_percent",
 			Help: "Mean memory usage percentage for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 
-	e.nodeMemoryStdDev = promauto.NewGaugeVec(
+	e.nodeMemoryStdDev = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_memory_stddev_percent",
 			Help: "Standard deviation of memory usage for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 
-	e.nodeMemoryEWMA = promauto.NewGaugeVec(
+	e.nodeMemoryEWMA = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_node_memory_ewma_percent",
 			Help: "Exponentially weighted moving average of memory usage for each node",
 		},
-		[]string{"node"},
+		[]string{"cluster_id", "cluster", "node"},
 	)
 }
 
 // createPodMetrics creates all pod-related metrics
 func (e *PrometheusExporter) createPodMetrics() {
-	e.podRestartCount = promauto.NewGaugeVec(
+	e.podRestartCount = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_pod_restart_count",
 			Help: "Current restart count for each pod",
 		},
-		[]string{"pod", "namespace"},
+		[]string{"cluster_id", "cluster", "pod", "namespace"},
 	)
 
-	e.podRestartMean = promauto.NewGaugeVec(
+	e.podRestartMean = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_pod_restart_mean",
 			Help: "Mean restart count for each pod",
 		},
-		[]string{"pod", "namespace"},
+		[]string{"cluster_id", "cluster", "pod", "namespace"},
 	)
 
-	e.podRestartStdDev = promauto.NewGaugeVec(
+	e.podRestartStdDev = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_pod_restart_stddev",
 			Help: "Standard deviation of restart count for each pod",
 		},
-		[]string{"pod", "namespace"},
+		[]string{"cluster_id", "cluster", "pod", "namespace"},
 	)
 
-	e.podRestartEWMA = promauto.NewGaugeVec(
+	e.podRestartEWMA = e.factory.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "huginn_pod_restart_ewma",
 			Help: "Exponentially weighted moving average of restart count for each pod",
 		},
-		[]string{"pod", "namespace"},
+		[]string{"cluster_id", "cluster", "pod", "namespace"},
+	)
+}
+
+// createStorageMetrics creates the persistent volume and claim metrics
+func (e *PrometheusExporter) createStorageMetrics() {
+	e.pvcRequestedBytes = e.factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "huginn_pvc_requested_bytes",
+			Help: "Storage requested by each persistent volume claim, labeled with its phase and storage class",
+		},
+		[]string{"cluster_id", "cluster", "pvc", "namespace", "storage_class", "phase"},
+	)
+
+	e.pvCapacityBytes = e.factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "huginn_pv_capacity_bytes",
+			Help: "Capacity of each persistent volume, labeled with its phase and storage class",
+		},
+		[]string{"cluster_id", "cluster", "pv", "storage_class", "phase"},
+	)
+}
+
+// createWorkloadMetrics creates the workload replica metrics
+func (e *PrometheusExporter) createWorkloadMetrics() {
+	e.workloadReplicasDesired = e.factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "huginn_workload_replicas_desired",
+			Help: "Desired replicas of each workload",
+		},
+		[]string{"cluster_id", "cluster", "kind", "workload", "namespace"},
+	)
+
+	e.workloadReplicasAvailable = e.factory.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "huginn_workload_replicas_available",
+			Help: "Available replicas of each workload",
+		},
+		[]string{"cluster_id", "cluster", "kind", "workload", "namespace"},
 	)
 }
 
-// isResourceEnabled checks if a resource type is enabled in configuration
-func (e *PrometheusExporter) isResourceEnabled(resourceType string) bool {
-	// Check if any cluster has this resource enabled
-	for _, cluster := range e.config.Clusters {
+// createEventMetrics creates the cluster event metrics
+func (e *PrometheusExporter) createEventMetrics() {
+	e.eventsTotal = e.factory.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "huginn_events_total",
+			Help: "Total number of collected cluster events by reason, severity and namespace",
+		},
+		[]string{"cluster_id", "cluster", "reason", "severity", "namespace"},
+	)
+	e.eventCounts = make(map[string]int32)
+}
+
+// Metric families that can be enabled in metricsExporter.families. The anomaly, health, tuning and
+// pipeline metrics make up the anomalies family, which is always exported.
+const (
+	FamilyAnomalies = "anomalies"
+	FamilyNodes     = "nodes"
+	FamilyPods      = "pods"
+	FamilyStorage   = "storage"
+	FamilyWorkloads = "workloads"
+	FamilyEvents    = "events"
+)
+
+// MetricFamilies lists the metric families that can be enabled
+var MetricFamilies = []string{FamilyAnomalies, FamilyNodes, FamilyPods, FamilyStorage, FamilyWorkloads, FamilyEvents}
+
+// enabledFamilies returns the metric families to export. Without explicit families, node and pod
+// metrics are exported if any enabled cluster collects them, along with the other families.
+func enabledFamilies(cfg *config.Config) map[string]bool {
+	families := map[string]bool{FamilyAnomalies: true}
+	if len(cfg.MetricsExporter.Families) > 0 {
+		for _, family := range cfg.MetricsExporter.Families {
+			if !slices.Contains(MetricFamilies, family) {
+				log.Printf("Warning: ignoring unknown metric family %q, expected one of %v", family, MetricFamilies)
+				continue
+			}
+			families[family] = true
+		}
+		return families
+	}
+
+	families[FamilyStorage] = true
+	families[FamilyWorkloads] = true
+	families[FamilyEvents] = true
+	for _, cluster := range cfg.Clusters {
 		if !cluster.Enabled {
 			continue
 		}
 		for _, resource := range cluster.Resources {
-			if resource == resourceType {
-				return true
+			if resource == FamilyNodes || resource == FamilyPods {
+				families[resource] = true
 			}
 		}
 	}
-	return false
+	return families
 }
 
-// UpdateMetrics updates all Prometheus metrics based on current cluster state
-func (e *PrometheusExporter) UpdateMetrics(state types.ClusterState) {
-	// Reset all metrics to avoid stale data
-	e.resetMetrics()
+// enabled reports whether a metric family is exported
+func (e *PrometheusExporter) enabled(family string) bool {
+	return e.families[family]
+}
+
+// UpdateMetrics updates the Prometheus metrics of a cluster from its current state and the history
+// of the detector analyzing it. A nil detector uses the exporter's.
+func (e *PrometheusExporter) UpdateMetrics(state types.ClusterState, detector *anomaly.Detector) {
+	if detector == nil {
+		detector = e.detector
+	}
+
+	// Reset the cluster's metrics to avoid stale data; other clusters' metrics are kept
+	e.resetMetrics(state.ClusterID)
 
 	// Update node metrics only if nodes are enabled
-	if e.isResourceEnabled("nodes") && e.nodeCPUUsage != nil {
+	if e.enabled(FamilyNodes) {
 		for _, node := range state.Nodes {
 			// Raw values (converted to numeric for Prometheus)
 			cpuRaw := parseResourceValue(node.CPUUsage)
@@ -269,103 +501,165 @@ func (e *PrometheusExporter) UpdateMetrics(state types.ClusterState) {
 
 			// Set raw values
 			if e.nodeCPURaw != nil {
-				e.nodeCPURaw.WithLabelValues(node.Name).Set(cpuRaw)
+				e.nodeCPURaw.WithLabelValues(state.ClusterID, state.ClusterName, node.Name).Set(cpuRaw)
 			}
 			if e.nodeMemoryRaw != nil {
-				e.nodeMemoryRaw.WithLabelValues(node.Name).Set(memoryRaw)
+				e.nodeMemoryRaw.WithLabelValues(state.ClusterID, state.ClusterName, node.Name).Set(memoryRaw)
 			}
 
 			// Set capacity values
 			if e.nodeCPUCapacity != nil {
-				e.nodeCPUCapacity.WithLabelValues(node.Name).Set(cpuCapacity)
+				e.nodeCPUCapacity.WithLabelValues(state.ClusterID, state.ClusterName, node.Name).Set(cpuCapacity)
 			}
 			if e.nodeMemoryCapacity != nil {
-				e.nodeMemoryCapacity.WithLabelValues(node.Name).Set(memoryCapacity)
+				e.nodeMemoryCapacity.WithLabelValues(state.ClusterID, state.ClusterName, node.Name).Set(memoryCapacity)
 			}
 
 			// Set percentage values (these are now correctly calculated)
 			if e.nodeCPUUsage != nil {
-				e.nodeCPUUsage.WithLabelValues(node.Name).Set(node.CPUUsagePercent)
+				e.nodeCPUUsage.WithLabelValues(state.ClusterID, state.ClusterName, node.Name).Set(node.CPUUsagePercent)
 			}
 			if e.nodeMemoryUsage != nil {
-				e.nodeMemoryUsage.WithLabelValues(node.Name).Set(node.MemoryUsagePercent)
+				e.nodeMemoryUsage.WithLabelValues(state.ClusterID, state.ClusterName, node.Name).Set(node.MemoryUsagePercent)
 			}
 
 			// Update statistical measures for nodes
-			e.updateNodeStats(node.Name, "cpu", node.CPUUsagePercent)
-			e.updateNodeStats(node.Name, "memory", node.MemoryUsagePercent)
+			e.updateNodeStats(state, detector, node.Name, "cpu", node.CPUUsagePercent)
+			e.updateNodeStats(state, detector, node.Name, "memory", node.MemoryUsagePercent)
 		}
 	}
 
 	// Update pod metrics only if pods are enabled
-	if e.isResourceEnabled("pods") && e.podRestartCount != nil {
+	if e.enabled(FamilyPods) {
 		for ns, resources := range state.Resources {
 			for _, pod := range resources.Pods {
 				restartCount := float64(pod.RestartCount)
 				if e.podRestartCount != nil {
-					e.podRestartCount.WithLabelValues(pod.Name, ns).Set(restartCount)
+					e.podRestartCount.WithLabelValues(state.ClusterID, state.ClusterName, pod.Name, ns).Set(restartCount)
 				}
 
 				// Update statistical measures for pods
-				e.updatePodStats(pod.Name, ns, restartCount)
+				e.updatePodStats(state, detector, pod.Name, ns, restartCount)
+			}
+		}
+	}
+
+	if e.enabled(FamilyStorage) {
+		for ns, resources := range state.Resources {
+			for _, pvc := range resources.PersistentVolumeClaims {
+				e.pvcRequestedBytes.WithLabelValues(state.ClusterID, state.ClusterName, pvc.Name, ns, pvc.StorageClassName, pvc.Status).Set(parseQuantity(pvc.RequestedStorage))
 			}
 		}
+		for _, pv := range state.PersistentVolumes {
+			e.pvCapacityBytes.WithLabelValues(state.ClusterID, state.ClusterName, pv.Name, pv.StorageClassName, pv.Status).Set(parseQuantity(pv.Capacity))
+		}
+	}
+
+	if e.enabled(FamilyWorkloads) {
+		for ns, resources := range state.Resources {
+			for _, deployment := range resources.Deployments {
+				e.workloadReplicasDesired.WithLabelValues(state.ClusterID, state.ClusterName, "Deployment", deployment.Name, ns).Set(float64(deployment.Replicas))
+				e.workloadReplicasAvailable.WithLabelValues(state.ClusterID, state.ClusterName, "Deployment", deployment.Name, ns).Set(float64(deployment.AvailableReplicas))
+			}
+		}
+	}
+
+	if e.enabled(FamilyEvents) {
+		e.updateEventMetrics(state)
 	}
 
 	// Update historical data points
 	e.updateHistoricalMetrics()
 }
 
+// updateEventMetrics counts the occurrences of the cluster's events since they were last listed.
+// Events are listed again on every cycle, so only the increase of their count is added.
+func (e *PrometheusExporter) updateEventMetrics(state types.ClusterState) {
+	e.eventsMu.Lock()
+	defer e.eventsMu.Unlock()
+
+	prefix := state.ClusterID + "/"
+	seen := make(map[string]bool, len(state.Events))
+	for _, event := range state.Events {
+		// Distinct events may share a resource and reason; only events without a UID, e.g.
+		// simulated ones, are told apart by them
+		key := prefix + event.UID
+		if event.UID == "" {
+			key = prefix + event.Namespace + "/" + event.Resource + "/" + event.Reason
+		}
+		seen[key] = true
+		// Events without a count occurred once
+		count := max(event.Count, 1)
+		previous, exists := e.eventCounts[key]
+		e.eventCounts[key] = count
+		if exists && count <= previous {
+			// A lower count is a recreated event, counted from its new count
+			if count == previous {
+				continue
+			}
+			previous = 0
+		}
+		e.eventsTotal.WithLabelValues(state.ClusterID, state.ClusterName, event.Reason, event.Severity, event.Namespace).Add(float64(count - previous))
+	}
+
+	// Forget the events that expired
+	for key := range e.eventCounts {
+		if strings.HasPrefix(key, prefix) && !seen[key] {
+			delete(e.eventCounts, key)
+		}
+	}
+}
+
 // updateNodeStats updates statistical measures for a specific node and metric
-func (e *PrometheusExporter) updateNodeStats(nodeName, metricType string, currentValue float64) {
-	history := e.detector.GetMetricHistory("node", nodeName, metricType)
+func (e *PrometheusExporter) updateNodeStats(state types.ClusterState, detector *anomaly.Detector, nodeName, metricType string, currentValue float64) {
+	history := detector.GetMetricHistory("node", nodeName, metricType)
 	if len(history) == 0 {
 		return
 	}
 
-	mean, stddev, ewma := e.detector.ComputeStats(history, 0.3) // Using default alpha
+	mean, stddev, ewma := detector.ComputeStats(history, 0.3) // Using default alpha
 
 	switch metricType {
 	case "cpu":
 		if e.nodeCPUMean != nil {
-			e.nodeCPUMean.WithLabelValues(nodeName).Set(mean)
+			e.nodeCPUMean.WithLabelValues(state.ClusterID, state.ClusterName, nodeName).Set(mean)
 		}
 		if e.nodeCPUStdDev != nil {
-			e.nodeCPUStdDev.WithLabelValues(nodeName).Set(stddev)
+			e.nodeCPUStdDev.WithLabelValues(state.ClusterID, state.ClusterName, nodeName).Set(stddev)
 		}
 		if e.nodeCPUEWMA != nil {
-			e.nodeCPUEWMA.WithLabelValues(nodeName).Set(ewma)
+			e.nodeCPUEWMA.WithLabelValues(state.ClusterID, state.ClusterName, nodeName).Set(ewma)
 		}
 	case "memory":
 		if e.nodeMemoryMean != nil {
-			e.nodeMemoryMean.WithLabelValues(nodeName).Set(mean)
+			e.nodeMemoryMean.WithLabelValues(state.ClusterID, state.ClusterName, nodeName).Set(mean)
 		}
 		if e.nodeMemoryStdDev != nil {
-			e.nodeMemoryStdDev.WithLabelValues(nodeName).Set(stddev)
+			e.nodeMemoryStdDev.WithLabelValues(state.ClusterID, state.ClusterName, nodeName).Set(stddev)
 		}
 		if e.nodeMemoryEWMA != nil {
-			e.nodeMemoryEWMA.WithLabelValues(nodeName).Set(ewma)
+			e.nodeMemoryEWMA.WithLabelValues(state.ClusterID, state.ClusterName, nodeName).Set(ewma)
 		}
 	}
 }
 
 // updatePodStats updates statistical measures for a specific pod
-func (e *PrometheusExporter) updatePodStats(podName, namespace string, currentValue float64) {
-	history := e.detector.GetMetricHistory("pod", podName, "restarts")
+func (e *PrometheusExporter) updatePodStats(state types.ClusterState, detector *anomaly.Detector, podName, namespace string, currentValue float64) {
+	history := detector.GetMetricHistory("pod", podName, "restarts")
 	if len(history) == 0 {
 		return
 	}
 
-	mean, stddev, ewma := e.detector.ComputeStats(history, 0.1) // Using default alpha 0.3
+	mean, stddev, ewma := detector.ComputeStats(history, 0.1) // Using default alpha 0.3
 
 	if e.podRestartMean != nil {
-		e.podRestartMean.WithLabelValues(podName, namespace).Set(mean)
+		e.podRestartMean.WithLabelValues(state.ClusterID, state.ClusterName, podName, namespace).Set(mean)
 	}
 	if e.podRestartStdDev != nil {
-		e.podRestartStdDev.WithLabelValues(podName, namespace).Set(stddev)
+		e.podRestartStdDev.WithLabelValues(state.ClusterID, state.ClusterName, podName, namespace).Set(stddev)
 	}
 	if e.podRestartEWMA != nil {
-		e.podRestartEWMA.WithLabelValues(podName, namespace).Set(ewma)
+		e.podRestartEWMA.WithLabelValues(state.ClusterID, state.ClusterName, podName, namespace).Set(ewma)
 	}
 }
 
@@ -376,96 +670,116 @@ func (e *PrometheusExporter) updateHistoricalMetrics() {
 	// In a full implementation, you might want to export a rolling window
 }
 
+// Registry returns the registry the exporter's metrics are registered with
+func (e *PrometheusExporter) Registry() *prometheus.Registry {
+	return e.registry
+}
+
+// FleetRegistry returns the registry summarizing the exporter's metrics per cluster
+func (e *PrometheusExporter) FleetRegistry() *prometheus.Registry {
+	return e.fleet
+}
+
+// RecordTuning records the threshold and alpha learning tuned a cluster's detector metric to
+func (e *PrometheusExporter) RecordTuning(clusterID, cluster, metric string, threshold, alpha float64) {
+	e.tunedThreshold.WithLabelValues(clusterID, cluster, metric).Set(threshold)
+	e.tunedAlpha.WithLabelValues(clusterID, cluster, metric).Set(alpha)
+}
+
+// RecordHealthScore records the composite health score of a cluster
+func (e *PrometheusExporter) RecordHealthScore(clusterID, cluster string, score float64) {
+	e.healthScore.WithLabelValues(clusterID, cluster).Set(score)
+}
+
+// ObserveDetection records the duration of a detection cycle of a cluster
+func (e *PrometheusExporter) ObserveDetection(clusterID, cluster string, duration time.Duration) {
+	e.detectionDuration.WithLabelValues(clusterID, cluster).Observe(duration.Seconds())
+}
+
+// ObserveEmbedding records the duration of embedding an anomaly of a cluster
+func (e *PrometheusExporter) ObserveEmbedding(clusterID, cluster string, duration time.Duration) {
+	e.embeddingDuration.WithLabelValues(clusterID, cluster).Observe(duration.Seconds())
+}
+
+// ObserveStorageWrite records the duration of storing an anomaly of a cluster
+func (e *PrometheusExporter) ObserveStorageWrite(clusterID, cluster string, duration time.Duration) {
+	e.storageWriteDuration.WithLabelValues(clusterID, cluster).Observe(duration.Seconds())
+}
+
+// SetStorageHealthy records whether the storage backend is healthy
+func (e *PrometheusExporter) SetStorageHealthy(healthy bool) {
+	if healthy {
+		e.storageHealthy.Set(1)
+	} else {
+		e.storageHealthy.Set(0)
+	}
+}
+
+// RecordOpenAnomalies records the open anomalies of a cluster, replacing those of the previous cycle
+func (e *PrometheusExporter) RecordOpenAnomalies(clusterID, cluster string, open map[string]map[types.Severity]int) {
+	e.anomaliesOpen.DeletePartialMatch(prometheus.Labels{"cluster_id": clusterID})
+	for anomalyType, severities := range open {
+		for severity, count := range severities {
+			e.anomaliesOpen.WithLabelValues(clusterID, cluster, anomalyType, string(severity)).Set(float64(count))
+		}
+	}
+}
+
 // RecordAnomaly records a detected anomaly
 func (e *PrometheusExporter) RecordAnomaly(anomaly types.Anomaly) {
-	severityScore := getSeverityScore(anomaly.Severity)
+	severityScore := float64(anomaly.Severity.Rank())
 
-	e.anomalyDetected.WithLabelValues(
+	counter := e.anomalyDetected.WithLabelValues(
+		anomaly.ClusterID,
+		anomaly.ClusterName,
 		anomaly.Type,
 		anomaly.Resource,
 		anomaly.Namespace,
-		anomaly.Severity,
-	).Inc()
+		string(anomaly.Severity),
+	)
+	// The alert ID exemplar links a spike to the alert, e.g. /api/v1/alerts/<id>/explain.
+	// AddWithExemplar panics on labels over the exemplar limit, which long IDs can reach.
+	if exemplar := alertExemplar(anomaly.ID); exemplar != nil {
+		counter.(prometheus.ExemplarAdder).AddWithExemplar(1, exemplar)
+	} else {
+		counter.Inc()
+	}
 
 	e.anomalySeverity.WithLabelValues(
+		anomaly.ClusterID,
+		anomaly.ClusterName,
 		anomaly.Type,
 		anomaly.Resource,
 		anomaly.Namespace,
 	).Set(severityScore)
 }
 
-// resetMetrics resets all metrics to avoid stale data
-func (e *PrometheusExporter) resetMetrics() {
-	// Reset node metrics only if they exist
-	if e.nodeCPURaw != nil {
-		e.nodeCPURaw.Reset()
-	}
-	if e.nodeMemoryRaw != nil {
-		e.nodeMemoryRaw.Reset()
-	}
-	if e.nodeCPUUsage != nil {
-		e.nodeCPUUsage.Reset()
-	}
-	if e.nodeMemoryUsage != nil {
-		e.nodeMemoryUsage.Reset()
-	}
-	if e.nodeCPUCapacity != nil {
-		e.nodeCPUCapacity.Reset()
-	}
-	if e.nodeMemoryCapacity != nil {
-		e.nodeMemoryCapacity.Reset()
-	}
-
-	// Reset pod metrics only if they exist
-	if e.podRestartCount != nil {
-		e.podRestartCount.Reset()
-	}
-
-	// Reset statistical measures only if they exist
-	if e.nodeCPUMean != nil {
-		e.nodeCPUMean.Reset()
+// alertExemplar returns the exemplar labels of an alert ID, or nil if there is no ID or it does
+// not fit in an exemplar
+func alertExemplar(id string) prometheus.Labels {
+	const name = "alert_id"
+	if id == "" || !utf8.ValidString(id) || utf8.RuneCountInString(name+id) > prometheus.ExemplarMaxRunes {
+		return nil
 	}
-	if e.nodeCPUStdDev != nil {
-		e.nodeCPUStdDev.Reset()
-	}
-	if e.nodeCPUEWMA != nil {
-		e.nodeCPUEWMA.Reset()
-	}
-	if e.nodeMemoryMean != nil {
-		e.nodeMemoryMean.Reset()
-	}
-	if e.nodeMemoryStdDev != nil {
-		e.nodeMemoryStdDev.Reset()
-	}
-	if e.nodeMemoryEWMA != nil {
-		e.nodeMemoryEWMA.Reset()
-	}
-	if e.podRestartMean != nil {
-		e.podRestartMean.Reset()
-	}
-	if e.podRestartStdDev != nil {
-		e.podRestartStdDev.Reset()
-	}
-	if e.podRestartEWMA != nil {
-		e.podRestartEWMA.Reset()
-	}
-
-	// Always reset anomaly and history metrics (they're always created)
-	e.anomalySeverity.Reset()
-	e.metricHistory.Reset()
+	return prometheus.Labels{name: id}
 }
 
-// getSeverityScore converts severity string to numeric score
-func getSeverityScore(severity string) float64 {
-	switch severity {
-	case "low":
-		return 1
-	case "medium":
-		return 2
-	case "high":
-		return 3
-	default:
-		return 0
+// resetMetrics removes the per-resource metrics of a cluster to avoid stale data
+func (e *PrometheusExporter) resetMetrics(clusterID string) {
+	cluster := prometheus.Labels{"cluster_id": clusterID}
+	for _, vec := range []*prometheus.GaugeVec{
+		e.nodeCPURaw, e.nodeMemoryRaw, e.nodeCPUUsage, e.nodeMemoryUsage, e.nodeCPUCapacity, e.nodeMemoryCapacity,
+		e.podRestartCount,
+		e.nodeCPUMean, e.nodeCPUStdDev, e.nodeCPUEWMA, e.nodeMemoryMean, e.nodeMemoryStdDev, e.nodeMemoryEWMA,
+		e.podRestartMean, e.podRestartStdDev, e.podRestartEWMA,
+		e.pvcRequestedBytes, e.pvCapacityBytes, e.workloadReplicasDesired, e.workloadReplicasAvailable,
+		// Anomaly and history metrics are always created
+		e.anomalySeverity, e.metricHistory,
+	} {
+		// The metrics of disabled families do not exist
+		if vec != nil {
+			vec.DeletePartialMatch(cluster)
+		}
 	}
 }
 
@@ -478,3 +792,12 @@ func parseResourceValue(value string) float64 {
 	}
 	return numeric
 }
+
+// parseQuantity converts a Kubernetes quantity such as "10Gi" to a float64, 0 if it is invalid
+func parseQuantity(value string) float64 {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0
+	}
+	return quantity.AsApproximateFloat64()
+}
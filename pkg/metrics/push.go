@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+)
+
+// Pusher periodically pushes the exporter's metrics to a Pushgateway and/or a remote-write
+// endpoint, for environments where the agent cannot be scraped
+type Pusher struct {
+	exporter *PrometheusExporter
+	config   config.MetricsPushConfig
+	client   *http.Client
+}
+
+// NewPusher creates a pusher of the exporter's metrics, or returns nil if no target is configured
+func NewPusher(exporter *PrometheusExporter, cfg config.MetricsPushConfig) *Pusher {
+	if cfg.Pushgateway.URL == "" && cfg.RemoteWrite.URL == "" {
+		return nil
+	}
+	return &Pusher{
+		exporter: exporter,
+		config:   cfg,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Start pushes the metrics every interval until ctx is done
+func (p *Pusher) Start(ctx context.Context) {
+	interval := time.Duration(p.config.IntervalSeconds) * time.Second
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.Push(ctx)
+			}
+		}
+	}()
+}
+
+// Push pushes the current metrics to every configured target, logging failures
+func (p *Pusher) Push(ctx context.Context) {
+	if p.config.Pushgateway.URL != "" {
+		if err := p.pushGateway(ctx); err != nil {
+			log.Printf("Warning: failed to push metrics to Pushgateway: %v", err)
+		}
+	}
+	if p.config.RemoteWrite.URL != "" {
+		if err := p.remoteWrite(ctx); err != nil {
+			log.Printf("Warning: failed to remote-write metrics: %v", err)
+		}
+	}
+}
+
+// pushGateway replaces the metrics of this instance's group on the Pushgateway
+func (p *Pusher) pushGateway(ctx context.Context) error {
+	cfg := p.config.Pushgateway
+	pusher := push.New(cfg.URL, cfg.Job).Gatherer(p.exporter.Registry()).Client(p.client)
+	if cfg.Instance != "" {
+		pusher = pusher.Grouping("instance", cfg.Instance)
+	}
+	if cfg.Username != "" || cfg.Password != "" {
+		pusher = pusher.BasicAuth(cfg.Username, cfg.Password)
+	}
+	return pusher.PushContext(ctx)
+}
+
+// remoteWrite sends the current metrics as a snappy-compressed remote-write request
+func (p *Pusher) remoteWrite(ctx context.Context) error {
+	families, err := p.exporter.Registry().Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %v", err)
+	}
+	body := snappy.Encode(nil, encodeWriteRequest(families, time.Now()))
+
+	cfg := p.config.RemoteWrite
+	req, err := http.NewRequestWithContext(ctx, "POST", cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for key, value := range cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	if cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.BearerToken)
+	} else if cfg.Username != "" || cfg.Password != "" {
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote-write endpoint returned %s: %s", resp.Status, message)
+	}
+	return nil
+}
+
+// sample is a single value of a series in a remote-write request
+type sample struct {
+	labels map[string]string // Including __name__
+	value  float64
+}
+
+// familySamples flattens a metric family into samples, expanding summaries and histograms into
+// their quantile, bucket, sum and count series as Prometheus does when scraping
+func familySamples(family *dto.MetricFamily) []sample {
+	var samples []sample
+	for _, metric := range family.GetMetric() {
+		series := func(suffix string, value float64, extra ...string) {
+			labels := map[string]string{"__name__": family.GetName() + suffix}
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			for i := 0; i+1 < len(extra); i += 2 {
+				labels[extra[i]] = extra[i+1]
+			}
+			samples = append(samples, sample{labels: labels, value: value})
+		}
+		switch {
+		case metric.Gauge != nil:
+			series("", metric.GetGauge().GetValue())
+		case metric.Counter != nil:
+			series("", metric.GetCounter().GetValue())
+		case metric.Untyped != nil:
+			series("", metric.GetUntyped().GetValue())
+		case metric.Summary != nil:
+			summary := metric.GetSummary()
+			for _, q := range summary.GetQuantile() {
+				series("", q.GetValue(), "quantile", strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64))
+			}
+			series("_sum", summary.GetSampleSum())
+			series("_count", float64(summary.GetSampleCount()))
+		case metric.Histogram != nil:
+			histogram := metric.GetHistogram()
+			for _, b := range histogram.GetBucket() {
+				series("_bucket", float64(b.GetCumulativeCount()), "le", strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64))
+			}
+			series("_bucket", float64(histogram.GetSampleCount()), "le", "+Inf")
+			series("_sum", histogram.GetSampleSum())
+			series("_count", float64(histogram.GetSampleCount()))
+		}
+	}
+	return samples
+}
+
+// encodeWriteRequest encodes metric families as a remote-write WriteRequest protobuf:
+// repeated TimeSeries (1) of repeated Label (1: name, 2: value) and repeated Sample
+// (1: double value, 2: int64 timestamp in milliseconds)
+func encodeWriteRequest(families []*dto.MetricFamily, at time.Time) []byte {
+	var request []byte
+	for _, family := range families {
+		for _, s := range familySamples(family) {
+			names := make([]string, 0, len(s.labels))
+			for name := range s.labels {
+				names = append(names, name)
+			}
+			// Remote-write requires labels sorted by name
+			sort.Strings(names)
+
+			var series []byte
+			for _, name := range names {
+				var label []byte
+				label = protowire.AppendTag(label, 1, protowire.BytesType)
+				label = protowire.AppendString(label, name)
+				label = protowire.AppendTag(label, 2, protowire.BytesType)
+				label = protowire.AppendString(label, s.labels[name])
+				series = protowire.AppendTag(series, 1, protowire.BytesType)
+				series = protowire.AppendBytes(series, label)
+			}
+			var value []byte
+			value = protowire.AppendTag(value, 1, protowire.Fixed64Type)
+			value = protowire.AppendFixed64(value, math.Float64bits(s.value))
+			value = protowire.AppendTag(value, 2, protowire.VarintType)
+			value = protowire.AppendVarint(value, uint64(at.UnixMilli()))
+			series = protowire.AppendTag(series, 2, protowire.BytesType)
+			series = protowire.AppendBytes(series, value)
+
+			request = protowire.AppendTag(request, 1, protowire.BytesType)
+			request = protowire.AppendBytes(request, series)
+		}
+	}
+	return request
+}
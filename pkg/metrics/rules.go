@@ -0,0 +1,265 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rodolfo-mora/huginn/pkg/anomaly"
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+	"gopkg.in/yaml.v2"
+)
+
+// Rule file formats
+const (
+	FormatRules          = "rules"          // Prometheus rule file, loaded with rule_files
+	FormatPrometheusRule = "prometheusrule" // Prometheus Operator PrometheusRule resource
+)
+
+// RuleFile is a Prometheus alerting rule file
+type RuleFile struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+// RuleGroup is a named group of alerting rules
+type RuleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is a Prometheus alerting rule
+type Rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// prometheusRule is the Prometheus Operator resource wrapping a rule file
+type prometheusRule struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name   string            `yaml:"name"`
+		Labels map[string]string `yaml:"labels"`
+	} `yaml:"metadata"`
+	Spec RuleFile `yaml:"spec"`
+}
+
+// GenerateRules returns alerting rules over the exported metrics that match the configured
+// thresholds, along with warnings about thresholds the metrics cannot express
+func GenerateRules(cfg *config.Config) (RuleFile, []string) {
+	families := enabledFamilies(cfg)
+	detection := cfg.AnomalyDetection
+	pending := fmt.Sprintf("%ds", 2*cfg.ObservationInterval)
+	var rules []Rule
+	var warnings []string
+
+	// Threshold overrides can only be expressed over the labels the metrics carry
+	var nodeOverrides, podOverrides []config.ThresholdOverride
+	for i, override := range detection.ThresholdOverrides {
+		if override.LabelSelector != "" {
+			warnings = append(warnings, fmt.Sprintf("threshold override %d: label selectors are not exported as metric labels, skipped", i))
+			continue
+		}
+		if override.NodeNamePattern != "" {
+			nodeOverrides = append(nodeOverrides, override)
+		}
+		switch {
+		case override.Namespace != "" && override.NodeNamePattern == "":
+			podOverrides = append(podOverrides, override)
+		case override.PodRestartThreshold > 0:
+			warnings = append(warnings, fmt.Sprintf("threshold override %d: pod restart metrics carry no node label, restart threshold skipped", i))
+		}
+	}
+
+	if families[FamilyNodes] {
+		for _, metric := range []struct {
+			detector, name, text, resource string
+			threshold                      float64
+			override                       func(config.ThresholdOverride) float64
+		}{
+			{anomaly.DetectorCPU, "CPU", "CPU", "cpu", detection.CPUThreshold, func(o config.ThresholdOverride) float64 { return o.CPUThreshold }},
+			{anomaly.DetectorMemory, "Memory", "memory", "memory", detection.MemoryThreshold, func(o config.ThresholdOverride) float64 { return o.MemoryThreshold }},
+		} {
+			if slices.Contains(detection.DisabledDetectors, metric.detector) {
+				continue
+			}
+			series := "huginn_node_" + metric.resource + "_usage_percent"
+			var earlier []string
+			for _, override := range nodeOverrides {
+				threshold := metric.override(override)
+				if threshold == 0 {
+					threshold = metric.threshold
+				}
+				matchers := append([]string{fmt.Sprintf("node=~%q", globRegexp(override.NodeNamePattern))}, excluding("node", earlier)...)
+				rules = append(rules, nodeRule(metric.name, metric.text, series, matchers, threshold, pending))
+				earlier = append(earlier, override.NodeNamePattern)
+			}
+			rules = append(rules, nodeRule(metric.name, metric.text, series, excluding("node", earlier), metric.threshold, pending))
+
+			if detection.Severity.Enabled {
+				if critical := detection.Severity.Metrics[metric.resource].Critical; critical > 0 {
+					rules = append(rules, Rule{
+						Alert:  "Critical" + metric.name + "Usage",
+						Expr:   fmt.Sprintf("%s > %g", series, critical),
+						For:    pending,
+						Labels: map[string]string{"severity": "critical", "service": "huginn"},
+						Annotations: map[string]string{
+							"summary":     fmt.Sprintf("Critical %s usage on {{ $labels.node }} ({{ $labels.cluster }})", metric.text),
+							"description": fmt.Sprintf("Node {{ $labels.node }} in cluster {{ $labels.cluster }} has %s usage of {{ $value }}%%, above %g%%", metric.text, critical),
+						},
+					})
+				}
+			}
+		}
+	}
+
+	if families[FamilyPods] && !slices.Contains(detection.DisabledDetectors, anomaly.DetectorRestarts) {
+		var earlier []string
+		for _, override := range podOverrides {
+			threshold := override.PodRestartThreshold
+			if threshold == 0 {
+				threshold = detection.PodRestartThreshold
+			}
+			matchers := append([]string{fmt.Sprintf("namespace=~%q", globRegexp(override.Namespace))}, excluding("namespace", earlier)...)
+			rules = append(rules, restartRule(matchers, threshold, detection.RestartWindowMinutes, pending))
+			earlier = append(earlier, override.Namespace)
+		}
+		rules = append(rules, restartRule(excluding("namespace", earlier), detection.PodRestartThreshold, detection.RestartWindowMinutes, pending))
+	}
+
+	// Anomalies open at the notification severity
+	var severities []string
+	for _, severity := range types.Severities {
+		if severity.AtLeast(types.Severity(cfg.Notification.MinSeverity)) {
+			severities = append(severities, string(severity))
+		}
+	}
+	rules = append(rules, Rule{
+		Alert:  "HuginnAnomalyOpen",
+		Expr:   fmt.Sprintf("huginn_anomalies_open{severity=~%q} > 0", strings.Join(severities, "|")),
+		Labels: map[string]string{"severity": "warning", "service": "huginn"},
+		Annotations: map[string]string{
+			"summary":     "{{ $labels.type }} anomalies open in cluster {{ $labels.cluster }}",
+			"description": "{{ $value }} {{ $labels.severity }} {{ $labels.type }} anomalies are open in cluster {{ $labels.cluster }}",
+		},
+	})
+
+	if detection.HealthScore.Enabled {
+		window := detection.HealthScore.Intervals * cfg.ObservationInterval
+		rules = append(rules, Rule{
+			Alert:  "ClusterHealthDrop",
+			Expr:   fmt.Sprintf("huginn_cluster_health_score < avg_over_time(huginn_cluster_health_score[%ds]) - %g", window, detection.HealthScore.DropThreshold),
+			Labels: map[string]string{"severity": "warning", "service": "huginn"},
+			Annotations: map[string]string{
+				"summary":     "Health of cluster {{ $labels.cluster }} dropped",
+				"description": fmt.Sprintf("The health score of cluster {{ $labels.cluster }} is {{ $value }}, more than %g points below its recent mean", detection.HealthScore.DropThreshold),
+			},
+		})
+	}
+
+	rules = append(rules, Rule{
+		Alert:  "ClusterUnreachable",
+		Expr:   "huginn_cluster_healthy == 0",
+		For:    pending,
+		Labels: map[string]string{"severity": "critical", "service": "huginn"},
+		Annotations: map[string]string{
+			"summary":     "Cluster {{ $labels.cluster }} cannot be observed",
+			"description": "The latest observations of cluster {{ $labels.cluster }} ({{ $labels.cluster_id }}) have failed",
+		},
+	})
+
+	return RuleFile{Groups: []RuleGroup{{Name: "huginn_generated", Rules: rules}}}, warnings
+}
+
+// WriteRules writes a rule file in the given format
+func WriteRules(w io.Writer, format string, rules RuleFile) error {
+	var v interface{}
+	switch format {
+	case FormatRules:
+		v = rules
+	case FormatPrometheusRule:
+		resource := prometheusRule{APIVersion: "monitoring.coreos.com/v1", Kind: "PrometheusRule", Spec: rules}
+		resource.Metadata.Name = "huginn"
+		resource.Metadata.Labels = map[string]string{"app": "huginn"}
+		v = resource
+	default:
+		return fmt.Errorf("unsupported rules format: %s (expected %s or %s)", format, FormatRules, FormatPrometheusRule)
+	}
+
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rules: %v", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// nodeRule returns the rule for a node usage metric above a threshold
+func nodeRule(name, text, series string, matchers []string, threshold float64, pending string) Rule {
+	return Rule{
+		Alert:  "High" + name + "Usage",
+		Expr:   fmt.Sprintf("%s%s > %g", series, selector(matchers), threshold),
+		For:    pending,
+		Labels: map[string]string{"severity": "warning", "service": "huginn"},
+		Annotations: map[string]string{
+			"summary":     fmt.Sprintf("High %s usage on {{ $labels.node }} ({{ $labels.cluster }})", text),
+			"description": fmt.Sprintf("Node {{ $labels.node }} in cluster {{ $labels.cluster }} has %s usage of {{ $value }}%%, above %g%%", text, threshold),
+		},
+	}
+}
+
+// restartRule returns the rule for pods restarting more than a threshold within the restart window
+func restartRule(matchers []string, threshold, windowMinutes int, pending string) Rule {
+	return Rule{
+		Alert:  "HighPodRestarts",
+		Expr:   fmt.Sprintf("increase(huginn_pod_restart_count%s[%dm]) > %d", selector(matchers), windowMinutes, threshold),
+		For:    pending,
+		Labels: map[string]string{"severity": "warning", "service": "huginn"},
+		Annotations: map[string]string{
+			"summary":     "Pod {{ $labels.pod }} is restarting ({{ $labels.cluster }})",
+			"description": fmt.Sprintf("Pod {{ $labels.pod }} in namespace {{ $labels.namespace }} of cluster {{ $labels.cluster }} restarted {{ $value }} times within %d minutes", windowMinutes),
+		},
+	}
+}
+
+// excluding returns a matcher excluding the label values matched by the globs, if any
+func excluding(label string, globs []string) []string {
+	if len(globs) == 0 {
+		return nil
+	}
+	patterns := make([]string, len(globs))
+	for i, glob := range globs {
+		patterns[i] = globRegexp(glob)
+	}
+	return []string{fmt.Sprintf("%s!~%q", label, strings.Join(patterns, "|"))}
+}
+
+// selector formats label matchers as a series selector
+func selector(matchers []string) string {
+	if len(matchers) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(matchers, ", ") + "}"
+}
+
+// globRegexp converts a glob, as matched by path.Match, to an equivalent regular expression
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
@@ -1,15 +1,29 @@
 package metrics
 
 import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
 	"net/http"
+	"net/http/pprof"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/version"
 )
 
 // MetricsServer provides an HTTP server to expose Prometheus metrics
 type MetricsServer struct {
 	addr     string
 	exporter *PrometheusExporter
+	mux      *http.ServeMux
+	tls      config.MetricsTLSConfig
+	auth     config.MetricsAuthConfig
+	debug    config.MetricsDebugConfig
+	// Checks served at /healthz and /readyz
+	liveness  healthChecks
+	readiness healthChecks
 }
 
 // NewMetricsServer creates a new metrics server
@@ -17,16 +31,98 @@ func NewMetricsServer(addr string, exporter *PrometheusExporter) *MetricsServer
 	return &MetricsServer{
 		addr:     addr,
 		exporter: exporter,
+		mux:      http.NewServeMux(),
 	}
 }
 
+// Handle registers an additional handler on the metrics server, e.g. for API endpoints
+func (s *MetricsServer) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// SetTLS serves over HTTPS with the given certificate and key files
+func (s *MetricsServer) SetTLS(tls config.MetricsTLSConfig) {
+	s.tls = tls
+}
+
+// SetAuth requires basic-auth credentials or a bearer token on every endpoint except the probes
+func (s *MetricsServer) SetAuth(auth config.MetricsAuthConfig) {
+	s.auth = auth
+}
+
+// SetDebug serves the pprof handlers if enabled
+func (s *MetricsServer) SetDebug(debug config.MetricsDebugConfig) {
+	s.debug = debug
+}
+
 // Start starts the metrics server
 func (s *MetricsServer) Start() error {
-	// Register the Prometheus handler
-	http.Handle("/metrics", promhttp.Handler())
+	// Register the Prometheus handler; exemplars are only exposed in the OpenMetrics format
+	s.mux.Handle("/metrics", promhttp.HandlerFor(s.exporter.Registry(), promhttp.HandlerOpts{EnableOpenMetrics: true}))
+
+	// Register the fleet view, summarizing the per-resource metrics per cluster
+	s.mux.Handle("/metrics/federate", promhttp.HandlerFor(s.exporter.FleetRegistry(), promhttp.HandlerOpts{}))
+
+	// Register the version endpoint
+	s.mux.HandleFunc("/api/v1/version", handleVersion)
+
+	// Register the probe endpoints
+	s.mux.HandleFunc("/healthz", s.liveness.handler)
+	s.mux.HandleFunc("/readyz", s.readiness.handler)
+
+	// Register the profiling endpoints, which require credentials like the metrics
+	if s.debug.Pprof {
+		s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+		s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
 
 	// Start the server
-	return http.ListenAndServe(s.addr, nil)
+	handler := s.authenticate(s.mux)
+	if s.tls.CertFile != "" && s.tls.KeyFile != "" {
+		return http.ListenAndServeTLS(s.addr, s.tls.CertFile, s.tls.KeyFile, handler)
+	}
+	return http.ListenAndServe(s.addr, handler)
+}
+
+// authenticate rejects requests without the configured credentials. Probes are exempt, since
+// kubelets and load balancers usually cannot authenticate.
+func (s *MetricsServer) authenticate(next http.Handler) http.Handler {
+	if s.auth.BearerToken == "" && s.auth.Username == "" && s.auth.Password == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || s.authorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.auth.Username != "" || s.auth.Password != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="huginn"`)
+		}
+		WriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	})
+}
+
+// authorized reports whether a request carries the configured basic-auth credentials or bearer token
+func (s *MetricsServer) authorized(r *http.Request) bool {
+	if s.auth.BearerToken != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && equal(token, s.auth.BearerToken) {
+			return true
+		}
+	}
+	if s.auth.Username != "" || s.auth.Password != "" {
+		if username, password, ok := r.BasicAuth(); ok && equal(username, s.auth.Username) && equal(password, s.auth.Password) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares credentials in constant time
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
 }
 
 // StartAsync starts the metrics server in a goroutine
@@ -37,3 +133,17 @@ func (s *MetricsServer) StartAsync() {
 		}
 	}()
 }
+
+// handleVersion returns the build information of the running binary
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, version.Get())
+}
+
+// WriteJSON writes a JSON response with the given status code
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode JSON response: %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package notification
+
+import (
+	"fmt"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// NewNotifier creates a notifier based on the notification configuration
+func NewNotifier(cfg config.NotificationConfig) (Notifier, error) {
+	switch cfg.Type {
+	case "slack":
+		return &SlackNotifier{WebhookURL: cfg.Slack.WebhookURL}, nil
+	case "email":
+		return &EmailNotifier{
+			SMTPHost:     cfg.Email.SMTPHost,
+			SMTPPort:     cfg.Email.SMTPPort,
+			SMTPUser:     cfg.Email.SMTPUser,
+			SMTPPassword: cfg.Email.SMTPPassword,
+			From:         cfg.Email.From,
+			To:           cfg.Email.To,
+		}, nil
+	case "webhook":
+		return &WebhookNotifier{
+			URL:     cfg.Webhook.URL,
+			Headers: cfg.Webhook.Headers,
+		}, nil
+	case "alertmanager":
+		return &AlertmanagerNotifier{
+			URL:           cfg.Alertmanager.URL,
+			DefaultLabels: cfg.Alertmanager.DefaultLabels,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported notification type: %s", cfg.Type)
+	}
+}
+
+// ShouldNotify determines if a notification should be sent based on severity. Severities are
+// compared case-insensitively; an unknown minimum severity lets every anomaly through.
+func ShouldNotify(anomaly types.Anomaly, minSeverity string) bool {
+	return anomaly.Severity.AtLeast(types.Severity(minSeverity))
+}
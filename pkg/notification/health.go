@@ -0,0 +1,42 @@
+package notification
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// TrackingNotifier records the outcome of the last notification sent through a notifier, so
+// readiness probes can report whether the notification channel is reachable
+type TrackingNotifier struct {
+	Notifier
+	mu      sync.Mutex
+	lastErr error
+	lastAt  time.Time
+}
+
+// NewTrackingNotifier wraps a notifier to track its delivery outcomes
+func NewTrackingNotifier(notifier Notifier) *TrackingNotifier {
+	return &TrackingNotifier{Notifier: notifier}
+}
+
+// Notify sends the notification and records its outcome
+func (n *TrackingNotifier) Notify(anomaly types.Anomaly) error {
+	err := n.Notifier.Notify(anomaly)
+	n.mu.Lock()
+	n.lastErr, n.lastAt = err, time.Now()
+	n.mu.Unlock()
+	return err
+}
+
+// Check returns the error of the last notification, or nil if it was delivered or none was sent
+func (n *TrackingNotifier) Check() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.lastErr != nil {
+		return fmt.Errorf("last notification at %s failed: %v", n.lastAt.Format(time.RFC3339), n.lastErr)
+	}
+	return nil
+}
@@ -19,6 +19,15 @@ type SlackNotifier struct {
 func (n *SlackNotifier) Notify(anomaly types.Anomaly) error {
 	message := fmt.Sprintf("*[%s] %s*\nResource: %s\nNamespace: %s\nSeverity: %s\nDescription: %s",
 		anomaly.Type, anomaly.Resource, anomaly.Resource, anomaly.Namespace, anomaly.Severity, anomaly.Description)
+	if anomaly.Fingerprint != "" {
+		message += fmt.Sprintf("\nFingerprint: %s", anomaly.Fingerprint)
+	}
+	if len(anomaly.Events) > 0 {
+		message += "\nRecent events:"
+		for _, event := range anomaly.Events {
+			message += fmt.Sprintf("\n• %s %s: %s", event.Timestamp.Format(time.RFC3339), event.Reason, event.Message)
+		}
+	}
 
 	payload := map[string]string{
 		"text": message,
@@ -68,6 +77,7 @@ type WebhookNotifier struct {
 // Notify sends an anomaly notification via webhook
 func (n *WebhookNotifier) Notify(anomaly types.Anomaly) error {
 	payload := map[string]interface{}{
+		"fingerprint": anomaly.Fingerprint,
 		"type":        anomaly.Type,
 		"resource":    anomaly.Resource,
 		"namespace":   anomaly.Namespace,
@@ -76,6 +86,7 @@ func (n *WebhookNotifier) Notify(anomaly types.Anomaly) error {
 		"value":       anomaly.Value,
 		"threshold":   anomaly.Threshold,
 		"timestamp":   anomaly.Timestamp,
+		"events":      anomaly.Events,
 	}
 
 	jsonData, err := json.Marshal(payload)
@@ -113,22 +124,31 @@ type AlertmanagerNotifier struct {
 	DefaultLabels map[string]string
 }
 
-// Notify sends an anomaly notification to Alertmanager
-func (n *AlertmanagerNotifier) Notify(anomaly types.Anomaly) error {
+// AlertmanagerLabels returns the labels an anomaly is sent to Alertmanager with
+func AlertmanagerLabels(anomaly types.Anomaly, defaultLabels map[string]string) map[string]string {
 	labels := make(map[string]string)
-	for k, v := range n.DefaultLabels {
+	for k, v := range defaultLabels {
 		labels[k] = v
 	}
 	labels["alertname"] = anomaly.Type
 	labels["resource"] = anomaly.Resource
 	labels["namespace"] = anomaly.Namespace
-	labels["severity"] = anomaly.Severity
+	labels["severity"] = string(anomaly.Severity)
+	return labels
+}
+
+// Notify sends an anomaly notification to Alertmanager
+func (n *AlertmanagerNotifier) Notify(anomaly types.Anomaly) error {
+	labels := AlertmanagerLabels(anomaly, n.DefaultLabels)
 
 	annotations := map[string]string{
 		"description": anomaly.Description,
 		"value":       fmt.Sprintf("%.2f", anomaly.Value),
 		"threshold":   fmt.Sprintf("%.2f", anomaly.Threshold),
 	}
+	if anomaly.Fingerprint != "" {
+		annotations["fingerprint"] = anomaly.Fingerprint
+	}
 
 	alert := types.AlertmanagerAlert{
 		Labels:       labels,
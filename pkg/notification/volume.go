@@ -0,0 +1,124 @@
+package notification
+
+import (
+	"sync"
+	"time"
+)
+
+// VolumeTracker counts sent notifications per route in hourly buckets, so growth in
+// alert volume can be noticed before a route's rate limit is routinely exceeded
+type VolumeTracker struct {
+	mu      sync.Mutex
+	window  int                          // Number of hourly buckets kept
+	buckets map[string]map[int64]float64 // route -> hour (unix hours) -> notifications
+}
+
+// VolumeForecast describes the notification volume trend of a route
+type VolumeForecast struct {
+	Route          string
+	Rate           float64       // Fitted notifications per hour at the latest complete hour
+	Slope          float64       // Change in notifications per hour, per hour
+	HoursOverLimit int           // Hours over the limit in the last day
+	ExceedsIn      time.Duration // Time until the fitted rate reaches the limit; 0 if it already has
+}
+
+// NewVolumeTracker creates a tracker keeping windowHours of history
+func NewVolumeTracker(windowHours int) *VolumeTracker {
+	return &VolumeTracker{
+		window:  windowHours,
+		buckets: make(map[string]map[int64]float64),
+	}
+}
+
+// Record counts a notification sent on route at t
+func (v *VolumeTracker) Record(route string, t time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.buckets[route] == nil {
+		v.buckets[route] = make(map[int64]float64)
+	}
+	hour := t.Unix() / 3600
+	v.buckets[route][hour]++
+
+	for h := range v.buckets[route] {
+		if h <= hour-int64(v.window) {
+			delete(v.buckets[route], h)
+		}
+	}
+}
+
+// Routes returns the routes notifications have been recorded for
+func (v *VolumeTracker) Routes() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	routes := make([]string, 0, len(v.buckets))
+	for route := range v.buckets {
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// Forecast fits a linear trend to the complete hours of a route's history and reports whether
+// the hourly limit is, or within horizon will be, routinely exceeded. It needs at least a day of
+// history; ok is false when there is not enough history or the limit is not at risk.
+func (v *VolumeTracker) Forecast(route string, limit float64, horizon time.Duration, now time.Time) (VolumeForecast, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	buckets := v.buckets[route]
+	if len(buckets) == 0 || limit <= 0 {
+		return VolumeForecast{}, false
+	}
+
+	// Use complete hours from the oldest recorded hour, counting hours without notifications as zero
+	current := now.Unix()/3600 - 1
+	oldest := current
+	for h := range buckets {
+		if h < oldest {
+			oldest = h
+		}
+	}
+	if current-oldest+1 < 24 {
+		return VolumeForecast{}, false
+	}
+	counts := make([]float64, 0, current-oldest+1)
+	for h := oldest; h <= current; h++ {
+		counts = append(counts, buckets[h])
+	}
+
+	// Least squares fit of count = intercept + slope * hour index
+	n := float64(len(counts))
+	var sumX, sumY, sumXY, sumXX float64
+	for i, c := range counts {
+		x := float64(i)
+		sumX += x
+		sumY += c
+		sumXY += x * c
+		sumXX += x * x
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	intercept := (sumY - slope*sumX) / n
+
+	forecast := VolumeForecast{
+		Route: route,
+		Rate:  intercept + slope*(n-1),
+		Slope: slope,
+	}
+	for _, c := range counts[len(counts)-24:] {
+		if c > limit {
+			forecast.HoursOverLimit++
+		}
+	}
+
+	// Over the limit for a quarter of the last day counts as routinely exceeded
+	if forecast.HoursOverLimit >= 6 || forecast.Rate >= limit {
+		return forecast, true
+	}
+	if slope <= 0 {
+		return forecast, false
+	}
+	forecast.ExceedsIn = time.Duration((limit - forecast.Rate) / slope * float64(time.Hour))
+	return forecast, forecast.ExceedsIn <= horizon
+}
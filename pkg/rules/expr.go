@@ -0,0 +1,52 @@
+// Package rules evaluates user-defined expressions over collected cluster state, e.g.
+// `node.MemoryUsagePercent > 90 && len(node.Namespaces) > 10`.
+//
+// Expressions are written in the expr language (https://expr-lang.org): fields are accessed by Go
+// field name, maps and slices are indexed with [], and strings are tested with operators such as
+// contains, startsWith, endsWith and matches or functions such as hasPrefix, lower and upper.
+package rules
+
+import (
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Program is a compiled expression
+type Program struct {
+	source  string
+	program *vm.Program
+}
+
+// Compile parses an expression
+func Compile(source string) (*Program, error) {
+	program, err := expr.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{source: source, program: program}, nil
+}
+
+// String returns the source of the expression
+func (p *Program) String() string {
+	return p.source
+}
+
+// Eval evaluates the expression with the given variables
+func (p *Program) Eval(env map[string]interface{}) (interface{}, error) {
+	return expr.Run(p.program, env)
+}
+
+// Match evaluates an expression that must produce a boolean
+func (p *Program) Match(env map[string]interface{}) (bool, error) {
+	value, err := p.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	matched, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression produced %T, not a boolean", value)
+	}
+	return matched, nil
+}
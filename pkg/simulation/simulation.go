@@ -0,0 +1,302 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// Profile sets the per-cycle probability of each injected failure, per node or pod
+type Profile struct {
+	Name         string
+	CPUSpike     float64 // A node's CPU spikes for a few cycles
+	MemoryLeak   float64 // A node's memory grows steadily until it is exhausted
+	NodeNotReady float64 // A node stops reporting Ready for a few cycles
+	CrashLoop    float64 // A pod restarts every cycle for a while
+	PendingPVC   float64 // A pod's claim stays Pending for a while
+	WarningEvent float64 // A pod emits a warning event
+}
+
+// Profiles are the built-in failure injection profiles
+var Profiles = map[string]Profile{
+	"none":  {Name: "none"},
+	"mixed": {Name: "mixed", CPUSpike: 0.01, MemoryLeak: 0.002, NodeNotReady: 0.001, CrashLoop: 0.002, PendingPVC: 0.0005, WarningEvent: 0.005},
+	"chaos": {Name: "chaos", CPUSpike: 0.1, MemoryLeak: 0.02, NodeNotReady: 0.01, CrashLoop: 0.02, PendingPVC: 0.005, WarningEvent: 0.05},
+}
+
+// Options configures the simulated fleet
+type Options struct {
+	Clusters int
+	Nodes    int // Nodes per cluster
+	Pods     int // Pods per cluster
+	Profile  Profile
+	Seed     int64
+}
+
+// Generator generates evolving synthetic state for a fleet of clusters
+type Generator struct {
+	options  Options
+	clusters map[string]*cluster
+}
+
+// cluster is the evolving state of one simulated cluster
+type cluster struct {
+	mu    sync.Mutex
+	id    string
+	name  string
+	rng   *rand.Rand
+	nodes []*node
+	pods  []*pod
+}
+
+type node struct {
+	name         string
+	pool         string
+	cpuBase      float64
+	memoryBase   float64
+	leak         float64 // Memory added by an ongoing leak
+	spikeLeft    int
+	notReadyLeft int
+}
+
+type pod struct {
+	name        string
+	namespace   string
+	owner       string
+	image       string
+	node        *node
+	restarts    int32
+	crashLeft   int
+	pendingLeft int
+}
+
+// NewGenerator creates a fleet generator
+func NewGenerator(options Options) *Generator {
+	g := &Generator{
+		options:  options,
+		clusters: make(map[string]*cluster),
+	}
+	namespaces := max(1, min(20, options.Pods/20))
+	for c := 1; c <= options.Clusters; c++ {
+		cl := &cluster{
+			id:   fmt.Sprintf("sim-%d", c),
+			name: fmt.Sprintf("simulated-%d", c),
+			rng:  rand.New(rand.NewSource(options.Seed + int64(c))),
+		}
+		for n := 0; n < options.Nodes; n++ {
+			cl.nodes = append(cl.nodes, &node{
+				name:       fmt.Sprintf("%s-node-%d", cl.id, n),
+				pool:       fmt.Sprintf("pool-%d", n%3),
+				cpuBase:    20 + cl.rng.Float64()*30,
+				memoryBase: 30 + cl.rng.Float64()*30,
+			})
+		}
+		for p := 0; p < options.Pods; p++ {
+			app := p % max(1, options.Pods/5)
+			var n *node
+			if len(cl.nodes) > 0 {
+				n = cl.nodes[p%len(cl.nodes)]
+			}
+			cl.pods = append(cl.pods, &pod{
+				name:      fmt.Sprintf("app-%d-%05d", app, p),
+				namespace: fmt.Sprintf("ns-%d", app%namespaces),
+				owner:     fmt.Sprintf("app-%d", app),
+				image:     fmt.Sprintf("registry.example.com/app-%d:1.%d.0", app, app%4),
+				node:      n,
+			})
+		}
+		g.clusters[cl.id] = cl
+	}
+	return g
+}
+
+// Clusters returns the configuration of the simulated clusters
+func (g *Generator) Clusters() []config.ClusterConfig {
+	clusters := make([]config.ClusterConfig, 0, len(g.clusters))
+	for _, cl := range g.clusters {
+		clusters = append(clusters, config.ClusterConfig{
+			ID:        cl.id,
+			Name:      cl.name,
+			Labels:    map[string]string{"simulated": "true"},
+			Resources: []string{"nodes", "pods", "events", "images", "persistentvolumeclaims"},
+			Enabled:   true,
+		})
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].ID < clusters[j].ID })
+	return clusters
+}
+
+// State advances a cluster by one cycle and returns its state. It is safe to call concurrently
+// for different clusters.
+func (g *Generator) State(clusterID string) types.ClusterState {
+	cl, ok := g.clusters[clusterID]
+	if !ok {
+		return types.ClusterState{ClusterID: clusterID}
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	now := time.Now()
+	profile := g.options.Profile
+	state := types.ClusterState{
+		ClusterID:   cl.id,
+		ClusterName: cl.name,
+		Resources:   make(map[string]types.ResourceList),
+	}
+
+	nodeNamespaces := make(map[*node]map[string]bool)
+	for _, p := range cl.pods {
+		if p.node == nil {
+			continue
+		}
+		if nodeNamespaces[p.node] == nil {
+			nodeNamespaces[p.node] = make(map[string]bool)
+		}
+		nodeNamespaces[p.node][p.namespace] = true
+	}
+
+	for _, n := range cl.nodes {
+		// Inject failures
+		if n.spikeLeft == 0 && cl.rng.Float64() < profile.CPUSpike {
+			n.spikeLeft = 3 + cl.rng.Intn(5)
+		}
+		if n.leak == 0 && cl.rng.Float64() < profile.MemoryLeak {
+			n.leak = 0.1
+		}
+		if n.notReadyLeft == 0 && cl.rng.Float64() < profile.NodeNotReady {
+			n.notReadyLeft = 3 + cl.rng.Intn(5)
+			state.Events = append(state.Events, types.ClusterEvent{Type: "Warning", Reason: "NodeNotReady", Message: fmt.Sprintf("Node %s status is now: NodeNotReady", n.name),
+				Timestamp: now, Resource: n.name, Severity: "Warning", Count: 1})
+		}
+
+		cpu := n.cpuBase + cl.rng.NormFloat64()*3
+		if n.spikeLeft > 0 {
+			cpu = 90 + cl.rng.Float64()*10
+			n.spikeLeft--
+		}
+		memory := n.memoryBase + n.leak + cl.rng.NormFloat64()*2
+		if n.leak > 0 {
+			n.leak += 1.5
+			if n.memoryBase+n.leak >= 100 {
+				n.leak = 0 // The node "OOMs" and recovers
+			}
+		}
+		condition := "True"
+		if n.notReadyLeft > 0 {
+			condition = "False"
+			n.notReadyLeft--
+		}
+
+		namespaces := make([]string, 0, len(nodeNamespaces[n]))
+		for ns := range nodeNamespaces[n] {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+		cpu, memory = clamp(cpu), clamp(memory)
+		state.Nodes = append(state.Nodes, types.Node{
+			Name:               n.name,
+			CPUUsage:           fmt.Sprintf("%.0fm", cpu*80),
+			MemoryUsage:        fmt.Sprintf("%.0fMi", memory*327.68),
+			CPUCapacity:        "8",
+			MemoryCapacity:     "32Gi",
+			CPUUsagePercent:    cpu,
+			MemoryUsagePercent: memory,
+			Condition:          "Ready",
+			ConditionStatus:    condition,
+			Namespaces:         namespaces,
+			Labels:             map[string]string{"karpenter.sh/nodepool": n.pool},
+			KubeletVersion:     "v1.29.2",
+		})
+	}
+
+	namespaceSet := make(map[string]bool)
+	images := make(map[string]map[string]*types.ImageUsage) // namespace -> image -> usage
+	for _, p := range cl.pods {
+		namespaceSet[p.namespace] = true
+		resources := state.Resources[p.namespace]
+
+		if p.crashLeft == 0 && cl.rng.Float64() < profile.CrashLoop {
+			p.crashLeft = 5 + cl.rng.Intn(10)
+		}
+		if p.crashLeft > 0 {
+			p.restarts++
+			p.crashLeft--
+			state.Events = append(state.Events, types.ClusterEvent{Type: "Warning", Reason: "BackOff", Message: "Back-off restarting failed container",
+				Timestamp: now, Namespace: p.namespace, Resource: p.name, Severity: "Warning", Count: p.restarts})
+		}
+		if cl.rng.Float64() < profile.WarningEvent {
+			state.Events = append(state.Events, types.ClusterEvent{Type: "Warning", Reason: "Unhealthy", Message: "Readiness probe failed",
+				Timestamp: now, Namespace: p.namespace, Resource: p.name, Severity: "Warning", Count: 1})
+		}
+		if p.pendingLeft == 0 && cl.rng.Float64() < profile.PendingPVC {
+			p.pendingLeft = 5 + cl.rng.Intn(10)
+		}
+
+		nodeName := ""
+		if p.node != nil {
+			nodeName = p.node.name
+		}
+		registry, _, _ := strings.Cut(p.image, "/")
+		tag := p.image[strings.LastIndex(p.image, ":")+1:]
+		resources.Pods = append(resources.Pods, types.Pod{
+			Name:           p.name,
+			Namespace:      p.namespace,
+			NodeName:       nodeName,
+			Status:         "Running",
+			RestartCount:   p.restarts,
+			CPURequests:    "100m",
+			MemoryRequests: "128Mi",
+			OwnerKind:      "Deployment",
+			OwnerName:      p.owner,
+			Labels:         map[string]string{"app": p.owner},
+			Containers:     []types.Container{{Name: "app", Image: p.image, Registry: registry, Tag: tag}},
+		})
+
+		if p.pendingLeft > 0 {
+			p.pendingLeft--
+			resources.PersistentVolumeClaims = append(resources.PersistentVolumeClaims, types.PersistentVolumeClaim{
+				Name: "data-" + p.name, Namespace: p.namespace, Status: "Pending", StorageClassName: "standard", RequestedStorage: "10Gi",
+			})
+		}
+		state.Resources[p.namespace] = resources
+
+		if images[p.namespace] == nil {
+			images[p.namespace] = make(map[string]*types.ImageUsage)
+		}
+		usage, ok := images[p.namespace][p.image]
+		if !ok {
+			usage = &types.ImageUsage{Image: p.image, Registry: registry, Tag: tag}
+			images[p.namespace][p.image] = usage
+		}
+		usage.Pods++
+		if workload := "Deployment/" + p.owner; len(usage.Workloads) == 0 || usage.Workloads[len(usage.Workloads)-1] != workload {
+			usage.Workloads = append(usage.Workloads, workload)
+		}
+	}
+
+	for ns, usages := range images {
+		resources := state.Resources[ns]
+		for _, usage := range usages {
+			resources.Images = append(resources.Images, *usage)
+		}
+		sort.Slice(resources.Images, func(i, j int) bool { return resources.Images[i].Image < resources.Images[j].Image })
+		state.Resources[ns] = resources
+	}
+	for ns := range namespaceSet {
+		state.Namespaces = append(state.Namespaces, ns)
+	}
+	sort.Strings(state.Namespaces)
+	return state
+}
+
+// clamp limits a percentage to 0-100
+func clamp(percent float64) float64 {
+	return math.Max(0, math.Min(100, percent))
+}
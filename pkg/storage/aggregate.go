@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// Fields stored alerts can be grouped by
+const (
+	GroupByType      = "type"
+	GroupByCluster   = "cluster"
+	GroupByNamespace = "namespace"
+	GroupBySeverity  = "severity"
+)
+
+// ErrAggregationUnsupported is returned by AggregateAlerts for storages that cannot aggregate
+var ErrAggregationUnsupported = errors.New("storage does not support aggregating alerts")
+
+// AggregateQuery selects stored alerts and how they are counted
+type AggregateQuery struct {
+	Start, End time.Time
+	Cluster    string        // Only alerts of this cluster name, if set
+	Namespace  string        // Only alerts of this namespace, if set
+	GroupBy    []string      // GroupBy* fields
+	Bucket     time.Duration // Also groups by time buckets of this size, if set
+}
+
+// Validate checks the group by fields and time range of a query
+func (q AggregateQuery) Validate() error {
+	for _, field := range q.GroupBy {
+		switch field {
+		case GroupByType, GroupByCluster, GroupByNamespace, GroupBySeverity:
+		default:
+			return fmt.Errorf("invalid group by field: %s (expected type, cluster, namespace or severity)", field)
+		}
+	}
+	if !q.End.After(q.Start) {
+		return fmt.Errorf("invalid time range: %s - %s", q.Start.Format(time.RFC3339), q.End.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// AlertCount is the number of stored alerts of a group
+type AlertCount struct {
+	Group  map[string]string `json:"group,omitempty"`  // Group by field -> value
+	Bucket *time.Time        `json:"bucket,omitempty"` // Start of the time bucket
+	Count  int               `json:"count"`
+}
+
+// Aggregator is implemented by storages that can count stored alerts
+type Aggregator interface {
+	AggregateAlerts(query AggregateQuery) ([]AlertCount, error)
+}
+
+// AggregateAlerts counts the stored alerts matching query, per group
+func AggregateAlerts(storage Storage, query AggregateQuery) ([]AlertCount, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if aggregator, ok := storage.(Aggregator); ok {
+		return aggregator.AggregateAlerts(query)
+	}
+	return nil, ErrAggregationUnsupported
+}
+
+// aggregate counts the anomalies matching query per group, ordered by bucket then descending count
+func aggregate(anomalies []types.Anomaly, query AggregateQuery) []AlertCount {
+	counts := make(map[string]*AlertCount)
+	for _, anomaly := range anomalies {
+		if anomaly.Timestamp.Before(query.Start) || anomaly.Timestamp.After(query.End) ||
+			(query.Cluster != "" && anomaly.ClusterName != query.Cluster) ||
+			(query.Namespace != "" && anomaly.Namespace != query.Namespace) {
+			continue
+		}
+
+		group := make(map[string]string, len(query.GroupBy))
+		key := make([]string, 0, len(query.GroupBy)+1)
+		for _, field := range query.GroupBy {
+			value := groupValue(anomaly, field)
+			group[field] = value
+			key = append(key, value)
+		}
+		var bucket *time.Time
+		if query.Bucket > 0 {
+			start := anomaly.Timestamp.Truncate(query.Bucket).UTC()
+			bucket = &start
+			key = append(key, start.Format(time.RFC3339))
+		}
+
+		k := strings.Join(key, "\x00")
+		if count, ok := counts[k]; ok {
+			count.Count++
+			continue
+		}
+		counts[k] = &AlertCount{Group: group, Bucket: bucket, Count: 1}
+	}
+
+	result := make([]AlertCount, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, *count)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Bucket != nil && !result[i].Bucket.Equal(*result[j].Bucket) {
+			return result[i].Bucket.Before(*result[j].Bucket)
+		}
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return fmt.Sprint(result[i].Group) < fmt.Sprint(result[j].Group)
+	})
+	return result
+}
+
+// groupValue returns the value of a group by field of an anomaly
+func groupValue(anomaly types.Anomaly, field string) string {
+	switch field {
+	case GroupByType:
+		return anomaly.Type
+	case GroupByCluster:
+		return anomaly.ClusterName
+	case GroupByNamespace:
+		return anomaly.Namespace
+	case GroupBySeverity:
+		return string(anomaly.Severity)
+	}
+	return ""
+}
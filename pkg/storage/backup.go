@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// BackupFormat identifies backup files in their header
+const BackupFormat = "huginn-alerts"
+
+// BackupVersion is the version of the backup format written
+const BackupVersion = 1
+
+// ErrDumpUnsupported is returned by DumpAlerts for storages that cannot list every stored alert
+var ErrDumpUnsupported = errors.New("storage does not support dumping alerts")
+
+// Dumper is implemented by storages that can list every stored alert along with its vector
+type Dumper interface {
+	DumpAlerts(fn func(StoreRequest) error) error
+}
+
+// DumpAlerts calls fn with every stored alert, stopping at the first error fn returns
+func DumpAlerts(storage Storage, fn func(StoreRequest) error) error {
+	if dumper, ok := storage.(Dumper); ok {
+		return dumper.DumpAlerts(fn)
+	}
+	return ErrDumpUnsupported
+}
+
+// BackupHeader is the first line of a backup file
+type BackupHeader struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Source     string    `json:"source,omitempty"`
+}
+
+// backupRecord is an alert of a backup file: the anomaly, with its correlated events, and its
+// vector
+type backupRecord struct {
+	Anomaly types.Anomaly `json:"anomaly"`
+	Vector  []float32     `json:"vector,omitempty"`
+}
+
+// WriteBackup writes every alert of the storage to w as JSON lines: a header naming the source
+// storage type, then one alert per line. It returns the number of alerts written.
+func WriteBackup(w io.Writer, storage Storage, source string) (int, error) {
+	encoder := json.NewEncoder(w)
+	header := BackupHeader{Format: BackupFormat, Version: BackupVersion, ExportedAt: time.Now().UTC(), Source: source}
+	if err := encoder.Encode(header); err != nil {
+		return 0, fmt.Errorf("failed to write backup header: %v", err)
+	}
+
+	count := 0
+	err := DumpAlerts(storage, func(request StoreRequest) error {
+		anomaly := request.Anomaly
+		if len(request.Events) > 0 {
+			anomaly.Events = request.Events
+		}
+		record := backupRecord{Anomaly: anomaly, Vector: request.Vector}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to write alert %s: %v", request.Anomaly.ID, err)
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// ReadBackup reads a backup written by WriteBackup, calling fn with every alert in order. It
+// returns the backup's header.
+func ReadBackup(r io.Reader, fn func(StoreRequest) error) (BackupHeader, error) {
+	decoder := json.NewDecoder(r)
+
+	var header BackupHeader
+	if err := decoder.Decode(&header); err != nil {
+		return header, fmt.Errorf("failed to read backup header: %v", err)
+	}
+	if header.Format != BackupFormat {
+		return header, fmt.Errorf("not a huginn alert backup (format %q)", header.Format)
+	}
+	if header.Version > BackupVersion {
+		return header, fmt.Errorf("unsupported backup version %d (expected at most %d)", header.Version, BackupVersion)
+	}
+
+	for line := 2; ; line++ {
+		var record backupRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return header, nil
+		}
+		if err != nil {
+			return header, fmt.Errorf("failed to read alert %d of backup: %v", line-1, err)
+		}
+		if err := fn(StoreRequest{Anomaly: record.Anomaly, Events: record.Anomaly.Events, Vector: record.Vector}); err != nil {
+			return header, err
+		}
+	}
+}
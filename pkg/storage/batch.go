@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// BatchedStorage buffers stored alerts and writes them in one request once the buffer holds
+// size alerts or interval has elapsed, so incidents producing hundreds of anomalies per cycle
+// do not cost a request each
+type BatchedStorage struct {
+	Storage
+	size    int
+	mu      sync.Mutex
+	pending []StoreRequest
+	onFlush func(alerts []StoreRequest, duration time.Duration, err error)
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewBatchedStorage wraps a storage with write batching and starts flushing it every interval
+func NewBatchedStorage(storage Storage, size int, interval time.Duration) *BatchedStorage {
+	b := &BatchedStorage{
+		Storage: storage,
+		size:    size,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+// SetFlushHandler sets the function called with the outcome of every flush. Alerts of a failed
+// flush are passed to it and not retried by the batch.
+func (b *BatchedStorage) SetFlushHandler(fn func(alerts []StoreRequest, duration time.Duration, err error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onFlush = fn
+}
+
+// StoreAlert buffers an alert, flushing the buffer if it is full. Write failures are reported
+// to the flush handler rather than returned.
+func (b *BatchedStorage) StoreAlert(alert StoreRequest) error {
+	b.mu.Lock()
+	b.pending = append(b.pending, alert)
+	full := len(b.pending) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		b.Flush()
+	}
+	return nil
+}
+
+// StoreAlerts writes alerts right away in one request, for callers that batch them already
+func (b *BatchedStorage) StoreAlerts(alerts []StoreRequest) error {
+	return StoreAll(b.Storage, alerts)
+}
+
+// SearchHybrid searches the wrapped storage by vector and keywords
+func (b *BatchedStorage) SearchHybrid(query string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchHybrid(b.Storage, query, vector, limit)
+}
+
+// SearchSimilarAlertsInCluster searches the wrapped storage for alerts of a cluster
+func (b *BatchedStorage) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchSimilarAlertsInCluster(b.Storage, cluster, vector, limit)
+}
+
+// SearchSimilarAlertsOfModel searches the wrapped storage for alerts embedded by a model
+func (b *BatchedStorage) SearchSimilarAlertsOfModel(model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchSimilarAlertsOfModel(b.Storage, model, cluster, vector, limit)
+}
+
+// AggregateAlerts counts the alerts stored in the wrapped storage, not those still buffered
+func (b *BatchedStorage) AggregateAlerts(query AggregateQuery) ([]AlertCount, error) {
+	return AggregateAlerts(b.Storage, query)
+}
+
+// StoreObservations stores the observations of a cycle in the wrapped storage directly, as they
+// are written in bulk already
+func (b *BatchedStorage) StoreObservations(state types.ClusterState) error {
+	return StoreObservations(b.Storage, state)
+}
+
+// Flush writes the buffered alerts
+func (b *BatchedStorage) Flush() {
+	b.mu.Lock()
+	alerts := b.pending
+	b.pending = nil
+	onFlush := b.onFlush
+	b.mu.Unlock()
+
+	if len(alerts) == 0 {
+		return
+	}
+	start := time.Now()
+	err := StoreAll(b.Storage, alerts)
+	if onFlush != nil {
+		onFlush(alerts, time.Since(start), err)
+	}
+}
+
+// Close stops the periodic flush and writes the buffered alerts
+func (b *BatchedStorage) Close() {
+	close(b.stop)
+	<-b.done
+	b.Flush()
+}
+
+// run flushes the buffer every interval until the batch is closed
+func (b *BatchedStorage) run(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.Flush()
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// ErrCircuitOpen is returned instead of calling the backend while its circuit is open
+var ErrCircuitOpen = errors.New("storage circuit is open")
+
+// CircuitBreaker pings a storage backend every interval and opens its circuit after threshold
+// consecutive failures, failing calls fast until a ping succeeds again. While the circuit is open
+// the client is recreated on every check, reconnecting to a backend that was restarted or moved.
+type CircuitBreaker struct {
+	config    StorageConfig
+	threshold int
+	mu        sync.RWMutex
+	client    Storage
+	failures  int
+	open      bool
+	lastErr   error
+	onChange  func(healthy bool, err error)
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewCircuitBreaker wraps the client of config with a circuit breaker and starts checking it
+// every interval
+func NewCircuitBreaker(client Storage, config StorageConfig, interval time.Duration, threshold int) *CircuitBreaker {
+	b := &CircuitBreaker{
+		config:    config,
+		threshold: threshold,
+		client:    client,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+// SetStateHandler sets the function called whenever the circuit opens or closes
+func (b *CircuitBreaker) SetStateHandler(fn func(healthy bool, err error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onChange = fn
+}
+
+// Healthy reports whether the circuit is closed, i.e. the backend passed its last checks
+func (b *CircuitBreaker) Healthy() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return !b.open
+}
+
+// Err returns why the circuit is open, or nil if it is closed
+func (b *CircuitBreaker) Err() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.open {
+		return nil
+	}
+	return fmt.Errorf("%w after %d failed checks: %v", ErrCircuitOpen, b.failures, b.lastErr)
+}
+
+// current returns the client, or ErrCircuitOpen if the circuit is open
+func (b *CircuitBreaker) current() (Storage, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.open {
+		return nil, ErrCircuitOpen
+	}
+	return b.client, nil
+}
+
+// Check pings the backend, reconnecting first if the circuit is open, and updates the circuit
+func (b *CircuitBreaker) Check() error {
+	b.mu.RLock()
+	client, open := b.client, b.open
+	b.mu.RUnlock()
+
+	var err error
+	if open {
+		// The new client is only used once it answers
+		var reconnected Storage
+		if reconnected, err = NewStorage(b.config); err == nil {
+			if err = reconnected.Ping(); err == nil {
+				client = reconnected
+			}
+		}
+	} else {
+		err = client.Ping()
+	}
+
+	b.mu.Lock()
+	b.client = client
+	wasOpen := b.open
+	if err != nil {
+		b.failures++
+		b.lastErr = err
+		b.open = b.failures >= b.threshold
+	} else {
+		b.failures = 0
+		b.lastErr = nil
+		b.open = false
+	}
+	changed, onChange := wasOpen != b.open, b.onChange
+	b.mu.Unlock()
+
+	if changed && onChange != nil {
+		onChange(!b.open, err)
+	}
+	return err
+}
+
+// Close stops checking the backend
+func (b *CircuitBreaker) Close() {
+	close(b.stop)
+	<-b.done
+}
+
+// run checks the backend every interval until the breaker is closed
+func (b *CircuitBreaker) run(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.Check()
+		}
+	}
+}
+
+// StoreAlert stores an alert unless the circuit is open
+func (b *CircuitBreaker) StoreAlert(alert StoreRequest) error {
+	client, err := b.current()
+	if err != nil {
+		return err
+	}
+	return client.StoreAlert(alert)
+}
+
+// StoreAlerts stores alerts unless the circuit is open
+func (b *CircuitBreaker) StoreAlerts(alerts []StoreRequest) error {
+	client, err := b.current()
+	if err != nil {
+		return err
+	}
+	return StoreAll(client, alerts)
+}
+
+// SearchSimilarAlerts searches alerts unless the circuit is open
+func (b *CircuitBreaker) SearchSimilarAlerts(vector []float32, limit int) ([]types.Anomaly, error) {
+	client, err := b.current()
+	if err != nil {
+		return nil, err
+	}
+	return client.SearchSimilarAlerts(vector, limit)
+}
+
+// SearchHybrid searches alerts by vector and keywords unless the circuit is open
+func (b *CircuitBreaker) SearchHybrid(query string, vector []float32, limit int) ([]types.Anomaly, error) {
+	client, err := b.current()
+	if err != nil {
+		return nil, err
+	}
+	return SearchHybrid(client, query, vector, limit)
+}
+
+// SearchSimilarAlertsInCluster searches alerts of a cluster unless the circuit is open
+func (b *CircuitBreaker) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	client, err := b.current()
+	if err != nil {
+		return nil, err
+	}
+	return SearchSimilarAlertsInCluster(client, cluster, vector, limit)
+}
+
+// SearchSimilarAlertsOfModel searches alerts embedded by a model unless the circuit is open
+func (b *CircuitBreaker) SearchSimilarAlertsOfModel(model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	client, err := b.current()
+	if err != nil {
+		return nil, err
+	}
+	return SearchSimilarAlertsOfModel(client, model, cluster, vector, limit)
+}
+
+// AggregateAlerts counts stored alerts unless the circuit is open
+func (b *CircuitBreaker) AggregateAlerts(query AggregateQuery) ([]AlertCount, error) {
+	client, err := b.current()
+	if err != nil {
+		return nil, err
+	}
+	return AggregateAlerts(client, query)
+}
+
+// StoreObservations stores the observations of a cycle unless the circuit is open
+func (b *CircuitBreaker) StoreObservations(state types.ClusterState) error {
+	client, err := b.current()
+	if err != nil {
+		return err
+	}
+	return StoreObservations(client, state)
+}
+
+// FindByFingerprint looks up an alert by fingerprint unless the circuit is open
+func (b *CircuitBreaker) FindByFingerprint(fingerprint string, since time.Time) (*types.Anomaly, error) {
+	client, err := b.current()
+	if err != nil {
+		return nil, err
+	}
+	return FindByFingerprint(client, fingerprint, since)
+}
+
+// SetFeedback labels a stored alert unless the circuit is open
+func (b *CircuitBreaker) SetFeedback(id string, feedback string) error {
+	client, err := b.current()
+	if err != nil {
+		return err
+	}
+	return client.SetFeedback(id, feedback)
+}
+
+// Ping reports the state of the circuit as of the last check, without reaching the backend
+func (b *CircuitBreaker) Ping() error {
+	return b.Err()
+}
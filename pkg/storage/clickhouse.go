@@ -0,0 +1,668 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// ClickHouseOptions holds the connection and table settings of the ClickHouse backend
+type ClickHouseOptions struct {
+	URL               string // HTTP interface, e.g. http://localhost:8123
+	User              string
+	Password          string
+	Database          string
+	Table             string // Alerts, one row per alert
+	ObservationsTable string // Node and pod metrics, one row per resource per cycle
+	Distance          string // cosine, l2 or dot
+}
+
+// clickHouseTime is the layout of DateTime64(3) values written through the HTTP interface. They
+// are parsed with time.DateTime, which accepts the fractional seconds of either precision.
+const clickHouseTime = "2006-01-02 15:04:05.000"
+
+// clickHouseAlertColumns are the columns of the alerts table. Replacing rows by alert ID lets
+// feedback and deduplicated occurrences be written as new rows instead of mutations.
+const clickHouseAlertColumns = `
+	alertid String,
+	fingerprint String,
+	cluster_id String,
+	cluster LowCardinality(String),
+	type LowCardinality(String),
+	resource_type LowCardinality(String),
+	resource String,
+	namespace LowCardinality(String),
+	node_name String,
+	namespaces_on_this_node String,
+	severity LowCardinality(String),
+	score Float64,
+	description String,
+	value Float64,
+	threshold Float64,
+	timestamp DateTime64(3, 'UTC'),
+	cycle Int64,
+	feedback LowCardinality(String),
+	labels Map(String, String),
+	correlation_keys Map(String, String),
+	metadata String,
+	events String,
+	vector Array(Float32),
+	updated_at DateTime64(3, 'UTC'),
+	embedding_provider LowCardinality(String),
+	embedding_model LowCardinality(String),
+	embedding_dimension UInt32,
+	INDEX timestamp_idx timestamp TYPE minmax GRANULARITY 4`
+
+// clickHouseAddedAlertColumns are the columns added to the alerts table since it was first
+// released, added to existing tables when the client starts
+var clickHouseAddedAlertColumns = []string{
+	"embedding_provider LowCardinality(String)",
+	"embedding_model LowCardinality(String)",
+	"embedding_dimension UInt32",
+}
+
+// clickHouseObservationColumns are the columns of the observations table
+const clickHouseObservationColumns = `
+	cluster_id String,
+	cluster LowCardinality(String),
+	cycle Int64,
+	timestamp DateTime64(3, 'UTC'),
+	resource_type LowCardinality(String),
+	namespace LowCardinality(String),
+	name String,
+	node_name String,
+	status LowCardinality(String),
+	cpu_usage_percent Float64,
+	memory_usage_percent Float64,
+	nodefs_usage_percent Float64,
+	imagefs_usage_percent Float64,
+	disk_pressure Bool,
+	restart_count UInt32,
+	owner_kind LowCardinality(String),
+	owner_name String,
+	labels Map(String, String)`
+
+// clickHouseAlert is a row of the alerts table
+type clickHouseAlert struct {
+	AlertID              string            `json:"alertid"`
+	Fingerprint          string            `json:"fingerprint"`
+	ClusterID            string            `json:"cluster_id"`
+	Cluster              string            `json:"cluster"`
+	Type                 string            `json:"type"`
+	ResourceType         string            `json:"resource_type"`
+	Resource             string            `json:"resource"`
+	Namespace            string            `json:"namespace"`
+	NodeName             string            `json:"node_name"`
+	NamespacesOnThisNode string            `json:"namespaces_on_this_node"`
+	Severity             string            `json:"severity"`
+	Score                float64           `json:"score"`
+	Description          string            `json:"description"`
+	Value                float64           `json:"value"`
+	Threshold            float64           `json:"threshold"`
+	Timestamp            string            `json:"timestamp"`
+	Cycle                int64             `json:"cycle"`
+	Feedback             string            `json:"feedback"`
+	Labels               map[string]string `json:"labels"`
+	CorrelationKeys      map[string]string `json:"correlation_keys"`
+	Metadata             string            `json:"metadata"` // JSON
+	Events               string            `json:"events"`   // JSON
+	Vector               []float32         `json:"vector,omitempty"`
+	UpdatedAt            string            `json:"updated_at,omitempty"`
+	EmbeddingProvider    string            `json:"embedding_provider"`
+	EmbeddingModel       string            `json:"embedding_model"`
+	EmbeddingDimension   int               `json:"embedding_dimension"`
+}
+
+// clickHouseObservation is a row of the observations table
+type clickHouseObservation struct {
+	ClusterID           string            `json:"cluster_id"`
+	Cluster             string            `json:"cluster"`
+	Cycle               int64             `json:"cycle"`
+	Timestamp           string            `json:"timestamp"`
+	ResourceType        string            `json:"resource_type"`
+	Namespace           string            `json:"namespace"`
+	Name                string            `json:"name"`
+	NodeName            string            `json:"node_name"`
+	Status              string            `json:"status"`
+	CPUUsagePercent     float64           `json:"cpu_usage_percent"`
+	MemoryUsagePercent  float64           `json:"memory_usage_percent"`
+	NodeFSUsagePercent  float64           `json:"nodefs_usage_percent"`
+	ImageFSUsagePercent float64           `json:"imagefs_usage_percent"`
+	DiskPressure        bool              `json:"disk_pressure"`
+	RestartCount        int32             `json:"restart_count"`
+	OwnerKind           string            `json:"owner_kind"`
+	OwnerName           string            `json:"owner_name"`
+	Labels              map[string]string `json:"labels"`
+}
+
+// ClickHouseClient implements the Storage interface using ClickHouse, keeping alerts and the
+// metric observations of every cycle in wide tables for long-range analytics. Similarity searches
+// scan the stored vectors, which suits the alert volumes of a fleet rather than low latency.
+type ClickHouseClient struct {
+	url          string
+	user         string
+	password     string
+	database     string
+	table        string
+	observations string
+	distance     string // Distance function of similarity searches
+	client       *http.Client
+}
+
+// NewClickHouseClient creates a ClickHouse client, creating the database and tables if they do
+// not exist
+func NewClickHouseClient(options ClickHouseOptions) (*ClickHouseClient, error) {
+	distance := "cosineDistance(vector, {vector:Array(Float32)})"
+	switch strings.ToLower(options.Distance) {
+	case "euclid", "euclidean", "l2":
+		distance = "L2Distance(vector, {vector:Array(Float32)})"
+	case "dot", "dotproduct", "ip":
+		distance = "-dotProduct(vector, {vector:Array(Float32)})"
+	}
+
+	client := &ClickHouseClient{
+		url:          strings.TrimSuffix(options.URL, "/"),
+		user:         options.User,
+		password:     options.Password,
+		database:     options.Database,
+		table:        options.Table,
+		observations: options.ObservationsTable,
+		distance:     distance,
+		client:       &http.Client{Timeout: 30 * time.Second},
+	}
+
+	if err := client.ensureTables(); err != nil {
+		return nil, fmt.Errorf("failed to ensure tables exist: %v", err)
+	}
+	return client, nil
+}
+
+// ensureTables creates the database, alerts and observations tables if they do not exist
+func (c *ClickHouseClient) ensureTables() error {
+	statements := []string{
+		fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", clickHouseIdentifier(c.database)),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s\n) ENGINE = ReplacingMergeTree(updated_at) ORDER BY alertid",
+			c.qualified(c.table), clickHouseAlertColumns),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s\n) ENGINE = MergeTree PARTITION BY toYYYYMM(timestamp) ORDER BY (cluster, resource_type, name, timestamp)",
+			c.qualified(c.observations), clickHouseObservationColumns),
+	}
+	for _, column := range clickHouseAddedAlertColumns {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s", c.qualified(c.table), column))
+	}
+	for _, statement := range statements {
+		if _, err := c.query(statement, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StoreAlert stores an alert in ClickHouse
+func (c *ClickHouseClient) StoreAlert(alert StoreRequest) error {
+	return c.StoreAlerts([]StoreRequest{alert})
+}
+
+// StoreAlerts stores alerts in ClickHouse with a single insert
+func (c *ClickHouseClient) StoreAlerts(alerts []StoreRequest) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+	now := time.Now().UTC().Format(clickHouseTime)
+	rows := make([]interface{}, len(alerts))
+	for i, alert := range alerts {
+		row, err := clickHouseAlertRow(alert)
+		if err != nil {
+			return err
+		}
+		row.UpdatedAt = now
+		rows[i] = row
+	}
+	if err := c.insert(c.table, rows); err != nil {
+		return fmt.Errorf("failed to store alerts in ClickHouse: %v", err)
+	}
+	return nil
+}
+
+// clickHouseAlertRow converts an alert to a row of the alerts table
+func clickHouseAlertRow(alert StoreRequest) (clickHouseAlert, error) {
+	anomaly := alert.Anomaly
+	metadata, err := json.Marshal(alert.metadata())
+	if err != nil {
+		return clickHouseAlert{}, fmt.Errorf("failed to marshal metadata: %v", err)
+	}
+	events, err := json.Marshal(alert.Events)
+	if err != nil {
+		return clickHouseAlert{}, fmt.Errorf("failed to marshal events: %v", err)
+	}
+	id := anomaly.ID
+	if id == "" {
+		id = pointID(id)
+	}
+	return clickHouseAlert{
+		AlertID:              id,
+		Fingerprint:          anomaly.Fingerprint,
+		ClusterID:            anomaly.ClusterID,
+		Cluster:              anomaly.ClusterName,
+		Type:                 anomaly.Type,
+		ResourceType:         anomaly.ResourceType,
+		Resource:             anomaly.Resource,
+		Namespace:            anomaly.Namespace,
+		NodeName:             anomaly.NodeName,
+		NamespacesOnThisNode: anomaly.NamespacesOnThisNode,
+		Severity:             string(anomaly.Severity),
+		Score:                anomaly.Score,
+		Description:          anomaly.Description,
+		Value:                anomaly.Value,
+		Threshold:            anomaly.Threshold,
+		Timestamp:            observedAt(anomaly).UTC().Format(clickHouseTime),
+		Cycle:                anomaly.Cycle,
+		Feedback:             anomaly.Feedback,
+		Labels:               anomaly.Labels,
+		CorrelationKeys:      anomaly.CorrelationKeys,
+		Metadata:             string(metadata),
+		Events:               string(events),
+		Vector:               alert.Vector,
+		EmbeddingProvider:    anomaly.Embedding.Provider,
+		EmbeddingModel:       anomaly.Embedding.Model,
+		EmbeddingDimension:   anomaly.Embedding.Dimension,
+	}, nil
+}
+
+// anomaly converts a row of the alerts table back to an anomaly
+func (r clickHouseAlert) anomaly() types.Anomaly {
+	anomaly := types.Anomaly{
+		ID:                   r.AlertID,
+		Fingerprint:          r.Fingerprint,
+		ClusterID:            r.ClusterID,
+		ClusterName:          r.Cluster,
+		Type:                 r.Type,
+		ResourceType:         r.ResourceType,
+		Resource:             r.Resource,
+		Namespace:            r.Namespace,
+		NodeName:             r.NodeName,
+		NamespacesOnThisNode: r.NamespacesOnThisNode,
+		Severity:             types.Severity(r.Severity).Normalize(),
+		Score:                r.Score,
+		Description:          r.Description,
+		Value:                r.Value,
+		Threshold:            r.Threshold,
+		Cycle:                r.Cycle,
+		Feedback:             r.Feedback,
+		Labels:               r.Labels,
+		CorrelationKeys:      r.CorrelationKeys,
+		Embedding: types.EmbeddingModel{
+			Provider:  r.EmbeddingProvider,
+			Model:     r.EmbeddingModel,
+			Dimension: r.EmbeddingDimension,
+		},
+	}
+	if t, err := time.ParseInLocation(time.DateTime, r.Timestamp, time.UTC); err == nil {
+		anomaly.Timestamp = t
+	}
+	if r.Metadata != "" {
+		json.Unmarshal([]byte(r.Metadata), &anomaly.Metadata)
+	}
+	if r.Events != "" {
+		json.Unmarshal([]byte(r.Events), &anomaly.Events)
+	}
+	return anomaly
+}
+
+// StoreObservations stores the node and pod metrics of an observed cluster state with a single
+// insert
+func (c *ClickHouseClient) StoreObservations(state types.ClusterState) error {
+	timestamp := state.CycleEnd
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	base := clickHouseObservation{
+		ClusterID: state.ClusterID,
+		Cluster:   state.ClusterName,
+		Cycle:     state.Cycle,
+		Timestamp: timestamp.UTC().Format(clickHouseTime),
+	}
+
+	var rows []interface{}
+	for _, node := range state.Nodes {
+		row := base
+		row.ResourceType = "node"
+		row.Name = node.Name
+		row.NodeName = node.Name
+		row.Status = node.Status
+		row.CPUUsagePercent = node.CPUUsagePercent
+		row.MemoryUsagePercent = node.MemoryUsagePercent
+		row.NodeFSUsagePercent = node.NodeFSUsagePercent
+		row.ImageFSUsagePercent = node.ImageFSUsagePercent
+		row.DiskPressure = node.DiskPressure
+		row.Labels = node.Labels
+		rows = append(rows, row)
+	}
+	namespaces := make([]string, 0, len(state.Resources))
+	for namespace := range state.Resources {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		for _, pod := range state.Resources[namespace].Pods {
+			row := base
+			row.ResourceType = "pod"
+			row.Namespace = namespace
+			row.Name = pod.Name
+			row.NodeName = pod.NodeName
+			row.Status = pod.Status
+			row.RestartCount = pod.RestartCount
+			row.OwnerKind = pod.OwnerKind
+			row.OwnerName = pod.OwnerName
+			row.Labels = pod.Labels
+			rows = append(rows, row)
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := c.insert(c.observations, rows); err != nil {
+		return fmt.Errorf("failed to store observations in ClickHouse: %v", err)
+	}
+	return nil
+}
+
+// SearchSimilarAlerts searches for similar alerts in ClickHouse
+func (c *ClickHouseClient) SearchSimilarAlerts(vector []float32, limit int) ([]types.Anomaly, error) {
+	return c.search("", nil, vector, limit)
+}
+
+// SearchSimilarAlertsInCluster searches for similar alerts of a cluster in ClickHouse
+func (c *ClickHouseClient) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return c.search(cluster, nil, vector, limit)
+}
+
+// SearchSimilarAlertsOfModel searches for similar alerts embedded by model, of a cluster if set,
+// in ClickHouse. Alerts stored without a recorded model match any model.
+func (c *ClickHouseClient) SearchSimilarAlertsOfModel(model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return c.search(cluster, &model, vector, limit)
+}
+
+// search returns the alerts closest to vector, of a cluster if it is not empty. If model is set,
+// only alerts embedded by it are searched.
+func (c *ClickHouseClient) search(cluster string, model *types.EmbeddingModel, vector []float32, limit int) ([]types.Anomaly, error) {
+	where := "length(vector) = length({vector:Array(Float32)})"
+	params := map[string]string{"vector": clickHouseArray(vector), "limit": strconv.Itoa(limit)}
+	if cluster != "" {
+		where += " AND cluster = {cluster:String}"
+		params["cluster"] = cluster
+	}
+	if model != nil {
+		where += " AND (embedding_model = '' OR (embedding_provider = {provider:String} AND embedding_model = {model:String} AND embedding_dimension = {dimension:UInt32}))"
+		params["provider"] = model.Provider
+		params["model"] = model.Model
+		params["dimension"] = strconv.Itoa(model.Dimension)
+	}
+	return c.selectAlerts(fmt.Sprintf("WHERE %s ORDER BY %s LIMIT {limit:UInt32}", where, c.distance), params)
+}
+
+// FindByFingerprint returns the most recent alert with the fingerprint stored since the given
+// time, or nil if there is none
+func (c *ClickHouseClient) FindByFingerprint(fingerprint string, since time.Time) (*types.Anomaly, error) {
+	anomalies, err := c.selectAlerts("WHERE fingerprint = {fingerprint:String} AND timestamp >= {since:DateTime64(3, 'UTC')} ORDER BY timestamp DESC LIMIT 1",
+		map[string]string{"fingerprint": fingerprint, "since": since.UTC().Format(clickHouseTime)})
+	if err != nil {
+		return nil, err
+	}
+	if len(anomalies) == 0 {
+		return nil, nil
+	}
+	return &anomalies[0], nil
+}
+
+// selectAlerts returns the latest version of the alerts selected by the clauses following FROM
+func (c *ClickHouseClient) selectAlerts(clauses string, params map[string]string) ([]types.Anomaly, error) {
+	statement := fmt.Sprintf("SELECT * EXCEPT (vector, updated_at) FROM %s FINAL %s FORMAT JSONEachRow", c.qualified(c.table), clauses)
+	body, err := c.query(statement, params, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search alerts in ClickHouse: %v", err)
+	}
+
+	var anomalies []types.Anomaly
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var row clickHouseAlert
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return nil, fmt.Errorf("failed to decode ClickHouse row: %v", err)
+		}
+		anomalies = append(anomalies, row.anomaly())
+	}
+	return anomalies, scanner.Err()
+}
+
+// DumpAlerts calls fn with every stored alert and its vector, paging through the table in alert
+// ID order
+func (c *ClickHouseClient) DumpAlerts(fn func(StoreRequest) error) error {
+	statement := fmt.Sprintf("SELECT * EXCEPT (updated_at) FROM %s FINAL WHERE alertid > {after:String} ORDER BY alertid LIMIT 1000 FORMAT JSONEachRow",
+		c.qualified(c.table))
+	after := ""
+	for {
+		body, err := c.query(statement, map[string]string{"after": after}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to dump alerts from ClickHouse: %v", err)
+		}
+		rows := 0
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var row clickHouseAlert
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				return fmt.Errorf("failed to decode ClickHouse row: %v", err)
+			}
+			rows++
+			after = row.AlertID
+			anomaly := row.anomaly()
+			if err := fn(StoreRequest{Anomaly: anomaly, Events: anomaly.Events, Vector: row.Vector}); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		if rows == 0 {
+			return nil
+		}
+	}
+}
+
+// AggregateAlerts counts the stored alerts matching query per group in ClickHouse
+func (c *ClickHouseClient) AggregateAlerts(query AggregateQuery) ([]AlertCount, error) {
+	columns := map[string]string{
+		GroupByType:      "type",
+		GroupByCluster:   "cluster",
+		GroupByNamespace: "namespace",
+		GroupBySeverity:  "severity",
+	}
+	var keys, selects []string
+	for _, field := range query.GroupBy {
+		keys = append(keys, columns[field])
+		selects = append(selects, columns[field])
+	}
+	where := []string{"timestamp >= {start:DateTime64(3, 'UTC')}", "timestamp <= {end:DateTime64(3, 'UTC')}"}
+	params := map[string]string{
+		"start": query.Start.UTC().Format(clickHouseTime),
+		"end":   query.End.UTC().Format(clickHouseTime),
+	}
+	if query.Bucket > 0 {
+		keys = append(keys, "bucket")
+		selects = append(selects, "toStartOfInterval(timestamp, INTERVAL {bucket:UInt32} SECOND) AS bucket")
+		params["bucket"] = strconv.Itoa(int(query.Bucket / time.Second))
+	}
+	if query.Cluster != "" {
+		where = append(where, "cluster = {cluster:String}")
+		params["cluster"] = query.Cluster
+	}
+	if query.Namespace != "" {
+		where = append(where, "namespace = {namespace:String}")
+		params["namespace"] = query.Namespace
+	}
+
+	selects = append(selects, "count() AS count")
+	statement := fmt.Sprintf("SELECT %s FROM %s FINAL WHERE %s", strings.Join(selects, ", "), c.qualified(c.table), strings.Join(where, " AND "))
+	if len(keys) > 0 {
+		statement += " GROUP BY " + strings.Join(keys, ", ")
+	}
+	body, err := c.query(statement+" FORMAT JSONEachRow", params, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate alerts in ClickHouse: %v", err)
+	}
+
+	var result []AlertCount
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var row map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return nil, fmt.Errorf("failed to decode ClickHouse row: %v", err)
+		}
+		count := AlertCount{Group: make(map[string]string, len(query.GroupBy))}
+		for _, field := range query.GroupBy {
+			count.Group[field] = fmt.Sprint(row[columns[field]])
+		}
+		if query.Bucket > 0 {
+			if s, ok := row["bucket"].(string); ok {
+				if t, err := time.ParseInLocation(time.DateTime, s, time.UTC); err == nil {
+					count.Bucket = &t
+				}
+			}
+		}
+		switch n := row["count"].(type) {
+		case float64:
+			count.Count = int(n)
+		case string: // 64-bit integers are quoted unless disabled
+			count.Count, _ = strconv.Atoi(n)
+		}
+		result = append(result, count)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Bucket != nil && !result[i].Bucket.Equal(*result[j].Bucket) {
+			return result[i].Bucket.Before(*result[j].Bucket)
+		}
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return fmt.Sprint(result[i].Group) < fmt.Sprint(result[j].Group)
+	})
+	return result, nil
+}
+
+// SetFeedback labels a stored alert as a true or false positive by inserting its latest row
+// with the feedback, which replaces the previous one
+func (c *ClickHouseClient) SetFeedback(id string, feedback string) error {
+	statement := fmt.Sprintf("INSERT INTO %[1]s SELECT * REPLACE ({feedback:String} AS feedback, now64(3, 'UTC') AS updated_at) FROM %[1]s FINAL WHERE alertid = {id:String}",
+		c.qualified(c.table))
+	if _, err := c.query(statement, map[string]string{"id": id, "feedback": feedback}, nil); err != nil {
+		return fmt.Errorf("failed to store alert feedback in ClickHouse: %v", err)
+	}
+	return nil
+}
+
+// Ping checks that ClickHouse is reachable and answers queries
+func (c *ClickHouseClient) Ping() error {
+	if _, err := c.query("SELECT 1", nil, nil); err != nil {
+		return fmt.Errorf("failed to reach ClickHouse: %v", err)
+	}
+	return nil
+}
+
+// insert writes rows to a table as JSON lines
+func (c *ClickHouseClient) insert(table string, rows []interface{}) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("failed to marshal row: %v", err)
+		}
+	}
+	_, err := c.query(fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", c.qualified(table)), nil, &buf)
+	return err
+}
+
+// query runs a statement with query parameters through the HTTP interface. Statements with
+// data to insert are sent as the query string, with the data as the body.
+func (c *ClickHouseClient) query(statement string, params map[string]string, data io.Reader) ([]byte, error) {
+	values := url.Values{}
+	values.Set("output_format_json_quote_64bit_integers", "0")
+	values.Set("input_format_null_as_default", "1") // Nil maps and slices are written as null
+	for name, value := range params {
+		values.Set("param_"+name, value)
+	}
+
+	body := data
+	if data == nil {
+		body = strings.NewReader(statement)
+	} else {
+		values.Set("query", statement)
+	}
+	req, err := http.NewRequest("POST", c.url+"/?"+values.Encode(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if c.user != "" {
+		req.Header.Set("X-ClickHouse-User", c.user)
+	}
+	if c.password != "" {
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// qualified returns the name of a table in the client's database
+func (c *ClickHouseClient) qualified(table string) string {
+	return clickHouseIdentifier(c.database) + "." + clickHouseIdentifier(table)
+}
+
+// clickHouseIdentifier quotes an identifier
+func clickHouseIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
+
+// clickHouseArray formats a vector as an array query parameter
+func clickHouseArray(vector []float32) string {
+	values := make([]string, len(vector))
+	for i, v := range vector {
+		values[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	return "[" + strings.Join(values, ",") + "]"
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/alertid"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// Metadata keys of the occurrences of a deduplicated alert
+const (
+	MetadataOccurrences = "occurrences"
+	MetadataFirstSeen   = "firstSeen"
+	MetadataLastSeen    = "lastSeen"
+)
+
+// occurrence is the stored point repeated occurrences of a fingerprint are written over
+type occurrence struct {
+	id          string
+	first, last time.Time
+	count       int
+	feedback    string // Kept when later occurrences are written over the point
+}
+
+// dedupedStorage stores the repeated occurrences of an alert within the window over the point of
+// the first one, counting them in its metadata, so the store holds one point per incident
+// instead of many near-identical ones. Fingerprints not seen since startup are looked up in
+// storages implementing FingerprintFinder.
+type dedupedStorage struct {
+	Storage
+	window  time.Duration
+	mu      sync.Mutex
+	seen    map[string]*occurrence // fingerprint -> stored point
+	aliases map[string]*occurrence // ID of a deduplicated alert -> stored point
+	pruned  time.Time
+}
+
+// NewDedupedStorage wraps a storage with deduplication of alerts by fingerprint within window
+func NewDedupedStorage(storage Storage, window time.Duration) Storage {
+	return &dedupedStorage{
+		Storage: storage,
+		window:  window,
+		seen:    make(map[string]*occurrence),
+		aliases: make(map[string]*occurrence),
+	}
+}
+
+// StoreAlert stores the alert, over the point of an earlier occurrence if there is one
+func (d *dedupedStorage) StoreAlert(alert StoreRequest) error {
+	return d.Storage.StoreAlert(d.dedup(alert))
+}
+
+// StoreAlerts stores the alerts, over the points of earlier occurrences if there are any
+func (d *dedupedStorage) StoreAlerts(alerts []StoreRequest) error {
+	deduped := make([]StoreRequest, len(alerts))
+	for i, alert := range alerts {
+		deduped[i] = d.dedup(alert)
+	}
+	return StoreAll(d.Storage, deduped)
+}
+
+// SearchHybrid searches the wrapped storage by vector and keywords
+func (d *dedupedStorage) SearchHybrid(query string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchHybrid(d.Storage, query, vector, limit)
+}
+
+// SearchSimilarAlertsInCluster searches the wrapped storage for alerts of a cluster
+func (d *dedupedStorage) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchSimilarAlertsInCluster(d.Storage, cluster, vector, limit)
+}
+
+// SearchSimilarAlertsOfModel searches the wrapped storage for alerts embedded by a model
+func (d *dedupedStorage) SearchSimilarAlertsOfModel(model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchSimilarAlertsOfModel(d.Storage, model, cluster, vector, limit)
+}
+
+// AggregateAlerts counts the alerts stored in the wrapped storage
+func (d *dedupedStorage) AggregateAlerts(query AggregateQuery) ([]AlertCount, error) {
+	return AggregateAlerts(d.Storage, query)
+}
+
+// StoreObservations stores the observations of a cycle in the wrapped storage
+func (d *dedupedStorage) StoreObservations(state types.ClusterState) error {
+	return StoreObservations(d.Storage, state)
+}
+
+// SetFeedback labels the point an alert was stored over
+func (d *dedupedStorage) SetFeedback(id string, feedback string) error {
+	d.mu.Lock()
+	if o, ok := d.aliases[id]; ok {
+		id = o.id
+		o.feedback = feedback
+	}
+	for _, o := range d.seen {
+		if o.id == id {
+			o.feedback = feedback
+		}
+	}
+	d.mu.Unlock()
+	return d.Storage.SetFeedback(id, feedback)
+}
+
+// dedup returns the alert to store: with the ID of the first occurrence of its fingerprint
+// within the window and the occurrence counts in its metadata
+func (d *dedupedStorage) dedup(alert StoreRequest) StoreRequest {
+	now := time.Now()
+	fingerprint := alert.Anomaly.Fingerprint
+	if fingerprint == "" {
+		fingerprint = alertid.Fingerprint(alert.Anomaly)
+	}
+
+	d.mu.Lock()
+	o, ok := d.seen[fingerprint]
+	d.mu.Unlock()
+	if !ok || now.Sub(o.last) > d.window {
+		o = d.lookup(fingerprint, alert.Anomaly.ID, now)
+	}
+
+	d.mu.Lock()
+	d.seen[fingerprint] = o
+	o.count++
+	o.last = now
+	if alert.Anomaly.ID != o.id {
+		d.aliases[alert.Anomaly.ID] = o
+	}
+	count, first, feedback := o.count, o.first, o.feedback
+	d.prune(now)
+	d.mu.Unlock()
+
+	alert.Anomaly.ID = o.id
+	if alert.Anomaly.Feedback == "" {
+		alert.Anomaly.Feedback = feedback
+	}
+	metadata := make(map[string]interface{}, len(alert.Metadata)+3)
+	for key, value := range alert.Metadata {
+		metadata[key] = value
+	}
+	metadata[MetadataOccurrences] = count
+	metadata[MetadataFirstSeen] = first.UTC().Format(time.RFC3339)
+	metadata[MetadataLastSeen] = now.UTC().Format(time.RFC3339)
+	alert.Metadata = metadata
+	return alert
+}
+
+// lookup returns the stored point of the fingerprint within the window, or a new one with the
+// given ID if there is none or the storage cannot look it up
+func (d *dedupedStorage) lookup(fingerprint, id string, now time.Time) *occurrence {
+	stored, err := FindByFingerprint(d.Storage, fingerprint, now.Add(-d.window))
+	if err != nil || stored == nil {
+		return &occurrence{id: id, first: now}
+	}
+
+	o := &occurrence{id: stored.ID, first: stored.Timestamp, feedback: stored.Feedback}
+	switch count := stored.Metadata[MetadataOccurrences].(type) {
+	case float64: // Decoded from JSON
+		o.count = int(count)
+	case int:
+		o.count = count
+	}
+	if first, ok := stored.Metadata[MetadataFirstSeen].(string); ok {
+		if t, err := time.Parse(time.RFC3339, first); err == nil {
+			o.first = t
+		}
+	}
+	return o
+}
+
+// prune forgets the fingerprints that have not occurred within the window, once per window.
+// Callers must hold d.mu.
+func (d *dedupedStorage) prune(now time.Time) {
+	if now.Sub(d.pruned) < d.window {
+		return
+	}
+	for fingerprint, o := range d.seen {
+		if now.Sub(o.last) > d.window {
+			delete(d.seen, fingerprint)
+		}
+	}
+	for id, o := range d.aliases {
+		if now.Sub(o.last) > d.window {
+			delete(d.aliases, id)
+		}
+	}
+	d.pruned = now
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// deferredStorage connects to the storage backend on first use, retrying on every call until
+// it succeeds, so the agent can start while the backend is unavailable
+type deferredStorage struct {
+	config StorageConfig
+	mu     sync.Mutex
+	client Storage
+}
+
+// NewDeferredStorage creates a storage whose connection is established lazily
+func NewDeferredStorage(config StorageConfig) (Storage, error) {
+	switch config.Type {
+	case StorageTypeQdrant, StorageTypeRedis, StorageTypeLocal, StorageTypeMilvus, StorageTypeWeaviate, StorageTypeClickHouse:
+		return &deferredStorage{config: config}, nil
+	default:
+		return nil, fmt.Errorf("unsupported storage type: %s", config.Type)
+	}
+}
+
+// connect returns the connected client, connecting if needed
+func (d *deferredStorage) connect() (Storage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.client == nil {
+		client, err := NewStorage(d.config)
+		if err != nil {
+			return nil, fmt.Errorf("storage is not connected: %v", err)
+		}
+		d.client = client
+	}
+	return d.client, nil
+}
+
+// StoreAlert stores an alert once the backend is connected
+func (d *deferredStorage) StoreAlert(alert StoreRequest) error {
+	client, err := d.connect()
+	if err != nil {
+		return err
+	}
+	return client.StoreAlert(alert)
+}
+
+// StoreAlerts stores alerts once the backend is connected
+func (d *deferredStorage) StoreAlerts(alerts []StoreRequest) error {
+	client, err := d.connect()
+	if err != nil {
+		return err
+	}
+	return StoreAll(client, alerts)
+}
+
+// SearchSimilarAlerts searches alerts once the backend is connected
+func (d *deferredStorage) SearchSimilarAlerts(vector []float32, limit int) ([]types.Anomaly, error) {
+	client, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	return client.SearchSimilarAlerts(vector, limit)
+}
+
+// SearchHybrid searches alerts by vector and keywords once the backend is connected
+func (d *deferredStorage) SearchHybrid(query string, vector []float32, limit int) ([]types.Anomaly, error) {
+	client, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	return SearchHybrid(client, query, vector, limit)
+}
+
+// SearchSimilarAlertsInCluster searches alerts of a cluster once the backend is connected
+func (d *deferredStorage) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	client, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	return SearchSimilarAlertsInCluster(client, cluster, vector, limit)
+}
+
+// SearchSimilarAlertsOfModel searches alerts embedded by a model once the backend is connected
+func (d *deferredStorage) SearchSimilarAlertsOfModel(model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	client, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	return SearchSimilarAlertsOfModel(client, model, cluster, vector, limit)
+}
+
+// AggregateAlerts counts stored alerts once the backend is connected
+func (d *deferredStorage) AggregateAlerts(query AggregateQuery) ([]AlertCount, error) {
+	client, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	return AggregateAlerts(client, query)
+}
+
+// StoreObservations stores the observations of a cycle once the backend is connected
+func (d *deferredStorage) StoreObservations(state types.ClusterState) error {
+	client, err := d.connect()
+	if err != nil {
+		return err
+	}
+	return StoreObservations(client, state)
+}
+
+// FindByFingerprint looks up an alert by fingerprint once the backend is connected
+func (d *deferredStorage) FindByFingerprint(fingerprint string, since time.Time) (*types.Anomaly, error) {
+	client, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	return FindByFingerprint(client, fingerprint, since)
+}
+
+// Ping connects to the backend if needed and checks that it is reachable
+func (d *deferredStorage) Ping() error {
+	client, err := d.connect()
+	if err != nil {
+		return err
+	}
+	return client.Ping()
+}
+
+// SetFeedback labels an alert once the backend is connected
+func (d *deferredStorage) SetFeedback(id string, feedback string) error {
+	client, err := d.connect()
+	if err != nil {
+		return err
+	}
+	return client.SetFeedback(id, feedback)
+}
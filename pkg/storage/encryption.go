@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// encryptedPrefix starts the description of alerts whose payload is encrypted, followed by the
+// ID of the key and the base64-encoded nonce and ciphertext, separated by colons
+const encryptedPrefix = "enc:v1:"
+
+// encryptedPayload is the part of an alert that is encrypted
+type encryptedPayload struct {
+	Description string                 `json:"description"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Events      []types.Event          `json:"events,omitempty"`
+}
+
+// encryptedStorage encrypts the description, labels, metadata and events of alerts with AES-GCM
+// before they are written, and decrypts them when alerts are read back. Identifying fields such as
+// the cluster, type, namespace and severity stay in the clear so alerts can still be filtered and
+// counted. Keyword search cannot match encrypted descriptions.
+type encryptedStorage struct {
+	Storage
+	keyID    string
+	aead     cipher.AEAD
+	decrypts map[string]cipher.AEAD // Key ID -> cipher, for the current and previous keys
+}
+
+// NewEncryptedStorage wraps a storage with encryption of alert payloads. Alerts are encrypted with
+// key and decrypted with it or with one of the previous keys, so keys can be rotated. Keys must
+// be 16, 24 or 32 bytes long. Alerts stored before encryption was enabled are read as they are.
+func NewEncryptedStorage(storage Storage, key []byte, previous [][]byte) (Storage, error) {
+	s := &encryptedStorage{Storage: storage, decrypts: make(map[string]cipher.AEAD)}
+	for i, k := range append([][]byte{key}, previous...) {
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key: %v", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key: %v", err)
+		}
+		id := encryptionKeyID(k)
+		if i == 0 {
+			s.keyID, s.aead = id, aead
+		}
+		if _, ok := s.decrypts[id]; !ok {
+			s.decrypts[id] = aead
+		}
+	}
+	return s, nil
+}
+
+// encryptionKeyID identifies a key in encrypted payloads without revealing it
+func encryptionKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// StoreAlert stores the alert with its payload encrypted
+func (s *encryptedStorage) StoreAlert(alert StoreRequest) error {
+	encrypted, err := s.encrypt(alert)
+	if err != nil {
+		return err
+	}
+	return s.Storage.StoreAlert(encrypted)
+}
+
+// StoreAlerts stores the alerts with their payloads encrypted
+func (s *encryptedStorage) StoreAlerts(alerts []StoreRequest) error {
+	encrypted := make([]StoreRequest, len(alerts))
+	for i, alert := range alerts {
+		var err error
+		if encrypted[i], err = s.encrypt(alert); err != nil {
+			return err
+		}
+	}
+	return StoreAll(s.Storage, encrypted)
+}
+
+// SearchSimilarAlerts searches the wrapped storage and decrypts the alerts found
+func (s *encryptedStorage) SearchSimilarAlerts(vector []float32, limit int) ([]types.Anomaly, error) {
+	return s.decryptAll(s.Storage.SearchSimilarAlerts(vector, limit))
+}
+
+// SearchHybrid searches the wrapped storage by vector and keywords and decrypts the alerts found
+func (s *encryptedStorage) SearchHybrid(query string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return s.decryptAll(SearchHybrid(s.Storage, query, vector, limit))
+}
+
+// SearchSimilarAlertsInCluster searches the wrapped storage for alerts of a cluster and decrypts
+// the alerts found
+func (s *encryptedStorage) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return s.decryptAll(SearchSimilarAlertsInCluster(s.Storage, cluster, vector, limit))
+}
+
+// SearchSimilarAlertsOfModel searches the wrapped storage for alerts embedded by a model and
+// decrypts the alerts found
+func (s *encryptedStorage) SearchSimilarAlertsOfModel(model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return s.decryptAll(SearchSimilarAlertsOfModel(s.Storage, model, cluster, vector, limit))
+}
+
+// FindByFingerprint looks up an alert in the wrapped storage and decrypts it
+func (s *encryptedStorage) FindByFingerprint(fingerprint string, since time.Time) (*types.Anomaly, error) {
+	anomaly, err := FindByFingerprint(s.Storage, fingerprint, since)
+	if err != nil || anomaly == nil {
+		return anomaly, err
+	}
+	decrypted, err := s.decrypt(*anomaly)
+	if err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
+
+// AggregateAlerts counts the alerts stored in the wrapped storage, by their unencrypted fields
+func (s *encryptedStorage) AggregateAlerts(query AggregateQuery) ([]AlertCount, error) {
+	return AggregateAlerts(s.Storage, query)
+}
+
+// StoreObservations stores the observations of a cycle in the wrapped storage
+func (s *encryptedStorage) StoreObservations(state types.ClusterState) error {
+	return StoreObservations(s.Storage, state)
+}
+
+// DumpAlerts calls fn with every alert of the wrapped storage, decrypted
+func (s *encryptedStorage) DumpAlerts(fn func(StoreRequest) error) error {
+	return DumpAlerts(s.Storage, func(request StoreRequest) error {
+		anomaly, err := s.decrypt(request.Anomaly)
+		if err != nil {
+			return err
+		}
+		request.Anomaly, request.Events = anomaly, anomaly.Events
+		return fn(request)
+	})
+}
+
+// encrypt returns the request with the anomaly's description, labels, metadata and events,
+// including the request's, replaced by their ciphertext
+func (s *encryptedStorage) encrypt(alert StoreRequest) (StoreRequest, error) {
+	events := alert.Events
+	if len(events) == 0 {
+		events = alert.Anomaly.Events
+	}
+	plaintext, err := json.Marshal(encryptedPayload{
+		Description: alert.Anomaly.Description,
+		Labels:      alert.Anomaly.Labels,
+		Metadata:    alert.metadata(),
+		Events:      events,
+	})
+	if err != nil {
+		return alert, fmt.Errorf("failed to encode alert payload: %v", err)
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return alert, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, plaintext, nil)
+
+	alert.Anomaly.Description = encryptedPrefix + s.keyID + ":" + base64.StdEncoding.EncodeToString(sealed)
+	alert.Anomaly.Labels = nil
+	alert.Anomaly.Metadata = nil
+	alert.Anomaly.Events = nil
+	alert.Metadata = nil
+	alert.Events = nil
+	return alert, nil
+}
+
+// decrypt returns the anomaly with its payload decrypted. Anomalies that are not encrypted are
+// returned as they are.
+func (s *encryptedStorage) decrypt(anomaly types.Anomaly) (types.Anomaly, error) {
+	if !strings.HasPrefix(anomaly.Description, encryptedPrefix) {
+		return anomaly, nil
+	}
+	keyID, encoded, ok := strings.Cut(strings.TrimPrefix(anomaly.Description, encryptedPrefix), ":")
+	if !ok {
+		return anomaly, fmt.Errorf("invalid encrypted payload of alert %s", anomaly.ID)
+	}
+	aead, ok := s.decrypts[keyID]
+	if !ok {
+		return anomaly, fmt.Errorf("alert %s is encrypted with unknown key %s", anomaly.ID, keyID)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return anomaly, fmt.Errorf("invalid encrypted payload of alert %s", anomaly.ID)
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return anomaly, fmt.Errorf("failed to decrypt alert %s: %v", anomaly.ID, err)
+	}
+
+	var payload encryptedPayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return anomaly, fmt.Errorf("failed to decode payload of alert %s: %v", anomaly.ID, err)
+	}
+	anomaly.Description = payload.Description
+	anomaly.Labels = payload.Labels
+	anomaly.Metadata = payload.Metadata
+	anomaly.Events = payload.Events
+	return anomaly, nil
+}
+
+// decryptAll decrypts the anomalies returned by a search
+func (s *encryptedStorage) decryptAll(anomalies []types.Anomaly, err error) ([]types.Anomaly, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i, anomaly := range anomalies {
+		if anomalies[i], err = s.decrypt(anomaly); err != nil {
+			return nil, err
+		}
+	}
+	return anomalies, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 )
 
 // StorageType represents the type of storage backend
@@ -14,6 +15,14 @@ const (
 	StorageTypeQdrant StorageType = "qdrant"
 	// StorageTypeRedis represents Redis storage
 	StorageTypeRedis StorageType = "redis"
+	// StorageTypeLocal represents a file on local disk, for clusters without a database
+	StorageTypeLocal StorageType = "local"
+	// StorageTypeMilvus represents Milvus storage
+	StorageTypeMilvus StorageType = "milvus"
+	// StorageTypeWeaviate represents Weaviate storage
+	StorageTypeWeaviate StorageType = "weaviate"
+	// StorageTypeClickHouse represents ClickHouse storage, for long-range analytics
+	StorageTypeClickHouse StorageType = "clickhouse"
 )
 
 // StorageConfig holds configuration for storage backends
@@ -26,15 +35,35 @@ type StorageConfig struct {
 	Collection string
 	VectorSize int
 	Distance   string
+	Protocol   string // QdrantProtocolREST or QdrantProtocolGRPC
+	GRPCURL    string
+	Qdrant     QdrantOptions
+	// Local-specific settings
+	Path       string
+	Retention  time.Duration
+	Milvus     MilvusOptions
+	Weaviate   WeaviateOptions
+	ClickHouse ClickHouseOptions
 }
 
 // NewStorage creates a new storage instance based on the configuration
 func NewStorage(config StorageConfig) (Storage, error) {
 	switch config.Type {
 	case StorageTypeQdrant:
-		return NewQdrantClient(config.URL, config.Collection, config.VectorSize, config.Distance)
+		if config.Protocol == QdrantProtocolGRPC {
+			return NewQdrantGRPCClient(config.URL, config.GRPCURL, config.Collection, config.VectorSize, config.Distance, config.Qdrant)
+		}
+		return NewQdrantClient(config.URL, config.Collection, config.VectorSize, config.Distance, config.Qdrant)
 	case StorageTypeRedis:
 		return NewRedisClient(config.URL, config.Password, config.DB)
+	case StorageTypeLocal:
+		return NewLocalStorage(config.Path, config.Retention)
+	case StorageTypeMilvus:
+		return NewMilvusClient(config.Milvus)
+	case StorageTypeWeaviate:
+		return NewWeaviateClient(config.Weaviate)
+	case StorageTypeClickHouse:
+		return NewClickHouseClient(config.ClickHouse)
 	default:
 		return nil, fmt.Errorf("unsupported storage type: %s", config.Type)
 	}
@@ -67,6 +96,10 @@ func NewStorageFromEnv() (Storage, error) {
 			}
 		}
 		config.Distance = os.Getenv("QDRANT_DISTANCE")
+		config.Protocol = os.Getenv("QDRANT_PROTOCOL")
+		config.GRPCURL = os.Getenv("QDRANT_GRPC_URL")
+		config.Qdrant.APIKey = os.Getenv("QDRANT_API_KEY")
+		config.Qdrant.CAFile = os.Getenv("QDRANT_CA_FILE")
 		return NewStorage(config)
 
 	case StorageTypeRedis:
@@ -85,6 +118,59 @@ func NewStorageFromEnv() (Storage, error) {
 		}
 		return NewStorage(config)
 
+	case StorageTypeMilvus:
+		config.Milvus = MilvusOptions{
+			URL:        os.Getenv("MILVUS_URL"),
+			Token:      os.Getenv("MILVUS_TOKEN"),
+			Collection: os.Getenv("MILVUS_COLLECTION"),
+		}
+		if config.Milvus.URL == "" {
+			config.Milvus.URL = "http://localhost:19530"
+		}
+		if config.Milvus.Collection == "" {
+			config.Milvus.Collection = "huginn_alerts"
+		}
+		return NewStorage(config)
+
+	case StorageTypeWeaviate:
+		config.Weaviate = WeaviateOptions{
+			URL:         os.Getenv("WEAVIATE_URL"),
+			APIKey:      os.Getenv("WEAVIATE_API_KEY"),
+			Class:       os.Getenv("WEAVIATE_CLASS"),
+			HybridAlpha: 0.75,
+		}
+		if config.Weaviate.URL == "" {
+			config.Weaviate.URL = "http://localhost:8080"
+		}
+		if config.Weaviate.Class == "" {
+			config.Weaviate.Class = "HuginnAlert"
+		}
+		return NewStorage(config)
+
+	case StorageTypeClickHouse:
+		config.ClickHouse = ClickHouseOptions{
+			URL:               os.Getenv("CLICKHOUSE_URL"),
+			User:              os.Getenv("CLICKHOUSE_USER"),
+			Password:          os.Getenv("CLICKHOUSE_PASSWORD"),
+			Database:          os.Getenv("CLICKHOUSE_DATABASE"),
+			Table:             "alerts",
+			ObservationsTable: "observations",
+		}
+		if config.ClickHouse.URL == "" {
+			config.ClickHouse.URL = "http://localhost:8123"
+		}
+		if config.ClickHouse.Database == "" {
+			config.ClickHouse.Database = "huginn"
+		}
+		return NewStorage(config)
+
+	case StorageTypeLocal:
+		config.Path = os.Getenv("LOCAL_STORAGE_PATH")
+		if config.Path == "" {
+			config.Path = "data/alerts.jsonl"
+		}
+		return NewStorage(config)
+
 	default:
 		return nil, fmt.Errorf("unsupported storage type: %s", storageType)
 	}
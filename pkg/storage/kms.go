@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/awsauth"
+)
+
+// kmsEndpoint is the AWS KMS endpoint of a region
+const kmsEndpoint = "https://kms.%s.amazonaws.com"
+
+// KMSKeyProvider decrypts data keys encrypted by AWS KMS, so encryption keys can be stored and
+// distributed encrypted (envelope encryption) and only exist in the clear in the agent's memory.
+// Requests are signed with Signature Version 4.
+type KMSKeyProvider struct {
+	endpoint    string
+	region      string
+	credentials awsauth.Credentials
+	client      *http.Client
+}
+
+// NewKMSKeyProvider creates a client of AWS KMS in a region. The endpoint defaults to the
+// region's.
+func NewKMSKeyProvider(endpoint, region string, credentials awsauth.Credentials) (*KMSKeyProvider, error) {
+	if region == "" {
+		return nil, fmt.Errorf("KMS region is required")
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("KMS credentials are required")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf(kmsEndpoint, region)
+	}
+	return &KMSKeyProvider{
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		region:      region,
+		credentials: credentials,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// DecryptKey decrypts a data key encrypted by KMS, e.g. the CiphertextBlob returned by
+// `aws kms generate-data-key --key-spec AES_256`
+func (k *KMSKeyProvider) DecryptKey(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	body, err := json.Marshal(map[string][]byte{"CiphertextBlob": ciphertext})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal KMS request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", k.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+	awsauth.Sign(req, body, "kms", k.region, k.credentials, time.Now().UTC())
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key with KMS: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("failed to decrypt key with KMS: %s - %s", resp.Status, string(message))
+	}
+	var result struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode KMS response: %v", err)
+	}
+	if len(result.Plaintext) == 0 {
+		return nil, fmt.Errorf("KMS returned no plaintext key")
+	}
+	return result.Plaintext, nil
+}
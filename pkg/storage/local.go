@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// localRecord is an alert stored by the local backend
+type localRecord struct {
+	ID       string        `json:"id"`
+	Vector   []float32     `json:"vector"`
+	Anomaly  types.Anomaly `json:"anomaly"`
+	StoredAt time.Time     `json:"storedAt"`
+}
+
+// localCompactInterval is how often the alert file is compacted and expired alerts are dropped
+// while the storage is in use
+const localCompactInterval = time.Hour
+
+// localCompactMinLines is how many lines must be appended before the file is compacted for size
+const localCompactMinLines = 1000
+
+// LocalStorage stores alerts in a JSON lines file on disk and searches them by brute-force
+// cosine similarity in memory, for air-gapped and edge clusters without an external database.
+// Updates are appended as new lines; the last line for an ID wins when the file is loaded.
+// A brute-force search needs every vector in memory anyway, so an embedded database such as
+// SQLite or bbolt would add a dependency (cgo for SQLite) without saving memory; retention
+// bounds both the file and the map instead.
+type LocalStorage struct {
+	path      string
+	retention time.Duration
+	mu        sync.RWMutex
+	records   map[string]*localRecord
+
+	appended    int // Lines appended since the last compaction
+	compactedAt time.Time
+}
+
+// NewLocalStorage opens the alert file at path, creating it if needed, and drops alerts older
+// than retention unless it is zero
+func NewLocalStorage(path string, retention time.Duration) (*LocalStorage, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %v", err)
+	}
+
+	s := &LocalStorage{
+		path:      path,
+		retention: retention,
+		records:   make(map[string]*localRecord),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, fmt.Errorf("failed to compact local storage: %v", err)
+	}
+	return s, nil
+}
+
+// StoreAlert stores an alert
+func (s *LocalStorage) StoreAlert(alert StoreRequest) error {
+	return s.StoreAlerts([]StoreRequest{alert})
+}
+
+// StoreAlerts stores alerts with a single write
+func (s *LocalStorage) StoreAlerts(alerts []StoreRequest) error {
+	records := make([]*localRecord, len(alerts))
+	for i, alert := range alerts {
+		anomaly := alert.Anomaly
+		anomaly.Events = alert.Events
+		anomaly.Metadata = alert.metadata()
+		records[i] = &localRecord{
+			ID:       pointID(anomaly.ID),
+			Vector:   alert.Vector,
+			Anomaly:  anomaly,
+			StoredAt: time.Now(),
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(records); err != nil {
+		return err
+	}
+	for _, record := range records {
+		s.records[record.ID] = record
+	}
+	s.maybeCompact()
+	return nil
+}
+
+// SearchSimilarAlerts returns the stored alerts most similar to vector, most similar first
+func (s *LocalStorage) SearchSimilarAlerts(vector []float32, limit int) ([]types.Anomaly, error) {
+	return s.search("", nil, vector, limit)
+}
+
+// SearchSimilarAlertsInCluster returns the stored alerts of a cluster most similar to vector
+func (s *LocalStorage) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return s.search(cluster, nil, vector, limit)
+}
+
+// SearchSimilarAlertsOfModel returns the stored alerts embedded by model most similar to vector,
+// of a cluster if set
+func (s *LocalStorage) SearchSimilarAlertsOfModel(model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return s.search(cluster, &model, vector, limit)
+}
+
+// search returns the stored alerts of cluster, or of every cluster if it is empty, most similar
+// to vector. If model is set, only alerts embedded by it are searched.
+func (s *LocalStorage) search(cluster string, model *types.EmbeddingModel, vector []float32, limit int) ([]types.Anomaly, error) {
+	s.mu.RLock()
+	type match struct {
+		record     *localRecord
+		similarity float64
+	}
+	matches := make([]match, 0, len(s.records))
+	for _, record := range s.records {
+		if len(record.Vector) != len(vector) || (cluster != "" && record.Anomaly.ClusterName != cluster) {
+			continue
+		}
+		if model != nil && !embeddedBy(record.Anomaly, *model) {
+			continue
+		}
+		matches = append(matches, match{record, cosineSimilarity(vector, record.Vector)})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].similarity > matches[j].similarity
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	anomalies := make([]types.Anomaly, len(matches))
+	for i, m := range matches {
+		anomalies[i] = m.record.Anomaly
+	}
+	return anomalies, nil
+}
+
+// FindByFingerprint returns the most recently stored alert with the fingerprint stored since
+// the given time, or nil if there is none
+func (s *LocalStorage) FindByFingerprint(fingerprint string, since time.Time) (*types.Anomaly, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var latest *localRecord
+	for _, record := range s.records {
+		if record.Anomaly.Fingerprint != fingerprint || record.StoredAt.Before(since) {
+			continue
+		}
+		if latest == nil || record.StoredAt.After(latest.StoredAt) {
+			latest = record
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	anomaly := latest.Anomaly
+	return &anomaly, nil
+}
+
+// AggregateAlerts counts the stored alerts matching query
+func (s *LocalStorage) AggregateAlerts(query AggregateQuery) ([]AlertCount, error) {
+	s.mu.RLock()
+	anomalies := make([]types.Anomaly, 0, len(s.records))
+	for _, record := range s.records {
+		anomaly := record.Anomaly
+		if anomaly.Timestamp.IsZero() {
+			anomaly.Timestamp = record.StoredAt
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+	s.mu.RUnlock()
+	return aggregate(anomalies, query), nil
+}
+
+// DumpAlerts calls fn with every stored alert and its vector, oldest first
+func (s *LocalStorage) DumpAlerts(fn func(StoreRequest) error) error {
+	s.mu.RLock()
+	records := make([]*localRecord, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].StoredAt.Before(records[j].StoredAt)
+	})
+	for _, record := range records {
+		if err := fn(StoreRequest{Anomaly: record.Anomaly, Events: record.Anomaly.Events, Vector: record.Vector}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetFeedback labels a stored alert as a true or false positive
+func (s *LocalStorage) SetFeedback(id string, feedback string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[pointID(id)]
+	if !ok {
+		return fmt.Errorf("alert not found: %s", id)
+	}
+	updated := *record
+	updated.Anomaly.Feedback = feedback
+	if err := s.append([]*localRecord{&updated}); err != nil {
+		return err
+	}
+	s.records[updated.ID] = &updated
+	s.maybeCompact()
+	return nil
+}
+
+// Ping checks that the alert file is writable
+func (s *LocalStorage) Ping() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("local storage is not writable: %v", err)
+	}
+	return f.Close()
+}
+
+// append writes records to the end of the file. Callers must hold s.mu.
+func (s *LocalStorage) append(records []*localRecord) error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open local storage: %v", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to write alert: %v", err)
+		}
+	}
+	s.appended += len(records)
+	return w.Flush()
+}
+
+// maybeCompact compacts the file once it holds more superseded lines than alerts, and drops
+// expired alerts every localCompactInterval. Callers must hold s.mu.
+func (s *LocalStorage) maybeCompact() {
+	if (s.appended < localCompactMinLines || s.appended < len(s.records)) && time.Since(s.compactedAt) < localCompactInterval {
+		return
+	}
+	if err := s.compactLocked(); err != nil {
+		log.Printf("Warning: failed to compact local storage: %v", err)
+		s.compactedAt = time.Now()
+	}
+}
+
+// load reads the file, keeping the last version of each alert
+func (s *LocalStorage) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open local storage: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record localRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// Skip lines left partially written by a crash
+			continue
+		}
+		s.records[record.ID] = &record
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read local storage: %v", err)
+	}
+	return nil
+}
+
+// compact rewrites the file keeping only the latest version of alerts within retention
+func (s *LocalStorage) compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compactLocked()
+}
+
+// compactLocked rewrites the file and drops expired alerts. Callers must hold s.mu.
+func (s *LocalStorage) compactLocked() error {
+	cutoff := time.Now().Add(-s.retention)
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for id, record := range s.records {
+		if s.retention > 0 && record.StoredAt.Before(cutoff) {
+			delete(s.records, id)
+			continue
+		}
+		if err := enc.Encode(record); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.appended, s.compactedAt = 0, time.Now()
+	return nil
+}
+
+// cosineSimilarity returns the cosine of the angle between two vectors of equal length
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// MilvusOptions holds the connection and collection settings of the Milvus backend
+type MilvusOptions struct {
+	URL        string
+	Token      string // API key, or "user:password"
+	Database   string
+	Collection string
+	VectorSize int
+	Metric     string // COSINE, L2 or IP
+}
+
+// milvusScalarFields are the payload fields stored as typed columns so they can be filtered on;
+// the rest of the payload is stored in the dynamic field
+var milvusScalarFields = []struct {
+	name, dataType string
+}{
+	{"cluster", "VarChar"},
+	{"namespace", "VarChar"},
+	{"severity", "VarChar"},
+	{"type", "VarChar"},
+	{"timestamp", "Int64"},
+}
+
+// MilvusClient implements the Storage interface using the Milvus RESTful API (v2)
+type MilvusClient struct {
+	url        string
+	token      string
+	database   string
+	collection string
+	vectorSize int
+	metric     string
+	client     *http.Client
+}
+
+// NewMilvusClient creates a Milvus client, creating the collection if it does not exist
+func NewMilvusClient(options MilvusOptions) (*MilvusClient, error) {
+	client := &MilvusClient{
+		url:        strings.TrimSuffix(options.URL, "/"),
+		token:      options.Token,
+		database:   options.Database,
+		collection: options.Collection,
+		vectorSize: options.VectorSize,
+		metric:     options.Metric,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if err := client.ensureCollection(); err != nil {
+		return nil, fmt.Errorf("failed to ensure collection exists: %v", err)
+	}
+	return client, nil
+}
+
+// ensureCollection creates the collection, with an index on the vector field, if it does not exist
+func (c *MilvusClient) ensureCollection() error {
+	var has struct {
+		Has bool `json:"has"`
+	}
+	if err := c.call("/v2/vectordb/collections/has", map[string]interface{}{}, &has); err != nil {
+		return err
+	}
+	if has.Has {
+		return nil
+	}
+
+	size := c.vectorSize
+	if size <= 0 {
+		size = 384
+	}
+	metric := strings.ToUpper(c.metric)
+	switch metric {
+	case "", "COSINE":
+		metric = "COSINE"
+	case "EUCLID", "EUCLIDEAN", "L2":
+		metric = "L2"
+	case "DOT", "DOTPRODUCT", "IP":
+		metric = "IP"
+	}
+
+	fields := []map[string]interface{}{
+		{"fieldName": "id", "dataType": "VarChar", "isPrimary": true, "elementTypeParams": map[string]interface{}{"max_length": 64}},
+		{"fieldName": "vector", "dataType": "FloatVector", "elementTypeParams": map[string]interface{}{"dim": size}},
+	}
+	for _, field := range milvusScalarFields {
+		f := map[string]interface{}{"fieldName": field.name, "dataType": field.dataType}
+		if field.dataType == "VarChar" {
+			f["elementTypeParams"] = map[string]interface{}{"max_length": 512}
+		}
+		fields = append(fields, f)
+	}
+
+	request := map[string]interface{}{
+		"schema": map[string]interface{}{
+			"autoId":             false,
+			"enableDynamicField": true,
+			"fields":             fields,
+		},
+		"indexParams": []map[string]interface{}{
+			{"fieldName": "vector", "indexName": "vector", "metricType": metric, "indexType": "AUTOINDEX"},
+		},
+	}
+	return c.call("/v2/vectordb/collections/create", request, nil)
+}
+
+// StoreAlert stores an alert in Milvus
+func (c *MilvusClient) StoreAlert(alert StoreRequest) error {
+	return c.StoreAlerts([]StoreRequest{alert})
+}
+
+// StoreAlerts stores alerts in Milvus with a single upsert
+func (c *MilvusClient) StoreAlerts(alerts []StoreRequest) error {
+	data := make([]map[string]interface{}, len(alerts))
+	for i, alert := range alerts {
+		entity, err := milvusEntity(alert)
+		if err != nil {
+			return err
+		}
+		data[i] = entity
+	}
+	if err := c.call("/v2/vectordb/entities/upsert", map[string]interface{}{"data": data}, nil); err != nil {
+		return fmt.Errorf("failed to store alerts in Milvus: %v", err)
+	}
+	return nil
+}
+
+// milvusEntity converts an alert to an entity. The payload is normalized through JSON, dropping
+// null values, which dynamic fields do not accept.
+func milvusEntity(alert StoreRequest) (map[string]interface{}, error) {
+	data, err := json.Marshal(alertPayload(alert))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	var entity map[string]interface{}
+	if err := json.Unmarshal(data, &entity); err != nil {
+		return nil, fmt.Errorf("failed to normalize payload: %v", err)
+	}
+	for key, value := range entity {
+		if value == nil {
+			delete(entity, key)
+		}
+	}
+	entity["id"] = pointID(alert.Anomaly.ID)
+	entity["vector"] = alert.Vector
+	return entity, nil
+}
+
+// SearchSimilarAlerts searches for similar alerts in Milvus
+func (c *MilvusClient) SearchSimilarAlerts(vector []float32, limit int) ([]types.Anomaly, error) {
+	return c.search(vector, limit, "")
+}
+
+// SearchSimilarAlertsFiltered searches for similar alerts matching a Milvus boolean expression
+// over the scalar fields, e.g. `cluster == "prod" and severity in ["critical", "high"]`
+func (c *MilvusClient) SearchSimilarAlertsFiltered(vector []float32, limit int, filter string) ([]types.Anomaly, error) {
+	return c.search(vector, limit, filter)
+}
+
+// SearchSimilarAlertsInCluster searches for similar alerts of a cluster in Milvus
+func (c *MilvusClient) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return c.search(vector, limit, "cluster == "+strconv.Quote(cluster))
+}
+
+func (c *MilvusClient) search(vector []float32, limit int, filter string) ([]types.Anomaly, error) {
+	request := map[string]interface{}{
+		"data":         [][]float32{vector},
+		"annsField":    "vector",
+		"limit":        limit,
+		"outputFields": []string{"*"},
+	}
+	if filter != "" {
+		request["filter"] = filter
+	}
+	var results []map[string]interface{}
+	if err := c.call("/v2/vectordb/entities/search", request, &results); err != nil {
+		return nil, fmt.Errorf("failed to search alerts in Milvus: %v", err)
+	}
+
+	anomalies := make([]types.Anomaly, len(results))
+	for i, result := range results {
+		anomalies[i] = anomalyFromPayload(result)
+	}
+	return anomalies, nil
+}
+
+// ListAlerts returns the alerts of a cluster name, namespace and severity, if set, stored within
+// a time range
+func (c *MilvusClient) ListAlerts(cluster, namespace, severity string, startTime, endTime time.Time) ([]AlertVector, error) {
+	filters := []string{fmt.Sprintf("timestamp >= %d and timestamp <= %d", startTime.Unix(), endTime.Unix())}
+	if cluster != "" {
+		filters = append(filters, "cluster == "+strconv.Quote(cluster))
+	}
+	if namespace != "" {
+		filters = append(filters, "namespace == "+strconv.Quote(namespace))
+	}
+	if severity != "" {
+		filters = append(filters, "severity == "+strconv.Quote(severity))
+	}
+
+	var entities []map[string]interface{}
+	request := map[string]interface{}{
+		"filter":       strings.Join(filters, " and "),
+		"outputFields": []string{"*"},
+		"limit":        100,
+	}
+	if err := c.call("/v2/vectordb/entities/query", request, &entities); err != nil {
+		return nil, fmt.Errorf("error listing alerts from Milvus: %v", err)
+	}
+
+	alerts := make([]AlertVector, len(entities))
+	for i, entity := range entities {
+		anomaly := anomalyFromPayload(entity)
+		alerts[i] = AlertVector{
+			ID:        getStringFromPayload(entity, "id"),
+			Timestamp: anomaly.Timestamp,
+			Payload: AlertVectorPayload{
+				Fingerprint:     anomaly.Fingerprint,
+				Cluster:         anomaly.ClusterName,
+				Type:            anomaly.Type,
+				Resource:        anomaly.Resource,
+				Namespace:       anomaly.Namespace,
+				Severity:        anomaly.Severity,
+				Description:     anomaly.Description,
+				Value:           anomaly.Value,
+				Threshold:       anomaly.Threshold,
+				Labels:          anomaly.Labels,
+				Events:          anomaly.Events,
+				Metadata:        anomaly.Metadata,
+				CorrelationKeys: anomaly.CorrelationKeys,
+				Feedback:        anomaly.Feedback,
+				Embedding:       anomaly.Embedding,
+			},
+		}
+	}
+	return alerts, nil
+}
+
+// DumpAlerts calls fn with every stored alert and its vector, paging through the collection in
+// primary key order
+func (c *MilvusClient) DumpAlerts(fn func(StoreRequest) error) error {
+	last := ""
+	for {
+		var entities []map[string]interface{}
+		request := map[string]interface{}{
+			"filter":       "id > " + strconv.Quote(last),
+			"outputFields": []string{"*", "vector"},
+			"limit":        256,
+		}
+		if err := c.call("/v2/vectordb/entities/query", request, &entities); err != nil {
+			return fmt.Errorf("failed to dump alerts from Milvus: %v", err)
+		}
+		if len(entities) == 0 {
+			return nil
+		}
+		for _, entity := range entities {
+			if id := getStringFromPayload(entity, "id"); id > last {
+				last = id
+			}
+			var vector []float32
+			if values, ok := entity["vector"].([]interface{}); ok {
+				vector = make([]float32, 0, len(values))
+				for _, value := range values {
+					if f, ok := value.(float64); ok {
+						vector = append(vector, float32(f))
+					}
+				}
+			}
+			anomaly := anomalyFromPayload(entity)
+			if err := fn(StoreRequest{Anomaly: anomaly, Events: anomaly.Events, Vector: vector}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// SetFeedback labels a stored alert as a true or false positive. Milvus does not update fields
+// in place, so the entity is read with its vector and upserted with the feedback.
+func (c *MilvusClient) SetFeedback(id string, feedback string) error {
+	var entities []map[string]interface{}
+	request := map[string]interface{}{
+		"filter":       "id == " + strconv.Quote(pointID(id)),
+		"outputFields": []string{"*", "vector"},
+	}
+	if err := c.call("/v2/vectordb/entities/query", request, &entities); err != nil {
+		return fmt.Errorf("failed to read alert from Milvus: %v", err)
+	}
+	if len(entities) == 0 {
+		return fmt.Errorf("alert not found: %s", id)
+	}
+
+	entity := entities[0]
+	entity["feedback"] = feedback
+	if err := c.call("/v2/vectordb/entities/upsert", map[string]interface{}{"data": []map[string]interface{}{entity}}, nil); err != nil {
+		return fmt.Errorf("failed to store alert feedback in Milvus: %v", err)
+	}
+	return nil
+}
+
+// Ping checks that Milvus is reachable and the collection exists
+func (c *MilvusClient) Ping() error {
+	if err := c.call("/v2/vectordb/collections/describe", map[string]interface{}{}, nil); err != nil {
+		return fmt.Errorf("failed to reach Milvus: %v", err)
+	}
+	return nil
+}
+
+// call posts a request for the collection to a RESTful API endpoint and decodes the data of the
+// response into result, if set. Milvus reports errors in the response code rather than the HTTP status.
+func (c *MilvusClient) call(endpoint string, request map[string]interface{}, result interface{}) error {
+	request["collectionName"] = c.collection
+	if c.database != "" {
+		request["dbName"] = c.database
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", c.url+endpoint, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("milvus returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var response struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	if response.Code != 0 {
+		return fmt.Errorf("milvus error %d: %s", response.Code, response.Message)
+	}
+	if result != nil && len(response.Data) > 0 {
+		if err := json.Unmarshal(response.Data, result); err != nil {
+			return fmt.Errorf("failed to decode response data: %v", err)
+		}
+	}
+	return nil
+}
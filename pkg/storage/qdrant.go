@@ -2,10 +2,15 @@ package storage
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -20,21 +25,93 @@ type QdrantClient struct {
 	client     *http.Client
 	vectorSize int
 	distance   string
+	options    QdrantOptions
+}
+
+// QdrantOptions holds the authentication, TLS and timeout settings of Qdrant connections
+type QdrantOptions struct {
+	APIKey             string
+	CAFile             string // Trusted in addition to the system roots
+	InsecureSkipVerify bool
+	Timeout            time.Duration // Defaults to 10 seconds
+	// VerifyVectorSize fails to connect to an existing collection of another vector size
+	VerifyVectorSize bool
+	// RecreateOnMismatch recreates such a collection instead, deleting its points
+	RecreateOnMismatch bool
+}
+
+// ErrVectorSizeMismatch is returned when an existing collection holds vectors of another size
+// than the embeddings to store
+var ErrVectorSizeMismatch = errors.New("collection vector size does not match the embedding dimension")
+
+// tlsConfig returns the TLS configuration verifying the Qdrant server
+func (o QdrantOptions) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Qdrant CA file: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Qdrant CA file %s", o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// timeout returns the request timeout
+func (o QdrantOptions) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return 10 * time.Second
+	}
+	return o.Timeout
+}
+
+// apiKeyTransport adds the Qdrant API key to every request
+type apiKeyTransport struct {
+	apiKey string
+	next   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.apiKey == "" {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("api-key", t.apiKey)
+	return t.next.RoundTrip(req)
 }
 
 // NewQdrantClient creates a new Qdrant client
-func NewQdrantClient(url, collection string, vectorSize int, distance string) (*QdrantClient, error) {
+func NewQdrantClient(url, collection string, vectorSize int, distance string, options QdrantOptions) (*QdrantClient, error) {
+	tlsConfig, err := options.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
 	client := &QdrantClient{
 		url:        url,
 		collection: collection,
-		client:     &http.Client{Timeout: 10 * time.Second},
+		client: &http.Client{
+			Transport: apiKeyTransport{apiKey: options.APIKey, next: transport},
+			Timeout:   options.timeout(),
+		},
 		vectorSize: vectorSize,
 		distance:   distance,
+		options:    options,
 	}
 
 	// Ensure collection exists
 	if err := client.ensureCollection(); err != nil {
-		return nil, fmt.Errorf("failed to ensure collection exists: %v", err)
+		return nil, fmt.Errorf("failed to ensure collection exists: %w", err)
 	}
 
 	return client, nil
@@ -55,9 +132,27 @@ func (c *QdrantClient) ensureCollection() error {
 	}
 	defer resp.Body.Close()
 
-	// If collection exists, we're done
+	// If collection exists, check its vector size if asked to, and add the payload indexes it
+	// was created without, e.g. by an older version
 	if resp.StatusCode == http.StatusOK {
-		return nil
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read collection info: %v", err)
+		}
+		if c.options.VerifyVectorSize {
+			if err := c.verifyVectorSize(bytes.NewReader(body)); err != nil {
+				return err
+			}
+		}
+		var info struct {
+			Result struct {
+				PayloadSchema map[string]json.RawMessage `json:"payload_schema"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(body, &info); err != nil {
+			return fmt.Errorf("failed to decode collection info: %v", err)
+		}
+		return c.createPayloadIndexes(info.Result.PayloadSchema)
 	}
 
 	// If collection doesn't exist, create it
@@ -70,6 +165,55 @@ func (c *QdrantClient) ensureCollection() error {
 	return fmt.Errorf("unexpected status code %d when checking collection: %s", resp.StatusCode, string(body))
 }
 
+// verifyVectorSize compares the vector size of the collection described by body with the size of
+// the vectors to store, recreating the collection on a mismatch if configured to
+func (c *QdrantClient) verifyVectorSize(body io.Reader) error {
+	var info struct {
+		Result struct {
+			Config struct {
+				Params struct {
+					Vectors json.RawMessage `json:"vectors"`
+				} `json:"params"`
+			} `json:"config"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(body).Decode(&info); err != nil {
+		return fmt.Errorf("failed to decode collection info: %v", err)
+	}
+	var vectors struct {
+		Size int `json:"size"`
+	}
+	// Collections of named vectors have no single size to verify
+	if err := json.Unmarshal(info.Result.Config.Params.Vectors, &vectors); err != nil || vectors.Size == 0 {
+		return nil
+	}
+	if vectors.Size == c.vectorSize {
+		return nil
+	}
+
+	if !c.options.RecreateOnMismatch {
+		return fmt.Errorf("%w: collection %s holds %d-dimensional vectors but embeddings have %d dimensions; "+
+			"re-embed its alerts into a new collection or set storage.qdrant.recreateOnDimensionMismatch to recreate it",
+			ErrVectorSizeMismatch, c.collection, vectors.Size, c.vectorSize)
+	}
+	log.Printf("Warning: recreating collection %s with %d-dimensional vectors instead of %d, deleting its alerts",
+		c.collection, c.vectorSize, vectors.Size)
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/collections/%s", c.url, c.collection), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete collection: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete collection, status %d: %s", resp.StatusCode, string(data))
+	}
+	return c.createCollection()
+}
+
 // createCollection creates a new collection with proper configuration
 func (c *QdrantClient) createCollection() error {
 	// Defaults if not provided
@@ -122,47 +266,72 @@ func (c *QdrantClient) createCollection() error {
 		return fmt.Errorf("failed to create collection, status %d: %s", resp.StatusCode, string(body))
 	}
 
+	return c.createPayloadIndexes(nil)
+}
+
+// qdrantPayloadIndexes are the payload fields alerts are filtered on, with their index types
+var qdrantPayloadIndexes = []struct {
+	field, schema string
+}{
+	{"cluster", "keyword"},
+	{"fingerprint", "keyword"},
+	{"namespace", "keyword"},
+	{"severity", "keyword"},
+	{"type", "keyword"},
+	{"timestamp", "integer"},
+	{"embeddingmodel", "keyword"},
+}
+
+// createPayloadIndexes indexes the filtered payload fields missing from existing, the payload schema
+// of the collection, so filters stay fast as the collection grows
+func (c *QdrantClient) createPayloadIndexes(existing map[string]json.RawMessage) error {
+	url := fmt.Sprintf("%s/collections/%s/index?wait=true", c.url, c.collection)
+	for _, index := range qdrantPayloadIndexes {
+		if _, ok := existing[index.field]; ok {
+			continue
+		}
+		data, err := json.Marshal(map[string]string{"field_name": index.field, "field_schema": index.schema})
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload index: %v", err)
+		}
+		req, err := http.NewRequest("PUT", url, bytes.NewBuffer(data))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to create payload index on %s: %v", index.field, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to create payload index on %s, status %d: %s", index.field, resp.StatusCode, string(body))
+		}
+	}
 	return nil
 }
 
 // StoreAlert stores an alert in Qdrant
-func (c *QdrantClient) StoreAlert(vector []float32, anomaly types.Anomaly) error {
-	// Create the point payload in Qdrant format
-	point := map[string]interface{}{
-		"id":     uuid.New().String(),
-		"vector": vector,
-		"payload": map[string]interface{}{
-			"type":                 anomaly.Type,
-			"resourcetype":         anomaly.ResourceType,
-			"resource":             anomaly.Resource,
-			"cluster":              anomaly.ClusterName,
-			"namespace":            anomaly.Namespace,
-			"nodename":             anomaly.NodeName,
-			"severity":             anomaly.Severity,
-			"description":          anomaly.Description,
-			"value":                anomaly.Value,
-			"threshold":            anomaly.Threshold,
-			"namespacesonthisnode": anomaly.NamespacesOnThisNode,
-			"events":               anomaly.Events,
-			"labels":               anomaly.Labels,
-			"timestamp":            time.Now().Unix(),
-		},
-	}
+func (c *QdrantClient) StoreAlert(alert StoreRequest) error {
+	return c.StoreAlerts([]StoreRequest{alert})
+}
 
-	// Add optional fields if they exist
-	if anomaly.Labels != nil {
-		point["payload"].(map[string]interface{})["labels"] = anomaly.Labels
-	}
-	if anomaly.Events != nil {
-		point["payload"].(map[string]interface{})["events"] = anomaly.Events
-	}
-	if anomaly.Metadata != nil {
-		point["payload"].(map[string]interface{})["metadata"] = anomaly.Metadata
+// StoreAlerts stores alerts in Qdrant with a single upsert
+func (c *QdrantClient) StoreAlerts(alerts []StoreRequest) error {
+	// Create the points in Qdrant format
+	points := make([]map[string]interface{}, len(alerts))
+	for i, alert := range alerts {
+		points[i] = map[string]interface{}{
+			"id":      pointID(alert.Anomaly.ID),
+			"vector":  alert.Vector,
+			"payload": alertPayload(alert),
+		}
 	}
 
 	// Create the upsert payload
 	upsertPayload := map[string]interface{}{
-		"points": []map[string]interface{}{point},
+		"points": points,
 	}
 
 	// Marshal to JSON
@@ -195,12 +364,48 @@ func (c *QdrantClient) StoreAlert(vector []float32, anomaly types.Anomaly) error
 
 // SearchSimilarAlerts searches for similar alerts in Qdrant
 func (c *QdrantClient) SearchSimilarAlerts(vector []float32, limit int) ([]types.Anomaly, error) {
+	return c.search(vector, limit, nil)
+}
+
+// SearchSimilarAlertsInCluster searches for similar alerts of a cluster in Qdrant
+func (c *QdrantClient) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return c.search(vector, limit, map[string]interface{}{
+		"must": []map[string]interface{}{
+			{"key": "cluster", "match": map[string]interface{}{"value": cluster}},
+		},
+	})
+}
+
+// SearchSimilarAlertsOfModel searches for similar alerts embedded by model, of a cluster if set,
+// in Qdrant. Alerts stored without a recorded model match any model.
+func (c *QdrantClient) SearchSimilarAlertsOfModel(model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	must := []map[string]interface{}{
+		{"should": []map[string]interface{}{
+			{"is_empty": map[string]interface{}{"key": "embeddingmodel"}},
+			{"must": []map[string]interface{}{
+				{"key": "embeddingprovider", "match": map[string]interface{}{"value": model.Provider}},
+				{"key": "embeddingmodel", "match": map[string]interface{}{"value": model.Model}},
+				{"key": "embeddingdimension", "match": map[string]interface{}{"value": model.Dimension}},
+			}},
+		}},
+	}
+	if cluster != "" {
+		must = append(must, map[string]interface{}{"key": "cluster", "match": map[string]interface{}{"value": cluster}})
+	}
+	return c.search(vector, limit, map[string]interface{}{"must": must})
+}
+
+// search searches for the alerts most similar to vector matching filter, if set
+func (c *QdrantClient) search(vector []float32, limit int, filter map[string]interface{}) ([]types.Anomaly, error) {
 	// Create search payload in Qdrant format
 	searchPayload := map[string]interface{}{
 		"vector":       vector,
 		"limit":        limit,
 		"with_payload": true,
 	}
+	if filter != nil {
+		searchPayload["filter"] = filter
+	}
 
 	// Marshal to JSON
 	data, err := json.Marshal(searchPayload)
@@ -241,81 +446,158 @@ func (c *QdrantClient) SearchSimilarAlerts(vector []float32, limit int) ([]types
 	// Convert to anomalies with consistent field handling
 	anomalies := make([]types.Anomaly, len(result.Result))
 	for i, r := range result.Result {
-		payload := r.Payload
-
-		anomaly := types.Anomaly{
-			Type:                 getStringFromPayload(payload, "type"),
-			ResourceType:         getStringFromPayload(payload, "resourcetype"),
-			Resource:             getStringFromPayload(payload, "resource"),
-			ClusterName:          getStringFromPayload(payload, "cluster"),
-			Namespace:            getStringFromPayload(payload, "namespace"),
-			NodeName:             getStringFromPayload(payload, "nodename"),
-			Severity:             getStringFromPayload(payload, "severity"),
-			Description:          getStringFromPayload(payload, "description"),
-			NamespacesOnThisNode: getStringFromPayload(payload, "namespacesonthisnode"),
-		}
+		anomalies[i] = anomalyFromPayload(r.Payload)
+	}
 
-		// Numeric values
-		if value, ok := payload["value"].(float64); ok {
-			anomaly.Value = value
-		}
-		if threshold, ok := payload["threshold"].(float64); ok {
-			anomaly.Threshold = threshold
-		}
+	return anomalies, nil
+}
 
-		// Timestamp (stored as unix seconds)
-		if ts, ok := payload["timestamp"].(float64); ok {
-			anomaly.Timestamp = time.Unix(int64(ts), 0)
-		}
+// alertPayload returns the payload stored with an alert by the vector database backends
+func alertPayload(alert StoreRequest) map[string]interface{} {
+	anomaly := alert.Anomaly
+	payload := map[string]interface{}{
+		"alertid":              anomaly.ID,
+		"fingerprint":          anomaly.Fingerprint,
+		"type":                 anomaly.Type,
+		"resourcetype":         anomaly.ResourceType,
+		"resource":             anomaly.Resource,
+		"cluster":              anomaly.ClusterName,
+		"namespace":            anomaly.Namespace,
+		"nodename":             anomaly.NodeName,
+		"severity":             anomaly.Severity,
+		"description":          anomaly.Description,
+		"value":                anomaly.Value,
+		"threshold":            anomaly.Threshold,
+		"namespacesonthisnode": anomaly.NamespacesOnThisNode,
+		"events":               alert.Events,
+		"labels":               anomaly.Labels,
+		"timestamp":            observedAt(anomaly).Unix(),
+		"cycle":                anomaly.Cycle,
+	}
 
-		// Labels map[string]string
-		if labelsRaw, ok := payload["labels"].(map[string]interface{}); ok {
-			labels := make(map[string]string, len(labelsRaw))
-			for k, v := range labelsRaw {
-				if sv, ok := v.(string); ok {
-					labels[k] = sv
-				} else {
-					labels[k] = fmt.Sprintf("%v", v)
-				}
+	// Add optional fields if they exist
+	if metadata := alert.metadata(); metadata != nil {
+		payload["metadata"] = metadata
+	}
+	if anomaly.CorrelationKeys != nil {
+		payload["correlationkeys"] = anomaly.CorrelationKeys
+	}
+	if anomaly.Feedback != "" {
+		payload["feedback"] = anomaly.Feedback
+	}
+	if !anomaly.Embedding.IsZero() {
+		payload["embeddingprovider"] = anomaly.Embedding.Provider
+		payload["embeddingmodel"] = anomaly.Embedding.Model
+		payload["embeddingdimension"] = anomaly.Embedding.Dimension
+	}
+	return payload
+}
+
+// anomalyFromPayload converts the payload of a stored point back to an anomaly
+func anomalyFromPayload(payload map[string]interface{}) types.Anomaly {
+	anomaly := types.Anomaly{
+		ID:                   getStringFromPayload(payload, "alertid"),
+		Fingerprint:          getStringFromPayload(payload, "fingerprint"),
+		Feedback:             getStringFromPayload(payload, "feedback"),
+		Type:                 getStringFromPayload(payload, "type"),
+		ResourceType:         getStringFromPayload(payload, "resourcetype"),
+		Resource:             getStringFromPayload(payload, "resource"),
+		ClusterName:          getStringFromPayload(payload, "cluster"),
+		Namespace:            getStringFromPayload(payload, "namespace"),
+		NodeName:             getStringFromPayload(payload, "nodename"),
+		Severity:             types.Severity(getStringFromPayload(payload, "severity")).Normalize(),
+		Description:          getStringFromPayload(payload, "description"),
+		NamespacesOnThisNode: getStringFromPayload(payload, "namespacesonthisnode"),
+		Embedding: types.EmbeddingModel{
+			Provider: getStringFromPayload(payload, "embeddingprovider"),
+			Model:    getStringFromPayload(payload, "embeddingmodel"),
+		},
+	}
+
+	// Numeric values
+	if value, ok := payload["value"].(float64); ok {
+		anomaly.Value = value
+	}
+	if threshold, ok := payload["threshold"].(float64); ok {
+		anomaly.Threshold = threshold
+	}
+
+	// Timestamp (stored as unix seconds)
+	if ts, ok := payload["timestamp"].(float64); ok {
+		anomaly.Timestamp = time.Unix(int64(ts), 0)
+	}
+	if cycle, ok := payload["cycle"].(float64); ok {
+		anomaly.Cycle = int64(cycle)
+	}
+	if dimension, ok := payload["embeddingdimension"].(float64); ok {
+		anomaly.Embedding.Dimension = int(dimension)
+	}
+
+	// Labels map[string]string
+	if labelsRaw, ok := payload["labels"].(map[string]interface{}); ok {
+		labels := make(map[string]string, len(labelsRaw))
+		for k, v := range labelsRaw {
+			if sv, ok := v.(string); ok {
+				labels[k] = sv
+			} else {
+				labels[k] = fmt.Sprintf("%v", v)
 			}
-			anomaly.Labels = labels
 		}
+		anomaly.Labels = labels
+	}
 
-		// Metadata passthrough if object
-		if md, ok := payload["metadata"].(map[string]interface{}); ok {
-			anomaly.Metadata = md
+	// Correlation keys map[string]string
+	if keysRaw, ok := payload["correlationkeys"].(map[string]interface{}); ok {
+		keys := make(map[string]string, len(keysRaw))
+		for k, v := range keysRaw {
+			keys[k] = fmt.Sprintf("%v", v)
 		}
+		anomaly.CorrelationKeys = keys
+	}
+
+	// Metadata passthrough if object
+	if md, ok := payload["metadata"].(map[string]interface{}); ok {
+		anomaly.Metadata = md
+	}
 
-		// Events []types.Event (best-effort conversion)
-		if evRaw, ok := payload["events"].([]interface{}); ok {
-			events := make([]types.Event, 0, len(evRaw))
-			for _, e := range evRaw {
-				if m, ok := e.(map[string]interface{}); ok {
-					evt := types.Event{}
-					if s, ok := m["Type"].(string); ok {
-						evt.Type = s
-					}
-					if s, ok := m["Reason"].(string); ok {
-						evt.Reason = s
-					}
-					if s, ok := m["Message"].(string); ok {
-						evt.Message = s
-					}
-					if num, ok := m["Timestamp"].(float64); ok {
-						evt.Timestamp = time.Unix(int64(num), 0)
-					}
-					events = append(events, evt)
+	// Events []types.Event (best-effort conversion)
+	if evRaw, ok := payload["events"].([]interface{}); ok {
+		events := make([]types.Event, 0, len(evRaw))
+		for _, e := range evRaw {
+			if m, ok := e.(map[string]interface{}); ok {
+				evt := types.Event{}
+				if s, ok := m["Type"].(string); ok {
+					evt.Type = s
 				}
-			}
-			if len(events) > 0 {
-				anomaly.Events = events
+				if s, ok := m["Reason"].(string); ok {
+					evt.Reason = s
+				}
+				if s, ok := m["Message"].(string); ok {
+					evt.Message = s
+				}
+				if num, ok := m["Timestamp"].(float64); ok {
+					evt.Timestamp = time.Unix(int64(num), 0)
+				}
+				events = append(events, evt)
 			}
 		}
-
-		anomalies[i] = anomaly
+		if len(events) > 0 {
+			anomaly.Events = events
+		}
 	}
+	return anomaly
+}
 
-	return anomalies, nil
+// pointID returns the Qdrant point ID for an alert ID. Qdrant only accepts UUIDs and integers,
+// so other IDs are mapped to a name-based UUID; the alert ID itself is kept in the payload.
+func pointID(alertID string) string {
+	if alertID == "" {
+		return uuid.New().String()
+	}
+	if _, err := uuid.Parse(alertID); err == nil {
+		return alertID
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("huginn:"+alertID)).String()
 }
 
 // getStringFromPayload safely extracts a string value from the payload
@@ -326,6 +608,198 @@ func getStringFromPayload(payload map[string]interface{}, key string) string {
 	return ""
 }
 
+// SetFeedback labels a stored alert as a true or false positive by updating its payload
+func (c *QdrantClient) SetFeedback(id string, feedback string) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"payload": map[string]interface{}{"feedback": feedback},
+		"points":  []string{pointID(id)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal feedback payload: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/collections/%s/points/payload", c.url, c.collection)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to store alert feedback in Qdrant: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to store alert feedback in Qdrant: %s - %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+// FindByFingerprint returns the most recent alert with the fingerprint stored since the given
+// time, or nil if there is none
+func (c *QdrantClient) FindByFingerprint(fingerprint string, since time.Time) (*types.Anomaly, error) {
+	payloads, _, err := c.scroll(map[string]interface{}{
+		"filter": map[string]interface{}{
+			"must": []map[string]interface{}{
+				{"key": "fingerprint", "match": map[string]interface{}{"value": fingerprint}},
+				{"key": "timestamp", "range": map[string]interface{}{"gte": since.Unix()}},
+			},
+		},
+		"order_by":     map[string]interface{}{"key": "timestamp", "direction": "desc"},
+		"limit":        1,
+		"with_payload": true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search alerts by fingerprint in Qdrant: %v", err)
+	}
+	if len(payloads) == 0 {
+		return nil, nil
+	}
+	anomaly := anomalyFromPayload(payloads[0])
+	return &anomaly, nil
+}
+
+// AggregateAlerts counts the alerts matching query, scrolling through the grouped payload fields
+func (c *QdrantClient) AggregateAlerts(query AggregateQuery) ([]AlertCount, error) {
+	must := []map[string]interface{}{
+		{"key": "timestamp", "range": map[string]interface{}{"gte": query.Start.Unix(), "lte": query.End.Unix()}},
+	}
+	if query.Cluster != "" {
+		must = append(must, map[string]interface{}{"key": "cluster", "match": map[string]interface{}{"value": query.Cluster}})
+	}
+	if query.Namespace != "" {
+		must = append(must, map[string]interface{}{"key": "namespace", "match": map[string]interface{}{"value": query.Namespace}})
+	}
+
+	var anomalies []types.Anomaly
+	var offset interface{}
+	for {
+		request := map[string]interface{}{
+			"filter":       map[string]interface{}{"must": must},
+			"limit":        1000,
+			"with_payload": []string{"type", "cluster", "namespace", "severity", "timestamp"},
+			"with_vector":  false,
+		}
+		if offset != nil {
+			request["offset"] = offset
+		}
+		payloads, next, err := c.scroll(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to aggregate alerts in Qdrant: %v", err)
+		}
+		for _, payload := range payloads {
+			anomalies = append(anomalies, anomalyFromPayload(payload))
+		}
+		if next == nil {
+			break
+		}
+		offset = next
+	}
+	return aggregate(anomalies, query), nil
+}
+
+// DumpAlerts calls fn with every stored alert and its vector, scrolling through the collection
+func (c *QdrantClient) DumpAlerts(fn func(StoreRequest) error) error {
+	var offset interface{}
+	for {
+		request := map[string]interface{}{
+			"limit":        256,
+			"with_payload": true,
+			"with_vector":  true,
+		}
+		if offset != nil {
+			request["offset"] = offset
+		}
+		points, next, err := c.scrollPoints(request)
+		if err != nil {
+			return fmt.Errorf("failed to dump alerts from Qdrant: %v", err)
+		}
+		for _, point := range points {
+			anomaly := anomalyFromPayload(point.Payload)
+			if err := fn(StoreRequest{Anomaly: anomaly, Events: anomaly.Events, Vector: point.Vector}); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		offset = next
+	}
+}
+
+// qdrantPoint is a point returned by a scroll request
+type qdrantPoint struct {
+	Payload map[string]interface{} `json:"payload"`
+	Vector  []float32              `json:"vector"`
+}
+
+// scroll runs a scroll request, returning the payloads of the points and the offset of the
+// next page, nil on the last one
+func (c *QdrantClient) scroll(request map[string]interface{}) ([]map[string]interface{}, interface{}, error) {
+	points, next, err := c.scrollPoints(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	payloads := make([]map[string]interface{}, len(points))
+	for i, point := range points {
+		payloads[i] = point.Payload
+	}
+	return payloads, next, nil
+}
+
+// scrollPoints runs a scroll request, returning the points and the offset of the next page, nil
+// on the last one
+func (c *QdrantClient) scrollPoints(request map[string]interface{}) ([]qdrantPoint, interface{}, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal scroll request: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/collections/%s/points/scroll", c.url, c.collection)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("%s - %s", resp.Status, string(body))
+	}
+
+	var result struct {
+		Result struct {
+			Points         []qdrantPoint `json:"points"`
+			NextPageOffset interface{}   `json:"next_page_offset"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode scroll response: %v", err)
+	}
+	return result.Result.Points, result.Result.NextPageOffset, nil
+}
+
+// Ping checks that Qdrant is reachable and the collection exists
+func (c *QdrantClient) Ping() error {
+	resp, err := c.client.Get(fmt.Sprintf("%s/collections/%s", c.url, c.collection))
+	if err != nil {
+		return fmt.Errorf("failed to reach Qdrant: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from Qdrant: %s", resp.Status)
+	}
+	return nil
+}
+
 // GetAlert implements the Storage interface
 func (q *QdrantClient) GetAlert(id string) (*AlertVector, error) {
 	url := fmt.Sprintf("%s/collections/%s/points/%s", q.url, q.collection, id)
@@ -353,13 +827,21 @@ func (q *QdrantClient) GetAlert(id string) (*AlertVector, error) {
 	return &result, nil
 }
 
-// ListAlerts implements the Storage interface
-func (q *QdrantClient) ListAlerts(namespace, severity string, startTime, endTime time.Time) ([]AlertVector, error) {
+// ListAlerts returns the alerts of a cluster name, namespace and severity, if set, stored within
+// a time range
+func (q *QdrantClient) ListAlerts(cluster, namespace, severity string, startTime, endTime time.Time) ([]AlertVector, error) {
 	// Create filter payload
 	filter := map[string]interface{}{
 		"must": []map[string]interface{}{},
 	}
 
+	if cluster != "" {
+		filter["must"] = append(filter["must"].([]map[string]interface{}), map[string]interface{}{
+			"key":   "cluster",
+			"match": map[string]string{"value": cluster},
+		})
+	}
+
 	if namespace != "" {
 		filter["must"] = append(filter["must"].([]map[string]interface{}), map[string]interface{}{
 			"key":   "namespace",
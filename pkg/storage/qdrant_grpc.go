@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// Qdrant transports
+const (
+	QdrantProtocolREST = "rest"
+	QdrantProtocolGRPC = "grpc"
+)
+
+// qdrantGRPCPort is the port Qdrant serves gRPC on by default
+const qdrantGRPCPort = "6334"
+
+// QdrantGRPCClient stores and searches alerts over Qdrant's gRPC API with the official client,
+// which avoids the JSON encoding of vectors. Collection management, feedback and health checks
+// use the REST API, which is also the fallback when a gRPC call cannot reach the server.
+type QdrantGRPCClient struct {
+	*QdrantClient
+	grpc *qdrant.Client
+}
+
+// NewQdrantGRPCClient creates a Qdrant client using gRPC at grpcURL, or at the REST host on port
+// 6334 if grpcURL is empty. The connection is established on first use.
+func NewQdrantGRPCClient(restURL, grpcURL, collection string, vectorSize int, distance string, options QdrantOptions) (*QdrantGRPCClient, error) {
+	rest, err := NewQdrantClient(restURL, collection, vectorSize, distance, options)
+	if err != nil {
+		return nil, err
+	}
+	if grpcURL == "" {
+		grpcURL, err = defaultGRPCURL(restURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	u, err := url.Parse(grpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Qdrant gRPC URL %q: %v", grpcURL, err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return nil, fmt.Errorf("invalid Qdrant gRPC URL %q: a port is required", grpcURL)
+	}
+
+	tlsConfig, err := options.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := qdrant.NewClient(&qdrant.Config{
+		Host:      u.Hostname(),
+		Port:      port,
+		APIKey:    options.APIKey,
+		UseTLS:    u.Scheme == "https",
+		TLSConfig: tlsConfig,
+		// The check calls the server, which may not be reachable yet at startup
+		SkipCompatibilityCheck: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Qdrant gRPC client: %v", err)
+	}
+	return &QdrantGRPCClient{QdrantClient: rest, grpc: client}, nil
+}
+
+// defaultGRPCURL returns the REST URL with Qdrant's gRPC port
+func defaultGRPCURL(restURL string) (string, error) {
+	u, err := url.Parse(restURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid Qdrant URL %q: %v", restURL, err)
+	}
+	u.Host = net.JoinHostPort(u.Hostname(), qdrantGRPCPort)
+	u.Path = ""
+	return u.String(), nil
+}
+
+// StoreAlert upserts an alert over gRPC, falling back to REST if the server cannot be reached
+func (c *QdrantGRPCClient) StoreAlert(alert StoreRequest) error {
+	return c.StoreAlerts([]StoreRequest{alert})
+}
+
+// StoreAlerts upserts alerts with a single gRPC call, falling back to REST if the server cannot
+// be reached
+func (c *QdrantGRPCClient) StoreAlerts(alerts []StoreRequest) error {
+	points := make([]*qdrant.PointStruct, len(alerts))
+	for i, alert := range alerts {
+		payload, err := grpcPayload(alertPayload(alert))
+		if err != nil {
+			return err
+		}
+		points[i] = &qdrant.PointStruct{
+			Id:      qdrant.NewIDUUID(pointID(alert.Anomaly.ID)),
+			Vectors: qdrant.NewVectorsDense(alert.Vector),
+			Payload: payload,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.options.timeout())
+	defer cancel()
+	_, err := c.grpc.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: c.collection,
+		Wait:           qdrant.PtrOf(true),
+		Points:         points,
+	})
+	if err != nil {
+		if !isTransportError(err) {
+			return fmt.Errorf("failed to upsert alerts over gRPC: %v", err)
+		}
+		log.Printf("Warning: Qdrant gRPC unavailable, storing alerts over REST: %v", err)
+		return c.QdrantClient.StoreAlerts(alerts)
+	}
+	return nil
+}
+
+// SearchSimilarAlerts searches alerts over gRPC, falling back to REST if the server cannot be reached
+func (c *QdrantGRPCClient) SearchSimilarAlerts(vector []float32, limit int) ([]types.Anomaly, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.options.timeout())
+	defer cancel()
+	response, err := c.grpc.GetPointsClient().Search(ctx, &qdrant.SearchPoints{
+		CollectionName: c.collection,
+		Vector:         vector,
+		Limit:          uint64(limit),
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		if !isTransportError(err) {
+			return nil, fmt.Errorf("failed to search alerts over gRPC: %v", err)
+		}
+		log.Printf("Warning: Qdrant gRPC unavailable, searching alerts over REST: %v", err)
+		return c.QdrantClient.SearchSimilarAlerts(vector, limit)
+	}
+
+	anomalies := make([]types.Anomaly, 0, len(response.GetResult()))
+	for _, point := range response.GetResult() {
+		payload := make(map[string]interface{}, len(point.GetPayload()))
+		for key, value := range point.GetPayload() {
+			payload[key] = jsonValue(value)
+		}
+		anomalies = append(anomalies, anomalyFromPayload(payload))
+	}
+	return anomalies, nil
+}
+
+// isTransportError reports whether a call failed before the server could answer it
+func isTransportError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// grpcPayload converts a payload to Qdrant values. The payload is normalized through JSON so it
+// is stored exactly as over REST, with integral numbers stored as integers as Qdrant does for JSON
+// payloads.
+func grpcPayload(payload map[string]interface{}) (map[string]*qdrant.Value, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var normalized map[string]interface{}
+	if err := decoder.Decode(&normalized); err != nil {
+		return nil, fmt.Errorf("failed to normalize payload: %v", err)
+	}
+	values, err := qdrant.TryValueMap(payloadNumbers(normalized).(map[string]interface{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert payload: %v", err)
+	}
+	return values, nil
+}
+
+// payloadNumbers converts the JSON numbers of a decoded value to integers if they are integral
+// and to floats otherwise
+func payloadNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = payloadNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = payloadNumbers(item)
+		}
+	}
+	return v
+}
+
+// jsonValue converts a Qdrant value into the types JSON decoding produces, so payloads read over
+// gRPC convert to anomalies like those read over REST
+func jsonValue(value *qdrant.Value) interface{} {
+	switch kind := value.GetKind().(type) {
+	case *qdrant.Value_DoubleValue:
+		return kind.DoubleValue
+	case *qdrant.Value_IntegerValue:
+		return float64(kind.IntegerValue)
+	case *qdrant.Value_StringValue:
+		return kind.StringValue
+	case *qdrant.Value_BoolValue:
+		return kind.BoolValue
+	case *qdrant.Value_StructValue:
+		fields := make(map[string]interface{}, len(kind.StructValue.GetFields()))
+		for key, field := range kind.StructValue.GetFields() {
+			fields[key] = jsonValue(field)
+		}
+		return fields
+	case *qdrant.Value_ListValue:
+		list := make([]interface{}, 0, len(kind.ListValue.GetValues()))
+		for _, item := range kind.ListValue.GetValues() {
+			list = append(list, jsonValue(item))
+		}
+		return list
+	}
+	return nil
+}
@@ -2,18 +2,36 @@ package storage
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/rodolfo-mora/huginn/pkg/types"
 )
 
-// RedisClient implements the Storage interface using Redis
+const (
+	// redisVectorIndex is the RediSearch index of the alert vectors
+	redisVectorIndex = "huginn:alerts"
+	// redisTimeIndex is the sorted set of alert IDs scored by timestamp
+	redisTimeIndex = "alerts:time"
+)
+
+// RedisClient implements the Storage interface using Redis. Similar alerts are found by KNN search
+// over a RediSearch HNSW index when the module is available, and are the most recent alerts otherwise.
 type RedisClient struct {
-	client *redis.Client
-	ctx    context.Context
+	client  *redis.Client
+	ctx     context.Context
+	search  bool // RediSearch is available
+	mu      sync.Mutex
+	indexed bool // The vector index exists
 }
 
 // NewRedisClient creates a new Redis client
@@ -22,6 +40,8 @@ func NewRedisClient(url, password string, db int) (*RedisClient, error) {
 		Addr:     url,
 		Password: password,
 		DB:       db,
+		// RediSearch replies are parsed in their RESP2 form
+		Protocol: 2,
 	})
 
 	// Test connection
@@ -30,62 +50,198 @@ func NewRedisClient(url, password string, db int) (*RedisClient, error) {
 		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
 	}
 
-	return &RedisClient{
+	c := &RedisClient{
 		client: client,
 		ctx:    ctx,
-	}, nil
+	}
+	if err := client.Do(ctx, "FT._LIST").Err(); err != nil {
+		log.Printf("Warning: RediSearch is not available, similar alerts are the most recent ones: %v", err)
+	} else {
+		c.search = true
+		// The index is created on the first store, once the vector dimension is known
+		c.indexed = client.Do(ctx, "FT.INFO", redisVectorIndex).Err() == nil
+		if c.indexed {
+			// Indexes created before cluster filtering lack the cluster field
+			if err := client.Do(ctx, "FT.ALTER", redisVectorIndex, "SCHEMA", "ADD", "cluster", "TAG").Err(); err != nil && !strings.Contains(err.Error(), "Duplicate") {
+				log.Printf("Warning: failed to add the cluster field to the vector index: %v", err)
+			}
+		}
+	}
+	return c, nil
 }
 
-// StoreAlert stores an alert in Redis
-func (c *RedisClient) StoreAlert(vector []float32, anomaly types.Anomaly) error {
-	// Create alert vector
-	alertVector := AlertVector{
-		ID:        fmt.Sprintf("%s-%s-%d", anomaly.Type, anomaly.Resource, time.Now().UnixNano()),
-		Vector:    vector,
-		Timestamp: time.Now(),
-		Payload: AlertVectorPayload{
-			Type:        anomaly.Type,
-			Resource:    anomaly.Resource,
-			Namespace:   anomaly.Namespace,
-			Severity:    anomaly.Severity,
-			Description: anomaly.Description,
-			Value:       anomaly.Value,
-			Threshold:   anomaly.Threshold,
-			Labels:      anomaly.Labels,
-			Events:      anomaly.Events,
-			Metadata:    anomaly.Metadata,
-		},
+// ensureIndex creates the vector index of the given dimension if it does not exist
+func (c *RedisClient) ensureIndex(dimension int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.indexed {
+		return nil
 	}
 
-	// Marshal to JSON
-	data, err := json.Marshal(alertVector)
-	if err != nil {
-		return fmt.Errorf("failed to marshal alert vector: %v", err)
+	err := c.client.Do(c.ctx, "FT.CREATE", redisVectorIndex, "ON", "HASH", "PREFIX", "1", "vector:",
+		"SCHEMA", "embedding", "VECTOR", "HNSW", "6", "TYPE", "FLOAT32", "DIM", dimension, "DISTANCE_METRIC", "COSINE",
+		"cluster", "TAG").Err()
+	if err != nil && !strings.Contains(err.Error(), "Index already exists") {
+		return fmt.Errorf("failed to create vector index: %v", err)
 	}
+	c.indexed = true
+	return nil
+}
+
+// StoreAlert stores an alert in Redis
+func (c *RedisClient) StoreAlert(alert StoreRequest) error {
+	return c.StoreAlerts([]StoreRequest{alert})
+}
 
-	// Store in Redis
-	key := fmt.Sprintf("alert:%s", alertVector.ID)
-	if err := c.client.Set(c.ctx, key, data, 24*time.Hour).Err(); err != nil {
-		return fmt.Errorf("failed to store alert in Redis: %v", err)
+// StoreAlerts stores alerts in Redis through a single pipeline
+func (c *RedisClient) StoreAlerts(alerts []StoreRequest) error {
+	if c.search && len(alerts) > 0 {
+		if err := c.ensureIndex(len(alerts[0].Vector)); err != nil {
+			log.Printf("Warning: %v", err)
+		}
 	}
 
-	// Add to vector index
-	// Note: This is a simplified implementation. In a real system, you would use
-	// a proper vector similarity search library or Redis module.
-	vectorKey := fmt.Sprintf("vector:%s", alertVector.ID)
-	if err := c.client.Set(c.ctx, vectorKey, vector, 24*time.Hour).Err(); err != nil {
-		return fmt.Errorf("failed to store vector in Redis: %v", err)
+	pipe := c.client.Pipeline()
+	for _, alert := range alerts {
+		alertVector := redisAlertVector(alert)
+
+		// Marshal to JSON
+		data, err := json.Marshal(alertVector)
+		if err != nil {
+			return fmt.Errorf("failed to marshal alert vector: %v", err)
+		}
+		pipe.Set(c.ctx, fmt.Sprintf("alert:%s", alertVector.ID), data, 24*time.Hour)
+
+		// Vectors are hashes with the embedding as little-endian float32s, as RediSearch indexes them
+		vectorKey := fmt.Sprintf("vector:%s", alertVector.ID)
+		pipe.HSet(c.ctx, vectorKey, "embedding", vectorBytes(alert.Vector), "cluster", alertVector.Payload.Cluster)
+		pipe.Expire(c.ctx, vectorKey, 24*time.Hour)
+
+		// Secondary indexes read by ListAlerts and DeleteAlert
+		pipe.SAdd(c.ctx, "alerts:all", alertVector.ID)
+		if alertVector.Payload.Cluster != "" {
+			pipe.SAdd(c.ctx, fmt.Sprintf("alerts:cluster:%s", alertVector.Payload.Cluster), alertVector.ID)
+		}
+		if alertVector.Payload.Namespace != "" {
+			pipe.SAdd(c.ctx, fmt.Sprintf("alerts:namespace:%s", alertVector.Payload.Namespace), alertVector.ID)
+		}
+		if alertVector.Payload.Severity != "" {
+			pipe.SAdd(c.ctx, fmt.Sprintf("alerts:severity:%s", alertVector.Payload.Severity), alertVector.ID)
+		}
+		pipe.ZAdd(c.ctx, redisTimeIndex, redis.Z{Score: float64(alertVector.Timestamp.Unix()), Member: alertVector.ID})
 	}
+	// Alerts expire with their keys; set members are removed when ListAlerts finds them expired
+	pipe.ZRemRangeByScore(c.ctx, redisTimeIndex, "-inf", strconv.FormatInt(time.Now().Add(-24*time.Hour).Unix(), 10))
 
+	if _, err := pipe.Exec(c.ctx); err != nil {
+		return fmt.Errorf("failed to store alerts in Redis: %v", err)
+	}
 	return nil
 }
 
-// SearchSimilarAlerts searches for similar alerts in Redis
+// redisAlertVector converts an alert to the record stored in Redis
+func redisAlertVector(alert StoreRequest) AlertVector {
+	anomaly := alert.Anomaly
+	id := anomaly.ID
+	if id == "" {
+		id = fmt.Sprintf("%s-%s-%d", anomaly.Type, anomaly.Resource, time.Now().UnixNano())
+	}
+	return AlertVector{
+		ID:        id,
+		Vector:    alert.Vector,
+		Timestamp: observedAt(anomaly),
+		Payload: AlertVectorPayload{
+			Fingerprint:     anomaly.Fingerprint,
+			Cluster:         anomaly.ClusterName,
+			Type:            anomaly.Type,
+			Resource:        anomaly.Resource,
+			Namespace:       anomaly.Namespace,
+			Severity:        anomaly.Severity,
+			Description:     anomaly.Description,
+			Value:           anomaly.Value,
+			Threshold:       anomaly.Threshold,
+			Labels:          anomaly.Labels,
+			Events:          alert.Events,
+			Metadata:        alert.metadata(),
+			CorrelationKeys: anomaly.CorrelationKeys,
+			Feedback:        anomaly.Feedback,
+			Embedding:       anomaly.Embedding,
+		},
+	}
+}
+
+// SearchSimilarAlerts searches for similar alerts by KNN search if RediSearch is available,
+// returning the most recent alerts otherwise
 func (c *RedisClient) SearchSimilarAlerts(vector []float32, limit int) ([]types.Anomaly, error) {
-	// Note: This is a simplified implementation. In a real system, you would use
-	// a proper vector similarity search library or Redis module.
-	// This implementation just returns the most recent alerts.
+	return c.searchAlerts("", vector, limit)
+}
+
+// SearchSimilarAlertsInCluster searches for similar alerts of a cluster, pre-filtering the KNN
+// search by the cluster tag
+func (c *RedisClient) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return c.searchAlerts(cluster, vector, limit)
+}
 
+// searchAlerts returns the alerts of cluster, or of every cluster if it is empty, nearest to vector
+func (c *RedisClient) searchAlerts(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	c.mu.Lock()
+	indexed := c.indexed
+	c.mu.Unlock()
+	if indexed {
+		anomalies, err := c.searchKNN(cluster, vector, limit)
+		if err == nil {
+			return anomalies, nil
+		}
+		log.Printf("Warning: vector search failed, returning recent alerts: %v", err)
+	}
+	return c.recentAlerts(cluster, limit)
+}
+
+// searchKNN returns the alerts of cluster, if set, nearest to vector in the RediSearch index
+func (c *RedisClient) searchKNN(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	filter := "*"
+	if cluster != "" {
+		filter = fmt.Sprintf("(@cluster:{%s})", redisTagEscape(cluster))
+	}
+	reply, err := c.client.Do(c.ctx, "FT.SEARCH", redisVectorIndex,
+		fmt.Sprintf("%s=>[KNN %d @embedding $vec AS score]", filter, limit),
+		"PARAMS", "2", "vec", vectorBytes(vector),
+		"SORTBY", "score", "RETURN", "1", "score",
+		"LIMIT", "0", strconv.Itoa(limit), "DIALECT", "2").Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	// The reply is the total followed by each key and its returned fields
+	var anomalies []types.Anomaly
+	for i := 1; i < len(reply); i += 2 {
+		key, ok := reply[i].(string)
+		if !ok {
+			continue
+		}
+		alert, err := c.GetAlert(strings.TrimPrefix(key, "vector:"))
+		if err != nil {
+			continue
+		}
+		anomalies = append(anomalies, alert.anomaly())
+	}
+	return anomalies, nil
+}
+
+// redisTagEscape escapes the punctuation and spaces of a TAG query value
+func redisTagEscape(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// recentAlerts returns stored alerts of cluster, if set, without ranking them by similarity
+func (c *RedisClient) recentAlerts(cluster string, limit int) ([]types.Anomaly, error) {
 	// Get all vector keys
 	keys, err := c.client.Keys(c.ctx, "vector:*").Result()
 	if err != nil {
@@ -108,21 +264,10 @@ func (c *RedisClient) SearchSimilarAlerts(vector []float32, limit int) ([]types.
 			continue
 		}
 
-		// Convert to anomaly
-		anomaly := types.Anomaly{
-			Type:        alertVector.Payload.Type,
-			Resource:    alertVector.Payload.Resource,
-			Namespace:   alertVector.Payload.Namespace,
-			Severity:    alertVector.Payload.Severity,
-			Description: alertVector.Payload.Description,
-			Value:       alertVector.Payload.Value,
-			Threshold:   alertVector.Payload.Threshold,
-			Labels:      alertVector.Payload.Labels,
-			Events:      alertVector.Payload.Events,
-			Metadata:    alertVector.Payload.Metadata,
+		if cluster != "" && alertVector.Payload.Cluster != cluster {
+			continue
 		}
-
-		anomalies = append(anomalies, anomaly)
+		anomalies = append(anomalies, alertVector.anomaly())
 		if len(anomalies) >= limit {
 			break
 		}
@@ -131,6 +276,41 @@ func (c *RedisClient) SearchSimilarAlerts(vector []float32, limit int) ([]types.
 	return anomalies, nil
 }
 
+// vectorBytes encodes a vector as little-endian float32s
+func vectorBytes(vector []float32) []byte {
+	b := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return b
+}
+
+// Ping checks that Redis is reachable
+func (c *RedisClient) Ping() error {
+	if err := c.client.Ping(c.ctx).Err(); err != nil {
+		return fmt.Errorf("failed to ping Redis: %v", err)
+	}
+	return nil
+}
+
+// SetFeedback labels a stored alert as a true or false positive, keeping its expiry
+func (c *RedisClient) SetFeedback(id string, feedback string) error {
+	alert, err := c.GetAlert(id)
+	if err != nil {
+		return err
+	}
+	alert.Payload.Feedback = feedback
+
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert vector: %v", err)
+	}
+	if err := c.client.Set(c.ctx, fmt.Sprintf("alert:%s", id), data, redis.KeepTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store alert feedback in Redis: %v", err)
+	}
+	return nil
+}
+
 // GetAlert implements the Storage interface
 func (r *RedisClient) GetAlert(id string) (*AlertVector, error) {
 	key := fmt.Sprintf("alert:%s", id)
@@ -150,46 +330,55 @@ func (r *RedisClient) GetAlert(id string) (*AlertVector, error) {
 	return &alert, nil
 }
 
-// ListAlerts implements the Storage interface
-func (r *RedisClient) ListAlerts(namespace, severity string, startTime, endTime time.Time) ([]AlertVector, error) {
-	var alertIDs []string
-	var err error
-
-	// Get base set of alerts
-	if namespace != "" && severity != "" {
-		// Intersect namespace and severity sets
-		key := fmt.Sprintf("alerts:namespace:%s", namespace)
-		severityKey := fmt.Sprintf("alerts:severity:%s", severity)
-		alertIDs, err = r.client.SInter(r.ctx, key, severityKey).Result()
-	} else if namespace != "" {
-		alertIDs, err = r.client.SMembers(r.ctx, fmt.Sprintf("alerts:namespace:%s", namespace)).Result()
-	} else if severity != "" {
-		alertIDs, err = r.client.SMembers(r.ctx, fmt.Sprintf("alerts:severity:%s", severity)).Result()
-	} else {
-		alertIDs, err = r.client.SMembers(r.ctx, "alerts:all").Result()
-	}
-
+// ListAlerts returns the alerts of a cluster name, namespace and severity, if set, stored within
+// a time range
+func (r *RedisClient) ListAlerts(cluster, namespace, severity string, startTime, endTime time.Time) ([]AlertVector, error) {
+	// Alerts within the time range, oldest first
+	alertIDs, err := r.client.ZRangeByScore(r.ctx, redisTimeIndex, &redis.ZRangeBy{
+		Min: strconv.FormatInt(startTime.Unix(), 10),
+		Max: strconv.FormatInt(endTime.Unix(), 10),
+	}).Result()
 	if err != nil {
 		return nil, fmt.Errorf("error getting alert IDs: %v", err)
 	}
 
-	// Filter by time range
-	var filteredIDs []string
-	for _, id := range alertIDs {
-		alert, err := r.GetAlert(id)
+	// Restrict them to the cluster, namespace and severity sets
+	var filters []string
+	if cluster != "" {
+		filters = append(filters, fmt.Sprintf("alerts:cluster:%s", cluster))
+	}
+	if namespace != "" {
+		filters = append(filters, fmt.Sprintf("alerts:namespace:%s", namespace))
+	}
+	if severity != "" {
+		filters = append(filters, fmt.Sprintf("alerts:severity:%s", severity))
+	}
+	if len(filters) > 0 {
+		members, err := r.client.SInter(r.ctx, filters...).Result()
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("error getting alert IDs: %v", err)
 		}
-		if alert.Timestamp.After(startTime) && alert.Timestamp.Before(endTime) {
-			filteredIDs = append(filteredIDs, id)
+		matching := make(map[string]bool, len(members))
+		for _, id := range members {
+			matching[id] = true
 		}
+		filtered := alertIDs[:0]
+		for _, id := range alertIDs {
+			if matching[id] {
+				filtered = append(filtered, id)
+			}
+		}
+		alertIDs = filtered
 	}
 
-	// Get full alert data
+	// Get full alert data, dropping expired alerts from the indexes
 	var alerts []AlertVector
-	for _, id := range filteredIDs {
+	for _, id := range alertIDs {
 		alert, err := r.GetAlert(id)
 		if err != nil {
+			if _, getErr := r.client.Get(r.ctx, fmt.Sprintf("alert:%s", id)).Result(); getErr == redis.Nil {
+				r.removeFromIndexes(id, cluster, namespace, severity)
+			}
 			continue
 		}
 		alerts = append(alerts, *alert)
@@ -198,6 +387,54 @@ func (r *RedisClient) ListAlerts(namespace, severity string, startTime, endTime
 	return alerts, nil
 }
 
+// AggregateAlerts counts the alerts matching query, read through the time and namespace indexes
+func (r *RedisClient) AggregateAlerts(query AggregateQuery) ([]AlertCount, error) {
+	alerts, err := r.ListAlerts(query.Cluster, query.Namespace, "", query.Start, query.End)
+	if err != nil {
+		return nil, err
+	}
+	anomalies := make([]types.Anomaly, len(alerts))
+	for i, alert := range alerts {
+		anomalies[i] = alert.anomaly()
+	}
+	return aggregate(anomalies, query), nil
+}
+
+// DumpAlerts calls fn with every stored alert and its vector, oldest first, read through the time
+// index
+func (r *RedisClient) DumpAlerts(fn func(StoreRequest) error) error {
+	alerts, err := r.ListAlerts("", "", "", time.Unix(0, 0), time.Now().Add(24*time.Hour))
+	if err != nil {
+		return fmt.Errorf("failed to dump alerts from Redis: %v", err)
+	}
+	for _, alert := range alerts {
+		anomaly := alert.anomaly()
+		if err := fn(StoreRequest{Anomaly: anomaly, Events: anomaly.Events, Vector: alert.Vector}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeFromIndexes removes an expired alert from the indexes it is known to be in
+func (r *RedisClient) removeFromIndexes(id, cluster, namespace, severity string) {
+	pipe := r.client.Pipeline()
+	pipe.SRem(r.ctx, "alerts:all", id)
+	pipe.ZRem(r.ctx, redisTimeIndex, id)
+	if cluster != "" {
+		pipe.SRem(r.ctx, fmt.Sprintf("alerts:cluster:%s", cluster), id)
+	}
+	if namespace != "" {
+		pipe.SRem(r.ctx, fmt.Sprintf("alerts:namespace:%s", namespace), id)
+	}
+	if severity != "" {
+		pipe.SRem(r.ctx, fmt.Sprintf("alerts:severity:%s", severity), id)
+	}
+	if _, err := pipe.Exec(r.ctx); err != nil {
+		log.Printf("Warning: failed to remove expired alert %s from indexes: %v", id, err)
+	}
+}
+
 // DeleteAlert implements the Storage interface
 func (r *RedisClient) DeleteAlert(id string) error {
 	alert, err := r.GetAlert(id)
@@ -207,7 +444,7 @@ func (r *RedisClient) DeleteAlert(id string) error {
 
 	// Delete from main storage
 	key := fmt.Sprintf("alert:%s", id)
-	if err := r.client.Del(r.ctx, key).Err(); err != nil {
+	if err := r.client.Del(r.ctx, key, fmt.Sprintf("vector:%s", id)).Err(); err != nil {
 		return fmt.Errorf("error deleting alert from Redis: %v", err)
 	}
 
@@ -216,6 +453,12 @@ func (r *RedisClient) DeleteAlert(id string) error {
 		return fmt.Errorf("error removing from all alerts index: %v", err)
 	}
 
+	if alert.Payload.Cluster != "" {
+		if err := r.client.SRem(r.ctx, fmt.Sprintf("alerts:cluster:%s", alert.Payload.Cluster), id).Err(); err != nil {
+			return fmt.Errorf("error removing from cluster index: %v", err)
+		}
+	}
+
 	if alert.Payload.Namespace != "" {
 		if err := r.client.SRem(r.ctx, fmt.Sprintf("alerts:namespace:%s", alert.Payload.Namespace), id).Err(); err != nil {
 			return fmt.Errorf("error removing from namespace index: %v", err)
@@ -228,8 +471,7 @@ func (r *RedisClient) DeleteAlert(id string) error {
 		}
 	}
 
-	timeKey := fmt.Sprintf("alerts:time:%d", alert.Timestamp.Unix())
-	if err := r.client.SRem(r.ctx, timeKey, id).Err(); err != nil {
+	if err := r.client.ZRem(r.ctx, redisTimeIndex, id).Err(); err != nil {
 		return fmt.Errorf("error removing from time index: %v", err)
 	}
 
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// ReplicatedStorage writes alerts to a primary storage and copies them to replicas, e.g. Qdrant
+// for search plus a local file as a fallback. Searches are answered by the primary. A failing
+// replica never fails a write: its alerts are kept and retried with its next write.
+type ReplicatedStorage struct {
+	Storage
+	replicas []*replica
+}
+
+// replica is a storage written to besides the primary, with the alerts of its failed writes
+type replica struct {
+	name      string
+	storage   Storage
+	queueSize int
+	mu        sync.Mutex
+	pending   []StoreRequest
+}
+
+// NewReplicatedStorage creates a storage writing to primary and to the backends of replicas.
+// Replicas connect on first use, so an unavailable replica does not prevent startup. Up to
+// queueSize alerts of failed writes are retried per replica.
+func NewReplicatedStorage(primary Storage, replicas []StorageConfig, queueSize int) (*ReplicatedStorage, error) {
+	r := &ReplicatedStorage{Storage: primary}
+	for _, config := range replicas {
+		client, err := NewDeferredStorage(config)
+		if err != nil {
+			return nil, err
+		}
+		r.replicas = append(r.replicas, &replica{name: string(config.Type), storage: client, queueSize: queueSize})
+	}
+	return r, nil
+}
+
+// StoreAlert stores an alert in the primary and the replicas
+func (r *ReplicatedStorage) StoreAlert(alert StoreRequest) error {
+	return r.StoreAlerts([]StoreRequest{alert})
+}
+
+// StoreAlerts stores alerts in the primary and the replicas concurrently, returning the error
+// of the primary only
+func (r *ReplicatedStorage) StoreAlerts(alerts []StoreRequest) error {
+	var wg sync.WaitGroup
+	for _, rep := range r.replicas {
+		wg.Add(1)
+		go func(rep *replica) {
+			defer wg.Done()
+			rep.write(alerts)
+		}(rep)
+	}
+	err := StoreAll(r.Storage, alerts)
+	wg.Wait()
+	return err
+}
+
+// SearchHybrid searches the primary by vector and keywords
+func (r *ReplicatedStorage) SearchHybrid(query string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchHybrid(r.Storage, query, vector, limit)
+}
+
+// SearchSimilarAlertsInCluster searches the primary for alerts of a cluster
+func (r *ReplicatedStorage) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchSimilarAlertsInCluster(r.Storage, cluster, vector, limit)
+}
+
+// SearchSimilarAlertsOfModel searches the primary for alerts embedded by a model
+func (r *ReplicatedStorage) SearchSimilarAlertsOfModel(model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchSimilarAlertsOfModel(r.Storage, model, cluster, vector, limit)
+}
+
+// AggregateAlerts counts the alerts stored in the primary
+func (r *ReplicatedStorage) AggregateAlerts(query AggregateQuery) ([]AlertCount, error) {
+	return AggregateAlerts(r.Storage, query)
+}
+
+// StoreObservations stores the observations of a cycle in the primary, and in the replicas on a
+// best-effort basis. Observations of failed replica writes are not retried.
+func (r *ReplicatedStorage) StoreObservations(state types.ClusterState) error {
+	for _, rep := range r.replicas {
+		if err := StoreObservations(rep.storage, state); err != nil {
+			log.Printf("Warning: failed to store observations in %s replica: %v", rep.name, err)
+		}
+	}
+	return StoreObservations(r.Storage, state)
+}
+
+// FindByFingerprint looks up an alert by fingerprint in the primary
+func (r *ReplicatedStorage) FindByFingerprint(fingerprint string, since time.Time) (*types.Anomaly, error) {
+	return FindByFingerprint(r.Storage, fingerprint, since)
+}
+
+// SetFeedback labels an alert in the primary, and in the replicas on a best-effort basis
+func (r *ReplicatedStorage) SetFeedback(id string, feedback string) error {
+	for _, rep := range r.replicas {
+		if err := rep.storage.SetFeedback(id, feedback); err != nil {
+			log.Printf("Warning: failed to store alert feedback in %s replica: %v", rep.name, err)
+		}
+	}
+	return r.Storage.SetFeedback(id, feedback)
+}
+
+// write stores the pending alerts of the replica followed by alerts, keeping the most recent
+// ones if the write fails
+func (rep *replica) write(alerts []StoreRequest) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	batch := append(rep.pending, alerts...)
+	if err := StoreAll(rep.storage, batch); err != nil {
+		if dropped := len(batch) - rep.queueSize; dropped > 0 {
+			batch = batch[dropped:]
+			log.Printf("Warning: dropped %d alerts queued for the %s replica, the queue is full", dropped, rep.name)
+		}
+		rep.pending = batch
+		log.Printf("Warning: failed to store %d alerts in %s replica, retrying with its next write: %v", len(alerts), rep.name, err)
+		return
+	}
+	rep.pending = nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/alertid"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// Sampler decides which alerts are stored: a severity-dependent fraction of them. The first
+// occurrence of a fingerprint within the window is always stored so every kind of incident stays
+// searchable. Alerts are sampled before they are embedded, so dropped alerts cost no embedding.
+type Sampler struct {
+	rates  map[string]float64 // lowercase severity -> fraction of alerts stored
+	window time.Duration
+	mu     sync.Mutex
+	seen   map[string]time.Time // fingerprint -> last occurrence
+	pruned time.Time
+}
+
+// NewSampler creates a sampling policy. rates maps lowercase severities to the fraction (0-1) of
+// alerts stored; severities without a rate are always stored.
+func NewSampler(rates map[string]float64, firstOccurrenceWindow time.Duration) *Sampler {
+	return &Sampler{
+		rates:  rates,
+		window: firstOccurrenceWindow,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Sample returns the anomalies that are to be stored. A nil sampler stores every anomaly.
+func (s *Sampler) Sample(anomalies []types.Anomaly) []types.Anomaly {
+	if s == nil {
+		return anomalies
+	}
+	var sampled []types.Anomaly
+	for _, anomaly := range anomalies {
+		if s.sample(anomaly) {
+			sampled = append(sampled, anomaly)
+		}
+	}
+	return sampled
+}
+
+// sample decides whether an alert is stored
+func (s *Sampler) sample(anomaly types.Anomaly) bool {
+	now := time.Now()
+	fingerprint := alertid.Fingerprint(anomaly)
+
+	s.mu.Lock()
+	last, seen := s.seen[fingerprint]
+	s.seen[fingerprint] = now
+	// Forget fingerprints that have not occurred within the window, once an hour
+	if now.Sub(s.pruned) >= time.Hour {
+		for key, t := range s.seen {
+			if now.Sub(t) > s.window {
+				delete(s.seen, key)
+			}
+		}
+		s.pruned = now
+	}
+	s.mu.Unlock()
+
+	if !seen || now.Sub(last) > s.window {
+		return true
+	}
+	rate, ok := s.rates[strings.ToLower(string(anomaly.Severity))]
+	if !ok {
+		return true
+	}
+	return rand.Float64() < rate
+}
@@ -8,11 +8,168 @@ import (
 
 // Storage defines the interface for alert storage
 type Storage interface {
-	// StoreAlert stores an alert with its vector embedding
-	StoreAlert(vector []float32, anomaly types.Anomaly) error
+	// StoreAlert stores an alert with its correlated events and vector embedding
+	StoreAlert(alert StoreRequest) error
 
 	// SearchSimilarAlerts searches for similar alerts using vector similarity
 	SearchSimilarAlerts(vector []float32, limit int) ([]types.Anomaly, error)
+
+	// SetFeedback labels a stored alert as a true or false positive
+	SetFeedback(id string, feedback string) error
+
+	// Ping checks that the backend is reachable
+	Ping() error
+}
+
+// BatchStorage is implemented by backends that store several alerts in one request
+type BatchStorage interface {
+	StoreAlerts(alerts []StoreRequest) error
+}
+
+// StoreAll stores alerts in one request if the storage supports it and one by one otherwise
+func StoreAll(storage Storage, alerts []StoreRequest) error {
+	if batch, ok := storage.(BatchStorage); ok {
+		return batch.StoreAlerts(alerts)
+	}
+	for _, alert := range alerts {
+		if err := storage.StoreAlert(alert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HybridSearcher is implemented by backends that combine vector similarity with keyword matching
+type HybridSearcher interface {
+	SearchHybrid(query string, vector []float32, limit int) ([]types.Anomaly, error)
+}
+
+// SearchHybrid searches alerts similar to vector and matching the keywords of query if the
+// storage supports hybrid search, and by vector similarity only otherwise
+func SearchHybrid(storage Storage, query string, vector []float32, limit int) ([]types.Anomaly, error) {
+	if hybrid, ok := storage.(HybridSearcher); ok {
+		return hybrid.SearchHybrid(query, vector, limit)
+	}
+	return storage.SearchSimilarAlerts(vector, limit)
+}
+
+// ClusterSearcher is implemented by storages that can restrict similarity searches to the alerts
+// of a cluster
+type ClusterSearcher interface {
+	SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error)
+}
+
+// clusterSearchOverfetch is how many more alerts are searched when filtering them by cluster
+// after the search, for storages that cannot filter while searching
+const clusterSearchOverfetch = 10
+
+// SearchSimilarAlertsInCluster searches alerts of a cluster name similar to vector, or of every
+// cluster if cluster is empty
+func SearchSimilarAlertsInCluster(storage Storage, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	if cluster == "" {
+		return storage.SearchSimilarAlerts(vector, limit)
+	}
+	if searcher, ok := storage.(ClusterSearcher); ok {
+		return searcher.SearchSimilarAlertsInCluster(cluster, vector, limit)
+	}
+
+	found, err := storage.SearchSimilarAlerts(vector, limit*clusterSearchOverfetch)
+	if err != nil {
+		return nil, err
+	}
+	anomalies := make([]types.Anomaly, 0, limit)
+	for _, anomaly := range found {
+		if anomaly.ClusterName == cluster && len(anomalies) < limit {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	return anomalies, nil
+}
+
+// ModelSearcher is implemented by storages that can restrict similarity searches to the alerts
+// embedded by a model
+type ModelSearcher interface {
+	SearchSimilarAlertsOfModel(model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error)
+}
+
+// SearchSimilarAlertsOfModel searches alerts embedded by model similar to vector, of a cluster
+// name or of every cluster if cluster is empty. Alerts stored without a recorded model are
+// assumed to have been embedded by it.
+func SearchSimilarAlertsOfModel(storage Storage, model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	if searcher, ok := storage.(ModelSearcher); ok {
+		return searcher.SearchSimilarAlertsOfModel(model, cluster, vector, limit)
+	}
+
+	found, err := SearchSimilarAlertsInCluster(storage, cluster, vector, limit*clusterSearchOverfetch)
+	if err != nil {
+		return nil, err
+	}
+	anomalies := make([]types.Anomaly, 0, limit)
+	for _, anomaly := range found {
+		if embeddedBy(anomaly, model) && len(anomalies) < limit {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	return anomalies, nil
+}
+
+// embeddedBy reports whether an anomaly was stored with a vector of model, or without a record
+// of its model
+func embeddedBy(anomaly types.Anomaly, model types.EmbeddingModel) bool {
+	return anomaly.Embedding.IsZero() || anomaly.Embedding == model
+}
+
+// FingerprintFinder is implemented by storages that can look up stored alerts by fingerprint
+type FingerprintFinder interface {
+	FindByFingerprint(fingerprint string, since time.Time) (*types.Anomaly, error)
+}
+
+// FindByFingerprint returns the most recent alert with the fingerprint stored since the given
+// time if the storage supports lookups by fingerprint, and nil otherwise
+func FindByFingerprint(storage Storage, fingerprint string, since time.Time) (*types.Anomaly, error) {
+	if finder, ok := storage.(FingerprintFinder); ok {
+		return finder.FindByFingerprint(fingerprint, since)
+	}
+	return nil, nil
+}
+
+// ObservationStorage is implemented by storages that keep the metric observations of every
+// cycle, e.g. for long-range analytics
+type ObservationStorage interface {
+	StoreObservations(state types.ClusterState) error
+}
+
+// StoreObservations stores the node and pod metrics of an observed cluster state if the storage
+// keeps observations, and does nothing otherwise
+func StoreObservations(storage Storage, state types.ClusterState) error {
+	if observations, ok := storage.(ObservationStorage); ok {
+		return observations.StoreObservations(state)
+	}
+	return nil
+}
+
+// StoreRequest is an alert to store: the anomaly, the events correlated with it, its vector
+// embedding and metadata stored along with the anomaly's own
+type StoreRequest struct {
+	Anomaly  types.Anomaly
+	Events   []types.Event
+	Vector   []float32
+	Metadata map[string]interface{}
+}
+
+// metadata returns the anomaly's metadata with the request's added, without modifying either
+func (r StoreRequest) metadata() map[string]interface{} {
+	if len(r.Metadata) == 0 {
+		return r.Anomaly.Metadata
+	}
+	metadata := make(map[string]interface{}, len(r.Anomaly.Metadata)+len(r.Metadata))
+	for key, value := range r.Anomaly.Metadata {
+		metadata[key] = value
+	}
+	for key, value := range r.Metadata {
+		metadata[key] = value
+	}
+	return metadata
 }
 
 // AlertVector represents an alert stored in the vector database
@@ -23,16 +180,56 @@ type AlertVector struct {
 	Timestamp time.Time          `json:"timestamp"`
 }
 
+// anomaly converts a stored alert back to an anomaly
+func (a AlertVector) anomaly() types.Anomaly {
+	return types.Anomaly{
+		ID:              a.ID,
+		Fingerprint:     a.Payload.Fingerprint,
+		ClusterName:     a.Payload.Cluster,
+		Timestamp:       a.Timestamp,
+		Type:            a.Payload.Type,
+		Resource:        a.Payload.Resource,
+		Namespace:       a.Payload.Namespace,
+		Severity:        a.Payload.Severity,
+		Description:     a.Payload.Description,
+		Value:           a.Payload.Value,
+		Threshold:       a.Payload.Threshold,
+		Labels:          a.Payload.Labels,
+		Events:          a.Payload.Events,
+		Metadata:        a.Payload.Metadata,
+		CorrelationKeys: a.Payload.CorrelationKeys,
+		Feedback:        a.Payload.Feedback,
+		Embedding:       a.Payload.Embedding,
+	}
+}
+
 // AlertVectorPayload represents the payload stored with an alert vector
 type AlertVectorPayload struct {
+	Fingerprint string                 `json:"fingerprint,omitempty"`
+	Cluster     string                 `json:"cluster,omitempty"`
 	Type        string                 `json:"type"`
 	Resource    string                 `json:"resource"`
 	Namespace   string                 `json:"namespace"`
-	Severity    string                 `json:"severity"`
+	Severity    types.Severity         `json:"severity"`
 	Description string                 `json:"description"`
 	Value       float64                `json:"value"`
 	Threshold   float64                `json:"threshold"`
 	Labels      map[string]string      `json:"labels"`
 	Events      []types.Event          `json:"events"`
 	Metadata    map[string]interface{} `json:"metadata"`
+	// CorrelationKeys join the alert with external systems (PagerDuty, Jira, Alertmanager)
+	CorrelationKeys map[string]string `json:"correlationKeys,omitempty"`
+	// Feedback is types.FeedbackTruePositive or types.FeedbackFalsePositive once labeled
+	Feedback string `json:"feedback,omitempty"`
+	// Embedding is the model the vector was embedded by, if recorded
+	Embedding types.EmbeddingModel `json:"embedding"`
+}
+
+// observedAt returns when an anomaly's condition was measured, falling back to now for
+// anomalies without a timestamp
+func observedAt(anomaly types.Anomaly) time.Time {
+	if anomaly.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return anomaly.Timestamp
 }
@@ -0,0 +1,440 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// WeaviateOptions holds the connection and class settings of the Weaviate backend
+type WeaviateOptions struct {
+	URL      string
+	APIKey   string
+	Class    string // Capitalized, e.g. HuginnAlert
+	Distance string // cosine, l2-squared or dot
+	// HybridAlpha weighs vector similarity against keyword matching in hybrid searches, from 0
+	// (keywords only) to 1 (vector only)
+	HybridAlpha float64
+}
+
+// weaviateProperties are the properties of the alert class. Nested payload values are stored
+// as JSON text, since object properties require a fixed nested schema.
+var weaviateProperties = []struct {
+	name, dataType string
+	nested         bool
+}{
+	{"alertid", "text", false},
+	{"fingerprint", "text", false},
+	{"type", "text", false},
+	{"resourcetype", "text", false},
+	{"resource", "text", false},
+	{"cluster", "text", false},
+	{"namespace", "text", false},
+	{"nodename", "text", false},
+	{"severity", "text", false},
+	{"description", "text", false},
+	{"namespacesonthisnode", "text", false},
+	{"feedback", "text", false},
+	{"value", "number", false},
+	{"threshold", "number", false},
+	{"timestamp", "int", false},
+	{"cycle", "int", false},
+	{"embeddingprovider", "text", false},
+	{"embeddingmodel", "text", false},
+	{"embeddingdimension", "int", false},
+	{"events", "text", true},
+	{"labels", "text", true},
+	{"metadata", "text", true},
+	{"correlationkeys", "text", true},
+}
+
+// WeaviateClient implements the Storage interface using Weaviate
+type WeaviateClient struct {
+	url      string
+	apiKey   string
+	class    string
+	distance string
+	alpha    float64
+	client   *http.Client
+}
+
+// NewWeaviateClient creates a Weaviate client, creating the alert class if it does not exist
+func NewWeaviateClient(options WeaviateOptions) (*WeaviateClient, error) {
+	client := &WeaviateClient{
+		url:      strings.TrimSuffix(options.URL, "/"),
+		apiKey:   options.APIKey,
+		class:    options.Class,
+		distance: options.Distance,
+		alpha:    options.HybridAlpha,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if err := client.ensureClass(); err != nil {
+		return nil, fmt.Errorf("failed to ensure class exists: %v", err)
+	}
+	return client, nil
+}
+
+// ensureClass creates the alert class, with vectors supplied by huginn, if it does not exist
+func (c *WeaviateClient) ensureClass() error {
+	status, body, err := c.do("GET", "/v1/schema/"+c.class, nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusOK {
+		return c.addMissingProperties(body)
+	}
+	if status != http.StatusNotFound {
+		return fmt.Errorf("unexpected status code %d when checking class", status)
+	}
+
+	distance := strings.ToLower(c.distance)
+	switch distance {
+	case "", "cosine":
+		distance = "cosine"
+	case "euclid", "euclidean", "l2":
+		distance = "l2-squared"
+	case "dotproduct", "ip":
+		distance = "dot"
+	}
+	properties := make([]map[string]interface{}, len(weaviateProperties))
+	for i, property := range weaviateProperties {
+		properties[i] = map[string]interface{}{"name": property.name, "dataType": []string{property.dataType}}
+	}
+	class := map[string]interface{}{
+		"class":             c.class,
+		"vectorizer":        "none",
+		"vectorIndexConfig": map[string]interface{}{"distance": distance},
+		"properties":        properties,
+	}
+	status, body, err = c.do("POST", "/v1/schema", class)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("failed to create class, status %d: %s", status, string(body))
+	}
+	return nil
+}
+
+// addMissingProperties adds the properties a class created by an earlier version lacks, since
+// queries fail on unknown properties
+func (c *WeaviateClient) addMissingProperties(schema []byte) error {
+	var class struct {
+		Properties []struct {
+			Name string `json:"name"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(schema, &class); err != nil {
+		return fmt.Errorf("failed to decode class schema: %v", err)
+	}
+	existing := make(map[string]bool, len(class.Properties))
+	for _, property := range class.Properties {
+		existing[strings.ToLower(property.Name)] = true
+	}
+	for _, property := range weaviateProperties {
+		if existing[property.name] {
+			continue
+		}
+		status, body, err := c.do("POST", "/v1/schema/"+c.class+"/properties",
+			map[string]interface{}{"name": property.name, "dataType": []string{property.dataType}})
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("failed to add property %s, status %d: %s", property.name, status, string(body))
+		}
+	}
+	return nil
+}
+
+// StoreAlert stores an alert in Weaviate
+func (c *WeaviateClient) StoreAlert(alert StoreRequest) error {
+	return c.StoreAlerts([]StoreRequest{alert})
+}
+
+// StoreAlerts stores alerts in Weaviate with a single batch request
+func (c *WeaviateClient) StoreAlerts(alerts []StoreRequest) error {
+	objects := make([]map[string]interface{}, len(alerts))
+	for i, alert := range alerts {
+		properties, err := weaviateObjectProperties(alert)
+		if err != nil {
+			return err
+		}
+		objects[i] = map[string]interface{}{
+			"class":      c.class,
+			"id":         pointID(alert.Anomaly.ID),
+			"properties": properties,
+			"vector":     alert.Vector,
+		}
+	}
+
+	status, body, err := c.do("POST", "/v1/batch/objects", map[string]interface{}{"objects": objects})
+	if err != nil {
+		return fmt.Errorf("failed to store alerts in Weaviate: %v", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("failed to store alerts in Weaviate, status %d: %s", status, string(body))
+	}
+
+	// Batches succeed as a whole while reporting errors per object
+	var results []struct {
+		Result struct {
+			Errors *struct {
+				Error []struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"errors"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &results); err != nil {
+		return fmt.Errorf("failed to decode batch response: %v", err)
+	}
+	for _, result := range results {
+		if result.Result.Errors != nil && len(result.Result.Errors.Error) > 0 {
+			return fmt.Errorf("failed to store alert in Weaviate: %s", result.Result.Errors.Error[0].Message)
+		}
+	}
+	return nil
+}
+
+// weaviateObjectProperties converts an alert to the properties of an object
+func weaviateObjectProperties(alert StoreRequest) (map[string]interface{}, error) {
+	payload := alertPayload(alert)
+	properties := make(map[string]interface{}, len(weaviateProperties))
+	for _, property := range weaviateProperties {
+		value, ok := payload[property.name]
+		if !ok {
+			continue
+		}
+		if property.nested {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal %s: %v", property.name, err)
+			}
+			value = string(data)
+		}
+		properties[property.name] = value
+	}
+	return properties, nil
+}
+
+// SearchSimilarAlerts searches for similar alerts in Weaviate
+func (c *WeaviateClient) SearchSimilarAlerts(vector []float32, limit int) ([]types.Anomaly, error) {
+	return c.get(fmt.Sprintf("nearVector: {vector: %s}", graphQLVector(vector)), limit)
+}
+
+// SearchSimilarAlertsInCluster searches for similar alerts of a cluster in Weaviate
+func (c *WeaviateClient) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	// GraphQL strings are escaped like JSON strings
+	quoted, err := json.Marshal(cluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cluster: %v", err)
+	}
+	return c.get(fmt.Sprintf("nearVector: {vector: %s}, where: {path: [\"cluster\"], operator: Equal, valueText: %s}",
+		graphQLVector(vector), quoted), limit)
+}
+
+// SearchHybrid searches for alerts both similar to vector and matching the keywords of query
+func (c *WeaviateClient) SearchHybrid(query string, vector []float32, limit int) ([]types.Anomaly, error) {
+	// GraphQL strings are escaped like JSON strings
+	quoted, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %v", err)
+	}
+	return c.get(fmt.Sprintf("hybrid: {query: %s, vector: %s, alpha: %g}", quoted, graphQLVector(vector), c.alpha), limit)
+}
+
+// get runs a GraphQL Get query of alerts with the given search argument
+func (c *WeaviateClient) get(search string, limit int) ([]types.Anomaly, error) {
+	names := make([]string, len(weaviateProperties))
+	for i, property := range weaviateProperties {
+		names[i] = property.name
+	}
+	query := fmt.Sprintf("{ Get { %s(%s, limit: %d) { %s } } }", c.class, search, limit, strings.Join(names, " "))
+
+	status, body, err := c.do("POST", "/v1/graphql", map[string]interface{}{"query": query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search alerts in Weaviate: %v", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to search alerts in Weaviate, status %d: %s", status, string(body))
+	}
+
+	var response struct {
+		Data struct {
+			Get map[string][]map[string]interface{} `json:"Get"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %v", err)
+	}
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("failed to search alerts in Weaviate: %s", response.Errors[0].Message)
+	}
+
+	objects := response.Data.Get[c.class]
+	anomalies := make([]types.Anomaly, len(objects))
+	for i, object := range objects {
+		for _, property := range weaviateProperties {
+			if text, ok := object[property.name].(string); ok && property.nested {
+				var value interface{}
+				if json.Unmarshal([]byte(text), &value) == nil {
+					object[property.name] = value
+				}
+			}
+		}
+		anomalies[i] = anomalyFromPayload(object)
+	}
+	return anomalies, nil
+}
+
+// DumpAlerts calls fn with every stored alert and its vector, paging through the class with the
+// cursor API
+func (c *WeaviateClient) DumpAlerts(fn func(StoreRequest) error) error {
+	names := make([]string, len(weaviateProperties))
+	for i, property := range weaviateProperties {
+		names[i] = property.name
+	}
+	after := ""
+	for {
+		cursor := ""
+		if after != "" {
+			cursor = fmt.Sprintf(", after: %q", after)
+		}
+		query := fmt.Sprintf("{ Get { %s(limit: 256%s) { %s _additional { id vector } } } }", c.class, cursor, strings.Join(names, " "))
+		status, body, err := c.do("POST", "/v1/graphql", map[string]interface{}{"query": query})
+		if err != nil {
+			return fmt.Errorf("failed to dump alerts from Weaviate: %v", err)
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("failed to dump alerts from Weaviate, status %d: %s", status, string(body))
+		}
+
+		var response struct {
+			Data struct {
+				Get map[string][]map[string]interface{} `json:"Get"`
+			} `json:"data"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return fmt.Errorf("failed to decode dump response: %v", err)
+		}
+		if len(response.Errors) > 0 {
+			return fmt.Errorf("failed to dump alerts from Weaviate: %s", response.Errors[0].Message)
+		}
+
+		objects := response.Data.Get[c.class]
+		if len(objects) == 0 {
+			return nil
+		}
+		for _, object := range objects {
+			var vector []float32
+			if additional, ok := object["_additional"].(map[string]interface{}); ok {
+				after, _ = additional["id"].(string)
+				if values, ok := additional["vector"].([]interface{}); ok {
+					vector = make([]float32, 0, len(values))
+					for _, value := range values {
+						if f, ok := value.(float64); ok {
+							vector = append(vector, float32(f))
+						}
+					}
+				}
+			}
+			for _, property := range weaviateProperties {
+				if text, ok := object[property.name].(string); ok && property.nested {
+					var value interface{}
+					if json.Unmarshal([]byte(text), &value) == nil {
+						object[property.name] = value
+					}
+				}
+			}
+			anomaly := anomalyFromPayload(object)
+			if err := fn(StoreRequest{Anomaly: anomaly, Events: anomaly.Events, Vector: vector}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// SetFeedback labels a stored alert as a true or false positive
+func (c *WeaviateClient) SetFeedback(id string, feedback string) error {
+	update := map[string]interface{}{
+		"class":      c.class,
+		"properties": map[string]interface{}{"feedback": feedback},
+	}
+	status, body, err := c.do("PATCH", "/v1/objects/"+c.class+"/"+pointID(id), update)
+	if err != nil {
+		return fmt.Errorf("failed to store alert feedback in Weaviate: %v", err)
+	}
+	if status != http.StatusNoContent && status != http.StatusOK {
+		return fmt.Errorf("failed to store alert feedback in Weaviate, status %d: %s", status, string(body))
+	}
+	return nil
+}
+
+// Ping checks that Weaviate is reachable and the class exists
+func (c *WeaviateClient) Ping() error {
+	status, _, err := c.do("GET", "/v1/schema/"+c.class, nil)
+	if err != nil {
+		return fmt.Errorf("failed to reach Weaviate: %v", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("unexpected status from Weaviate: %d", status)
+	}
+	return nil
+}
+
+// do sends a request with an optional JSON body and returns the response status and body
+func (c *WeaviateClient) do(method, path string, request interface{}) (int, []byte, error) {
+	var reader io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to marshal request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.url+path, reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// graphQLVector formats a vector as a GraphQL list literal
+func graphQLVector(vector []float32) string {
+	values := make([]string, len(vector))
+	for i, v := range vector {
+		values[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// writeBehindMinBackoff is the delay before the first retry of a failed write-behind write
+const writeBehindMinBackoff = time.Second
+
+// WriteBehindStorage queues stored alerts and writes them to the wrapped storage in the
+// background, so a backend outage neither blocks the caller nor loses alerts. Failed writes are
+// retried with exponential backoff. Once the queue holds size alerts the oldest are dropped. If
+// a path is set, the queue is kept in a JSON lines file and survives restarts.
+type WriteBehindStorage struct {
+	Storage
+	size       int
+	batchSize  int
+	maxBackoff time.Duration
+	path       string
+	mu         sync.Mutex
+	queue      []StoreRequest
+	inflight   int // Alerts at the head of the queue being written
+	dropped    int // Alerts dropped because the queue was full since the last write
+	onFlush    func(alerts []StoreRequest, duration time.Duration, err error)
+	wake       chan struct{}
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewWriteBehindStorage wraps a storage with a write-behind queue of size alerts, written in
+// batches of batchSize and retried up to every maxBackoff. Alerts queued in the file at path, if
+// set, by a previous run are loaded.
+func NewWriteBehindStorage(storage Storage, size, batchSize int, path string, maxBackoff time.Duration) (*WriteBehindStorage, error) {
+	w := &WriteBehindStorage{
+		Storage:    storage,
+		size:       size,
+		batchSize:  batchSize,
+		maxBackoff: maxBackoff,
+		path:       path,
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create write-behind queue directory: %v", err)
+		}
+		if err := w.load(); err != nil {
+			return nil, err
+		}
+		if len(w.queue) > 0 {
+			log.Printf("Loaded %d alerts queued for storage", len(w.queue))
+			w.signal()
+		}
+	}
+	go w.run()
+	return w, nil
+}
+
+// SetFlushHandler sets the function called with the outcome of every background write
+func (w *WriteBehindStorage) SetFlushHandler(fn func(alerts []StoreRequest, duration time.Duration, err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onFlush = fn
+}
+
+// StoreAlert queues an alert. It only fails if the alert cannot be persisted to the queue file.
+func (w *WriteBehindStorage) StoreAlert(alert StoreRequest) error {
+	return w.StoreAlerts([]StoreRequest{alert})
+}
+
+// StoreAlerts queues alerts
+func (w *WriteBehindStorage) StoreAlerts(alerts []StoreRequest) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.queue = append(w.queue, alerts...)
+	overflow := len(w.queue) - w.size
+	if overflow > 0 {
+		w.queue = w.queue[overflow:]
+		w.dropped += overflow
+		w.inflight = max(w.inflight-overflow, 0)
+	}
+	w.signal()
+	if w.path == "" {
+		return nil
+	}
+	// Dropped alerts are removed from the file too, so it stays as bounded as the queue
+	if overflow > 0 {
+		if err := w.rewrite(); err != nil {
+			return fmt.Errorf("failed to queue alerts: %v", err)
+		}
+		return nil
+	}
+	return w.append(alerts)
+}
+
+// SearchHybrid searches the wrapped storage by vector and keywords
+func (w *WriteBehindStorage) SearchHybrid(query string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchHybrid(w.Storage, query, vector, limit)
+}
+
+// SearchSimilarAlertsInCluster searches the wrapped storage for alerts of a cluster
+func (w *WriteBehindStorage) SearchSimilarAlertsInCluster(cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchSimilarAlertsInCluster(w.Storage, cluster, vector, limit)
+}
+
+// SearchSimilarAlertsOfModel searches the wrapped storage for alerts embedded by a model
+func (w *WriteBehindStorage) SearchSimilarAlertsOfModel(model types.EmbeddingModel, cluster string, vector []float32, limit int) ([]types.Anomaly, error) {
+	return SearchSimilarAlertsOfModel(w.Storage, model, cluster, vector, limit)
+}
+
+// AggregateAlerts counts the alerts stored in the wrapped storage, not those still queued
+func (w *WriteBehindStorage) AggregateAlerts(query AggregateQuery) ([]AlertCount, error) {
+	return AggregateAlerts(w.Storage, query)
+}
+
+// StoreObservations stores the observations of a cycle in the wrapped storage directly, without
+// queueing them
+func (w *WriteBehindStorage) StoreObservations(state types.ClusterState) error {
+	return StoreObservations(w.Storage, state)
+}
+
+// Len returns the number of queued alerts
+func (w *WriteBehindStorage) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.queue)
+}
+
+// Close stops the background writes, attempts to write the queue once more and persists what
+// remains if the queue is backed by a file
+func (w *WriteBehindStorage) Close() {
+	close(w.stop)
+	<-w.done
+	w.drain()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.queue) == 0 {
+		return
+	}
+	if w.path == "" {
+		log.Printf("Warning: dropping %d alerts queued for storage", len(w.queue))
+		return
+	}
+	if err := w.rewrite(); err != nil {
+		log.Printf("Warning: failed to persist %d alerts queued for storage: %v", len(w.queue), err)
+	}
+}
+
+// signal wakes the background writer without blocking
+func (w *WriteBehindStorage) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the queue whenever alerts are added, backing off exponentially while writes fail
+func (w *WriteBehindStorage) run() {
+	defer close(w.done)
+	var backoff time.Duration
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.wake:
+			if retry != nil {
+				// New alerts wait for the pending retry
+				continue
+			}
+		case <-retry:
+			retry = nil
+		}
+
+		if w.drain() {
+			backoff = 0
+		} else {
+			backoff = min(max(2*backoff, writeBehindMinBackoff), w.maxBackoff)
+			retry = time.After(backoff)
+		}
+	}
+}
+
+// drain writes the queue in batches until it is empty or a write fails, reporting whether it
+// was emptied
+func (w *WriteBehindStorage) drain() bool {
+	for {
+		w.mu.Lock()
+		if dropped := w.dropped; dropped > 0 {
+			w.dropped = 0
+			log.Printf("Warning: dropped %d alerts queued for storage, the queue is full", dropped)
+		}
+		n := min(len(w.queue), w.batchSize)
+		if n == 0 {
+			w.mu.Unlock()
+			return true
+		}
+		batch := append([]StoreRequest(nil), w.queue[:n]...)
+		w.inflight = n
+		onFlush := w.onFlush
+		w.mu.Unlock()
+
+		start := time.Now()
+		err := StoreAll(w.Storage, batch)
+		if onFlush != nil {
+			onFlush(batch, time.Since(start), err)
+		}
+
+		w.mu.Lock()
+		if err == nil {
+			// Alerts of the batch dropped meanwhile are no longer at the head
+			w.queue = w.queue[w.inflight:]
+			if w.path != "" {
+				if err := w.rewrite(); err != nil {
+					log.Printf("Warning: failed to update the storage queue file: %v", err)
+				}
+			}
+		}
+		w.inflight = 0
+		w.mu.Unlock()
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// append writes alerts to the end of the queue file. Callers must hold w.mu.
+func (w *WriteBehindStorage) append(alerts []StoreRequest) error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open storage queue file: %v", err)
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	for _, alert := range alerts {
+		if err := enc.Encode(alert); err != nil {
+			return fmt.Errorf("failed to queue alert: %v", err)
+		}
+	}
+	return bw.Flush()
+}
+
+// rewrite replaces the queue file with the current queue. Callers must hold w.mu.
+func (w *WriteBehindStorage) rewrite() error {
+	tmp := w.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	for _, alert := range w.queue {
+		if err := enc.Encode(alert); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.path)
+}
+
+// load reads the queue file, keeping the most recent size alerts
+func (w *WriteBehindStorage) load() error {
+	f, err := os.Open(w.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open storage queue file: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var alert StoreRequest
+		if err := json.Unmarshal(scanner.Bytes(), &alert); err != nil {
+			// A partially written last line is dropped
+			continue
+		}
+		w.queue = append(w.queue, alert)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read storage queue file: %v", err)
+	}
+	if overflow := len(w.queue) - w.size; overflow > 0 {
+		w.queue = w.queue[overflow:]
+	}
+	return nil
+}
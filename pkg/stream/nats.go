@@ -0,0 +1,348 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/types"
+)
+
+// subjectReplacer replaces the characters a subject token cannot contain
+var subjectReplacer = strings.NewReplacer(" ", "_", ".", "_", "*", "_", ">", "_", "\t", "_")
+
+// NATSPublisher publishes detected alerts as JSON to NATS subjects rendered from a template, e.g.
+// one subject per cluster and severity. With JetStream it waits for the stream to acknowledge
+// each alert. It speaks the NATS client protocol directly and reconnects on the next publish
+// after a connection fails.
+type NATSPublisher struct {
+	config  config.NATSConfig
+	subject *template.Template
+	timeout time.Duration
+	mu      sync.Mutex // Serializes publishes
+	conn    *natsConn
+}
+
+// natsConn is a connection to a NATS server. Its reader answers server PINGs and delivers the
+// JetStream acknowledgements published to its inbox.
+type natsConn struct {
+	conn  net.Conn
+	wmu   sync.Mutex
+	w     *bufio.Writer
+	inbox string
+	acks  chan []byte
+	err   chan error // Receives why the connection failed, once
+	done  chan struct{}
+}
+
+// jsAck is the reply of JetStream to a published message
+type jsAck struct {
+	Stream string `json:"stream"`
+	Seq    uint64 `json:"seq"`
+	Error  *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// NewNATSPublisher creates a NATS publisher. It returns nil if publishing is disabled.
+func NewNATSPublisher(cfg config.NATSConfig) (*NATSPublisher, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	subject, err := template.New("subject").Parse(cfg.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS subject: %v", err)
+	}
+	return &NATSPublisher{
+		config:  cfg,
+		subject: subject,
+		timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+	}, nil
+}
+
+// Subject returns the subject an anomaly is published to
+func (p *NATSPublisher) Subject(anomaly types.Anomaly) (string, error) {
+	anomaly.ClusterName = subjectReplacer.Replace(anomaly.ClusterName)
+	anomaly.ClusterID = subjectReplacer.Replace(anomaly.ClusterID)
+	anomaly.Namespace = subjectReplacer.Replace(anomaly.Namespace)
+	anomaly.Type = subjectReplacer.Replace(anomaly.Type)
+	anomaly.Severity = types.Severity(subjectReplacer.Replace(string(anomaly.Severity)))
+
+	var buf bytes.Buffer
+	if err := p.subject.Execute(&buf, anomaly); err != nil {
+		return "", fmt.Errorf("failed to render NATS subject: %v", err)
+	}
+	subject := buf.String()
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") || strings.Contains(subject, "..") {
+		return "", fmt.Errorf("invalid NATS subject %q", subject)
+	}
+	return subject, nil
+}
+
+// Publish publishes the anomalies in order, stopping at the first that fails
+func (p *NATSPublisher) Publish(anomalies []types.Anomaly) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, anomaly := range anomalies {
+		subject, err := p.Subject(anomaly)
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(anomaly)
+		if err != nil {
+			return fmt.Errorf("failed to encode anomaly: %v", err)
+		}
+		if err := p.publish(subject, payload); err != nil {
+			return fmt.Errorf("failed to publish anomaly to %s: %v", subject, err)
+		}
+	}
+	return nil
+}
+
+// publish publishes one message, connecting first if needed. The connection is dropped on
+// failure so the next publish reconnects. Callers must hold p.mu.
+func (p *NATSPublisher) publish(subject string, payload []byte) error {
+	if p.conn == nil {
+		conn, err := dialNATS(p.config, p.timeout)
+		if err != nil {
+			return err
+		}
+		p.conn = conn
+	}
+
+	err := p.conn.publish(subject, payload, p.config.JetStream, p.timeout)
+	if err != nil {
+		p.conn.close()
+		p.conn = nil
+	}
+	return err
+}
+
+// Close closes the connection to the server
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.close()
+	p.conn = nil
+	return err
+}
+
+// dialNATS connects and authenticates to the server of cfg.URL
+func dialNATS(cfg config.NATSConfig, timeout time.Duration) (*natsConn, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS URL: %v", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	raw, err := net.DialTimeout("tcp", host, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %v", host, err)
+	}
+	conn := raw
+	raw.SetDeadline(time.Now().Add(timeout))
+	r := bufio.NewReader(raw)
+
+	// The server greets with INFO, then the client may upgrade to TLS
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		raw.Close()
+		return nil, fmt.Errorf("unexpected NATS greeting %q: %v", strings.TrimSpace(line), err)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "INFO ")), &info)
+	if u.Scheme == "tls" || info.TLSRequired {
+		tlsConn := tls.Client(raw, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			raw.Close()
+			return nil, fmt.Errorf("NATS TLS handshake failed: %v", err)
+		}
+		conn = tlsConn
+		r = bufio.NewReader(tlsConn)
+	}
+
+	user, password, token := cfg.User, cfg.Password, cfg.Token
+	if u.User != nil && user == "" && token == "" {
+		if pass, ok := u.User.Password(); ok {
+			user, password = u.User.Username(), pass
+		} else {
+			token = u.User.Username()
+		}
+	}
+	connect, _ := json.Marshal(map[string]interface{}{
+		"verbose":    false,
+		"pedantic":   false,
+		"name":       "huginn",
+		"lang":       "go",
+		"version":    "1.0.0",
+		"protocol":   1,
+		"user":       user,
+		"pass":       password,
+		"auth_token": token,
+	})
+
+	c := &natsConn{
+		conn:  conn,
+		w:     bufio.NewWriter(conn),
+		inbox: "_INBOX." + randomToken(),
+		acks:  make(chan []byte, 1),
+		err:   make(chan error, 1),
+		done:  make(chan struct{}),
+	}
+	fmt.Fprintf(c.w, "CONNECT %s\r\nSUB %s.* 1\r\nPING\r\n", connect, c.inbox)
+	if err := c.w.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send NATS CONNECT: %v", err)
+	}
+
+	// The server answers the PING once CONNECT is accepted, or -ERR if it is not
+	for {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("NATS handshake failed: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "PING" {
+			c.w.WriteString("PONG\r\n")
+			c.w.Flush()
+			continue
+		}
+		if line != "PONG" {
+			conn.Close()
+			return nil, fmt.Errorf("NATS handshake failed: %s", line)
+		}
+		break
+	}
+	conn.SetDeadline(time.Time{})
+
+	go c.read(r)
+	return c, nil
+}
+
+// publish writes a message and, with JetStream, waits for the stream's acknowledgement
+func (c *natsConn) publish(subject string, payload []byte, jetStream bool, timeout time.Duration) error {
+	c.wmu.Lock()
+	c.conn.SetWriteDeadline(time.Now().Add(timeout))
+	if jetStream {
+		fmt.Fprintf(c.w, "PUB %s %s.%s %d\r\n", subject, c.inbox, randomToken(), len(payload))
+	} else {
+		fmt.Fprintf(c.w, "PUB %s %d\r\n", subject, len(payload))
+	}
+	c.w.Write(payload)
+	c.w.WriteString("\r\n")
+	err := c.w.Flush()
+	c.wmu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !jetStream {
+		return nil
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case data := <-c.acks:
+		var ack jsAck
+		if err := json.Unmarshal(data, &ack); err != nil {
+			return fmt.Errorf("invalid JetStream acknowledgement %q", data)
+		}
+		if ack.Error != nil {
+			return fmt.Errorf("JetStream error %d: %s", ack.Error.Code, ack.Error.Description)
+		}
+		if ack.Stream == "" {
+			return fmt.Errorf("invalid JetStream acknowledgement %q", data)
+		}
+		return nil
+	case err := <-c.err:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("no JetStream acknowledgement within %s; is a stream capturing the subject?", timeout)
+	}
+}
+
+// read handles the messages of the server until the connection fails
+func (c *natsConn) read(r *bufio.Reader) {
+	fail := func(err error) {
+		select {
+		case c.err <- err:
+		default:
+		}
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			select {
+			case <-c.done:
+			default:
+				fail(fmt.Errorf("NATS connection lost: %v", err))
+			}
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PING":
+			c.wmu.Lock()
+			c.w.WriteString("PONG\r\n")
+			c.w.Flush()
+			c.wmu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			fail(fmt.Errorf("NATS error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR"))))
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(line)
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				fail(fmt.Errorf("invalid NATS message header %q", line))
+				return
+			}
+			data := make([]byte, size+2) // Payload and CRLF
+			if _, err := io.ReadFull(r, data); err != nil {
+				fail(fmt.Errorf("NATS connection lost: %v", err))
+				return
+			}
+			// Acknowledgements arriving after their publish timed out are dropped
+			select {
+			case c.acks <- data[:size]:
+			default:
+			}
+		}
+	}
+}
+
+// close closes the connection
+func (c *natsConn) close() error {
+	close(c.done)
+	return c.conn.Close()
+}
+
+// randomToken returns a random subject token
+func randomToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,289 @@
+package timeseries
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/anomaly"
+	"github.com/rodolfo-mora/huginn/pkg/config"
+)
+
+// Supported time-series databases
+const (
+	TypeInfluxDB        = "influxdb"
+	TypeVictoriaMetrics = "victoriametrics"
+)
+
+// Tags of the exported points
+const (
+	tagClusterID    = "cluster_id"
+	tagCluster      = "cluster"
+	tagResourceType = "resource_type"
+	tagResource     = "resource"
+	tagMetric       = "metric"
+	fieldValue      = "value"
+)
+
+// Client exports the detector's metric observations to InfluxDB or VictoriaMetrics as points of
+// the line protocol, one per observation tagged with its cluster, resource and metric, and reads
+// them back to restore the detector's history after a restart
+type Client struct {
+	config  config.TimeSeriesConfig
+	url     string
+	restore time.Duration
+	client  *http.Client
+}
+
+// NewClient creates a time-series client. It returns nil if exporting is disabled.
+func NewClient(cfg config.TimeSeriesConfig) (*Client, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	switch cfg.Type {
+	case TypeInfluxDB, TypeVictoriaMetrics:
+	default:
+		return nil, fmt.Errorf("unsupported time-series database: %s (expected influxdb or victoriametrics)", cfg.Type)
+	}
+	return &Client{
+		config:  cfg,
+		url:     strings.TrimSuffix(cfg.URL, "/"),
+		restore: time.Duration(cfg.RestoreHours) * time.Hour,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Write exports the observations of a cluster with a single request
+func (c *Client) Write(clusterID, clusterName string, observations []anomaly.MetricObservation) error {
+	if len(observations) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, obs := range observations {
+		buf.WriteString(escapeMeasurement(c.config.Measurement))
+		for _, tag := range [][2]string{
+			{tagClusterID, clusterID},
+			{tagCluster, clusterName},
+			{tagMetric, obs.MetricType},
+			{tagResource, obs.ResourceID},
+			{tagResourceType, obs.ResourceType},
+		} {
+			// Empty tag values are not allowed by the line protocol
+			if tag[1] != "" {
+				buf.WriteString("," + tag[0] + "=" + escapeTag(tag[1]))
+			}
+		}
+		fmt.Fprintf(&buf, " %s=%s %d\n", fieldValue, strconv.FormatFloat(obs.Value, 'g', -1, 64), obs.Timestamp.UnixMilli())
+	}
+
+	var endpoint string
+	query := url.Values{"precision": {"ms"}}
+	switch c.config.Type {
+	case TypeInfluxDB:
+		endpoint = "/api/v2/write"
+		query.Set("org", c.config.Org)
+		query.Set("bucket", c.config.Bucket)
+	case TypeVictoriaMetrics:
+		endpoint = "/write"
+	}
+	status, body, err := c.do("POST", endpoint+"?"+query.Encode(), "text/plain; charset=utf-8", &buf)
+	if err != nil {
+		return fmt.Errorf("failed to export observations: %v", err)
+	}
+	if status != http.StatusNoContent && status != http.StatusOK {
+		return fmt.Errorf("failed to export observations, status %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Restore returns the observations of a cluster exported within the restore window, or nil if
+// restoring is disabled
+func (c *Client) Restore(clusterID string) ([]anomaly.MetricObservation, error) {
+	if c.restore <= 0 {
+		return nil, nil
+	}
+	since := time.Now().Add(-c.restore)
+	switch c.config.Type {
+	case TypeInfluxDB:
+		return c.readInfluxDB(clusterID, since)
+	default:
+		return c.readVictoriaMetrics(clusterID, since)
+	}
+}
+
+// readVictoriaMetrics reads observations with the export API, which returns a JSON line per
+// series
+func (c *Client) readVictoriaMetrics(clusterID string, since time.Time) ([]anomaly.MetricObservation, error) {
+	// Points of the line protocol are stored as <measurement>_<field> series
+	selector := fmt.Sprintf("%s_%s{%s=%s}", c.config.Measurement, fieldValue, tagClusterID, strconv.Quote(clusterID))
+	query := url.Values{"match[]": {selector}, "start": {strconv.FormatInt(since.Unix(), 10)}}
+	status, body, err := c.do("GET", "/api/v1/export?"+query.Encode(), "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read observations: %v", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to read observations, status %d: %s", status, strings.TrimSpace(string(body)))
+	}
+
+	var observations []anomaly.MetricObservation
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var series struct {
+			Metric     map[string]string `json:"metric"`
+			Values     []float64         `json:"values"`
+			Timestamps []int64           `json:"timestamps"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &series); err != nil {
+			return nil, fmt.Errorf("failed to decode exported series: %v", err)
+		}
+		for i, value := range series.Values {
+			if i >= len(series.Timestamps) {
+				break
+			}
+			observations = append(observations, anomaly.MetricObservation{
+				Timestamp:    time.UnixMilli(series.Timestamps[i]),
+				ResourceType: series.Metric[tagResourceType],
+				ResourceID:   series.Metric[tagResource],
+				MetricType:   series.Metric[tagMetric],
+				Value:        value,
+			})
+		}
+	}
+	return observations, scanner.Err()
+}
+
+// readInfluxDB reads observations with a Flux query, which returns CSV tables
+func (c *Client) readInfluxDB(clusterID string, since time.Time) ([]anomaly.MetricObservation, error) {
+	flux := fmt.Sprintf(`from(bucket: %s)
+  |> range(start: %s)
+  |> filter(fn: (r) => r._measurement == %s and r._field == %s and r.%s == %s)
+  |> keep(columns: ["_time", "_value", %q, %q, %q])`,
+		strconv.Quote(c.config.Bucket), since.UTC().Format(time.RFC3339),
+		strconv.Quote(c.config.Measurement), strconv.Quote(fieldValue), tagClusterID, strconv.Quote(clusterID),
+		tagResourceType, tagResource, tagMetric)
+	request, err := json.Marshal(map[string]interface{}{"query": flux, "type": "flux"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %v", err)
+	}
+	query := url.Values{"org": {c.config.Org}}
+	status, body, err := c.do("POST", "/api/v2/query?"+query.Encode(), "application/json", bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read observations: %v", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to read observations, status %d: %s", status, strings.TrimSpace(string(body)))
+	}
+
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	var observations []anomaly.MetricObservation
+	var columns map[string]int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode query result: %v", err)
+		}
+
+		// Every table of the result starts with a header row
+		if contains(record, "_time") && contains(record, "_value") {
+			columns = make(map[string]int, len(record))
+			for i, name := range record {
+				columns[name] = i
+			}
+			continue
+		}
+		if columns == nil {
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		timestamp, err := time.Parse(time.RFC3339Nano, field("_time"))
+		if err != nil {
+			continue
+		}
+		value, err := strconv.ParseFloat(field("_value"), 64)
+		if err != nil {
+			continue
+		}
+		observations = append(observations, anomaly.MetricObservation{
+			Timestamp:    timestamp,
+			ResourceType: field(tagResourceType),
+			ResourceID:   field(tagResource),
+			MetricType:   field(tagMetric),
+			Value:        value,
+		})
+	}
+	return observations, nil
+}
+
+// do sends a request and returns the response status and body
+func (c *Client) do(method, path, contentType string, body io.Reader) (int, []byte, error) {
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.config.Type == TypeInfluxDB {
+		req.Header.Set("Accept", "application/csv")
+	}
+	if c.config.Token != "" {
+		scheme := "Bearer "
+		if c.config.Type == TypeInfluxDB {
+			scheme = "Token "
+		}
+		req.Header.Set("Authorization", scheme+c.config.Token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
+
+// escapeMeasurement escapes a measurement name of the line protocol
+func escapeMeasurement(name string) string {
+	return strings.NewReplacer(",", `\,`, " ", `\ `).Replace(name)
+}
+
+// escapeTag escapes a tag value of the line protocol
+func escapeTag(value string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(value)
+}
+
+// contains reports whether values contains value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity is the severity of an anomaly
+type Severity string
+
+// Anomaly severities
+const (
+	SeverityLow      Severity = "Low"
+	SeverityMedium   Severity = "Medium"
+	SeverityHigh     Severity = "High"
+	SeverityCritical Severity = "Critical"
+)
+
+// Severities lists the anomaly severities from the lowest up
+var Severities = []Severity{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// ParseSeverity returns the severity named s, matching case-insensitively
+func ParseSeverity(s string) (Severity, error) {
+	for _, severity := range Severities {
+		if strings.EqualFold(string(severity), strings.TrimSpace(s)) {
+			return severity, nil
+		}
+	}
+	return "", fmt.Errorf("unknown severity %q (expected Low, Medium, High or Critical)", s)
+}
+
+// Normalize returns the canonical spelling of the severity, e.g. "High" for "high". Unknown
+// severities are returned unchanged.
+func (s Severity) Normalize() Severity {
+	if severity, err := ParseSeverity(string(s)); err == nil {
+		return severity
+	}
+	return s
+}
+
+// Rank returns the position of the severity from 1 (Low) to 4 (Critical), or 0 when it is unknown
+func (s Severity) Rank() int {
+	for i, severity := range Severities {
+		if severity == s.Normalize() {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// AtLeast reports whether the severity is at least min. Every severity is at least an unknown one.
+func (s Severity) AtLeast(min Severity) bool {
+	return s.Rank() >= min.Rank()
+}
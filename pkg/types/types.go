@@ -1,6 +1,7 @@
 package types
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -14,6 +15,10 @@ type ClusterState struct {
 	Events      []ClusterEvent // Cluster-wide events
 	// Cluster-scoped resources
 	PersistentVolumes []PersistentVolume
+	// Observation cycle the state was collected in
+	Cycle      int64     // Monotonic counter of the agent's observation cycles, starting at 1
+	CycleStart time.Time // When collection started
+	CycleEnd   time.Time // When collection completed
 }
 
 // MultiClusterState represents the aggregated state of multiple clusters
@@ -45,6 +50,18 @@ type Node struct {
 	ConditionStatus    string
 	Status             string
 	Namespaces         []string // Namespaces running on this node
+	Labels             map[string]string
+	CreatedAt          time.Time
+	// Filesystem usage percentages from the kubelet stats summary (0 when not collected)
+	NodeFSUsagePercent  float64 // Root filesystem of the kubelet (logs, emptyDir volumes)
+	ImageFSUsagePercent float64 // Filesystem of the container runtime (images, writable layers)
+	DiskPressure        bool    // Whether the DiskPressure condition is true
+	DiskPressureSince   time.Time
+	// Node software versions reported by the kubelet
+	KubeletVersion          string
+	KernelVersion           string
+	ContainerRuntimeVersion string
+	OSImage                 string
 }
 
 // ResourceList represents a list of resources in a namespace
@@ -54,6 +71,14 @@ type ResourceList struct {
 	Deployments []Deployment
 	// Namespace-scoped storage resources
 	PersistentVolumeClaims []PersistentVolumeClaim
+	// Container images running in the namespace
+	Images []ImageUsage
+	// Configuration objects (metadata and size only, never contents)
+	Secrets    []ConfigObject
+	ConfigMaps []ConfigObject
+	// Whether the configuration objects were listed this cycle; empty lists are only meaningful if so
+	SecretsCollected    bool
+	ConfigMapsCollected bool
 }
 
 // Pod represents a Kubernetes pod
@@ -68,6 +93,50 @@ type Pod struct {
 	MemoryRequests string // Effective memory requests for the pod
 	MemoryLimits   string // Effective memory limits for the pod
 	State          string // State of the pod
+	OwnerKind      string // Kind of the workload controlling this pod (Deployment, StatefulSet, ...)
+	OwnerName      string // Name of the workload controlling this pod
+	Labels         map[string]string
+	CreatedAt      time.Time
+	Containers     []Container
+	Security       PodSecurity
+}
+
+// PodSecurity represents the security-relevant settings of a pod spec
+type PodSecurity struct {
+	Privileged     bool     // Any container runs privileged
+	HostNetwork    bool     // The pod uses the node's network namespace
+	HostPID        bool     // The pod uses the node's PID namespace
+	HostPathMounts []string // Host paths mounted into the pod
+	RunAsRoot      bool     // Any container runs as, or is not prevented from running as, UID 0
+}
+
+// Container represents a container declared in a pod spec
+type Container struct {
+	Name     string
+	Image    string // Image reference as written in the pod spec
+	Registry string // Registry host the image is pulled from (e.g., "docker.io")
+	Tag      string // Image tag, "latest" when the reference has neither tag nor digest
+	Digest   string // Image digest if the reference is pinned (e.g., "sha256:...")
+	Init     bool   // Whether this is an init container
+}
+
+// ImageUsage represents a container image in use within a namespace
+type ImageUsage struct {
+	Image     string
+	Registry  string
+	Tag       string
+	Digest    string
+	Pods      int      // Number of pods running this image
+	Workloads []string // Workloads running this image as "Kind/Name"
+}
+
+// ConfigObject represents the metadata of a Secret or ConfigMap
+type ConfigObject struct {
+	Name            string
+	Namespace       string
+	ResourceVersion string
+	CreatedAt       time.Time
+	SizeBytes       int // Total size of the object's data
 }
 
 // Service represents a Kubernetes service
@@ -78,8 +147,9 @@ type Service struct {
 
 // Deployment represents a Kubernetes deployment
 type Deployment struct {
-	Name     string
-	Replicas int32
+	Name              string
+	Replicas          int32 // Desired replicas
+	AvailableReplicas int32 // Replicas available for at least minReadySeconds
 }
 
 // PersistentVolumeClaim represents a Kubernetes PVC
@@ -106,8 +176,23 @@ type PersistentVolume struct {
 	ClaimName        string
 }
 
+// NodeMetricHistory represents node usage samples from an external metrics source
+type NodeMetricHistory struct {
+	Node   string
+	CPU    []MetricSample // CPU usage percentage
+	Memory []MetricSample // Memory usage percentage
+}
+
+// MetricSample represents a single timestamped metric value
+type MetricSample struct {
+	Timestamp time.Time
+	Value     float64
+}
+
 // Anomaly represents a detected anomaly in the cluster
 type Anomaly struct {
+	ID                   string // Assigned when the anomaly is recorded, see StorageConfig.AlertID
+	Fingerprint          string // Stable across occurrences: hash of cluster, type, resource type, namespace and resource
 	ClusterID            string
 	ClusterName          string
 	Type                 string
@@ -116,7 +201,8 @@ type Anomaly struct {
 	Namespace            string
 	NodeName             string // Name of the Kubernetes node where this anomaly occurred
 	NamespacesOnThisNode string
-	Severity             string
+	Severity             Severity
+	Score                float64 // 0-100 ranking from severity, deviation, threshold breach and persistence
 	Description          string
 	Value                float64
 	Threshold            float64
@@ -124,8 +210,38 @@ type Anomaly struct {
 	Labels               map[string]string
 	Events               []Event
 	Metadata             map[string]interface{}
+	CorrelationKeys      map[string]string // Keys joining the anomaly with external systems, e.g. "pagerdutyDedupKey"
+	Feedback             string            // FeedbackTruePositive or FeedbackFalsePositive once labeled
+	Cycle                int64             // Observation cycle the anomaly was detected from, see ClusterState
+	CycleStart           time.Time
+	CycleEnd             time.Time
+	Embedding            EmbeddingModel // Model whose vector the anomaly was stored with, if recorded
+}
+
+// EmbeddingModel identifies the model that produced a stored vector. Vectors of different models
+// are not comparable even when their dimensions agree.
+type EmbeddingModel struct {
+	Provider  string // Embedding type, e.g. "ollama" or "openai"
+	Model     string
+	Dimension int
+}
+
+// IsZero reports whether no model is recorded
+func (m EmbeddingModel) IsZero() bool {
+	return m == EmbeddingModel{}
 }
 
+// String returns the model as provider/model/dimension
+func (m EmbeddingModel) String() string {
+	return fmt.Sprintf("%s/%s/%d", m.Provider, m.Model, m.Dimension)
+}
+
+// Feedback labels of anomalies
+const (
+	FeedbackTruePositive  = "true_positive"
+	FeedbackFalsePositive = "false_positive"
+)
+
 // Event represents a Kubernetes event
 type Event struct {
 	Type      string
@@ -134,13 +250,23 @@ type Event struct {
 	Timestamp time.Time
 }
 
+// ObservedAt returns when the state was measured: the end of its observation cycle, or now for
+// states that were not stamped
+func (s ClusterState) ObservedAt() time.Time {
+	if s.CycleEnd.IsZero() {
+		return time.Now()
+	}
+	return s.CycleEnd
+}
+
 // Observation represents a historical observation of the cluster state
 type Observation struct {
 	ClusterID   string
 	ClusterName string
 	Timestamp   time.Time
 	State       ClusterState
-	Reward      float64
+	Reward      float64        // Cluster health from 0 to 1
+	Anomalies   map[string]int // Anomalies detected from State per metric (cpu, memory, disk, restarts); nil until detected
 }
 
 // AlertmanagerAlert represents an alert sent to Alertmanager
@@ -159,6 +285,7 @@ type AlertmanagerPayload struct {
 
 // ClusterEvent represents a Kubernetes cluster event
 type ClusterEvent struct {
+	UID       string // UID of the Kubernetes event object, which identifies it across cycles
 	Type      string
 	Reason    string
 	Message   string
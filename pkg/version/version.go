@@ -0,0 +1,33 @@
+package version
+
+import (
+	"runtime"
+)
+
+// Build information, set at build time via ldflags, e.g.:
+//
+//	go build -ldflags "-X github.com/rodolfo-mora/huginn/pkg/version.Version=v1.2.3 \
+//	  -X github.com/rodolfo-mora/huginn/pkg/version.Commit=$(git rev-parse --short HEAD)"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Info represents the build information of the running binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build information of the running binary
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/journal"
+	"github.com/rodolfo-mora/huginn/pkg/notification"
+)
+
+// runReplay re-applies the current notification policy to anomalies recorded in the journal,
+// e.g. after fixing a broken notification route, without re-running detection
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	since := fs.Duration("since", time.Hour, "Replay anomalies detected within this window")
+	all := fs.Bool("all", false, "Also resend anomalies that were already notified")
+	dryRun := fs.Bool("dry-run", false, "Print the notifications that would be sent without sending them")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	// The agent may be appending to the journal; opening it shared leaves it uncompacted
+	j, err := journal.OpenShared(cfg.Notification.Journal.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open anomaly journal: %v", err)
+	}
+
+	notifier, err := notification.NewNotifier(cfg.Notification)
+	if err != nil {
+		return err
+	}
+
+	records, err := j.Since(time.Now().Add(-*since))
+	if err != nil {
+		return err
+	}
+
+	sent := 0
+	for _, record := range records {
+		if record.Notified && !*all {
+			continue
+		}
+		if !notification.ShouldNotify(record.Anomaly, cfg.Notification.MinSeverity) || record.Anomaly.Score < cfg.Notification.MinScore {
+			continue
+		}
+
+		if *dryRun {
+			fmt.Printf("Would notify: [%s] %s/%s (%s): %s\n", record.Anomaly.Severity, record.Anomaly.ClusterName,
+				record.Anomaly.Resource, record.Anomaly.Type, record.Anomaly.Description)
+			sent++
+			continue
+		}
+
+		if err := notifier.Notify(record.Anomaly); err != nil {
+			log.Printf("Failed to send notification for anomaly %s: %v", record.ID, err)
+			continue
+		}
+		record.Notified = true
+		record.NotifiedAt = time.Now()
+		if err := j.Append(record); err != nil {
+			log.Printf("Failed to write notification status to journal: %v", err)
+		}
+		sent++
+	}
+
+	log.Printf("Replayed %d of %d journaled anomalies", sent, len(records))
+	return nil
+}
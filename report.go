@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/agent"
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/storage"
+)
+
+// runReport prints a summary of the alerts stored in the vector database, counted per group
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	window := fs.String("window", "7d", "Summarize alerts stored within this window, e.g. 24h or 30d")
+	groupBy := fs.String("group-by", "type,cluster", "Comma-separated fields to group by (type, cluster, namespace, severity)")
+	bucket := fs.String("bucket", "", "Also group by time buckets of this size, e.g. 1d")
+	cluster := fs.String("cluster", "", "Only count alerts of this cluster name")
+	namespace := fs.String("namespace", "", "Only count alerts of this namespace")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+	query, err := agent.ParseAggregateQuery(*window, *groupBy, *bucket)
+	if err != nil {
+		return err
+	}
+	query.Cluster, query.Namespace = *cluster, *namespace
+
+	client, err := agent.OpenStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to storage: %v", err)
+	}
+	counts, err := storage.AggregateAlerts(client, query)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Stored alerts from %s to %s\n\n", query.Start.Format(time.RFC3339), query.End.Format(time.RFC3339))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var header []string
+	if query.Bucket > 0 {
+		header = append(header, "BUCKET")
+	}
+	for _, field := range query.GroupBy {
+		header = append(header, strings.ToUpper(field))
+	}
+	fmt.Fprintln(w, strings.Join(append(header, "COUNT"), "\t"))
+
+	total := 0
+	for _, count := range counts {
+		var row []string
+		if count.Bucket != nil {
+			row = append(row, count.Bucket.Format(time.RFC3339))
+		}
+		for _, field := range query.GroupBy {
+			row = append(row, count.Group[field])
+		}
+		fmt.Fprintln(w, strings.Join(append(row, fmt.Sprint(count.Count)), "\t"))
+		total += count.Count
+	}
+	w.Flush()
+	fmt.Printf("\nTotal: %d alerts in %d groups\n", total, len(counts))
+	return nil
+}
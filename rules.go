@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/metrics"
+)
+
+// runRules writes Prometheus alerting rules matching the configured thresholds, so external
+// alerting stays in sync with the agent's configuration
+func runRules(args []string) error {
+	fs := flag.NewFlagSet("rules", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	format := fs.String("format", metrics.FormatRules, "Output format (rules or prometheusrule)")
+	output := fs.String("output", "", "Output file (default stdout)")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	rules, warnings := metrics.GenerateRules(cfg)
+	for _, warning := range warnings {
+		log.Printf("Warning: %s", warning)
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := metrics.WriteRules(w, *format, rules); err != nil {
+		return err
+	}
+	if *output != "" {
+		log.Printf("Wrote %d rules to %s", len(rules.Groups[0].Rules), *output)
+	}
+	return nil
+}
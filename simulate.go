@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rodolfo-mora/huginn/pkg/agent"
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/simulation"
+)
+
+// runSimulate drives the full pipeline with synthetic clusters and reports huginn's own resource usage
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	clusters := fs.Int("clusters", 3, "Number of simulated clusters")
+	nodes := fs.Int("nodes", 20, "Nodes per simulated cluster")
+	pods := fs.Int("pods", 200, "Pods per simulated cluster")
+	cycles := fs.Int("cycles", 60, "Observation cycles to run")
+	interval := fs.Duration("interval", 0, "Pause between cycles")
+	profileName := fs.String("profile", "mixed", "Failure injection profile (none, mixed or chaos)")
+	seed := fs.Int64("seed", 1, "Random seed of the simulated fleet")
+	notify := fs.Bool("notify", false, "Send notifications for simulated anomalies")
+	fs.Parse(args)
+
+	profile, ok := simulation.Profiles[*profileName]
+	if !ok {
+		return fmt.Errorf("unknown failure injection profile: %s", *profileName)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+	// There is no downtime to catch up on, and simulated anomalies must not page anyone by default
+	cfg.CatchUp.Enabled = false
+	if !*notify {
+		cfg.Notification.Enabled = false
+	}
+	// Simulated observations must not be mixed into the history of the real clusters
+	cfg.Storage.TimeSeries.Enabled = false
+
+	// Nor may simulated alerts reach the real alert store, where they would turn up in similarity
+	// searches, or the real journal, from which feedback is replayed: both are kept in a scratch
+	// directory, so the embedding and storage pipeline still runs
+	scratch, err := os.MkdirTemp("", "huginn-simulate-")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %v", err)
+	}
+	defer os.RemoveAll(scratch)
+	cfg.Storage.Type = "local"
+	cfg.Storage.Local.Path = filepath.Join(scratch, "alerts.jsonl")
+	cfg.Storage.Replicas.Types = nil
+	cfg.Storage.WriteBehind.Path = ""
+	cfg.Storage.Archive.Enabled = false
+	cfg.Storage.NATS.Enabled = false
+	cfg.Notification.Journal.Path = filepath.Join(scratch, "journal.jsonl")
+
+	generator := simulation.NewGenerator(simulation.Options{
+		Clusters: *clusters,
+		Nodes:    *nodes,
+		Pods:     *pods,
+		Profile:  profile,
+		Seed:     *seed,
+	})
+	multiAgent, err := agent.NewSimulatedMultiClusterAgent(cfg, generator)
+	if err != nil {
+		return fmt.Errorf("failed to create simulated agent: %v", err)
+	}
+	defer multiAgent.Stop()
+
+	var total, slowest time.Duration
+	var peakHeap uint64
+	anomalyTypes := make(map[string]int)
+	var memStats runtime.MemStats
+	for cycle := 1; cycle <= *cycles; cycle++ {
+		start := time.Now()
+		if err := multiAgent.ObserveAllClustersWithContext(multiAgent.GetContext()); err != nil {
+			return fmt.Errorf("cycle %d: %v", cycle, err)
+		}
+		if err := multiAgent.LearnFromAllClusters(); err != nil {
+			return fmt.Errorf("cycle %d: %v", cycle, err)
+		}
+		anomalies, err := multiAgent.DetectAllAnomaliesWithContext(multiAgent.GetContext())
+		if err != nil {
+			return fmt.Errorf("cycle %d: %v", cycle, err)
+		}
+		elapsed := time.Since(start)
+
+		total += elapsed
+		slowest = max(slowest, elapsed)
+		for _, anomaly := range anomalies {
+			anomalyTypes[anomaly.Type]++
+		}
+		runtime.ReadMemStats(&memStats)
+		peakHeap = max(peakHeap, memStats.HeapAlloc)
+
+		if *interval > 0 && cycle < *cycles {
+			time.Sleep(*interval)
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "Simulated %d clusters x %d nodes x %d pods, profile %s, %d cycles\n",
+		*clusters, *nodes, *pods, profile.Name, *cycles)
+	fmt.Fprintf(os.Stdout, "Cycle duration: avg %v, max %v\n",
+		(total / time.Duration(max(1, *cycles))).Round(time.Microsecond), slowest.Round(time.Microsecond))
+	fmt.Fprintf(os.Stdout, "Heap: peak %s, final %s; goroutines: %d\n",
+		formatBytes(peakHeap), formatBytes(memStats.HeapAlloc), runtime.NumGoroutine())
+
+	names := make([]string, 0, len(anomalyTypes))
+	for anomalyType := range anomalyTypes {
+		names = append(names, anomalyType)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stdout, "Anomalies: %d\n", sumCounts(anomalyTypes))
+	for _, anomalyType := range names {
+		fmt.Fprintf(os.Stdout, "  %-32s %d\n", anomalyType, anomalyTypes[anomalyType])
+	}
+	return nil
+}
+
+// sumCounts adds up the counts of a histogram
+func sumCounts(counts map[string]int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
+
+// formatBytes formats a byte count in MiB
+func formatBytes(bytes uint64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(bytes)/(1<<20)), ".0") + "MiB"
+}
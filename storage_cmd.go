@@ -0,0 +1,181 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/rodolfo-mora/huginn/pkg/agent"
+	"github.com/rodolfo-mora/huginn/pkg/config"
+	"github.com/rodolfo-mora/huginn/pkg/storage"
+)
+
+// runStorage backs up the alert store to a file or restores it from one, e.g. to migrate between
+// backends, or re-embeds it with another model
+func runStorage(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected a subcommand: export, import or reembed")
+	}
+	switch args[0] {
+	case "export":
+		return runStorageExport(args[1:])
+	case "import":
+		return runStorageImport(args[1:])
+	case "reembed":
+		return runStorageReembed(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand: %s (expected export, import or reembed)", args[0])
+	}
+}
+
+// runStorageExport writes every stored alert, with its vector, to a backup file
+func runStorageExport(args []string) error {
+	fs := flag.NewFlagSet("storage export", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	storageType := fs.String("type", "", "Export from this storage type instead of storage.type")
+	output := fs.String("o", "", "Backup file, gzipped if it ends in .gz (default stdout)")
+	fs.Parse(args)
+
+	cfg, err := loadStorageConfig(*configPath, *storageType)
+	if err != nil {
+		return err
+	}
+	client, err := agent.OpenStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to storage: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create backup file: %v", err)
+		}
+		defer f.Close()
+		w = f
+		if strings.HasSuffix(*output, ".gz") {
+			gz := gzip.NewWriter(f)
+			defer gz.Close()
+			w = gz
+		}
+	}
+
+	count, err := storage.WriteBackup(w, client, cfg.Storage.Type)
+	if err != nil {
+		return err
+	}
+	log.Printf("Exported %d alerts from %s", count, cfg.Storage.Type)
+	return nil
+}
+
+// runStorageImport stores every alert of a backup file
+func runStorageImport(args []string) error {
+	fs := flag.NewFlagSet("storage import", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	storageType := fs.String("type", "", "Import into this storage type instead of storage.type")
+	input := fs.String("i", "", "Backup file, gzipped if it ends in .gz (default stdin)")
+	batchSize := fs.Int("batch", 100, "Alerts stored per request")
+	fs.Parse(args)
+
+	if *batchSize < 1 {
+		return fmt.Errorf("invalid batch size: %d", *batchSize)
+	}
+	cfg, err := loadStorageConfig(*configPath, *storageType)
+	if err != nil {
+		return err
+	}
+	client, err := agent.OpenStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to storage: %v", err)
+	}
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return fmt.Errorf("failed to open backup file: %v", err)
+		}
+		defer f.Close()
+		r = f
+		if strings.HasSuffix(*input, ".gz") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return fmt.Errorf("failed to open backup file: %v", err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+	}
+
+	count := 0
+	batch := make([]storage.StoreRequest, 0, *batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := storage.StoreAll(client, batch); err != nil {
+			return fmt.Errorf("failed to store alerts %d-%d: %v", count+1, count+len(batch), err)
+		}
+		count += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	header, err := storage.ReadBackup(r, func(request storage.StoreRequest) error {
+		batch = append(batch, request)
+		if len(batch) == *batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return fmt.Errorf("%v (%d alerts imported)", err, count)
+	}
+	log.Printf("Imported %d alerts exported from %s at %s into %s", count, header.Source, header.ExportedAt.Format("2006-01-02 15:04:05"), cfg.Storage.Type)
+	return nil
+}
+
+// runStorageReembed re-encodes every stored alert with the configured embedding model into a new
+// collection, so the model can be upgraded without losing the history searched for similar alerts
+func runStorageReembed(args []string) error {
+	fs := flag.NewFlagSet("storage reembed", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	target := fs.String("collection", "", "Collection to store the re-embedded alerts in (Qdrant or Milvus collection, Weaviate class, ClickHouse table or local file)")
+	batchSize := fs.Int("batch", 32, "Alerts embedded and stored per request")
+	fs.Parse(args)
+
+	if *target == "" {
+		return fmt.Errorf("-collection is required")
+	}
+	if *batchSize < 1 {
+		return fmt.Errorf("invalid batch size: %d", *batchSize)
+	}
+	cfg, err := loadStorageConfig(*configPath, "")
+	if err != nil {
+		return err
+	}
+	count, err := agent.ReembedAlerts(cfg, *target, *batchSize)
+	if err != nil {
+		return err
+	}
+	log.Printf("Re-embedded %d alerts with %s into %s; point the %s storage configuration at it to search them", count, cfg.Embedding.Type, *target, cfg.Storage.Type)
+	return nil
+}
+
+// loadStorageConfig loads the configuration, overriding the storage type if one is given
+func loadStorageConfig(path, storageType string) (*config.Config, error) {
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	if storageType != "" {
+		cfg.Storage.Type = storageType
+	}
+	return cfg, nil
+}